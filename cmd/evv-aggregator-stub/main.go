@@ -0,0 +1,123 @@
+// Command evv-aggregator-stub is a local stand-in for a state EVV aggregator.
+//
+// It accepts the CSV or JSON files produced by the EVV exporter on POST /submissions
+// and answers synchronously with a per-visit result: a visit is rejected when any of
+// its fields is empty (or always, with -reject-all), otherwise accepted. With -async it
+// only acknowledges the upload with 202 so results can be posted back by hand.
+//
+// Usage:
+//
+//	evv-aggregator-stub [-addr :7070] [-async] [-reject-all]
+//
+// Point the API at it with EVV_AGGREGATOR_URL=http://localhost:7070/submissions.
+package main
+
+import (
+	"caregiver-shift-tracker/models"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	addr := flag.String("addr", ":7070", "listen address")
+	async := flag.Bool("async", false, "acknowledge uploads with 202 instead of returning results")
+	rejectAll := flag.Bool("reject-all", false, "reject every visit")
+	flag.Parse()
+
+	http.HandleFunc("/submissions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		idField := r.Header.Get("X-EVV-Visit-ID-Field")
+		var (
+			rows []map[string]string
+			err  error
+		)
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			rows, err = readJSON(r)
+		} else {
+			rows, err = readCSV(r)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("received batch %s for %s with %d visits", r.Header.Get("X-EVV-Batch-ID"), r.Header.Get("X-EVV-State"), len(rows))
+
+		if *async {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		response := models.EVVAggregatorResponse{}
+		for _, row := range rows {
+			visitID, err := strconv.Atoi(row[idField])
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid visit id %q in field %q", row[idField], idField), http.StatusBadRequest)
+				return
+			}
+			result := models.EVVAggregatorResult{VisitID: uint(visitID), Status: models.EVV_SUBMISSION_ACCEPTED}
+			if *rejectAll {
+				result.Status = models.EVV_SUBMISSION_REJECTED
+				result.Reason = "rejected by stub"
+			} else if missing := firstEmpty(row); missing != "" {
+				result.Status = models.EVV_SUBMISSION_REJECTED
+				result.Reason = "missing " + missing
+			}
+			response.Results = append(response.Results, result)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+
+	log.Printf("EVV aggregator stub listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func readCSV(r *http.Request) ([]map[string]string, error) {
+	records, err := csv.NewReader(r.Body).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty file")
+	}
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			row[name] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func readJSON(r *http.Request) ([]map[string]string, error) {
+	var payload struct {
+		Visits []map[string]string `json:"visits"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	return payload.Visits, nil
+}
+
+func firstEmpty(row map[string]string) string {
+	for name, value := range row {
+		if value == "" {
+			return name
+		}
+	}
+	return ""
+}
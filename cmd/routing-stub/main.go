@@ -0,0 +1,79 @@
+// Command routing-stub is a local stand-in for an OSRM routing server.
+//
+// It answers GET /route/v1/driving/{lon},{lat};{lon},{lat} with the great-circle distance
+// stretched by a detour factor and driven at a constant speed, so routed figures differ from
+// the straight line without a real road network. With -fail every request returns 503, to
+// exercise the straight-line fallback.
+//
+// Usage:
+//
+//	routing-stub [-addr :5050] [-detour 1.3] [-speed 40] [-fail]
+//
+// Point the API at it with ROUTING_PROVIDER=osrm and ROUTING_OSRM_URL=http://localhost:5050.
+package main
+
+import (
+	"caregiver-shift-tracker/utils"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	addr := flag.String("addr", ":5050", "listen address")
+	detour := flag.Float64("detour", 1.3, "road distance as a multiple of the straight line")
+	speed := flag.Float64("speed", 40, "driving speed in km/h")
+	fail := flag.Bool("fail", false, "answer every request with 503")
+	flag.Parse()
+
+	http.HandleFunc("/route/v1/driving/", func(w http.ResponseWriter, r *http.Request) {
+		if *fail {
+			http.Error(w, "routing unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		points, ok := parseCoordinates(strings.TrimPrefix(r.URL.Path, "/route/v1/driving/"))
+		if !ok || len(points) != 2 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"code": "InvalidQuery"})
+			return
+		}
+
+		meters := utils.DistanceMeters(points[0][1], points[0][0], points[1][1], points[1][0]) * *detour
+		seconds := 0.0
+		if *speed > 0 {
+			seconds = meters / (*speed * 1000 / 3600)
+		}
+		log.Printf("route %v -> %v: %.0f m", points[0], points[1], meters)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code":   "Ok",
+			"routes": []map[string]float64{{"distance": meters, "duration": seconds}},
+		})
+	})
+
+	log.Printf("routing stub listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// parseCoordinates reads OSRM's "lon,lat;lon,lat" path segment
+func parseCoordinates(path string) ([][2]float64, bool) {
+	var points [][2]float64
+	for _, pair := range strings.Split(path, ";") {
+		parts := strings.Split(pair, ",")
+		if len(parts) != 2 {
+			return nil, false
+		}
+		lon, err1 := strconv.ParseFloat(parts[0], 64)
+		lat, err2 := strconv.ParseFloat(parts[1], 64)
+		if err1 != nil || err2 != nil || !utils.ValidCoordinates(lat, lon) {
+			return nil, false
+		}
+		points = append(points, [2]float64{lon, lat})
+	}
+	return points, true
+}
@@ -0,0 +1,65 @@
+// Command verify-ledger walks the EVV visit ledger and reports the first broken link.
+//
+// Usage:
+//
+//	verify-ledger [-schedule ID] [-from YYYY-MM-DD] [-to YYYY-MM-DD]
+//
+// It reads the same database environment variables as the API server and exits
+// with status 1 when the chain is broken.
+package main
+
+import (
+	"caregiver-shift-tracker/config"
+	"caregiver-shift-tracker/database"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	scheduleID := flag.Uint("schedule", 0, "only verify events for this schedule ID")
+	from := flag.String("from", "", "only verify events on or after this date (YYYY-MM-DD, UTC)")
+	to := flag.String("to", "", "only verify events on or before this date (YYYY-MM-DD, UTC)")
+	flag.Parse()
+
+	filter := models.LedgerFilter{ScheduleID: *scheduleID}
+	if *from != "" {
+		t, err := time.Parse("2006-01-02", *from)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -from date: %v\n", err)
+			os.Exit(2)
+		}
+		filter.From = &t
+	}
+	if *to != "" {
+		t, err := time.Parse("2006-01-02", *to)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -to date: %v\n", err)
+			os.Exit(2)
+		}
+		t = t.Add(24*time.Hour - time.Nanosecond)
+		filter.To = &t
+	}
+
+	db, err := database.InitializeDB(config.LoadConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "DB init error: %v\n", err)
+		os.Exit(2)
+	}
+
+	result, err := service.VerifyVisitLedger(db, filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "verification error: %v\n", err)
+		os.Exit(2)
+	}
+
+	out, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(out))
+	if !result.Valid {
+		os.Exit(1)
+	}
+}
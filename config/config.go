@@ -1,6 +1,9 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+)
 
 type Config struct {
 	ServerAddress string
@@ -20,6 +23,66 @@ type Config struct {
 	SMTPPort      string
 	SenderEmail   string
 	EmailPassword string
+
+	EVVSchemaFile    string
+	EVVAggregatorURL string
+	EVVProviderID    string
+
+	RequireClientSignature bool
+
+	StorageDriver      string
+	StorageLocalDir    string
+	S3Endpoint         string
+	S3Region           string
+	S3Bucket           string
+	S3AccessKey        string
+	S3SecretKey        string
+	AttachmentMaxBytes int64
+
+	GeofenceRadiusMeters        int64
+	GeofenceMaxExcursionMinutes int64
+
+	BreakMaxPerVisit          int64
+	BreakMinUnpaidMinutes     int64
+	BreakMaxMinutes           int64
+	BreakRequiredAfterMinutes int64
+	BreakRequiredMinutes      int64
+
+	MARMissedGraceMinutes   int64
+	MARCheckIntervalMinutes int64
+
+	TaskCompletionPolicy   string
+	TaskOverrideTTLMinutes int64
+
+	AgencyName               string
+	AgencyTimezone           string
+	PayPeriodType            string
+	PayPeriodAnchor          string
+	TimesheetRoundingMinutes int64
+	TimesheetRoundingMode    string
+
+	LaborWeeklyOvertimeHours  int64
+	LaborDailyOvertimeHours   int64
+	LaborDailyDoubleTimeHours int64
+	LaborIncludeTravel        bool
+	TravelSpeedKmh            int64
+
+	RoutingProvider string
+	RoutingOSRMURL  string
+
+	RouteDefaultWindowMinutes int64
+
+	OnTimeGraceMinutes int64
+
+	ReportCheckIntervalMinutes int64
+	ReportMaxAttempts          int64
+	ReportRetryBackoffMinutes  int64
+
+	PayrollLayoutFile           string
+	PayrollCompanyCode          string
+	PayrollOvertimeMultiplier   float64
+	PayrollDoubleTimeMultiplier float64
+	PayrollMileageRate          float64
 }
 
 func LoadConfig() *Config {
@@ -42,5 +105,83 @@ func LoadConfig() *Config {
 		SMTPPort:      os.Getenv("SMTP_PORT"),
 		SenderEmail:   os.Getenv("SEND_EMAIL"),
 		EmailPassword: os.Getenv("EMAIL_PASSWORD"),
+
+		EVVSchemaFile:    os.Getenv("EVV_SCHEMA_FILE"),
+		EVVAggregatorURL: os.Getenv("EVV_AGGREGATOR_URL"),
+		EVVProviderID:    os.Getenv("EVV_PROVIDER_ID"),
+
+		RequireClientSignature: os.Getenv("REQUIRE_CLIENT_SIGNATURE") == "true",
+
+		StorageDriver:      os.Getenv("STORAGE_DRIVER"),
+		StorageLocalDir:    os.Getenv("STORAGE_LOCAL_DIR"),
+		S3Endpoint:         os.Getenv("S3_ENDPOINT"),
+		S3Region:           os.Getenv("S3_REGION"),
+		S3Bucket:           os.Getenv("S3_BUCKET"),
+		S3AccessKey:        os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:        os.Getenv("S3_SECRET_KEY"),
+		AttachmentMaxBytes: getEnvInt64("ATTACHMENT_MAX_BYTES", 10<<20),
+
+		GeofenceRadiusMeters:        getEnvInt64("GEOFENCE_RADIUS_METERS", 150),
+		GeofenceMaxExcursionMinutes: getEnvInt64("GEOFENCE_MAX_EXCURSION_MINUTES", 15),
+
+		BreakMaxPerVisit:          getEnvInt64("BREAK_MAX_PER_VISIT", 0),
+		BreakMinUnpaidMinutes:     getEnvInt64("BREAK_MIN_UNPAID_MINUTES", 20),
+		BreakMaxMinutes:           getEnvInt64("BREAK_MAX_MINUTES", 60),
+		BreakRequiredAfterMinutes: getEnvInt64("BREAK_REQUIRED_AFTER_MINUTES", 0),
+		BreakRequiredMinutes:      getEnvInt64("BREAK_REQUIRED_MINUTES", 30),
+
+		MARMissedGraceMinutes:   getEnvInt64("MAR_MISSED_GRACE_MINUTES", 60),
+		MARCheckIntervalMinutes: getEnvInt64("MAR_CHECK_INTERVAL_MINUTES", 5),
+
+		TaskCompletionPolicy:   os.Getenv("TASK_COMPLETION_POLICY"),
+		TaskOverrideTTLMinutes: getEnvInt64("TASK_OVERRIDE_TTL_MINUTES", 30),
+
+		AgencyName:               os.Getenv("AGENCY_NAME"),
+		AgencyTimezone:           os.Getenv("AGENCY_TIMEZONE"),
+		PayPeriodType:            os.Getenv("PAY_PERIOD_TYPE"),
+		PayPeriodAnchor:          os.Getenv("PAY_PERIOD_ANCHOR"),
+		TimesheetRoundingMinutes: getEnvInt64("TIMESHEET_ROUNDING_MINUTES", 15),
+		TimesheetRoundingMode:    os.Getenv("TIMESHEET_ROUNDING_MODE"),
+
+		LaborWeeklyOvertimeHours:  getEnvInt64("LABOR_WEEKLY_OVERTIME_HOURS", 40),
+		LaborDailyOvertimeHours:   getEnvInt64("LABOR_DAILY_OVERTIME_HOURS", 0),
+		LaborDailyDoubleTimeHours: getEnvInt64("LABOR_DAILY_DOUBLE_TIME_HOURS", 0),
+		LaborIncludeTravel:        os.Getenv("LABOR_INCLUDE_TRAVEL") != "false",
+		TravelSpeedKmh:            getEnvInt64("TRAVEL_SPEED_KMH", 40),
+
+		RoutingProvider: os.Getenv("ROUTING_PROVIDER"),
+		RoutingOSRMURL:  os.Getenv("ROUTING_OSRM_URL"),
+
+		RouteDefaultWindowMinutes: getEnvInt64("ROUTE_DEFAULT_WINDOW_MINUTES", 60),
+
+		OnTimeGraceMinutes: getEnvInt64("ON_TIME_GRACE_MINUTES", 5),
+
+		ReportCheckIntervalMinutes: getEnvInt64("REPORT_CHECK_INTERVAL_MINUTES", 1),
+		ReportMaxAttempts:          getEnvInt64("REPORT_MAX_ATTEMPTS", 5),
+		ReportRetryBackoffMinutes:  getEnvInt64("REPORT_RETRY_BACKOFF_MINUTES", 5),
+
+		PayrollLayoutFile:           os.Getenv("PAYROLL_LAYOUT_FILE"),
+		PayrollCompanyCode:          os.Getenv("PAYROLL_COMPANY_CODE"),
+		PayrollOvertimeMultiplier:   getEnvFloat64("PAYROLL_OVERTIME_MULTIPLIER", 1.5),
+		PayrollDoubleTimeMultiplier: getEnvFloat64("PAYROLL_DOUBLE_TIME_MULTIPLIER", 2),
+		PayrollMileageRate:          getEnvFloat64("PAYROLL_MILEAGE_RATE", 0),
+	}
+}
+
+// getEnvInt64 reads an integer environment variable, falling back to def when unset or invalid
+func getEnvInt64(key string, def int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// getEnvFloat64 reads a decimal environment variable, falling back to def when unset or invalid
+func getEnvFloat64(key string, def float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return def
 	}
+	return value
 }
@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAnalyticsDays bounds a report's date range
+const maxAnalyticsDays = 366
+
+// GetVisitAnalytics godoc
+// @Summary Visit KPIs
+// @Description Visit counts by status, missed visits, on-time clock-in percentage against shift_time (within ON_TIME_GRACE_MINUTES) and average late minutes, grouped by caregiver, client or day. Dates are read and days laid out in the X-Timezone header's timezone.
+// @Tags Analytics
+// @Security BearerAuth
+// @Produce json
+// @Param X-Timezone header string false "IANA timezone (default UTC)"
+// @Param from query string false "First day (YYYY-MM-DD, default 30 days ago)"
+// @Param to query string false "Last day (YYYY-MM-DD, default today)"
+// @Param user_id query int false "Caregiver ID"
+// @Param client_id query int false "Client ID"
+// @Param group_by query string false "none (default), caregiver, client or day"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/analytics/visits [get]
+func (ctrl *Controller) GetVisitAnalytics(ctx *gin.Context) {
+	filter, ok := analyticsFilterFromQuery(ctx)
+	if !ok {
+		return
+	}
+	groupBy := ctx.DefaultQuery("group_by", models.ANALYTICS_GROUP_NONE)
+	switch groupBy {
+	case models.ANALYTICS_GROUP_NONE, models.ANALYTICS_GROUP_CAREGIVER, models.ANALYTICS_GROUP_CLIENT, models.ANALYTICS_GROUP_DAY:
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be none, caregiver, client or day"})
+		return
+	}
+
+	grace := time.Duration(ctrl.Config.OnTimeGraceMinutes) * time.Minute
+	report, err := service.VisitKPIReport(ctrl.DB, filter, groupBy, grace, time.Now())
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to build visit analytics: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build visit analytics"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"from":     filter.From.Format("2006-01-02"),
+		"to":       filter.To.AddDate(0, 0, -1).Format("2006-01-02"),
+		"timezone": filter.Location.String(),
+		"group_by": groupBy,
+		"groups":   report,
+	})
+}
+
+// GetTaskAnalytics godoc
+// @Summary Task completion KPIs
+// @Description Task completion rate on visits that were carried out and the most common reasons given for tasks not completed. Dates are read in the X-Timezone header's timezone.
+// @Tags Analytics
+// @Security BearerAuth
+// @Produce json
+// @Param X-Timezone header string false "IANA timezone (default UTC)"
+// @Param from query string false "First day (YYYY-MM-DD, default 30 days ago)"
+// @Param to query string false "Last day (YYYY-MM-DD, default today)"
+// @Param user_id query int false "Caregiver ID"
+// @Param client_id query int false "Client ID"
+// @Param top query int false "Number of reasons (default 5)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/analytics/tasks [get]
+func (ctrl *Controller) GetTaskAnalytics(ctx *gin.Context) {
+	filter, ok := analyticsFilterFromQuery(ctx)
+	if !ok {
+		return
+	}
+	top, err := strconv.Atoi(ctx.DefaultQuery("top", "5"))
+	if err != nil || top < 1 || top > 50 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "top must be between 1 and 50"})
+		return
+	}
+
+	kpis, err := service.TaskKPIReport(ctrl.DB, filter, top)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to build task analytics: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build task analytics"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"from":     filter.From.Format("2006-01-02"),
+		"to":       filter.To.AddDate(0, 0, -1).Format("2006-01-02"),
+		"timezone": filter.Location.String(),
+		"tasks":    kpis,
+	})
+}
+
+// GetMissedVisitTrend godoc
+// @Summary Missed-visit trend
+// @Description Visits due and missed per day, week (from Monday) or month. Intervals are laid out in the X-Timezone header's timezone; cancelled visits and visits not yet due are left out.
+// @Tags Analytics
+// @Security BearerAuth
+// @Produce json
+// @Param X-Timezone header string false "IANA timezone (default UTC)"
+// @Param from query string false "First day (YYYY-MM-DD, default 30 days ago)"
+// @Param to query string false "Last day (YYYY-MM-DD, default today)"
+// @Param user_id query int false "Caregiver ID"
+// @Param client_id query int false "Client ID"
+// @Param interval query string false "day (default), week or month"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/analytics/missed-trend [get]
+func (ctrl *Controller) GetMissedVisitTrend(ctx *gin.Context) {
+	filter, ok := analyticsFilterFromQuery(ctx)
+	if !ok {
+		return
+	}
+	interval := ctx.DefaultQuery("interval", models.ANALYTICS_INTERVAL_DAY)
+	switch interval {
+	case models.ANALYTICS_INTERVAL_DAY, models.ANALYTICS_INTERVAL_WEEK, models.ANALYTICS_INTERVAL_MONTH:
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "interval must be day, week or month"})
+		return
+	}
+
+	points, err := service.MissedVisitTrend(ctrl.DB, filter, interval, time.Now())
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to build missed-visit trend: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build missed-visit trend"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"from":     filter.From.Format("2006-01-02"),
+		"to":       filter.To.AddDate(0, 0, -1).Format("2006-01-02"),
+		"timezone": filter.Location.String(),
+		"interval": interval,
+		"points":   points,
+	})
+}
+
+// analyticsFilterFromQuery reads the date range (in the caller's X-Timezone) and the caregiver
+// and client filters. The range's To is exclusive.
+func analyticsFilterFromQuery(ctx *gin.Context) (models.AnalyticsFilter, bool) {
+	loc := GetUserTimeZone(ctx)
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	filter := models.AnalyticsFilter{Location: loc, From: today.AddDate(0, 0, -29), To: today.AddDate(0, 0, 1)}
+
+	if value := ctx.Query("from"); value != "" {
+		from, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return filter, false
+		}
+		filter.From = from
+	}
+	if value := ctx.Query("to"); value != "" {
+		to, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return filter, false
+		}
+		filter.To = to.AddDate(0, 0, 1)
+	}
+	if !filter.From.Before(filter.To) || filter.To.Sub(filter.From) > maxAnalyticsDays*24*time.Hour+time.Hour {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "from must be on or before to, and at most a year apart"})
+		return filter, false
+	}
+
+	for _, param := range []string{"user_id", "client_id"} {
+		value := ctx.Query(param)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+			return filter, false
+		}
+		if param == "user_id" {
+			filter.UserID = uint(id)
+		} else {
+			filter.ClientID = uint(id)
+		}
+	}
+	return filter, true
+}
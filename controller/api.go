@@ -1,6 +1,11 @@
 package controller
 
 import (
+	"caregiver-shift-tracker/config"
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/routing"
+	"caregiver-shift-tracker/storage"
 	"caregiver-shift-tracker/utils"
 	"fmt"
 	"strings"
@@ -12,23 +17,32 @@ import (
 )
 
 type Controller struct {
-	DB  *gorm.DB
-	GIN *gin.Engine
-	RDB *redis.Client
+	DB      *gorm.DB
+	GIN     *gin.Engine
+	RDB     *redis.Client
+	Config  *config.Config
+	Storage storage.Storage
+	Routing routing.Provider
 }
 
 // getUserIDFromJWT extracts user_id from JWT token
 func GetUserIDFromJWT(ctx *gin.Context) (int, error) {
+	userID, _, err := GetUserClaimsFromJWT(ctx)
+	return userID, err
+}
+
+// GetUserClaimsFromJWT extracts both user_id and role_id from JWT token
+func GetUserClaimsFromJWT(ctx *gin.Context) (int, int, error) {
 	authHeader := ctx.GetHeader("Authorization")
 	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		return 0, fmt.Errorf("authorization header missing or invalid")
+		return 0, 0, fmt.Errorf("authorization header missing or invalid")
 	}
 	token := strings.TrimPrefix(authHeader, "Bearer ")
-	userID, _, err := utils.ExtractJWT(token, false)
+	userID, roleID, err := utils.ExtractJWT(token, false)
 	if err != nil {
-		return 0, fmt.Errorf("invalid or expired token: %v", err)
+		return 0, 0, fmt.Errorf("invalid or expired token: %v", err)
 	}
-	return userID, nil
+	return userID, roleID, nil
 }
 
 func GetUserTimeZone(ctx *gin.Context) *time.Location {
@@ -43,3 +57,21 @@ func GetUserTimeZone(ctx *gin.Context) *time.Location {
 	}
 	return loc
 }
+
+// canAccessSchedule allows the assigned caregiver and staff (admin, customer care) to act on a schedule
+func canAccessSchedule(userID, roleID int, schedule *models.Schedule) bool {
+	return schedule.UserID == uint(userID) || models.IsStaffRole(roleID)
+}
+
+// agencyLocation is the timezone pay periods and other agency-wide reports are laid out in
+func (ctrl *Controller) agencyLocation() *time.Location {
+	if ctrl.Config.AgencyTimezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(ctrl.Config.AgencyTimezone)
+	if err != nil {
+		logger.ErrorLogger.Printf("Invalid AGENCY_TIMEZONE %q, using UTC: %v", ctrl.Config.AgencyTimezone, err)
+		return time.UTC
+	}
+	return loc
+}
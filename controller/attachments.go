@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"caregiver-shift-tracker/storage"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UploadAttachment godoc
+// @Summary Upload a visit attachment
+// @Description Upload a photo or document (JPEG, PNG, GIF, WebP or PDF) to a visit, optionally linked to one of its tasks or incidents
+// @Tags Attachments
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param file formData file true "File to upload"
+// @Param task_id formData int false "Task ID within the schedule"
+// @Param incident_id formData int false "Incident reported on the schedule"
+// @Success 201 {object} models.Attachment
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/attachments [post]
+func (ctrl *Controller) UploadAttachment(ctx *gin.Context) {
+	schedule, userID, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return
+	}
+
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "A file is required", "details": err.Error()})
+		return
+	}
+
+	attachment := models.Attachment{ScheduleID: schedule.ID, UploadedBy: uint(userID)}
+	if value := ctx.PostForm("task_id"); value != "" {
+		taskID, err := strconv.Atoi(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+		id := uint(taskID)
+		attachment.TaskID = &id
+	}
+	if value := ctx.PostForm("incident_id"); value != "" {
+		incidentID, err := strconv.Atoi(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid incident ID"})
+			return
+		}
+		id := uint(incidentID)
+		attachment.IncidentID = &id
+	}
+
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SaveAttachment(ctx.Request.Context(), tx, ctrl.Storage, file, &attachment, ctrl.Config.AttachmentMaxBytes); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_ATTACHMENT, attachment.ID, nil, attachment)
+	})
+	if err != nil {
+		if attachment.ID != 0 {
+			// The row was rolled back after the upload; do not leave the object behind
+			ctrl.Storage.Delete(ctx.Request.Context(), attachment.StorageKey)
+		}
+		switch {
+		case errors.Is(err, service.ErrAttachmentTooLarge):
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error(), "max_bytes": ctrl.Config.AttachmentMaxBytes})
+		case errors.Is(err, service.ErrAttachmentType):
+			ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrAttachmentEmpty), errors.Is(err, service.ErrAttachmentTaskMismatch),
+			errors.Is(err, service.ErrAttachmentIncident):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logger.ErrorLogger.Printf("Failed to save attachment for schedule %d: %v", schedule.ID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, attachment)
+}
+
+// GetAttachments godoc
+// @Summary List visit attachments
+// @Description List attachments uploaded to a visit (assigned caregiver or staff)
+// @Tags Attachments
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/attachments [get]
+func (ctrl *Controller) GetAttachments(ctx *gin.Context) {
+	schedule, _, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return
+	}
+	attachments, err := service.GetAttachments(ctrl.DB, schedule.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"attachments": attachments})
+}
+
+// DownloadAttachment godoc
+// @Summary Download a visit attachment
+// @Description Download an attachment's contents (assigned caregiver or staff). The X-Checksum-SHA256 header carries the stored checksum.
+// @Tags Attachments
+// @Security BearerAuth
+// @Produce octet-stream
+// @Param id path int true "Schedule ID"
+// @Param attachmentId path int true "Attachment ID"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/attachments/{attachmentId} [get]
+func (ctrl *Controller) DownloadAttachment(ctx *gin.Context) {
+	schedule, _, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return
+	}
+	attachment, ok := ctrl.attachmentFromParam(ctx, schedule.ID)
+	if !ok {
+		return
+	}
+
+	body, err := ctrl.Storage.Get(ctx.Request.Context(), attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Attachment content not found"})
+			return
+		}
+		logger.ErrorLogger.Printf("Failed to read attachment %d: %v", attachment.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read attachment"})
+		return
+	}
+	defer body.Close()
+
+	// The file name comes from the uploader, so let mime quote or RFC 2231 encode it
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	ctx.Header("Content-Disposition", disposition)
+	ctx.Header("X-Checksum-SHA256", attachment.SHA256)
+	ctx.DataFromReader(http.StatusOK, attachment.SizeBytes, attachment.ContentType, body, nil)
+}
+
+// DeleteAttachment godoc
+// @Summary Delete a visit attachment
+// @Description Delete an attachment. Allowed for the uploader and staff.
+// @Tags Attachments
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param attachmentId path int true "Attachment ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/attachments/{attachmentId} [delete]
+func (ctrl *Controller) DeleteAttachment(ctx *gin.Context) {
+	schedule, userID, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return
+	}
+	attachment, ok := ctrl.attachmentFromParam(ctx, schedule.ID)
+	if !ok {
+		return
+	}
+	_, roleID, _ := GetUserClaimsFromJWT(ctx)
+	if attachment.UploadedBy != uint(userID) && !models.IsStaffRole(roleID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: only the uploader or staff can delete an attachment"})
+		return
+	}
+
+	// The audit entry goes first so the stored object is only removed once nothing else can fail
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_ATTACHMENT, attachment.ID, attachment, nil); err != nil {
+			return err
+		}
+		return service.DeleteAttachment(ctx.Request.Context(), tx, ctrl.Storage, attachment)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to delete attachment %d: %v", attachment.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Attachment deleted"})
+}
+
+func (ctrl *Controller) attachmentFromParam(ctx *gin.Context, scheduleID uint) (*models.Attachment, bool) {
+	attachmentID, err := strconv.Atoi(ctx.Param("attachmentId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return nil, false
+	}
+	attachment, err := service.GetAttachment(ctrl.DB, scheduleID, uint(attachmentID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return nil, false
+	}
+	return attachment, true
+}
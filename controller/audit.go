@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// auditReasonKey lets a handler attach its own reason to the audit entries it records
+const auditReasonKey = "audit_reason"
+
+// recordAudit appends an audit entry for a mutation made in the current request.
+// The reason comes from the handler (auditReasonKey) or the X-Audit-Reason header.
+// Call it with the mutation's transaction and return its error from the transaction, so a
+// change whose audit entry cannot be written is rolled back rather than saved unaudited.
+func (ctrl *Controller) recordAudit(ctx *gin.Context, tx *gorm.DB, action, entityType string, entityID uint, before, after interface{}) error {
+	userID, roleID, _ := GetUserClaimsFromJWT(ctx)
+
+	entry := models.AuditLog{
+		ActorID:    uint(userID),
+		ActorRole:  roleID,
+		IPAddress:  ctx.ClientIP(),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+	reason := ctx.GetString(auditReasonKey)
+	if reason == "" {
+		reason = ctx.GetHeader("X-Audit-Reason")
+	}
+	if reason != "" {
+		entry.Reason = &reason
+	}
+
+	if err := service.RecordAudit(tx, &entry, before, after); err != nil {
+		logger.ErrorLogger.Printf("Failed to record audit entry for %s %d: %v", entityType, entityID, err)
+		return err
+	}
+	return nil
+}
+
+// parseDateParam accepts either RFC3339 or YYYY-MM-DD (interpreted in loc).
+// Date-only values for an upper bound are moved to the end of that day.
+func parseDateParam(value string, loc *time.Location, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+// GetAuditLogs godoc
+// @Summary Query the audit trail
+// @Description List audit entries filtered by entity, actor and date range (admin only)
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param entity_type query string false "Entity type (schedule, task, user, ...)"
+// @Param entity_id query int false "Entity ID"
+// @Param actor_id query int false "Actor user ID"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End date (YYYY-MM-DD or RFC3339)"
+// @Param limit query int false "Page size (max 500)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/audit [get]
+func (ctrl *Controller) GetAuditLogs(ctx *gin.Context) {
+	loc := GetUserTimeZone(ctx)
+	filter := models.AuditLogFilter{EntityType: ctx.Query("entity_type")}
+
+	for param, target := range map[string]*uint{"entity_id": &filter.EntityID, "actor_id": &filter.ActorID} {
+		if value := ctx.Query(param); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return
+			}
+			*target = uint(id)
+		}
+	}
+	for param, target := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := ctx.Query(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return
+			}
+			*target = n
+		}
+	}
+
+	var err error
+	if filter.From, err = parseDateParam(ctx.Query("from"), loc, false); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return
+	}
+	if filter.To, err = parseDateParam(ctx.Query("to"), loc, true); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return
+	}
+
+	logs, total, err := service.GetAuditLogs(ctrl.DB, filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"audit_logs": logs, "total": total})
+}
@@ -0,0 +1,28 @@
+package controller_test
+
+import (
+	"caregiver-shift-tracker/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestChangeRolledBackWhenAuditFails(t *testing.T) {
+	f := newVisitFixture(t)
+	scheduled, _ := f.visit(models.SCHEDULE_STATUS_SCHEDULED, nil)
+	if err := f.db.Migrator().DropTable(&models.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := f.request(http.MethodPost, fmt.Sprintf("/api/user/schedules/%d/start", scheduled.ID), `{"latitude": 40.7, "longitude": -74.0}`)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("starting a visit without an audit log: got %d %s, want 500", rec.Code, rec.Body.String())
+	}
+	var saved models.Schedule
+	if err := f.db.First(&saved, scheduled.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != models.SCHEDULE_STATUS_SCHEDULED || saved.StartTime != nil {
+		t.Fatalf("visit was started without an audit entry: status %s, start %v", saved.Status, saved.StartTime)
+	}
+}
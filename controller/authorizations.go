@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListAuthorizations godoc
+// @Summary List authorizations
+// @Description List payer authorizations, newest first
+// @Tags Authorizations
+// @Security BearerAuth
+// @Produce json
+// @Param client_id query int false "Client ID"
+// @Param active_on query string false "Only authorizations in effect on this day (YYYY-MM-DD)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/authorizations [get]
+func (ctrl *Controller) ListAuthorizations(ctx *gin.Context) {
+	clientID, ok := optionalClientIDQuery(ctx)
+	if !ok {
+		return
+	}
+	var activeOn *time.Time
+	if value := ctx.Query("active_on"); value != "" {
+		day, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid active_on date"})
+			return
+		}
+		activeOn = &day
+	}
+
+	auths, err := service.ListAuthorizations(ctrl.DB, clientID, activeOn)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authorizations"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"authorizations": auths})
+}
+
+// CreateAuthorization godoc
+// @Summary Create an authorization
+// @Description Record a payer's approval of units of a service code for a client, per week, month or in total. Visits already completed in its dates are counted against it.
+// @Tags Authorizations
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.AuthorizationRequest true "Authorization"
+// @Success 201 {object} models.Authorization
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/authorizations [post]
+func (ctrl *Controller) CreateAuthorization(ctx *gin.Context) {
+	var auth models.Authorization
+	if !ctrl.bindAuthorization(ctx, &auth) {
+		return
+	}
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SaveAuthorization(tx, &auth); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_AUTHORIZATION, auth.ID, nil, auth)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to create authorization: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create authorization"})
+		return
+	}
+	ctrl.recalculateAuthorizationUsage(&auth)
+	ctx.JSON(http.StatusCreated, auth)
+}
+
+// UpdateAuthorization godoc
+// @Summary Update an authorization
+// @Description Change an authorization's units, period or dates. Completed visits are recounted against it.
+// @Tags Authorizations
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Authorization ID"
+// @Param request body models.AuthorizationRequest true "Authorization"
+// @Success 200 {object} models.Authorization
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/authorizations/{id} [put]
+func (ctrl *Controller) UpdateAuthorization(ctx *gin.Context) {
+	auth, ok := ctrl.authorizationFromParam(ctx)
+	if !ok {
+		return
+	}
+	before := *auth
+	if !ctrl.bindAuthorization(ctx, auth) {
+		return
+	}
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SaveAuthorization(tx, auth); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_AUTHORIZATION, auth.ID, before, auth)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update authorization"})
+		return
+	}
+	ctrl.recalculateAuthorizationUsage(auth)
+	ctx.JSON(http.StatusOK, auth)
+}
+
+// GetAuthorizationUsage godoc
+// @Summary Units used against an authorization
+// @Description The completed visits that deducted units from an authorization
+// @Tags Authorizations
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Authorization ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/authorizations/{id}/usage [get]
+func (ctrl *Controller) GetAuthorizationUsage(ctx *gin.Context) {
+	auth, ok := ctrl.authorizationFromParam(ctx)
+	if !ok {
+		return
+	}
+	usage, err := service.ListAuthorizationUsage(ctrl.DB, auth.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authorization usage"})
+		return
+	}
+	total := 0
+	for _, u := range usage {
+		total += u.Units
+	}
+	ctx.JSON(http.StatusOK, gin.H{"authorization": auth, "used_units": total, "usage": usage})
+}
+
+// GetAuthorizationUtilization godoc
+// @Summary Authorization utilization
+// @Description Units authorized, used by completed visits and planned by scheduled visits for each authorization in effect on a day, in the week, month or span containing it
+// @Tags Authorizations
+// @Security BearerAuth
+// @Produce json
+// @Param client_id query int false "Client ID"
+// @Param date query string false "Report day (YYYY-MM-DD, agency timezone; default today)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/authorizations/utilization [get]
+func (ctrl *Controller) GetAuthorizationUtilization(ctx *gin.Context) {
+	clientID, ok := optionalClientIDQuery(ctx)
+	if !ok {
+		return
+	}
+	loc := ctrl.agencyLocation()
+	now := time.Now().In(loc)
+	filter := models.AuthorizationFilter{
+		ClientID: clientID,
+		Date:     time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+	}
+	if value := ctx.Query("date"); value != "" {
+		day, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return
+		}
+		filter.Date = day
+	}
+
+	report, err := service.AuthorizationUtilizationReport(ctrl.DB, filter, loc)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to build authorization utilization: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build authorization utilization"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"date": filter.Date.Format("2006-01-02"), "authorizations": report})
+}
+
+// bindAuthorization reads the request onto auth, writing a 400 and returning false when it is
+// invalid or names a client, payer or service code that does not exist
+func (ctrl *Controller) bindAuthorization(ctx *gin.Context, auth *models.Authorization) bool {
+	var req models.AuthorizationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authorization", "details": err.Error()})
+		return false
+	}
+	if _, err := service.GetClientByID(ctrl.DB, req.ClientID); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Client not found"})
+		return false
+	}
+	if req.PayerID != nil {
+		if _, err := service.GetPayerByID(ctrl.DB, *req.PayerID); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Payer not found"})
+			return false
+		}
+	}
+	if _, err := service.GetServiceCodeByID(ctrl.DB, req.ServiceCodeID); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Service code not found"})
+		return false
+	}
+	if err := service.ApplyAuthorizationRequest(auth, req); err != nil {
+		if errors.Is(err, service.ErrAuthorizationInvalid) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read authorization"})
+		return false
+	}
+	return true
+}
+
+func (ctrl *Controller) recalculateAuthorizationUsage(auth *models.Authorization) {
+	if err := service.RecalculateAuthorizationUsage(ctrl.DB, auth, ctrl.agencyLocation(), ctrl.breakRules()); err != nil {
+		logger.ErrorLogger.Printf("Failed to recount usage for authorization %d: %v", auth.ID, err)
+	}
+}
+
+func (ctrl *Controller) authorizationFromParam(ctx *gin.Context) (*models.Authorization, bool) {
+	authID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authorization ID"})
+		return nil, false
+	}
+	auth, err := service.GetAuthorizationByID(ctrl.DB, uint(authID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Authorization not found"})
+		return nil, false
+	}
+	return auth, true
+}
+
+// optionalClientIDQuery reads ?client_id=, 0 when absent
+func optionalClientIDQuery(ctx *gin.Context) (uint, bool) {
+	value := ctx.Query("client_id")
+	if value == "" {
+		return 0, true
+	}
+	clientID, err := strconv.Atoi(value)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client_id"})
+		return 0, false
+	}
+	return uint(clientID), true
+}
@@ -0,0 +1,549 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListPayers godoc
+// @Summary List payers
+// @Description List the programs, insurers and private payers clients can be billed to
+// @Tags Billing
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/payers [get]
+func (ctrl *Controller) ListPayers(ctx *gin.Context) {
+	payers, err := service.ListPayers(ctrl.DB)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payers"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"payers": payers})
+}
+
+// CreatePayer godoc
+// @Summary Create a payer
+// @Description Add a payer; assign it to clients with payer_id
+// @Tags Billing
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.Payer true "Payer"
+// @Success 201 {object} models.Payer
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/payers [post]
+func (ctrl *Controller) CreatePayer(ctx *gin.Context) {
+	var req models.Payer
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payer", "details": err.Error()})
+		return
+	}
+	req.ID = 0
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SavePayer(tx, &req); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_PAYER, req.ID, nil, req)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to create payer: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payer", "details": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, req)
+}
+
+// UpdatePayer godoc
+// @Summary Update a payer
+// @Description Update a payer's name, code or address. Issued invoices keep the name they were issued with.
+// @Tags Billing
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Payer ID"
+// @Param request body models.Payer true "Payer"
+// @Success 200 {object} models.Payer
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/payers/{id} [put]
+func (ctrl *Controller) UpdatePayer(ctx *gin.Context) {
+	payerID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payer ID"})
+		return
+	}
+	before, err := service.GetPayerByID(ctrl.DB, uint(payerID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Payer not found"})
+		return
+	}
+	var req models.Payer
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payer", "details": err.Error()})
+		return
+	}
+	req.ID = before.ID
+	req.CreatedAt = before.CreatedAt
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SavePayer(tx, &req); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_PAYER, req.ID, before, req)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payer", "details": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, req)
+}
+
+// ListServiceCodes godoc
+// @Summary List service codes
+// @Description List billable service codes with their unit size, rounding and rates
+// @Tags Billing
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/service-codes [get]
+func (ctrl *Controller) ListServiceCodes(ctx *gin.Context) {
+	codes, err := service.ListServiceCodes(ctrl.DB)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch service codes"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"service_codes": codes})
+}
+
+// CreateServiceCode godoc
+// @Summary Create a service code
+// @Description Add a billable service. Visits with this service_code are billed in unit_minutes units (default 15), rounded nearest, up or down.
+// @Tags Billing
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.ServiceCodeRequest true "Service code"
+// @Success 201 {object} models.ServiceCode
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/service-codes [post]
+func (ctrl *Controller) CreateServiceCode(ctx *gin.Context) {
+	var req models.ServiceCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service code", "details": err.Error()})
+		return
+	}
+	var code models.ServiceCode
+	service.ApplyServiceCodeRequest(&code, req)
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SaveServiceCode(tx, &code); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_SERVICE_CODE, code.ID, nil, code)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to create service code: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service code", "details": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, code)
+}
+
+// UpdateServiceCode godoc
+// @Summary Update a service code
+// @Description Change a service code's unit size, rounding or active flag. Issued invoices are not repriced; use rebill for that.
+// @Tags Billing
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Service code ID"
+// @Param request body models.ServiceCodeRequest true "Service code"
+// @Success 200 {object} models.ServiceCode
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/service-codes/{id} [put]
+func (ctrl *Controller) UpdateServiceCode(ctx *gin.Context) {
+	code, ok := ctrl.serviceCodeFromParam(ctx)
+	if !ok {
+		return
+	}
+	var req models.ServiceCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service code", "details": err.Error()})
+		return
+	}
+	before := *code
+	service.ApplyServiceCodeRequest(code, req)
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SaveServiceCode(tx, code); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_SERVICE_CODE, code.ID, before, code)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update service code", "details": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, code)
+}
+
+// CreateBillingRate godoc
+// @Summary Add a billing rate
+// @Description Add a price per unit for a service code, optionally only for one payer or client, effective from a date. Client rates beat payer rates, which beat the default.
+// @Tags Billing
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Service code ID"
+// @Param request body models.BillingRateRequest true "Rate"
+// @Success 201 {object} models.BillingRate
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/service-codes/{id}/rates [post]
+func (ctrl *Controller) CreateBillingRate(ctx *gin.Context) {
+	code, ok := ctrl.serviceCodeFromParam(ctx)
+	if !ok {
+		return
+	}
+	var req models.BillingRateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rate", "details": err.Error()})
+		return
+	}
+	if req.PayerID != nil {
+		if _, err := service.GetPayerByID(ctrl.DB, *req.PayerID); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Payer not found"})
+			return
+		}
+	}
+	if req.ClientID != nil {
+		if _, err := service.GetClientByID(ctrl.DB, *req.ClientID); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Client not found"})
+			return
+		}
+	}
+	rate, err := service.BuildBillingRate(code.ID, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.CreateBillingRate(tx, rate); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_SERVICE_CODE, code.ID, nil, rate)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to create billing rate for service code %d: %v", code.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create billing rate"})
+		return
+	}
+	ctx.JSON(http.StatusCreated, rate)
+}
+
+// GenerateInvoices godoc
+// @Summary Generate invoices
+// @Description Invoice every completed visit that started in the period and is not already billed, one invoice per client. Worked minutes (breaks deducted) are converted to units of the visit's service code and priced at the applicable rate. Visits without a service code or rate are listed as skipped.
+// @Tags Billing
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.InvoiceRequest true "Period (YYYY-MM-DD, inclusive) and optional client"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/invoices [post]
+func (ctrl *Controller) GenerateInvoices(ctx *gin.Context) {
+	userID, _, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var req models.InvoiceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	loc := ctrl.agencyLocation()
+	from, err := time.ParseInLocation("2006-01-02", req.From, loc)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return
+	}
+	to, err := time.ParseInLocation("2006-01-02", req.To, loc)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return
+	}
+	if to.Before(from) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	var invoices []models.Invoice
+	var skipped []service.BillingSkipped
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invoices, skipped, err = service.GenerateInvoices(tx, from, to.AddDate(0, 0, 1), req.ClientID, loc, ctrl.breakRules(), uint(userID))
+		if err != nil {
+			return err
+		}
+		for _, invoice := range invoices {
+			if err := ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_INVOICE, invoice.ID, nil, invoice); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrBillingNoVisits) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "skipped": skipped})
+			return
+		}
+		logger.ErrorLogger.Printf("Failed to generate invoices: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invoices"})
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"invoices": invoices, "skipped": skipped})
+}
+
+// ListInvoices godoc
+// @Summary List invoices
+// @Description List invoices, credit notes and rebills, newest first
+// @Tags Billing
+// @Security BearerAuth
+// @Produce json
+// @Param client_id query int false "Client ID"
+// @Param kind query string false "invoice, credit or rebill"
+// @Param from query string false "Periods ending after (YYYY-MM-DD)"
+// @Param to query string false "Periods starting on or before (YYYY-MM-DD)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/invoices [get]
+func (ctrl *Controller) ListInvoices(ctx *gin.Context) {
+	filter := models.InvoiceFilter{Kind: ctx.Query("kind")}
+	if value := ctx.Query("client_id"); value != "" {
+		clientID, err := strconv.Atoi(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client_id"})
+			return
+		}
+		filter.ClientID = uint(clientID)
+	}
+	loc := ctrl.agencyLocation()
+	var err error
+	if filter.From, err = parseDateParam(ctx.Query("from"), loc, false); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return
+	}
+	if filter.To, err = parseDateParam(ctx.Query("to"), loc, true); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return
+	}
+
+	invoices, err := service.ListInvoices(ctrl.DB, filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoices"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"invoices": invoices})
+}
+
+// GetInvoice godoc
+// @Summary Get an invoice
+// @Description Get an invoice with the visits it bills
+// @Tags Billing
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Invoice ID"
+// @Success 200 {object} models.Invoice
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/invoices/{id} [get]
+func (ctrl *Controller) GetInvoice(ctx *gin.Context) {
+	invoice, ok := ctrl.invoiceFromParam(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, invoice)
+}
+
+// DownloadInvoiceCSV godoc
+// @Summary Download an invoice as CSV
+// @Description One row per billed visit
+// @Tags Billing
+// @Security BearerAuth
+// @Produce text/csv
+// @Param id path int true "Invoice ID"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/invoices/{id}/csv [get]
+func (ctrl *Controller) DownloadInvoiceCSV(ctx *gin.Context) {
+	invoice, ok := ctrl.invoiceFromParam(ctx)
+	if !ok {
+		return
+	}
+	content, err := service.InvoiceCSV(invoice, ctrl.agencyLocation())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render invoice"})
+		return
+	}
+	ctx.Header("Content-Disposition", "attachment; filename="+invoice.InvoiceNumber+".csv")
+	ctx.Data(http.StatusOK, "text/csv; charset=UTF-8", content)
+}
+
+// DownloadInvoicePDF godoc
+// @Summary Download an invoice as PDF
+// @Description Printable invoice or credit note
+// @Tags Billing
+// @Security BearerAuth
+// @Produce application/pdf
+// @Param id path int true "Invoice ID"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/invoices/{id}/pdf [get]
+func (ctrl *Controller) DownloadInvoicePDF(ctx *gin.Context) {
+	invoice, ok := ctrl.invoiceFromParam(ctx)
+	if !ok {
+		return
+	}
+	content := service.InvoicePDF(invoice, ctrl.Config.AgencyName, ctrl.agencyLocation())
+	ctx.Header("Content-Disposition", "attachment; filename="+invoice.InvoiceNumber+".pdf")
+	ctx.Data(http.StatusOK, "application/pdf", content)
+}
+
+// RebillInvoice godoc
+// @Summary Credit and rebill visits
+// @Description Reverse visits on an invoice with a credit note and bill them again at their current times and rates. Without schedule_ids, every visit whose charge changed since billing (e.g. after an approved correction) is used.
+// @Tags Billing
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Invoice ID"
+// @Param request body models.InvoiceRebillRequest false "Visits to rebill"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/invoices/{id}/rebill [post]
+func (ctrl *Controller) RebillInvoice(ctx *gin.Context) {
+	userID, _, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	invoice, ok := ctrl.invoiceFromParam(ctx)
+	if !ok {
+		return
+	}
+	var req models.InvoiceRebillRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+	}
+
+	var credit, rebill *models.Invoice
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		credit, rebill, err = service.RebillInvoice(tx, invoice, req.ScheduleIDs, ctrl.agencyLocation(), ctrl.breakRules(), uint(userID))
+		if err != nil {
+			return err
+		}
+		if err := ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_INVOICE, credit.ID, nil, credit); err != nil {
+			return err
+		}
+		if rebill != nil {
+			return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_INVOICE, rebill.ID, nil, rebill)
+		}
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvoiceVisitNotBillable):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvoiceNotRebillable), errors.Is(err, service.ErrInvoiceNothingToRebill):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			logger.ErrorLogger.Printf("Failed to rebill invoice %d: %v", invoice.ID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebill invoice"})
+		}
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"credit": credit, "rebill": rebill})
+}
+
+func (ctrl *Controller) serviceCodeFromParam(ctx *gin.Context) (*models.ServiceCode, bool) {
+	codeID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service code ID"})
+		return nil, false
+	}
+	code, err := service.GetServiceCodeByID(ctrl.DB, uint(codeID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Service code not found"})
+		return nil, false
+	}
+	return code, true
+}
+
+func (ctrl *Controller) invoiceFromParam(ctx *gin.Context) (*models.Invoice, bool) {
+	invoiceID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return nil, false
+	}
+	invoice, err := service.GetInvoiceByID(ctrl.DB, uint(invoiceID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return nil, false
+	}
+	return invoice, true
+}
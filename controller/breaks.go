@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"caregiver-shift-tracker/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (ctrl *Controller) breakRules() models.BreakRules {
+	return models.BreakRules{
+		MaxBreaksPerVisit:     int(ctrl.Config.BreakMaxPerVisit),
+		MinUnpaidMinutes:      int(ctrl.Config.BreakMinUnpaidMinutes),
+		MaxBreakMinutes:       int(ctrl.Config.BreakMaxMinutes),
+		RequiredAfterMinutes:  int(ctrl.Config.BreakRequiredAfterMinutes),
+		RequiredBreakDuration: int(ctrl.Config.BreakRequiredMinutes),
+	}
+}
+
+// PauseVisit godoc
+// @Summary Pause visit (start a break)
+// @Description Open an unpaid break segment in an in-progress visit, recording where it started
+// @Tags Schedules
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param request body models.BreakRequest true "Break location"
+// @Success 201 {object} models.VisitBreak
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/pause [post]
+func (ctrl *Controller) PauseVisit(ctx *gin.Context) {
+	schedule, req, ok := ctrl.bindBreakRequest(ctx)
+	if !ok {
+		return
+	}
+	if !ctrl.ensureVisitUnlocked(ctx, schedule) {
+		return
+	}
+
+	var visitBreak *models.VisitBreak
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if visitBreak, err = service.PauseVisit(tx, schedule.ID, req.Latitude, req.Longitude, req.Reason, ctrl.breakRules()); err != nil {
+			return err
+		}
+		if err := ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_VISIT_PAUSE, models.AUDIT_ENTITY_BREAK, visitBreak.ID, nil, visitBreak); err != nil {
+			return err
+		}
+		return ctrl.appendVisitEvent(ctx, tx, schedule.ID, nil, models.LEDGER_EVENT_VISIT_PAUSE, gin.H{
+			"break_id":   visitBreak.ID,
+			"started_at": visitBreak.StartedAt,
+			"latitude":   req.Latitude,
+			"longitude":  req.Longitude,
+		})
+	})
+	if err != nil {
+		ctrl.writeBreakError(ctx, schedule.ID, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, visitBreak)
+}
+
+// ResumeVisit godoc
+// @Summary Resume visit (end a break)
+// @Description Close the open break segment, recording where it ended, and return the visit's worked/break time so far
+// @Tags Schedules
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param request body models.BreakRequest true "Resume location"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/resume [post]
+func (ctrl *Controller) ResumeVisit(ctx *gin.Context) {
+	schedule, req, ok := ctrl.bindBreakRequest(ctx)
+	if !ok {
+		return
+	}
+	if !ctrl.ensureVisitUnlocked(ctx, schedule) {
+		return
+	}
+	if schedule.Status != models.SCHEDULE_STATUS_IN_PROGRESS {
+		ctx.JSON(http.StatusConflict, gin.H{"error": service.ErrBreakVisitNotInProgress.Error()})
+		return
+	}
+
+	before := service.OpenBreak(schedule.Breaks)
+	var visitBreak *models.VisitBreak
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if visitBreak, err = service.ResumeVisit(tx, schedule.ID, req.Latitude, req.Longitude); err != nil {
+			return err
+		}
+		if err := ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_VISIT_RESUME, models.AUDIT_ENTITY_BREAK, visitBreak.ID, before, visitBreak); err != nil {
+			return err
+		}
+		return ctrl.appendVisitEvent(ctx, tx, schedule.ID, nil, models.LEDGER_EVENT_VISIT_RESUME, gin.H{
+			"break_id":  visitBreak.ID,
+			"ended_at":  visitBreak.EndedAt,
+			"latitude":  req.Latitude,
+			"longitude": req.Longitude,
+		})
+	})
+	if err != nil {
+		ctrl.writeBreakError(ctx, schedule.ID, err)
+		return
+	}
+
+	breaks := schedule.Breaks
+	for i := range breaks {
+		if breaks[i].ID == visitBreak.ID {
+			breaks[i] = *visitBreak
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"break":        visitBreak,
+		"time_summary": service.SummarizeVisitTime(schedule, breaks, ctrl.breakRules(), *visitBreak.EndedAt),
+	})
+}
+
+// bindBreakRequest loads the caller's own schedule and validates the break location
+func (ctrl *Controller) bindBreakRequest(ctx *gin.Context) (*models.Schedule, models.BreakRequest, bool) {
+	var req models.BreakRequest
+	schedule, userID, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return nil, req, false
+	}
+	if schedule.UserID != uint(userID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Schedule not assigned to user"})
+		return nil, req, false
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location data", "details": err.Error()})
+		return nil, req, false
+	}
+	if !utils.ValidCoordinates(req.Latitude, req.Longitude) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid latitude or longitude"})
+		return nil, req, false
+	}
+	return schedule, req, true
+}
+
+func (ctrl *Controller) writeBreakError(ctx *gin.Context, scheduleID uint, err error) {
+	switch {
+	case errors.Is(err, service.ErrBreakVisitNotInProgress), errors.Is(err, service.ErrBreakAlreadyOpen),
+		errors.Is(err, service.ErrBreakNotOpen), errors.Is(err, service.ErrBreakLimitReached):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.ErrorLogger.Printf("Failed to update break for schedule %d: %v", scheduleID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update break"})
+	}
+}
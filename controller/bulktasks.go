@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BulkUpdateTaskStatus godoc
+// @Summary Update many task statuses at once
+// @Description Apply status, reason and observation changes to several tasks of a visit in one transaction, restricted to the assigned caregiver. Each change is validated like /tasks/{taskId}/update; prerequisites completed earlier in the same batch count. If any change is invalid nothing is saved and the per-task errors are returned.
+// @Tags Tasks
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param request body models.BulkTaskStatusRequest true "Task status changes"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/tasks [patch]
+func (ctrl *Controller) BulkUpdateTaskStatus(ctx *gin.Context) {
+	userID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	scheduleID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+	schedule, err := service.GetScheduleByID(ctrl.DB, uint(scheduleID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+	if schedule.UserID != uint(userID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Schedule not assigned to user"})
+		return
+	}
+	if !ctrl.ensureVisitUnlocked(ctx, schedule) {
+		return
+	}
+
+	var req models.BulkTaskStatusRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	// Work on a copy of the visit's tasks so prerequisites see earlier changes in the batch
+	tasks := make([]models.Task, len(schedule.Tasks))
+	copy(tasks, schedule.Tasks)
+	byID := make(map[uint]*models.Task, len(tasks))
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+	}
+
+	now := time.Now()
+	results := make([]models.BulkTaskResult, len(req.Updates))
+	changes := make([]service.TaskStatusChange, 0, len(req.Updates))
+	befores := make([]models.Task, 0, len(req.Updates))
+	seen := make(map[uint]bool, len(req.Updates))
+	failed := false
+	for i, update := range req.Updates {
+		results[i] = models.BulkTaskResult{TaskID: update.TaskID, Status: update.Status}
+		change, before, message := ctrl.validateBulkTaskChange(update, byID, tasks, seen, schedule, uint(userID), now)
+		if message != "" {
+			results[i].Error = message
+			failed = true
+			continue
+		}
+		changes = append(changes, *change)
+		befores = append(befores, before)
+		if change.CompletedAt != nil {
+			results[i].CompletionTiming = service.CompletionTiming(&before, now)
+		}
+		results[i].Observations = change.Observations
+	}
+	if failed {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "No tasks were updated: one or more changes are invalid", "results": results})
+		return
+	}
+
+	var alerts [][]models.Alert
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if alerts, err = service.SaveTaskStatuses(tx, changes); err != nil {
+			return err
+		}
+		for i, change := range changes {
+			if err := ctrl.auditTaskChange(ctx, tx, models.AUDIT_ACTION_STATUS, &befores[i]); err != nil {
+				return err
+			}
+			if err := ctrl.appendTaskStatusEvent(ctx, tx, schedule.ID, change.Task.ID, change.Status, change.Reason, change.CompletedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to bulk update tasks for schedule %d: %v", schedule.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task statuses"})
+		return
+	}
+	for i := range changes {
+		results[i].Alerts = alerts[i]
+		for _, alert := range alerts[i] {
+			logger.InfoLogger.Printf("Observation alert %d raised for schedule %d: %s", alert.ID, schedule.ID, alert.Message)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Task statuses updated", "results": results})
+}
+
+// validateBulkTaskChange checks one change of a bulk update against the visit's tasks, returning
+// a message instead of writing a response so every failing task can be reported together.
+// On success the task in byID is updated in place for later prerequisite checks.
+func (ctrl *Controller) validateBulkTaskChange(update models.BulkTaskStatusItem, byID map[uint]*models.Task, tasks []models.Task, seen map[uint]bool, schedule *models.Schedule, userID uint, now time.Time) (*service.TaskStatusChange, models.Task, string) {
+	task, ok := byID[update.TaskID]
+	if !ok {
+		return nil, models.Task{}, "Task does not belong to this schedule"
+	}
+	if seen[update.TaskID] {
+		return nil, models.Task{}, "Task appears more than once in the batch"
+	}
+	seen[update.TaskID] = true
+	if task.Kind == models.TASK_KIND_MEDICATION {
+		return nil, models.Task{}, service.ErrMedicationTask.Error()
+	}
+	if update.Status == models.TASK_STATUS_NOT_COMPLETED && (update.Reason == nil || strings.TrimSpace(*update.Reason) == "") {
+		return nil, models.Task{}, "Reason required for not_completed status"
+	}
+	completing := update.Status == models.TASK_STATUS_COMPLETED
+	if completing {
+		if blocking := service.UnmetPrerequisites(task, tasks); len(blocking) > 0 {
+			return nil, models.Task{}, service.ErrPrerequisitesPending.Error()
+		}
+	}
+
+	var fields []models.ObservationField
+	if task.TaskTemplateID != nil {
+		var err error
+		if fields, err = service.GetObservationFields(ctrl.DB, *task.TaskTemplateID); err != nil {
+			return nil, models.Task{}, "Failed to load observation fields"
+		}
+	}
+	observations, err := service.BuildTaskObservations(fields, update.Observations, task, schedule, userID, now, completing)
+	if err != nil {
+		return nil, models.Task{}, err.Error()
+	}
+
+	before := *task
+	change := service.TaskStatusChange{
+		Task:         &before,
+		Status:       update.Status,
+		Reason:       update.Reason,
+		Fields:       fields,
+		Observations: observations,
+	}
+	if completing {
+		change.CompletedAt = &now
+	}
+	task.Status = update.Status
+	task.Reason = update.Reason
+	return &change, before, ""
+}
@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetMyAvailability godoc
+// @Summary My availability
+// @Description The weekly windows in which I can take visits
+// @Tags Capacity
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/availability [get]
+func (ctrl *Controller) GetMyAvailability(ctx *gin.Context) {
+	userID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	ctrl.respondWithAvailability(ctx, uint(userID))
+}
+
+// SetMyAvailability godoc
+// @Summary Set my availability
+// @Description Replace my weekly availability. Times are HH:MM in the agency timezone, weekday runs 0-6 from Sunday, and windows on the same day may not overlap.
+// @Tags Capacity
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.AvailabilityRequest true "Availability windows"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/availability [put]
+func (ctrl *Controller) SetMyAvailability(ctx *gin.Context) {
+	userID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	ctrl.replaceAvailability(ctx, uint(userID))
+}
+
+// GetCaregiverAvailability godoc
+// @Summary A caregiver's availability
+// @Description The weekly windows in which a caregiver can take visits
+// @Tags Capacity
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Caregiver ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/caregivers/{id}/availability [get]
+func (ctrl *Controller) GetCaregiverAvailability(ctx *gin.Context) {
+	user, ok := ctrl.caregiverFromParam(ctx)
+	if !ok {
+		return
+	}
+	ctrl.respondWithAvailability(ctx, user.ID)
+}
+
+// SetCaregiverAvailability godoc
+// @Summary Set a caregiver's availability
+// @Description Replace a caregiver's weekly availability. Times are HH:MM in the agency timezone, weekday runs 0-6 from Sunday, and windows on the same day may not overlap.
+// @Tags Capacity
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Caregiver ID"
+// @Param request body models.AvailabilityRequest true "Availability windows"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/caregivers/{id}/availability [put]
+func (ctrl *Controller) SetCaregiverAvailability(ctx *gin.Context) {
+	user, ok := ctrl.caregiverFromParam(ctx)
+	if !ok {
+		return
+	}
+	ctrl.replaceAvailability(ctx, user.ID)
+}
+
+// GetCapacityForecast godoc
+// @Summary Capacity forecast
+// @Description Forecast caregiver hours needed per region for the coming weeks against caregiver availability. Demand in each day and time-of-day slot (night 00-06, morning 06-12, afternoon 12-18, evening 18-24, agency timezone) is the larger of the hours already scheduled plus the visits recurring visit series will add on days not generated yet, and the average for that slot over the lookback weeks. Slots where demand exceeds available hours are listed as shortfalls.
+// @Tags Capacity
+// @Security BearerAuth
+// @Produce json
+// @Param from query string false "First week (YYYY-MM-DD, moved back to its Monday; default next Monday)"
+// @Param weeks query int false "Weeks to forecast (default 4, max 26)"
+// @Param lookback_weeks query int false "Weeks of history for the baseline (default 8, max 52)"
+// @Param region query string false "Only this region (unassigned for clients and availability without one)"
+// @Success 200 {object} models.CapacityForecast
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/capacity/forecast [get]
+func (ctrl *Controller) GetCapacityForecast(ctx *gin.Context) {
+	loc := ctrl.agencyLocation()
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	thisMonday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+
+	opts := models.CapacityForecastOptions{
+		From:       thisMonday.AddDate(0, 0, 7),
+		HistoryEnd: thisMonday,
+		Region:     strings.TrimSpace(ctx.Query("region")),
+		Location:   loc,
+	}
+	if value := ctx.Query("from"); value != "" {
+		from, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		opts.From = from.AddDate(0, 0, -((int(from.Weekday()) + 6) % 7))
+	}
+	var err error
+	if opts.Weeks, err = strconv.Atoi(ctx.DefaultQuery("weeks", "4")); err != nil || opts.Weeks < 1 || opts.Weeks > 26 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "weeks must be between 1 and 26"})
+		return
+	}
+	if opts.LookbackWeeks, err = strconv.Atoi(ctx.DefaultQuery("lookback_weeks", "8")); err != nil || opts.LookbackWeeks < 1 || opts.LookbackWeeks > 52 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "lookback_weeks must be between 1 and 52"})
+		return
+	}
+
+	forecast, err := service.CapacityForecastReport(ctrl.DB, opts)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to build capacity forecast: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build capacity forecast"})
+		return
+	}
+	ctx.JSON(http.StatusOK, forecast)
+}
+
+func (ctrl *Controller) respondWithAvailability(ctx *gin.Context, userID uint) {
+	windows, err := service.ListAvailability(ctrl.DB, userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch availability"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"user_id": userID, "timezone": ctrl.agencyLocation().String(), "windows": windows})
+}
+
+func (ctrl *Controller) replaceAvailability(ctx *gin.Context, userID uint) {
+	var req models.AvailabilityRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid availability", "details": err.Error()})
+		return
+	}
+	windows, err := service.BuildAvailability(userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrAvailabilityInvalid) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read availability"})
+		return
+	}
+
+	before, err := service.ListAvailability(ctrl.DB, userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch availability"})
+		return
+	}
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.ReplaceAvailability(tx, userID, windows); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_AVAILABILITY, userID, gin.H{"windows": before}, gin.H{"windows": windows})
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to save availability for user %d: %v", userID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save availability"})
+		return
+	}
+	ctrl.respondWithAvailability(ctx, userID)
+}
+
+func (ctrl *Controller) caregiverFromParam(ctx *gin.Context) (*models.User, bool) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid caregiver ID"})
+		return nil, false
+	}
+	user, err := service.GetUserByID(ctrl.DB, uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Caregiver not found"})
+		return nil, false
+	}
+	return user, true
+}
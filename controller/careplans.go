@@ -0,0 +1,425 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateTaskTemplate godoc
+// @Summary Create a task template
+// @Description Add a reusable task (e.g. "Give medication") to the template library
+// @Tags Care Plans
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.TaskTemplate true "Template"
+// @Success 201 {object} models.TaskTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/task-templates [post]
+func (ctrl *Controller) CreateTaskTemplate(ctx *gin.Context) {
+	var req models.TaskTemplate
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template data", "details": err.Error()})
+		return
+	}
+	req.ID = 0
+
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.CreateTaskTemplate(tx, &req); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_TEMPLATE, req.ID, nil, req)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to create task template: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task template"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, req)
+}
+
+// ListTaskTemplates godoc
+// @Summary List task templates
+// @Description List the task template library
+// @Tags Care Plans
+// @Security BearerAuth
+// @Produce json
+// @Param category query string false "Only templates in this category"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/task-templates [get]
+func (ctrl *Controller) ListTaskTemplates(ctx *gin.Context) {
+	templates, err := service.ListTaskTemplates(ctrl.DB, ctx.Query("category"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch task templates"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// UpdateTaskTemplate godoc
+// @Summary Update a task template
+// @Description Change a template. Tasks already created from it are not changed.
+// @Tags Care Plans
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param request body models.TaskTemplate true "Template"
+// @Success 200 {object} models.TaskTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/task-templates/{id} [put]
+func (ctrl *Controller) UpdateTaskTemplate(ctx *gin.Context) {
+	templateID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+	before, err := service.GetTaskTemplateByID(ctrl.DB, uint(templateID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Task template not found"})
+		return
+	}
+
+	var req models.TaskTemplate
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template data", "details": err.Error()})
+		return
+	}
+	req.ID = before.ID
+	req.CreatedAt = before.CreatedAt
+
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.UpdateTaskTemplate(tx, &req); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_TEMPLATE, req.ID, before, req)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task template"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, req)
+}
+
+// DeleteTaskTemplate godoc
+// @Summary Delete a task template
+// @Description Delete a template that is not used by any care plan
+// @Tags Care Plans
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Template ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/task-templates/{id} [delete]
+func (ctrl *Controller) DeleteTaskTemplate(ctx *gin.Context) {
+	templateID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+	before, err := service.GetTaskTemplateByID(ctrl.DB, uint(templateID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Task template not found"})
+		return
+	}
+
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.DeleteTaskTemplate(tx, before.ID); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_TEMPLATE, before.ID, before, nil)
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrTemplateInUse) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task template"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Task template deleted"})
+}
+
+// ListClientCarePlans godoc
+// @Summary List a client's care plans
+// @Description List a client's care plans with their items, the active plan first
+// @Tags Care Plans
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Client ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/clients/{id}/care-plans [get]
+func (ctrl *Controller) ListClientCarePlans(ctx *gin.Context) {
+	clientID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+	plans, err := service.ListCarePlans(ctrl.DB, uint(clientID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch care plans"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"care_plans": plans})
+}
+
+// CreateClientCarePlan godoc
+// @Summary Create a care plan
+// @Description Create a care plan for a client from task templates, each optionally limited to certain days of the week (sun..sat). An active plan replaces the client's previous active plan.
+// @Tags Care Plans
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Client ID"
+// @Param request body models.CarePlanRequest true "Care plan"
+// @Success 201 {object} models.CarePlan
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/clients/{id}/care-plans [post]
+func (ctrl *Controller) CreateClientCarePlan(ctx *gin.Context) {
+	userID, _, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	clientID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+	if _, err := service.GetClientByID(ctrl.DB, uint(clientID)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	var req models.CarePlanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid care plan data", "details": err.Error()})
+		return
+	}
+
+	var plan *models.CarePlan
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if plan, err = service.CreateCarePlan(tx, uint(clientID), req, uint(userID)); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_CARE_PLAN, plan.ID, nil, plan)
+	})
+	if err != nil {
+		ctrl.writeCarePlanError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, plan)
+}
+
+// GetCarePlan godoc
+// @Summary Get a care plan
+// @Description Fetch a care plan with its items and templates
+// @Tags Care Plans
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Care plan ID"
+// @Success 200 {object} models.CarePlan
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/care-plans/{id} [get]
+func (ctrl *Controller) GetCarePlan(ctx *gin.Context) {
+	plan, ok := ctrl.carePlanFromParam(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, plan)
+}
+
+// UpdateCarePlan godoc
+// @Summary Update a care plan
+// @Description Rename a care plan and replace its items. Existing schedules keep the tasks they already have.
+// @Tags Care Plans
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Care plan ID"
+// @Param request body models.CarePlanRequest true "Care plan"
+// @Success 200 {object} models.CarePlan
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/care-plans/{id} [put]
+func (ctrl *Controller) UpdateCarePlan(ctx *gin.Context) {
+	before, ok := ctrl.carePlanFromParam(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.CarePlanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid care plan data", "details": err.Error()})
+		return
+	}
+
+	var plan *models.CarePlan
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if plan, err = service.UpdateCarePlan(tx, before, req); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_CARE_PLAN, plan.ID, before, plan)
+	})
+	if err != nil {
+		ctrl.writeCarePlanError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, plan)
+}
+
+// ActivateCarePlan godoc
+// @Summary Activate a care plan
+// @Description Make this the client's active care plan, deactivating any other
+// @Tags Care Plans
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Care plan ID"
+// @Success 200 {object} models.CarePlan
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/care-plans/{id}/activate [post]
+func (ctrl *Controller) ActivateCarePlan(ctx *gin.Context) {
+	before, ok := ctrl.carePlanFromParam(ctx)
+	if !ok {
+		return
+	}
+	var plan *models.CarePlan
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.ActivateCarePlan(tx, before); err != nil {
+			return err
+		}
+		var err error
+		if plan, err = service.GetCarePlanByID(tx, before.ID); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_CARE_PLAN, plan.ID, before, plan)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate care plan"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, plan)
+}
+
+// ApplyCarePlanToSchedule godoc
+// @Summary Apply the care plan to a schedule
+// @Description Add tasks from the client's active care plan to an existing schedule. Templates already on the schedule are skipped.
+// @Tags Care Plans
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/schedules/{id}/apply-care-plan [post]
+func (ctrl *Controller) ApplyCarePlanToSchedule(ctx *gin.Context) {
+	scheduleID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+	schedule, err := service.GetScheduleByID(ctrl.DB, uint(scheduleID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+	if schedule.ClientID == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Schedule is not linked to a client"})
+		return
+	}
+
+	var tasks []models.Task
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if tasks, err = service.ApplyCarePlan(tx, schedule, GetUserTimeZone(ctx)); err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if err := ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_TASK, task.ID, nil, task); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to apply care plan to schedule %d: %v", schedule.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply care plan"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"added": len(tasks), "tasks": tasks})
+}
+
+func (ctrl *Controller) carePlanFromParam(ctx *gin.Context) (*models.CarePlan, bool) {
+	planID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid care plan ID"})
+		return nil, false
+	}
+	plan, err := service.GetCarePlanByID(ctrl.DB, uint(planID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Care plan not found"})
+		return nil, false
+	}
+	return plan, true
+}
+
+func (ctrl *Controller) writeCarePlanError(ctx *gin.Context, err error) {
+	if errors.Is(err, service.ErrTemplateNotFound) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logger.ErrorLogger.Printf("Failed to save care plan: %v", err)
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save care plan"})
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetVisitChecklist godoc
+// @Summary Visit checklist
+// @Description The visit's tasks in order, with each timed task's window, the prerequisites still blocking it and whether it is overdue (assigned caregiver or staff)
+// @Tags Tasks
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/checklist [get]
+func (ctrl *Controller) GetVisitChecklist(ctx *gin.Context) {
+	schedule, _, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return
+	}
+	tasks, err := service.GetScheduleTasks(ctrl.DB, schedule.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"checklist": service.BuildChecklist(tasks, time.Now())})
+}
+
+// SetVisitChecklist godoc
+// @Summary Arrange a visit checklist
+// @Description Set the position, target time window and prerequisites of tasks on a visit. Tasks not listed keep their settings. Prerequisites must be other tasks on the same visit and may not form a cycle.
+// @Tags Tasks
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param request body models.ChecklistRequest true "Checklist settings"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/schedules/{id}/checklist [put]
+func (ctrl *Controller) SetVisitChecklist(ctx *gin.Context) {
+	scheduleID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+	before, err := service.GetScheduleTasks(ctrl.DB, uint(scheduleID))
+	if err != nil || len(before) == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule has no tasks"})
+		return
+	}
+
+	var req models.ChecklistRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid checklist", "details": err.Error()})
+		return
+	}
+
+	var tasks []models.Task
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SetChecklist(tx, uint(scheduleID), req.Items); err != nil {
+			return err
+		}
+		var err error
+		if tasks, err = service.GetScheduleTasks(tx, uint(scheduleID)); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_SCHEDULE, uint(scheduleID), gin.H{"tasks": before}, gin.H{"tasks": tasks})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrChecklistTask), errors.Is(err, service.ErrChecklistPrerequisite), errors.Is(err, service.ErrChecklistCycle):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logger.ErrorLogger.Printf("Failed to update checklist for schedule %d: %v", scheduleID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update checklist"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"checklist": service.BuildChecklist(tasks, time.Now())})
+}
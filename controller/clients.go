@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateClient godoc
+// @Summary Create a client
+// @Description Register a client (EVV member) receiving care
+// @Tags Clients
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.Client true "Client Info"
+// @Success 201 {object} models.Client
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/clients [post]
+func (ctrl *Controller) CreateClient(ctx *gin.Context) {
+	var req models.Client
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client data", "details": err.Error()})
+		return
+	}
+	if !validClientCoordinates(&req) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid latitude or longitude"})
+		return
+	}
+	if !ctrl.validClientPayer(ctx, &req) {
+		return
+	}
+	req.ID = 0
+
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.CreateClient(tx, &req); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_CLIENT, req.ID, nil, req)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to create client: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, req)
+}
+
+// ListClients godoc
+// @Summary List clients
+// @Description List all clients
+// @Tags Clients
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/clients [get]
+func (ctrl *Controller) ListClients(ctx *gin.Context) {
+	clients, err := service.ListClients(ctrl.DB)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch clients"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+// GetClient godoc
+// @Summary Get a client
+// @Description Fetch a client by ID
+// @Tags Clients
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Client ID"
+// @Success 200 {object} models.Client
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/clients/{id} [get]
+func (ctrl *Controller) GetClient(ctx *gin.Context) {
+	clientID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+	client, err := service.GetClientByID(ctrl.DB, uint(clientID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, client)
+}
+
+// UpdateClient godoc
+// @Summary Update a client
+// @Description Update a client's details
+// @Tags Clients
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Client ID"
+// @Param request body models.Client true "Client Info"
+// @Success 200 {object} models.Client
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/clients/{id} [put]
+func (ctrl *Controller) UpdateClient(ctx *gin.Context) {
+	clientID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+	before, err := service.GetClientByID(ctrl.DB, uint(clientID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	var req models.Client
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client data", "details": err.Error()})
+		return
+	}
+	if !validClientCoordinates(&req) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid latitude or longitude"})
+		return
+	}
+	if !ctrl.validClientPayer(ctx, &req) {
+		return
+	}
+	req.ID = before.ID
+	req.CreatedAt = before.CreatedAt
+
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.UpdateClient(tx, &req); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_CLIENT, req.ID, before, req)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update client", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, req)
+}
+
+func validClientCoordinates(client *models.Client) bool {
+	if (client.Latitude == nil) != (client.Longitude == nil) {
+		return false
+	}
+	if client.Latitude != nil && (*client.Latitude < -90 || *client.Latitude > 90 || *client.Longitude < -180 || *client.Longitude > 180) {
+		return false
+	}
+	return true
+}
+
+// validClientPayer writes a 400 and returns false when the client names a payer that does not exist
+func (ctrl *Controller) validClientPayer(ctx *gin.Context, client *models.Client) bool {
+	if client.PayerID == nil {
+		return true
+	}
+	if _, err := service.GetPayerByID(ctrl.DB, *client.PayerID); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Payer not found"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (ctrl *Controller) taskCompletionPolicy() string {
+	switch ctrl.Config.TaskCompletionPolicy {
+	case models.TASK_POLICY_BLOCK, models.TASK_POLICY_OVERRIDE:
+		return ctrl.Config.TaskCompletionPolicy
+	default:
+		return models.TASK_POLICY_OFF
+	}
+}
+
+// checkTaskCompletion applies the completion policy to a checkout. It writes a 409 listing the
+// unresolved tasks and returns false when checkout must be refused; otherwise it returns the
+// override being used, if any.
+func (ctrl *Controller) checkTaskCompletion(ctx *gin.Context, schedule *models.Schedule, token string) (*models.TaskCompletionOverride, bool) {
+	policy := ctrl.taskCompletionPolicy()
+	if policy == models.TASK_POLICY_OFF {
+		return nil, true
+	}
+	unresolved := service.UnresolvedTasks(schedule.Tasks)
+	if len(unresolved) == 0 {
+		return nil, true
+	}
+
+	if policy == models.TASK_POLICY_OVERRIDE && token != "" {
+		override, err := service.FindCompletionOverride(ctrl.DB, schedule.ID, token, time.Now())
+		if err == nil {
+			return override, true
+		}
+		if !errors.Is(err, service.ErrOverrideInvalid) {
+			logger.ErrorLogger.Printf("Failed to look up completion override for schedule %d: %v", schedule.ID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify override token"})
+			return nil, false
+		}
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "policy": policy, "unresolved_tasks": unresolved})
+		return nil, false
+	}
+
+	ctx.JSON(http.StatusConflict, gin.H{
+		"error":            service.ErrTasksUnresolved.Error(),
+		"policy":           policy,
+		"override_allowed": policy == models.TASK_POLICY_OVERRIDE,
+		"unresolved_tasks": unresolved,
+	})
+	return nil, false
+}
+
+// IssueCompletionOverride godoc
+// @Summary Issue a task completion override
+// @Description Issue a single-use token that lets the caregiver end this visit with unresolved tasks (TASK_COMPLETION_POLICY=override). The token is only returned once.
+// @Tags Schedules
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param request body models.CompletionOverrideRequest true "Why the tasks may stay unresolved"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/schedules/{id}/completion-override [post]
+func (ctrl *Controller) IssueCompletionOverride(ctx *gin.Context) {
+	userID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	scheduleID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+	schedule, err := service.GetScheduleByID(ctrl.DB, uint(scheduleID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	var req models.CompletionOverrideRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required", "details": err.Error()})
+		return
+	}
+
+	ttl := time.Duration(ctrl.Config.TaskOverrideTTLMinutes) * time.Minute
+	var token string
+	var override *models.TaskCompletionOverride
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if token, override, err = service.IssueCompletionOverride(tx, schedule, uint(userID), req.Reason, ttl); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_OVERRIDE, override.ID, nil, override)
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrOverrideVisitEnded) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		logger.ErrorLogger.Printf("Failed to issue completion override for schedule %d: %v", schedule.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue override"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"override":         override,
+		"token":            token,
+		"unresolved_tasks": service.UnresolvedTasks(schedule.Tasks),
+	})
+}
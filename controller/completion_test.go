@@ -0,0 +1,35 @@
+package controller_test
+
+import (
+	"caregiver-shift-tracker/models"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStatusUpdateCannotCompleteVisitWithOpenTasks(t *testing.T) {
+	f := newVisitFixture(t)
+	f.config.TaskCompletionPolicy = "block"
+	started := time.Now().UTC().Add(-30 * time.Minute)
+	visit, _ := f.visit(models.SCHEDULE_STATUS_IN_PROGRESS, &started)
+
+	for _, status := range []string{models.SCHEDULE_STATUS_COMPLETED, models.SCHEDULE_STATUS_IN_PROGRESS} {
+		rec := f.request(http.MethodPut, fmt.Sprintf("/api/user/schedules/%d/status", visit.ID), fmt.Sprintf(`{"status": %q}`, status))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("setting status %s: got %d %s, want 400", status, rec.Code, rec.Body.String())
+		}
+	}
+	rec := f.request(http.MethodPost, fmt.Sprintf("/api/user/schedules/%d/end", visit.ID), `{"latitude": 40.7, "longitude": -74.0}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("ending a visit with open tasks: got %d %s, want 409", rec.Code, rec.Body.String())
+	}
+
+	var saved models.Schedule
+	if err := f.db.First(&saved, visit.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != models.SCHEDULE_STATUS_IN_PROGRESS || saved.EndTime != nil {
+		t.Fatalf("visit with open tasks was completed: status %s, end %v", saved.Status, saved.EndTime)
+	}
+}
@@ -0,0 +1,366 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetCorrectionReasonCodes godoc
+// @Summary List EVV correction reason codes
+// @Description List the standard EVV reason codes accepted for manual visit corrections
+// @Tags Corrections
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/user/corrections/reason-codes [get]
+func (ctrl *Controller) GetCorrectionReasonCodes(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"reason_codes": models.EVVReasonCodes})
+}
+
+// RequestVisitCorrection godoc
+// @Summary Propose a visit correction
+// @Description Propose corrected start/end times and locations for a visit with an EVV reason code. Allowed for the assigned caregiver and staff.
+// @Tags Corrections
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param request body models.VisitCorrectionRequest true "Corrected values"
+// @Success 201 {object} models.VisitCorrection
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/corrections [post]
+func (ctrl *Controller) RequestVisitCorrection(ctx *gin.Context) {
+	userID, roleID, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	scheduleID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+
+	schedule, err := service.GetScheduleByID(ctrl.DB, uint(scheduleID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+	if !canAccessSchedule(userID, roleID, schedule) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Schedule not assigned to user"})
+		return
+	}
+
+	var req models.VisitCorrectionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if msg := validateCorrectionRequest(&req); msg != "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !ctrl.ensureVisitUnlocked(ctx, schedule, req.StartTime) {
+		return
+	}
+
+	correction := models.VisitCorrection{
+		RequestedBy: uint(userID),
+		ReasonCode:  req.ReasonCode,
+		Note:        req.Note,
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+		StartLat:    req.StartLat,
+		StartLon:    req.StartLon,
+		EndLat:      req.EndLat,
+		EndLon:      req.EndLon,
+	}
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.CreateVisitCorrection(tx, schedule, &correction); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_CORRECTION, correction.ID, nil, correction)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrCorrectionAlreadyOpen):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrCorrectionInvalidTimes):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logger.ErrorLogger.Printf("Failed to create visit correction: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create visit correction"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, correction)
+}
+
+// GetVisitCorrections godoc
+// @Summary List corrections for a visit
+// @Description List all proposed, approved and rejected corrections for a schedule
+// @Tags Corrections
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/corrections [get]
+func (ctrl *Controller) GetVisitCorrections(ctx *gin.Context) {
+	userID, roleID, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	scheduleID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+
+	schedule, err := service.GetScheduleByID(ctrl.DB, uint(scheduleID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+	if !canAccessSchedule(userID, roleID, schedule) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Schedule not assigned to user"})
+		return
+	}
+
+	corrections, err := service.GetVisitCorrections(ctrl.DB, schedule.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch visit corrections"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"corrections": corrections})
+}
+
+// ListVisitCorrections godoc
+// @Summary Correction review queue
+// @Description List visit corrections by status (defaults to pending) for customer care review
+// @Tags Corrections
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "pending, approved, rejected or all"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/corrections [get]
+func (ctrl *Controller) ListVisitCorrections(ctx *gin.Context) {
+	status := ctx.DefaultQuery("status", models.CORRECTION_STATUS_PENDING)
+	switch status {
+	case "all":
+		status = ""
+	case models.CORRECTION_STATUS_PENDING, models.CORRECTION_STATUS_APPROVED, models.CORRECTION_STATUS_REJECTED:
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter"})
+		return
+	}
+
+	corrections, err := service.ListCorrectionsByStatus(ctrl.DB, status)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch visit corrections"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"corrections": corrections})
+}
+
+// ApproveVisitCorrection godoc
+// @Summary Approve a visit correction
+// @Description Apply a pending correction to its visit. The original values stay on the correction record.
+// @Tags Corrections
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Correction ID"
+// @Param request body models.CorrectionReviewRequest false "Review note"
+// @Success 200 {object} models.VisitCorrection
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/corrections/{id}/approve [post]
+func (ctrl *Controller) ApproveVisitCorrection(ctx *gin.Context) {
+	ctrl.reviewVisitCorrection(ctx, true)
+}
+
+// RejectVisitCorrection godoc
+// @Summary Reject a visit correction
+// @Description Reject a pending correction, leaving the visit unchanged
+// @Tags Corrections
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Correction ID"
+// @Param request body models.CorrectionReviewRequest false "Review note"
+// @Success 200 {object} models.VisitCorrection
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/corrections/{id}/reject [post]
+func (ctrl *Controller) RejectVisitCorrection(ctx *gin.Context) {
+	ctrl.reviewVisitCorrection(ctx, false)
+}
+
+func (ctrl *Controller) reviewVisitCorrection(ctx *gin.Context, approve bool) {
+	reviewerID, _, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	correctionID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid correction ID"})
+		return
+	}
+
+	var req models.CorrectionReviewRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+	}
+
+	pending, err := service.GetVisitCorrectionByID(ctrl.DB, uint(correctionID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Correction not found"})
+		return
+	}
+	scheduleBefore, err := service.GetScheduleByID(ctrl.DB, pending.ScheduleID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	var correction *models.VisitCorrection
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if approve {
+			correction, err = service.ApproveVisitCorrection(tx, uint(correctionID), uint(reviewerID), req.ReviewNote)
+		} else {
+			correction, err = service.RejectVisitCorrection(tx, uint(correctionID), uint(reviewerID), req.ReviewNote)
+		}
+		if err != nil {
+			return err
+		}
+
+		reason := correction.ReasonCode
+		if correction.Note != nil && *correction.Note != "" {
+			reason += ": " + *correction.Note
+		}
+		ctx.Set(auditReasonKey, reason)
+
+		if !approve {
+			return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_REJECT, models.AUDIT_ENTITY_CORRECTION, correction.ID, pending, correction)
+		}
+		if err := ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_APPROVE, models.AUDIT_ENTITY_CORRECTION, correction.ID, pending, correction); err != nil {
+			return err
+		}
+		if err := ctrl.auditScheduleChange(ctx, tx, models.AUDIT_ACTION_UPDATE, scheduleBefore); err != nil {
+			return err
+		}
+		return ctrl.appendVisitEvent(ctx, tx, correction.ScheduleID, nil, models.LEDGER_EVENT_VISIT_CORRECTION, gin.H{
+			"correction_id": correction.ID,
+			"reason_code":   correction.ReasonCode,
+			"requested_by":  correction.RequestedBy,
+			"original": gin.H{
+				"start_time": correction.OriginalStartTime,
+				"end_time":   correction.OriginalEndTime,
+				"start_lat":  correction.OriginalStartLat,
+				"start_lon":  correction.OriginalStartLon,
+				"end_lat":    correction.OriginalEndLat,
+				"end_lon":    correction.OriginalEndLon,
+			},
+			"corrected": gin.H{
+				"start_time": correction.StartTime,
+				"end_time":   correction.EndTime,
+				"start_lat":  correction.StartLat,
+				"start_lon":  correction.StartLon,
+				"end_lat":    correction.EndLat,
+				"end_lon":    correction.EndLon,
+			},
+		})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Correction not found"})
+		case errors.Is(err, service.ErrCorrectionNotPending), errors.Is(err, service.ErrTimesheetLocked):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrCorrectionInvalidTimes):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logger.ErrorLogger.Printf("Failed to review visit correction %d: %v", correctionID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review visit correction"})
+		}
+		return
+	}
+
+	if approve {
+		ctrl.afterVisitChange(scheduleBefore)
+	}
+
+	ctx.JSON(http.StatusOK, correction)
+}
+
+// validateCorrectionRequest returns a user-facing error message, or "" when the request is valid
+func validateCorrectionRequest(req *models.VisitCorrectionRequest) string {
+	req.ReasonCode = strings.ToUpper(strings.TrimSpace(req.ReasonCode))
+	if _, ok := models.EVVReasonCodes[req.ReasonCode]; !ok {
+		return "Invalid reason code"
+	}
+	if req.ReasonCode == models.EVV_REASON_OTHER && (req.Note == nil || strings.TrimSpace(*req.Note) == "") {
+		return "A note is required for reason code OTHER"
+	}
+	if req.StartTime == nil && req.EndTime == nil && req.StartLat == nil && req.StartLon == nil && req.EndLat == nil && req.EndLon == nil {
+		return "At least one corrected value is required"
+	}
+	if (req.StartLat == nil) != (req.StartLon == nil) || (req.EndLat == nil) != (req.EndLon == nil) {
+		return "Latitude and longitude must be corrected together"
+	}
+	for _, lat := range []*float64{req.StartLat, req.EndLat} {
+		if lat != nil && (*lat < -90 || *lat > 90) {
+			return "Invalid latitude or longitude"
+		}
+	}
+	for _, lon := range []*float64{req.StartLon, req.EndLon} {
+		if lon != nil && (*lon < -180 || *lon > 180) {
+			return "Invalid latitude or longitude"
+		}
+	}
+	now := time.Now()
+	if (req.StartTime != nil && req.StartTime.After(now)) || (req.EndTime != nil && req.EndTime.After(now)) {
+		return "Corrected times cannot be in the future"
+	}
+	return ""
+}
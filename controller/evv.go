@@ -0,0 +1,310 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadEVVSchema resolves the export schema for a state, writing the error response if it cannot
+func (ctrl *Controller) loadEVVSchema(ctx *gin.Context, state string) (models.EVVExportSchema, bool) {
+	schemas, err := service.LoadEVVSchemas(ctrl.Config.EVVSchemaFile)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to load EVV schemas: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load EVV export schemas"})
+		return models.EVVExportSchema{}, false
+	}
+	schema, ok := schemas[strings.ToUpper(state)]
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": service.ErrEVVUnknownState.Error()})
+		return models.EVVExportSchema{}, false
+	}
+	return schema, true
+}
+
+// GetEVVSchemas godoc
+// @Summary List EVV export schemas
+// @Description List the per-state aggregator file layouts configured on the server
+// @Tags EVV
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/evv/schemas [get]
+func (ctrl *Controller) GetEVVSchemas(ctx *gin.Context) {
+	schemas, err := service.LoadEVVSchemas(ctrl.Config.EVVSchemaFile)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load EVV export schemas", "details": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"schemas": schemas})
+}
+
+// CreateEVVExport godoc
+// @Summary Generate an EVV aggregator file
+// @Description Export completed visits in a date range that have not yet been accepted (new, rejected or corrected since submission) using the state's schema
+// @Tags EVV
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.EVVExportRequest true "State and period (YYYY-MM-DD)"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/evv/exports [post]
+func (ctrl *Controller) CreateEVVExport(ctx *gin.Context) {
+	userID, _, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.EVVExportRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	schema, ok := ctrl.loadEVVSchema(ctx, req.State)
+	if !ok {
+		return
+	}
+
+	loc := GetUserTimeZone(ctx)
+	from, err := parseDateParam(req.From, loc, false)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return
+	}
+	to, err := parseDateParam(req.To, loc, true)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return
+	}
+	if to.Before(*from) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	batch, skipped, err := service.CreateEVVExportBatch(ctrl.DB, schema, ctrl.Config.EVVProviderID, *from, *to, uint(userID))
+	if err != nil {
+		if errors.Is(err, service.ErrEVVNoVisits) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "skipped": skipped})
+			return
+		}
+		logger.ErrorLogger.Printf("Failed to create EVV export: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create EVV export"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"batch": batch, "skipped": skipped})
+}
+
+// ListEVVExports godoc
+// @Summary List EVV export batches
+// @Description List generated aggregator files, newest first
+// @Tags EVV
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/evv/exports [get]
+func (ctrl *Controller) ListEVVExports(ctx *gin.Context) {
+	batches, err := service.ListEVVExportBatches(ctrl.DB)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch EVV exports"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"batches": batches})
+}
+
+// GetEVVExport godoc
+// @Summary Get an EVV export batch
+// @Description Fetch a batch with the submission status of every visit it contains
+// @Tags EVV
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Batch ID"
+// @Success 200 {object} models.EVVExportBatch
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/evv/exports/{id} [get]
+func (ctrl *Controller) GetEVVExport(ctx *gin.Context) {
+	batch, ok := ctrl.evvBatchFromParam(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, batch)
+}
+
+// DownloadEVVExport godoc
+// @Summary Download an EVV export file
+// @Description Download the aggregator-ready CSV or JSON file for a batch
+// @Tags EVV
+// @Security BearerAuth
+// @Produce octet-stream
+// @Param id path int true "Batch ID"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/evv/exports/{id}/file [get]
+func (ctrl *Controller) DownloadEVVExport(ctx *gin.Context) {
+	batch, ok := ctrl.evvBatchFromParam(ctx)
+	if !ok {
+		return
+	}
+	contentType := "text/csv; charset=UTF-8"
+	if batch.Format == models.EVV_FORMAT_JSON {
+		contentType = "application/json; charset=UTF-8"
+	}
+	filename := fmt.Sprintf("evv-%s-%d.%s", strings.ToLower(batch.State), batch.ID, batch.Format)
+	ctx.Header("Content-Disposition", "attachment; filename="+filename)
+	ctx.Data(http.StatusOK, contentType, []byte(batch.Content))
+}
+
+// SubmitEVVExport godoc
+// @Summary Submit an EVV export to the aggregator
+// @Description Post the batch file to the configured aggregator and record any per-visit results it returns
+// @Tags EVV
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Batch ID"
+// @Success 200 {object} models.EVVExportBatch
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /api/admin/evv/exports/{id}/submit [post]
+func (ctrl *Controller) SubmitEVVExport(ctx *gin.Context) {
+	batch, ok := ctrl.evvBatchFromParam(ctx)
+	if !ok {
+		return
+	}
+	schema, ok := ctrl.loadEVVSchema(ctx, batch.State)
+	if !ok {
+		return
+	}
+
+	if err := service.SubmitEVVBatch(ctrl.DB, batch, schema, ctrl.Config.EVVAggregatorURL); err != nil {
+		if errors.Is(err, service.ErrEVVNoAggregatorURL) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.ErrorLogger.Printf("Failed to submit EVV batch %d: %v", batch.ID, err)
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "Failed to submit EVV export", "details": err.Error()})
+		return
+	}
+
+	batch, err := service.GetEVVExportBatch(ctrl.DB, batch.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload EVV export"})
+		return
+	}
+	ctx.JSON(http.StatusOK, batch)
+}
+
+// RecordEVVResults godoc
+// @Summary Record aggregator results
+// @Description Record accepted/rejected outcomes for visits in a batch (for aggregators that respond asynchronously)
+// @Tags EVV
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Batch ID"
+// @Param request body models.EVVAggregatorResponse true "Per-visit results"
+// @Success 200 {object} models.EVVExportBatch
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/evv/exports/{id}/results [post]
+func (ctrl *Controller) RecordEVVResults(ctx *gin.Context) {
+	batch, ok := ctrl.evvBatchFromParam(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.EVVAggregatorResponse
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	if err := service.ApplyEVVResults(ctrl.DB, batch.ID, req.Results); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to record EVV results", "details": err.Error()})
+		return
+	}
+
+	batch, err := service.GetEVVExportBatch(ctrl.DB, batch.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload EVV export"})
+		return
+	}
+	ctx.JSON(http.StatusOK, batch)
+}
+
+// ListEVVSubmissions godoc
+// @Summary List EVV submissions
+// @Description List per-visit aggregator submission history
+// @Tags EVV
+// @Security BearerAuth
+// @Produce json
+// @Param schedule_id query int false "Schedule ID"
+// @Param status query string false "generated, submitted, accepted or rejected"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/evv/submissions [get]
+func (ctrl *Controller) ListEVVSubmissions(ctx *gin.Context) {
+	var scheduleID int
+	if value := ctx.Query("schedule_id"); value != "" {
+		var err error
+		scheduleID, err = strconv.Atoi(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule_id"})
+			return
+		}
+	}
+
+	submissions, err := service.ListEVVSubmissions(ctrl.DB, uint(scheduleID), ctx.Query("status"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch EVV submissions"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"submissions": submissions})
+}
+
+func (ctrl *Controller) evvBatchFromParam(ctx *gin.Context) (*models.EVVExportBatch, bool) {
+	batchID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
+		return nil, false
+	}
+	batch, err := service.GetEVVExportBatch(ctrl.DB, uint(batchID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "EVV export not found"})
+		return nil, false
+	}
+	return batch, true
+}
@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReportIncident godoc
+// @Summary Report an incident
+// @Description Report a fall, injury, medication error or other incident on a visit. Attachments already uploaded to the visit can be linked by ID. High and critical incidents are emailed to customer care immediately.
+// @Tags Incidents
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param request body models.IncidentRequest true "Incident report"
+// @Success 201 {object} models.Incident
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/incidents [post]
+func (ctrl *Controller) ReportIncident(ctx *gin.Context) {
+	schedule, userID, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.IncidentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid incident report", "details": err.Error()})
+		return
+	}
+	incident := models.Incident{ScheduleID: schedule.ID, ClientID: schedule.ClientID, ReportedBy: uint(userID)}
+	if err := service.BuildIncident(&incident, req, time.Now()); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.CreateIncident(tx, &incident, req.AttachmentIDs); err != nil {
+			return err
+		}
+		if err := ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_INCIDENT, incident.ID, nil, incident); err != nil {
+			return err
+		}
+		return ctrl.appendVisitEvent(ctx, tx, schedule.ID, nil, models.LEDGER_EVENT_INCIDENT, gin.H{
+			"incident_id": incident.ID,
+			"category":    incident.Category,
+			"severity":    incident.Severity,
+			"occurred_at": incident.OccurredAt,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrIncidentAttachment) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.ErrorLogger.Printf("Failed to create incident for schedule %d: %v", schedule.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report incident"})
+		return
+	}
+
+	if models.IsHighSeverity(incident.Severity) {
+		if err := service.NotifyHighSeverityIncident(ctrl.DB, &incident, schedule); err != nil {
+			logger.ErrorLogger.Printf("Failed to send notification for incident %d: %v", incident.ID, err)
+		}
+	}
+
+	if reloaded, err := service.GetIncidentByID(ctrl.DB, incident.ID); err == nil {
+		incident = *reloaded
+	}
+	ctx.JSON(http.StatusCreated, incident)
+}
+
+// GetScheduleIncidents godoc
+// @Summary List a visit's incidents
+// @Description List the incidents reported on a visit (assigned caregiver or staff)
+// @Tags Incidents
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/incidents [get]
+func (ctrl *Controller) GetScheduleIncidents(ctx *gin.Context) {
+	schedule, _, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return
+	}
+	incidents, err := service.GetScheduleIncidents(ctrl.DB, schedule.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch incidents"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"incidents": incidents})
+}
+
+// GetIncidentCategories godoc
+// @Summary List incident categories
+// @Description List the accepted incident categories and their descriptions
+// @Tags Incidents
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /api/user/incidents/categories [get]
+func (ctrl *Controller) GetIncidentCategories(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, models.IncidentCategories)
+}
+
+// ListIncidents godoc
+// @Summary List incidents
+// @Description List incidents across visits, newest first
+// @Tags Incidents
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "open, under_review or closed"
+// @Param severity query string false "low, medium, high or critical"
+// @Param category query string false "Incident category"
+// @Param client_id query int false "Client ID"
+// @Param from query string false "Occurred on or after (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Occurred on or before (RFC3339 or YYYY-MM-DD)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/incidents [get]
+func (ctrl *Controller) ListIncidents(ctx *gin.Context) {
+	filter := models.IncidentFilter{
+		Status:   ctx.Query("status"),
+		Severity: ctx.Query("severity"),
+		Category: ctx.Query("category"),
+	}
+	if value := ctx.Query("client_id"); value != "" {
+		clientID, err := strconv.Atoi(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client_id"})
+			return
+		}
+		filter.ClientID = uint(clientID)
+	}
+	loc := GetUserTimeZone(ctx)
+	var err error
+	if filter.From, err = parseDateParam(ctx.Query("from"), loc, false); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return
+	}
+	if filter.To, err = parseDateParam(ctx.Query("to"), loc, true); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return
+	}
+
+	incidents, err := service.ListIncidents(ctrl.DB, filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch incidents"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"incidents": incidents})
+}
+
+// GetIncident godoc
+// @Summary Get an incident
+// @Description Get an incident with its attachments
+// @Tags Incidents
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Incident ID"
+// @Success 200 {object} models.Incident
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/incidents/{id} [get]
+func (ctrl *Controller) GetIncident(ctx *gin.Context) {
+	incident, ok := ctrl.incidentFromParam(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, incident)
+}
+
+// UpdateIncidentStatus godoc
+// @Summary Move an incident through review
+// @Description Move an incident from open to under_review, back to open, or to closed. Closing requires a resolution note.
+// @Tags Incidents
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Incident ID"
+// @Param request body models.IncidentStatusRequest true "New status and note"
+// @Success 200 {object} models.Incident
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/incidents/{id}/status [post]
+func (ctrl *Controller) UpdateIncidentStatus(ctx *gin.Context) {
+	userID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	incident, ok := ctrl.incidentFromParam(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.IncidentStatusRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	before := *incident
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.TransitionIncident(tx, incident, req.Status, req.Note, uint(userID)); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_STATUS, models.AUDIT_ENTITY_INCIDENT, incident.ID, before, incident)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrIncidentTransition):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "from": before.Status, "to": req.Status})
+		case errors.Is(err, service.ErrIncidentResolutionNeeded):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logger.ErrorLogger.Printf("Failed to update incident %d: %v", incident.ID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update incident"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, incident)
+}
+
+func (ctrl *Controller) incidentFromParam(ctx *gin.Context) (*models.Incident, bool) {
+	incidentID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid incident ID"})
+		return nil, false
+	}
+	incident, err := service.GetIncidentByID(ctrl.DB, uint(incidentID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return nil, false
+	}
+	return incident, true
+}
@@ -0,0 +1,373 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// defaultLaborRules are the configured overtime thresholds for caregivers without a rule set
+func (ctrl *Controller) defaultLaborRules() models.LaborRuleSet {
+	return models.LaborRuleSet{
+		Name:                        "default",
+		WeeklyOvertimeAfterMinutes:  int(ctrl.Config.LaborWeeklyOvertimeHours) * 60,
+		DailyOvertimeAfterMinutes:   int(ctrl.Config.LaborDailyOvertimeHours) * 60,
+		DailyDoubleTimeAfterMinutes: int(ctrl.Config.LaborDailyDoubleTimeHours) * 60,
+		IncludeTravel:               ctrl.Config.LaborIncludeTravel,
+	}
+}
+
+// computeLabor applies the caregiver's rule set to their visits between start and end
+func (ctrl *Controller) computeLabor(userID uint, start, end time.Time, opts service.LaborOptions) (*models.LaborBreakdown, error) {
+	rules, err := service.GetLaborRuleSet(ctrl.DB, userID, ctrl.defaultLaborRules())
+	if err != nil {
+		return nil, err
+	}
+	opts.SpeedKmh = float64(ctrl.Config.TravelSpeedKmh)
+	return service.ComputeLabor(ctrl.DB, userID, start, end, ctrl.agencyLocation(), rules, ctrl.breakRules(), opts)
+}
+
+// laborWarning reports the overtime a visit adds to its caregiver's workweek, comparing the
+// week's planned and worked hours with and without it. It returns nil when none is added.
+func (ctrl *Controller) laborWarning(schedule models.Schedule) (*models.LaborWarning, error) {
+	start, end := service.WorkweekFor(ctrl.payPeriodSettings(), schedule.ShiftTime)
+	opts := service.LaborOptions{IncludePlanned: true, ExcludeScheduleID: schedule.ID}
+	without, err := ctrl.computeLabor(schedule.UserID, start, end, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Extra = []models.Schedule{schedule}
+	with, err := ctrl.computeLabor(schedule.UserID, start, end, opts)
+	if err != nil {
+		return nil, err
+	}
+	return service.LaborWarningFor(without, with), nil
+}
+
+// laborPeriodFromQuery reads the pay period from ?date= (default today)
+func (ctrl *Controller) laborPeriodFromQuery(ctx *gin.Context) (time.Time, time.Time, bool) {
+	settings := ctrl.payPeriodSettings()
+	date := time.Now()
+	if value := ctx.Query("date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, settings.Location)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return time.Time{}, time.Time{}, false
+		}
+		date = parsed
+	}
+	start, end := service.PayPeriodFor(settings, date)
+	return start, end, true
+}
+
+// GetMyLaborBreakdown godoc
+// @Summary My overtime breakdown
+// @Description Regular, overtime and double-time minutes for the caregiver's pay period, including travel between consecutive visits on the same day. With include_planned=true, visits still scheduled are counted at their planned length.
+// @Tags Labor
+// @Security BearerAuth
+// @Produce json
+// @Param date query string false "Any date in the pay period (YYYY-MM-DD, agency timezone; default today)"
+// @Param include_planned query bool false "Project the period with scheduled visits"
+// @Success 200 {object} models.LaborBreakdown
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/labor [get]
+func (ctrl *Controller) GetMyLaborBreakdown(ctx *gin.Context) {
+	userID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	start, end, ok := ctrl.laborPeriodFromQuery(ctx)
+	if !ok {
+		return
+	}
+
+	opts := service.LaborOptions{IncludePlanned: ctx.Query("include_planned") == "true"}
+	breakdown, err := ctrl.computeLabor(uint(userID), start, end, opts)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to compute labor for user %d: %v", userID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute labor breakdown"})
+		return
+	}
+	ctx.JSON(http.StatusOK, breakdown)
+}
+
+// GetLaborBreakdowns godoc
+// @Summary Overtime breakdown per caregiver
+// @Description Regular, overtime and double-time minutes per caregiver for a pay period under each caregiver's rule set. Without user_id every caregiver is listed.
+// @Tags Labor
+// @Security BearerAuth
+// @Produce json
+// @Param user_id query int false "Caregiver ID"
+// @Param date query string false "Any date in the pay period (YYYY-MM-DD, agency timezone; default today)"
+// @Param include_planned query bool false "Project the period with scheduled visits"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/labor [get]
+func (ctrl *Controller) GetLaborBreakdowns(ctx *gin.Context) {
+	start, end, ok := ctrl.laborPeriodFromQuery(ctx)
+	if !ok {
+		return
+	}
+	var userIDs []uint
+	if value := ctx.Query("user_id"); value != "" {
+		userID, err := strconv.Atoi(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+		userIDs = []uint{uint(userID)}
+	} else {
+		ids, err := service.ListCaregiverIDs(ctrl.DB)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch caregivers"})
+			return
+		}
+		userIDs = ids
+	}
+
+	opts := service.LaborOptions{IncludePlanned: ctx.Query("include_planned") == "true"}
+	breakdowns := make([]models.LaborBreakdown, 0, len(userIDs))
+	for _, userID := range userIDs {
+		breakdown, err := ctrl.computeLabor(userID, start, end, opts)
+		if err != nil {
+			logger.ErrorLogger.Printf("Failed to compute labor for user %d: %v", userID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute labor breakdown"})
+			return
+		}
+		breakdowns = append(breakdowns, *breakdown)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"period_start": start, "period_end": end, "breakdowns": breakdowns})
+}
+
+// PreviewLaborAssignment godoc
+// @Summary Preview overtime for an assignment
+// @Description Show the overtime and double time a proposed visit would add to the caregiver's workweek before it is scheduled
+// @Tags Labor
+// @Security BearerAuth
+// @Produce json
+// @Param user_id query int true "Caregiver ID"
+// @Param shift_time query string true "Planned start (RFC3339)"
+// @Param duration_minutes query int false "Planned length (default 60)"
+// @Param client_id query int false "Client, used to estimate travel"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/labor/preview [get]
+func (ctrl *Controller) PreviewLaborAssignment(ctx *gin.Context) {
+	userID, err := strconv.Atoi(ctx.Query("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return
+	}
+	shiftTime, err := time.Parse(time.RFC3339, ctx.Query("shift_time"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shift_time, expected RFC3339"})
+		return
+	}
+	duration, err := strconv.Atoi(ctx.DefaultQuery("duration_minutes", "60"))
+	if err != nil || duration <= 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duration_minutes"})
+		return
+	}
+	proposed := models.Schedule{
+		UserID:          uint(userID),
+		ShiftTime:       shiftTime,
+		DurationMinutes: duration,
+		Status:          models.SCHEDULE_STATUS_SCHEDULED,
+	}
+	if value := ctx.Query("client_id"); value != "" {
+		clientID, err := strconv.Atoi(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client_id"})
+			return
+		}
+		id := uint(clientID)
+		proposed.ClientID = &id
+	}
+
+	warning, err := ctrl.laborWarning(proposed)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to preview labor for user %d: %v", userID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute labor breakdown"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"causes_overtime": warning != nil, "labor_warning": warning})
+}
+
+// ListLaborRuleSets godoc
+// @Summary List labor rule sets
+// @Description List the overtime rule sets caregivers can be assigned to
+// @Tags Labor
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/labor-rule-sets [get]
+func (ctrl *Controller) ListLaborRuleSets(ctx *gin.Context) {
+	sets, err := service.ListLaborRuleSets(ctrl.DB)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch labor rule sets"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"default": ctrl.defaultLaborRules(), "rule_sets": sets})
+}
+
+// CreateLaborRuleSet godoc
+// @Summary Create a labor rule set
+// @Description Create a set of overtime thresholds in minutes. A zero threshold disables that rule.
+// @Tags Labor
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.LaborRuleSetRequest true "Rule set"
+// @Success 201 {object} models.LaborRuleSet
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/labor-rule-sets [post]
+func (ctrl *Controller) CreateLaborRuleSet(ctx *gin.Context) {
+	var req models.LaborRuleSetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule set", "details": err.Error()})
+		return
+	}
+	var rules models.LaborRuleSet
+	if err := service.ApplyLaborRuleSetRequest(&rules, req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SaveLaborRuleSet(tx, &rules); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_LABOR_RULES, rules.ID, nil, rules)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to create labor rule set: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create labor rule set"})
+		return
+	}
+	ctx.JSON(http.StatusCreated, rules)
+}
+
+// UpdateLaborRuleSet godoc
+// @Summary Update a labor rule set
+// @Description Replace a rule set's thresholds. Breakdowns are computed on demand, so the change applies to every period.
+// @Tags Labor
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Rule set ID"
+// @Param request body models.LaborRuleSetRequest true "Rule set"
+// @Success 200 {object} models.LaborRuleSet
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/labor-rule-sets/{id} [put]
+func (ctrl *Controller) UpdateLaborRuleSet(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule set ID"})
+		return
+	}
+	rules, err := service.GetLaborRuleSetByID(ctrl.DB, uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Labor rule set not found"})
+		return
+	}
+	var req models.LaborRuleSetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule set", "details": err.Error()})
+		return
+	}
+	before := *rules
+	if err := service.ApplyLaborRuleSetRequest(rules, req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SaveLaborRuleSet(tx, rules); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_LABOR_RULES, rules.ID, before, rules)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to update labor rule set %d: %v", id, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update labor rule set"})
+		return
+	}
+	ctx.JSON(http.StatusOK, rules)
+}
+
+// AssignLaborRuleSet godoc
+// @Summary Assign a caregiver's labor rule set
+// @Description Put a caregiver on a rule set, or back on the configured defaults with a null labor_rule_set_id
+// @Tags Labor
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Caregiver ID"
+// @Param request body models.LaborRuleSetAssignment true "Rule set assignment"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/caregivers/{id}/labor-rule-set [put]
+func (ctrl *Controller) AssignLaborRuleSet(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid caregiver ID"})
+		return
+	}
+	user, err := service.GetUserByID(ctrl.DB, uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Caregiver not found"})
+		return
+	}
+	var req models.LaborRuleSetAssignment
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if req.LaborRuleSetID != nil {
+		if _, err := service.GetLaborRuleSetByID(ctrl.DB, *req.LaborRuleSetID); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Labor rule set not found"})
+			return
+		}
+	}
+
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.AssignLaborRuleSet(tx, user.ID, req.LaborRuleSetID); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_USER, user.ID,
+			gin.H{"labor_rule_set_id": user.LaborRuleSetID}, gin.H{"labor_rule_set_id": req.LaborRuleSetID})
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to assign labor rule set to user %d: %v", user.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign labor rule set"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Labor rule set assigned", "user_id": user.ID, "labor_rule_set_id": req.LaborRuleSetID})
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// appendVisitEvent records a visit lifecycle event in the tamper-evident ledger.
+// Call it with the visit change's transaction and return its error from the transaction,
+// so a change whose ledger event cannot be appended is rolled back rather than saved unrecorded.
+func (ctrl *Controller) appendVisitEvent(ctx *gin.Context, tx *gorm.DB, scheduleID uint, taskID *uint, eventType string, payload interface{}) error {
+	userID, _, _ := GetUserClaimsFromJWT(ctx)
+	if _, err := service.AppendVisitEvent(tx, scheduleID, taskID, eventType, uint(userID), payload); err != nil {
+		logger.ErrorLogger.Printf("Failed to append %s ledger event for schedule %d: %v", eventType, scheduleID, err)
+		return err
+	}
+	return nil
+}
+
+// parseLedgerFilter reads schedule_id, from and to query parameters
+func parseLedgerFilter(ctx *gin.Context) (models.LedgerFilter, bool) {
+	var filter models.LedgerFilter
+	loc := GetUserTimeZone(ctx)
+
+	if value := ctx.Query("schedule_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule_id"})
+			return filter, false
+		}
+		filter.ScheduleID = uint(id)
+	}
+
+	var err error
+	if filter.From, err = parseDateParam(ctx.Query("from"), loc, false); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return filter, false
+	}
+	if filter.To, err = parseDateParam(ctx.Query("to"), loc, true); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return filter, false
+	}
+	return filter, true
+}
+
+// GetVisitLedger godoc
+// @Summary List visit ledger entries
+// @Description List hash-chained EVV visit events for a schedule or date range (admin only)
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param schedule_id query int false "Schedule ID"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End date (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/ledger [get]
+func (ctrl *Controller) GetVisitLedger(ctx *gin.Context) {
+	filter, ok := parseLedgerFilter(ctx)
+	if !ok {
+		return
+	}
+	entries, err := service.GetVisitLedger(ctrl.DB, filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch visit ledger"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// VerifyVisitLedger godoc
+// @Summary Verify the visit ledger
+// @Description Walk the hash chain for a schedule or date range and report the first broken link (admin only)
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param schedule_id query int false "Schedule ID"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End date (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} models.LedgerVerification
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/ledger/verify [get]
+func (ctrl *Controller) VerifyVisitLedger(ctx *gin.Context) {
+	filter, ok := parseLedgerFilter(ctx)
+	if !ok {
+		return
+	}
+	result, err := service.VerifyVisitLedger(ctrl.DB, filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify visit ledger"})
+		return
+	}
+	if !result.Valid {
+		logger.ErrorLogger.Printf("Visit ledger verification failed at entry %d: %s", *result.BrokenEntryID, result.Reason)
+	}
+	ctx.JSON(http.StatusOK, result)
+}
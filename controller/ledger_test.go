@@ -0,0 +1,47 @@
+package controller_test
+
+import (
+	"caregiver-shift-tracker/models"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVisitChangeRolledBackWhenLedgerFails(t *testing.T) {
+	f := newVisitFixture(t)
+	started := time.Now().UTC().Add(-30 * time.Minute)
+	inProgress, task := f.visit(models.SCHEDULE_STATUS_IN_PROGRESS, &started)
+	if err := f.db.Migrator().DropTable(&models.VisitLedgerEntry{}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := f.request(http.MethodPost, fmt.Sprintf("/tasks/%d/update", task.ID), `{"status": "completed"}`)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("completing a task without a ledger: got %d %s, want 500", rec.Code, rec.Body.String())
+	}
+	rec = f.request(http.MethodPost, fmt.Sprintf("/api/user/schedules/%d/end", inProgress.ID), `{"latitude": 40.7, "longitude": -74.0}`)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("ending a visit without a ledger: got %d %s, want 500", rec.Code, rec.Body.String())
+	}
+
+	var saved models.Task
+	if err := f.db.First(&saved, task.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != models.TASK_STATUS_NOT_COMPLETED {
+		t.Errorf("task was marked %s without a ledger event", saved.Status)
+	}
+	var visit models.Schedule
+	if err := f.db.First(&visit, inProgress.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if visit.Status != models.SCHEDULE_STATUS_IN_PROGRESS || visit.EndTime != nil {
+		t.Errorf("visit was ended without a ledger event: status %s, end %v", visit.Status, visit.EndTime)
+	}
+	var audits int64
+	f.db.Model(&models.AuditLog{}).Count(&audits)
+	if audits != 0 {
+		t.Errorf("%d audit entries kept for rolled back changes", audits)
+	}
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (ctrl *Controller) geofenceSettings() service.GeofenceSettings {
+	return service.GeofenceSettings{
+		RadiusMeters: float64(ctrl.Config.GeofenceRadiusMeters),
+		MaxExcursion: time.Duration(ctrl.Config.GeofenceMaxExcursionMinutes) * time.Minute,
+	}
+}
+
+// RecordLocationPings godoc
+// @Summary Post location pings
+// @Description Record one or more GPS breadcrumbs for an in-progress visit (assigned caregiver only). Pings buffered offline may carry their own recorded_at; duplicates are ignored.
+// @Tags Locations
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param request body models.LocationPingRequest true "Location pings"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/locations [post]
+func (ctrl *Controller) RecordLocationPings(ctx *gin.Context) {
+	schedule, userID, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return
+	}
+	if schedule.UserID != uint(userID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Schedule not assigned to user"})
+		return
+	}
+
+	var req models.LocationPingRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location data", "details": err.Error()})
+		return
+	}
+
+	stored, err := service.RecordLocationPings(ctrl.DB, schedule, req.Pings, ctrl.geofenceSettings())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrPingVisitNotInProgress):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrPingInvalidCoordinates), errors.Is(err, service.ErrPingInvalidTime):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logger.ErrorLogger.Printf("Failed to record location pings for schedule %d: %v", schedule.ID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record location pings"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"received":         len(req.Pings),
+		"stored":           stored,
+		"geofence_flagged": schedule.GeofenceFlagged,
+	})
+}
+
+// GetGeofenceSummary godoc
+// @Summary Get geofence summary
+// @Description Time spent inside and outside the client geofence, and any extended excursions, based on the visit's breadcrumb trail (assigned caregiver or staff)
+// @Tags Locations
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} models.GeofenceSummary
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/locations/summary [get]
+func (ctrl *Controller) GetGeofenceSummary(ctx *gin.Context) {
+	schedule, _, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return
+	}
+	summary, _, err := service.GetGeofenceSummary(ctrl.DB, schedule, ctrl.geofenceSettings())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location pings"})
+		return
+	}
+	ctx.JSON(http.StatusOK, summary)
+}
+
+// GetLocationTrailGeoJSON godoc
+// @Summary Export the visit trail as GeoJSON
+// @Description GeoJSON FeatureCollection of the visit's breadcrumb trail: a LineString of the path, a Point per ping and the geofence center (assigned caregiver or staff)
+// @Tags Locations
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} models.GeoJSONFeatureCollection
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/locations/geojson [get]
+func (ctrl *Controller) GetLocationTrailGeoJSON(ctx *gin.Context) {
+	schedule, _, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return
+	}
+	summary, pings, err := service.GetGeofenceSummary(ctrl.DB, schedule, ctrl.geofenceSettings())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location pings"})
+		return
+	}
+	ctx.Header("Content-Type", "application/geo+json")
+	ctx.JSON(http.StatusOK, service.BuildTrailGeoJSON(schedule, pings, summary))
+}
+
+// ListGeofenceFlaggedVisits godoc
+// @Summary List visits flagged by the geofence
+// @Description List visits where the caregiver left the client geofence for longer than the allowed time
+// @Tags Locations
+// @Security BearerAuth
+// @Produce json
+// @Param from query string false "Shift date from (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Shift date to (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/geofence/flagged [get]
+func (ctrl *Controller) ListGeofenceFlaggedVisits(ctx *gin.Context) {
+	loc := GetUserTimeZone(ctx)
+	from, err := parseDateParam(ctx.Query("from"), loc, false)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return
+	}
+	to, err := parseDateParam(ctx.Query("to"), loc, true)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return
+	}
+
+	schedules, err := service.ListGeofenceFlaggedSchedules(ctrl.DB, from, to)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch flagged visits"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
@@ -0,0 +1,382 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateMedicationOrder godoc
+// @Summary Add a medication order
+// @Description Add a medication (drug, dose, route, daily HH:MM times) for a client. Doses are planned as medication tasks on every upcoming visit whose window covers one of the times.
+// @Tags Medications
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Client ID"
+// @Param request body models.MedicationOrderRequest true "Medication order"
+// @Success 201 {object} models.MedicationOrder
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/clients/{id}/medications [post]
+func (ctrl *Controller) CreateMedicationOrder(ctx *gin.Context) {
+	userID, _, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	clientID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+	if _, err := service.GetClientByID(ctrl.DB, uint(clientID)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	var req models.MedicationOrderRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid medication order", "details": err.Error()})
+		return
+	}
+	order := models.MedicationOrder{ClientID: uint(clientID), Active: true, CreatedBy: uint(userID)}
+	if err := service.BuildMedicationOrder(&order, req, GetUserTimeZone(ctx)); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.CreateMedicationOrder(tx, &order); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_MED_ORDER, order.ID, nil, order)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to create medication order: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create medication order"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, order)
+}
+
+// ListMedicationOrders godoc
+// @Summary List a client's medication orders
+// @Description List a client's medication orders, active first
+// @Tags Medications
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Client ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/clients/{id}/medications [get]
+func (ctrl *Controller) ListMedicationOrders(ctx *gin.Context) {
+	clientID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+	orders, err := service.ListMedicationOrders(ctrl.DB, uint(clientID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch medication orders"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"medications": orders})
+}
+
+// UpdateMedicationOrder godoc
+// @Summary Update a medication order
+// @Description Change a medication order. Pending doses on visits that have not started are re-planned.
+// @Tags Medications
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Medication order ID"
+// @Param request body models.MedicationOrderRequest true "Medication order"
+// @Success 200 {object} models.MedicationOrder
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/medications/{id} [put]
+func (ctrl *Controller) UpdateMedicationOrder(ctx *gin.Context) {
+	before, ok := ctrl.medicationOrderFromParam(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.MedicationOrderRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid medication order", "details": err.Error()})
+		return
+	}
+	order := *before
+	if err := service.BuildMedicationOrder(&order, req, GetUserTimeZone(ctx)); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.UpdateMedicationOrder(tx, &order); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_MED_ORDER, order.ID, before, order)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to update medication order %d: %v", order.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update medication order"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, order)
+}
+
+// DiscontinueMedicationOrder godoc
+// @Summary Discontinue a medication order
+// @Description Stop a medication. Pending doses on visits that have not started are removed.
+// @Tags Medications
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Medication order ID"
+// @Success 200 {object} models.MedicationOrder
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/medications/{id}/discontinue [post]
+func (ctrl *Controller) DiscontinueMedicationOrder(ctx *gin.Context) {
+	before, ok := ctrl.medicationOrderFromParam(ctx)
+	if !ok {
+		return
+	}
+	order := *before
+	order.Active = false
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.UpdateMedicationOrder(tx, &order); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_MED_ORDER, order.ID, before, order)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to discontinue medication order %d: %v", order.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discontinue medication order"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, order)
+}
+
+// GetMARGrid godoc
+// @Summary Monthly medication administration record
+// @Description A client's MAR for one month: a row per medication and time, a cell per day. format=html returns a printable page.
+// @Tags Medications
+// @Security BearerAuth
+// @Produce json
+// @Produce html
+// @Param id path int true "Client ID"
+// @Param month query string false "Month as YYYY-MM (default current month)"
+// @Param format query string false "json (default) or html"
+// @Success 200 {object} models.MARGrid
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/clients/{id}/mar [get]
+func (ctrl *Controller) GetMARGrid(ctx *gin.Context) {
+	clientID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+	client, err := service.GetClientByID(ctrl.DB, uint(clientID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	loc := GetUserTimeZone(ctx)
+	month := time.Now().In(loc)
+	if value := ctx.Query("month"); value != "" {
+		if month, err = time.ParseInLocation("2006-01", value, loc); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month, expected YYYY-MM"})
+			return
+		}
+	}
+
+	grid, err := service.BuildMARGrid(ctrl.DB, client, month, loc)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to build MAR for client %d: %v", client.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build medication administration record"})
+		return
+	}
+
+	if ctx.Query("format") == "html" {
+		page, err := service.RenderMARGridHTML(grid)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render medication administration record"})
+			return
+		}
+		ctx.Data(http.StatusOK, "text/html; charset=UTF-8", page)
+		return
+	}
+	ctx.JSON(http.StatusOK, grid)
+}
+
+// GetVisitMedications godoc
+// @Summary Doses planned on a visit
+// @Description List the medication doses planned on a visit and their status (assigned caregiver or staff)
+// @Tags Medications
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/medications [get]
+func (ctrl *Controller) GetVisitMedications(ctx *gin.Context) {
+	schedule, _, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return
+	}
+	administrations, err := service.GetScheduleAdministrations(ctrl.DB, schedule.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch medications"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"administrations": administrations})
+}
+
+// RecordMedicationAdministration godoc
+// @Summary Record a dose
+// @Description Record a planned dose as given, refused or held (refused and held need a reason). The matching medication task is updated too. Restricted to the assigned caregiver once the visit has started.
+// @Tags Medications
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Administration ID"
+// @Param request body models.MedicationAdministrationRequest true "Outcome"
+// @Success 200 {object} models.MedicationAdministration
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/medication-administrations/{id} [post]
+func (ctrl *Controller) RecordMedicationAdministration(ctx *gin.Context) {
+	userID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	administrationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid administration ID"})
+		return
+	}
+	administration, err := service.GetAdministrationByID(ctrl.DB, uint(administrationID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Medication administration not found"})
+		return
+	}
+	schedule, err := service.GetScheduleByID(ctrl.DB, administration.ScheduleID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+	if schedule.UserID != uint(userID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Schedule not assigned to user"})
+		return
+	}
+	if schedule.StartTime == nil {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Start the visit before recording medications"})
+		return
+	}
+	if !ctrl.ensureVisitUnlocked(ctx, schedule) {
+		return
+	}
+
+	var req models.MedicationAdministrationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	before := *administration
+	task, _ := service.GetTaskByID(ctrl.DB, administration.TaskID)
+	if task != nil && req.Status == models.MED_ADMIN_STATUS_GIVEN && !ctrl.checkPrerequisites(ctx, task) {
+		return
+	}
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.RecordAdministration(tx, administration, req, uint(userID)); err != nil {
+			return err
+		}
+		if err := ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_STATUS, models.AUDIT_ENTITY_MED_ADMIN, administration.ID, before, administration); err != nil {
+			return err
+		}
+		if task != nil {
+			if err := ctrl.auditTaskChange(ctx, tx, models.AUDIT_ACTION_STATUS, task); err != nil {
+				return err
+			}
+		}
+		taskID := administration.TaskID
+		return ctrl.appendVisitEvent(ctx, tx, schedule.ID, &taskID, models.LEDGER_EVENT_MEDICATION, gin.H{
+			"administration_id": administration.ID,
+			"drug_name":         administration.Order.DrugName,
+			"dose":              administration.Order.Dose,
+			"route":             administration.Order.Route,
+			"scheduled_for":     administration.ScheduledFor,
+			"status":            administration.Status,
+			"reason":            administration.Reason,
+			"administered_at":   administration.AdministeredAt,
+		})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAdministrationRecorded):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrAdministrationReason):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logger.ErrorLogger.Printf("Failed to record medication administration %d: %v", administration.ID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record medication administration"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, administration)
+}
+
+func (ctrl *Controller) medicationOrderFromParam(ctx *gin.Context) (*models.MedicationOrder, bool) {
+	orderID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid medication order ID"})
+		return nil, false
+	}
+	order, err := service.GetMedicationOrderByID(ctrl.DB, uint(orderID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Medication order not found"})
+		return nil, false
+	}
+	return order, true
+}
@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SetObservationFields godoc
+// @Summary Set a template's observation fields
+// @Description Replace the structured fields (number with unit, boolean, choice or text) captured when a task made from this template is updated, with validation ranges and alert thresholds
+// @Tags Observations
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param request body models.ObservationFieldsRequest true "Fields"
+// @Success 200 {object} models.TaskTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/task-templates/{id}/observation-fields [put]
+func (ctrl *Controller) SetObservationFields(ctx *gin.Context) {
+	templateID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+	before, err := service.GetTaskTemplateByID(ctrl.DB, uint(templateID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Task template not found"})
+		return
+	}
+
+	var req models.ObservationFieldsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid observation fields", "details": err.Error()})
+		return
+	}
+
+	var after *models.TaskTemplate
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := service.SetObservationFields(tx, before.ID, req.Fields); err != nil {
+			return err
+		}
+		var err error
+		if after, err = service.GetTaskTemplateByID(tx, before.ID); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_TEMPLATE, after.ID, before, after)
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrObservationInvalid) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save observation fields"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, after)
+}
+
+// GetClientObservations godoc
+// @Summary Client observation history
+// @Description List observations recorded for a client across visits, newest first
+// @Tags Observations
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Client ID"
+// @Param key query string false "Field key, e.g. systolic"
+// @Param from query string false "From date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "To date (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/clients/{id}/observations [get]
+func (ctrl *Controller) GetClientObservations(ctx *gin.Context) {
+	clientID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+	filter := models.ObservationFilter{ClientID: uint(clientID), Key: ctx.Query("key")}
+	loc := GetUserTimeZone(ctx)
+	if filter.From, err = parseDateParam(ctx.Query("from"), loc, false); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return
+	}
+	if filter.To, err = parseDateParam(ctx.Query("to"), loc, true); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return
+	}
+
+	observations, err := service.ListObservations(ctrl.DB, filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch observations"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"observations": observations})
+}
+
+// GetScheduleObservations godoc
+// @Summary Visit observations
+// @Description List observations recorded during a visit (assigned caregiver or staff)
+// @Tags Observations
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/schedules/{id}/observations [get]
+func (ctrl *Controller) GetScheduleObservations(ctx *gin.Context) {
+	schedule, _, ok := ctrl.accessibleScheduleFromParam(ctx)
+	if !ok {
+		return
+	}
+	observations, err := service.GetScheduleObservations(ctrl.DB, schedule.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch observations"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"observations": observations})
+}
+
+// ListAlerts godoc
+// @Summary List alerts
+// @Description List alerts raised from visit data, newest first
+// @Tags Alerts
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "open or acknowledged"
+// @Param type query string false "Alert type, e.g. observation"
+// @Param client_id query int false "Client ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/alerts [get]
+func (ctrl *Controller) ListAlerts(ctx *gin.Context) {
+	filter := models.AlertFilter{Status: ctx.Query("status"), Type: ctx.Query("type")}
+	if value := ctx.Query("client_id"); value != "" {
+		clientID, err := strconv.Atoi(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client_id"})
+			return
+		}
+		filter.ClientID = uint(clientID)
+	}
+
+	alerts, err := service.ListAlerts(ctrl.DB, filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
+
+// AcknowledgeAlert godoc
+// @Summary Acknowledge an alert
+// @Description Mark an open alert as handled, with an optional note
+// @Tags Alerts
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Alert ID"
+// @Param request body models.AlertAcknowledgeRequest false "Note"
+// @Success 200 {object} models.Alert
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/alerts/{id}/acknowledge [post]
+func (ctrl *Controller) AcknowledgeAlert(ctx *gin.Context) {
+	userID, _, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	alertID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+	alert, err := service.GetAlertByID(ctrl.DB, uint(alertID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+
+	var req models.AlertAcknowledgeRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+	}
+
+	before := *alert
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.AcknowledgeAlert(tx, alert, uint(userID), req.Note); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_ALERT, alert.ID, before, alert)
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrAlertAlreadyAcknowledged) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge alert"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, alert)
+}
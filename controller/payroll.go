@@ -0,0 +1,347 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loadPayrollLayout resolves a payroll layout by name, writing the error response if it cannot
+func (ctrl *Controller) loadPayrollLayout(ctx *gin.Context, name string) (models.PayrollLayout, bool) {
+	layouts, err := service.LoadPayrollLayouts(ctrl.Config.PayrollLayoutFile)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to load payroll layouts: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load payroll layouts"})
+		return models.PayrollLayout{}, false
+	}
+	layout, ok := layouts[strings.ToUpper(name)]
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": service.ErrPayrollUnknownLayout.Error()})
+		return models.PayrollLayout{}, false
+	}
+	return layout, true
+}
+
+func (ctrl *Controller) payrollOptions() service.PayrollOptions {
+	return service.PayrollOptions{
+		CompanyCode:          ctrl.Config.PayrollCompanyCode,
+		OvertimeMultiplier:   ctrl.Config.PayrollOvertimeMultiplier,
+		DoubleTimeMultiplier: ctrl.Config.PayrollDoubleTimeMultiplier,
+		MileageRate:          ctrl.Config.PayrollMileageRate,
+		Location:             ctrl.agencyLocation(),
+		DefaultRules:         ctrl.defaultLaborRules(),
+		SpeedKmh:             float64(ctrl.Config.TravelSpeedKmh),
+	}
+}
+
+// GetPayrollLayouts godoc
+// @Summary List payroll layouts
+// @Description List the payroll file layouts configured on the server
+// @Tags Payroll
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/payroll/layouts [get]
+func (ctrl *Controller) GetPayrollLayouts(ctx *gin.Context) {
+	layouts, err := service.LoadPayrollLayouts(ctrl.Config.PayrollLayoutFile)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load payroll layouts", "details": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"layouts": layouts})
+}
+
+// CreatePayrollExport godoc
+// @Summary Generate a payroll file
+// @Description Export the approved timesheets of a pay period that are not already in a batch. Each caregiver gets regular, overtime and double-time hours under their labor rule set, pay rates, mileage between visits and a visit count. Timesheets that are not approved are listed as skipped.
+// @Tags Payroll
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.PayrollExportRequest true "Layout and any date in the pay period"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/payroll/exports [post]
+func (ctrl *Controller) CreatePayrollExport(ctx *gin.Context) {
+	userID, _, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.PayrollExportRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	layout, ok := ctrl.loadPayrollLayout(ctx, req.Layout)
+	if !ok {
+		return
+	}
+	settings := ctrl.payPeriodSettings()
+	date, err := time.ParseInLocation("2006-01-02", req.Date, settings.Location)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+	start, end := service.PayPeriodFor(settings, date)
+
+	var batch *models.PayrollBatch
+	var skipped []service.PayrollSkipped
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if batch, skipped, err = service.CreatePayrollBatch(tx, layout, start, end, ctrl.payrollOptions(), uint(userID)); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_PAYROLL, batch.ID, nil, batch)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrPayrollNoTimesheets):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "skipped": skipped})
+		case errors.Is(err, service.ErrPayrollAlreadyExported):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "skipped": skipped})
+		default:
+			logger.ErrorLogger.Printf("Failed to create payroll export: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payroll export"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"batch": batch, "skipped": skipped})
+}
+
+// ListPayrollExports godoc
+// @Summary List payroll batches
+// @Description List generated payroll files, newest first
+// @Tags Payroll
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "exported or voided"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/payroll/exports [get]
+func (ctrl *Controller) ListPayrollExports(ctx *gin.Context) {
+	batches, err := service.ListPayrollBatches(ctrl.DB, ctx.Query("status"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payroll exports"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"batches": batches})
+}
+
+// GetPayrollExport godoc
+// @Summary Get a payroll batch
+// @Description Fetch a batch with its per-caregiver earnings lines and the visits it paid
+// @Tags Payroll
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Batch ID"
+// @Success 200 {object} models.PayrollBatch
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/payroll/exports/{id} [get]
+func (ctrl *Controller) GetPayrollExport(ctx *gin.Context) {
+	batch, ok := ctrl.payrollBatchFromParam(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, batch)
+}
+
+// DownloadPayrollExport godoc
+// @Summary Download a payroll file
+// @Description Download the file generated for a batch. Downloading again returns the same file; it never recalculates.
+// @Tags Payroll
+// @Security BearerAuth
+// @Produce octet-stream
+// @Param id path int true "Batch ID"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/payroll/exports/{id}/file [get]
+func (ctrl *Controller) DownloadPayrollExport(ctx *gin.Context) {
+	batch, ok := ctrl.payrollBatchFromParam(ctx)
+	if !ok {
+		return
+	}
+	filename := fmt.Sprintf("payroll-%s-%s.csv", strings.ToLower(batch.Layout), batch.BatchCode)
+	ctx.Header("Content-Disposition", "attachment; filename="+filename)
+	ctx.Data(http.StatusOK, "text/csv; charset=UTF-8", []byte(batch.Content))
+}
+
+// VoidPayrollExport godoc
+// @Summary Void a payroll batch
+// @Description Mark a batch as not processed by payroll so its timesheets can be exported again. The batch and its visit records are kept.
+// @Tags Payroll
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Batch ID"
+// @Param request body models.PayrollVoidRequest true "Reason"
+// @Success 200 {object} models.PayrollBatch
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/admin/payroll/exports/{id}/void [post]
+func (ctrl *Controller) VoidPayrollExport(ctx *gin.Context) {
+	userID, _, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	batch, ok := ctrl.payrollBatchFromParam(ctx)
+	if !ok {
+		return
+	}
+	var req models.PayrollVoidRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	before := *batch
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.VoidPayrollBatch(tx, batch, uint(userID), req.Reason); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_STATUS, models.AUDIT_ENTITY_PAYROLL, batch.ID, before, batch)
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrPayrollBatchVoided) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		logger.ErrorLogger.Printf("Failed to void payroll batch %d: %v", batch.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to void payroll export"})
+		return
+	}
+	ctx.JSON(http.StatusOK, batch)
+}
+
+// ListPayrollVisits godoc
+// @Summary Payroll history of visits
+// @Description List which payroll batches included a visit or a caregiver's visits
+// @Tags Payroll
+// @Security BearerAuth
+// @Produce json
+// @Param schedule_id query int false "Schedule ID"
+// @Param user_id query int false "Caregiver ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/payroll/visits [get]
+func (ctrl *Controller) ListPayrollVisits(ctx *gin.Context) {
+	var scheduleID, userID int
+	if value := ctx.Query("schedule_id"); value != "" {
+		var err error
+		if scheduleID, err = strconv.Atoi(value); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule_id"})
+			return
+		}
+	}
+	if value := ctx.Query("user_id"); value != "" {
+		var err error
+		if userID, err = strconv.Atoi(value); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+	}
+
+	visits, err := service.ListPayrollBatchVisits(ctrl.DB, uint(scheduleID), uint(userID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payroll visits"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"visits": visits})
+}
+
+// SetPayrollProfile godoc
+// @Summary Set a caregiver's pay
+// @Description Set the hourly rate and payroll-system employee ID used in payroll exports. Overtime and double-time rates are derived from the hourly rate.
+// @Tags Payroll
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Caregiver ID"
+// @Param request body models.PayrollProfileRequest true "Pay settings"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/caregivers/{id}/payroll-profile [put]
+func (ctrl *Controller) SetPayrollProfile(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid caregiver ID"})
+		return
+	}
+	user, err := service.GetUserByID(ctrl.DB, uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Caregiver not found"})
+		return
+	}
+	var req models.PayrollProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	before := gin.H{"hourly_rate": user.HourlyRate, "payroll_employee_id": user.PayrollEmployeeID}
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SetPayrollProfile(tx, user, req); err != nil {
+			return err
+		}
+		after := gin.H{"hourly_rate": user.HourlyRate, "payroll_employee_id": user.PayrollEmployeeID}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_USER, user.ID, before, after)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to set payroll profile for user %d: %v", user.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payroll profile"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"user_id": user.ID, "hourly_rate": user.HourlyRate, "payroll_employee_id": user.PayrollEmployeeID})
+}
+
+func (ctrl *Controller) payrollBatchFromParam(ctx *gin.Context) (*models.PayrollBatch, bool) {
+	batchID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
+		return nil, false
+	}
+	batch, err := service.GetPayrollBatch(ctrl.DB, uint(batchID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Payroll export not found"})
+		return nil, false
+	}
+	return batch, true
+}
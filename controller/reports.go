@@ -0,0 +1,297 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"caregiver-shift-tracker/utils"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// reportOptions are the report settings from configuration, the same ones the scheduler uses
+func (ctrl *Controller) reportOptions() service.ReportOptions {
+	return service.ReportOptions{
+		AgencyName:   ctrl.Config.AgencyName,
+		OnTimeGrace:  time.Duration(ctrl.Config.OnTimeGraceMinutes) * time.Minute,
+		MileageRate:  ctrl.Config.PayrollMileageRate,
+		MaxAttempts:  int(ctrl.Config.ReportMaxAttempts),
+		RetryBackoff: time.Duration(ctrl.Config.ReportRetryBackoffMinutes) * time.Minute,
+		Send:         utils.SendEmailMessage,
+	}
+}
+
+// ListReportSubscriptions godoc
+// @Summary List report subscriptions
+// @Description Scheduled report emails with their cadence and next run
+// @Tags Reports
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/report-subscriptions [get]
+func (ctrl *Controller) ListReportSubscriptions(ctx *gin.Context) {
+	subs, err := service.ListReportSubscriptions(ctrl.DB)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch report subscriptions"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"subscriptions": subs})
+}
+
+// CreateReportSubscription godoc
+// @Summary Create a report subscription
+// @Description Email a report (visits, tasks, missed_trend, travel or authorization_utilization) as a PDF or CSV attachment on a cron cadence ("minute hour day month weekday", or @daily, @weekly, @monthly) read in the subscription's timezone. Each run covers the previous day, week (Monday to Sunday) or month.
+// @Tags Reports
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.ReportSubscriptionRequest true "Subscription"
+// @Success 201 {object} models.ReportSubscription
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/report-subscriptions [post]
+func (ctrl *Controller) CreateReportSubscription(ctx *gin.Context) {
+	userID, _, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	sub := models.ReportSubscription{CreatedBy: uint(userID)}
+	if !ctrl.bindReportSubscription(ctx, &sub) {
+		return
+	}
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SaveReportSubscription(tx, &sub); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_REPORT, sub.ID, nil, sub)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to create report subscription: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create report subscription"})
+		return
+	}
+	ctx.JSON(http.StatusCreated, sub)
+}
+
+// UpdateReportSubscription godoc
+// @Summary Update a report subscription
+// @Description Replace a subscription's settings. The next run is worked out again from now; set active to false to pause it.
+// @Tags Reports
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param request body models.ReportSubscriptionRequest true "Subscription"
+// @Success 200 {object} models.ReportSubscription
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/report-subscriptions/{id} [put]
+func (ctrl *Controller) UpdateReportSubscription(ctx *gin.Context) {
+	sub, ok := ctrl.reportSubscriptionFromParam(ctx)
+	if !ok {
+		return
+	}
+	before := *sub
+	if !ctrl.bindReportSubscription(ctx, sub) {
+		return
+	}
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SaveReportSubscription(tx, sub); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_REPORT, sub.ID, before, sub)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report subscription"})
+		return
+	}
+	ctx.JSON(http.StatusOK, sub)
+}
+
+// DeleteReportSubscription godoc
+// @Summary Delete a report subscription
+// @Description Stop a scheduled report and remove its delivery history
+// @Tags Reports
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/report-subscriptions/{id} [delete]
+func (ctrl *Controller) DeleteReportSubscription(ctx *gin.Context) {
+	sub, ok := ctrl.reportSubscriptionFromParam(ctx)
+	if !ok {
+		return
+	}
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.DeleteReportSubscription(tx, sub); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_REPORT, sub.ID, sub, nil)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete report subscription"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Report subscription deleted"})
+}
+
+// ListReportDeliveries godoc
+// @Summary Report delivery history
+// @Description A subscription's deliveries, newest first, with their status (pending, sent, retrying or failed), attempts and last error
+// @Tags Reports
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param limit query int false "Number of deliveries (default 50, max 200)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/report-subscriptions/{id}/deliveries [get]
+func (ctrl *Controller) ListReportDeliveries(ctx *gin.Context) {
+	sub, ok := ctrl.reportSubscriptionFromParam(ctx)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+	deliveries, err := service.ListReportDeliveries(ctrl.DB, sub.ID, limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch report deliveries"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"subscription": sub, "deliveries": deliveries})
+}
+
+// RunReportSubscription godoc
+// @Summary Send a report now
+// @Description Deliver a subscription's report straight away for the period ending before today, without moving its schedule. A failed send is recorded and retried like a scheduled one.
+// @Tags Reports
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 201 {object} models.ReportDelivery
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 502 {object} map[string]interface{}
+// @Router /api/admin/report-subscriptions/{id}/run [post]
+func (ctrl *Controller) RunReportSubscription(ctx *gin.Context) {
+	sub, ok := ctrl.reportSubscriptionFromParam(ctx)
+	if !ok {
+		return
+	}
+	delivery, err := service.RunReportSubscription(ctrl.DB, sub, time.Now(), ctrl.reportOptions())
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusCreated, delivery)
+	case errors.Is(err, service.ErrReportNotSent):
+		logger.ErrorLogger.Printf("Report delivery %d for subscription %d failed: %v", delivery.ID, sub.ID, err)
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send report", "delivery": delivery})
+	default:
+		logger.ErrorLogger.Printf("Failed to run report subscription %d: %v", sub.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run report subscription"})
+	}
+}
+
+// RetryReportDelivery godoc
+// @Summary Retry a report delivery
+// @Description Attempt a failed or retrying delivery again now, for its original period and recipients
+// @Tags Reports
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Delivery ID"
+// @Success 200 {object} models.ReportDelivery
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 502 {object} map[string]interface{}
+// @Router /api/admin/report-deliveries/{id}/retry [post]
+func (ctrl *Controller) RetryReportDelivery(ctx *gin.Context) {
+	deliveryID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+	delivery, err := service.GetReportDeliveryByID(ctrl.DB, uint(deliveryID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Report delivery not found"})
+		return
+	}
+
+	err = service.RetryReportDelivery(ctrl.DB, delivery, time.Now(), ctrl.reportOptions())
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, delivery)
+	case errors.Is(err, service.ErrReportDeliverySent), errors.Is(err, service.ErrReportDeliveryInProgress):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrReportNotSent):
+		logger.ErrorLogger.Printf("Retry of report delivery %d failed: %v", delivery.ID, err)
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send report", "delivery": delivery})
+	default:
+		logger.ErrorLogger.Printf("Failed to retry report delivery %d: %v", delivery.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry report delivery"})
+	}
+}
+
+// bindReportSubscription reads the request onto sub, writing a 400 and returning false when
+// it is invalid
+func (ctrl *Controller) bindReportSubscription(ctx *gin.Context, sub *models.ReportSubscription) bool {
+	var req models.ReportSubscriptionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report subscription", "details": err.Error()})
+		return false
+	}
+	if err := service.ApplyReportSubscriptionRequest(sub, req, ctrl.Config.AgencyTimezone, time.Now()); err != nil {
+		if errors.Is(err, service.ErrInvalidReportSubscription) || errors.Is(err, service.ErrInvalidCadence) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read report subscription"})
+		return false
+	}
+	return true
+}
+
+func (ctrl *Controller) reportSubscriptionFromParam(ctx *gin.Context) (*models.ReportSubscription, bool) {
+	subID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return nil, false
+	}
+	sub, err := service.GetReportSubscriptionByID(ctrl.DB, uint(subID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Report subscription not found"})
+		return nil, false
+	}
+	return sub, true
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/routing"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PreviewRoute godoc
+// @Summary Preview an optimized route
+// @Description Propose an order and start times for a caregiver's visits on a day that minimizes travel while starting each visit between its earliest_start and latest_start (default shift_time ± ROUTE_DEFAULT_WINDOW_MINUTES), and compare it with the current plan. Nothing is saved.
+// @Tags Routes
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.RoutePreviewRequest true "Caregiver, day and optional candidate visits"
+// @Success 200 {object} models.RoutePlan
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/routes/preview [post]
+func (ctrl *Controller) PreviewRoute(ctx *gin.Context) {
+	var req models.RoutePreviewRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	day, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+	if (req.StartLat == nil) != (req.StartLon == nil) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "start_lat and start_lon must be given together"})
+		return
+	}
+
+	schedules, err := service.LoadRouteCandidates(ctrl.DB, req.UserID, day, ctrl.agencyLocation(), req.ScheduleIDs)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch visits"})
+		return
+	}
+	if len(req.ScheduleIDs) > 0 && len(schedules) != len(req.ScheduleIDs) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Every schedule must be a scheduled visit of this caregiver"})
+		return
+	}
+	clients, err := service.LoadVisitClients(ctrl.DB, schedules)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch clients"})
+		return
+	}
+
+	fallback := routing.NewStraightLine(float64(ctrl.Config.TravelSpeedKmh))
+	opts := service.RouteOptions{
+		Provider:      ctrl.Routing,
+		Fallback:      fallback,
+		DefaultWindow: time.Duration(ctrl.Config.RouteDefaultWindowMinutes) * time.Minute,
+	}
+	if opts.Provider == nil {
+		opts.Provider = fallback
+	}
+	if req.StartLat != nil {
+		opts.Origin = &routing.Point{Lat: *req.StartLat, Lon: *req.StartLon}
+	}
+
+	plan, err := service.OptimizeRoute(ctx.Request.Context(), schedules, clients, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRouteNoVisits):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrRouteTooManyVisits):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logger.ErrorLogger.Printf("Failed to optimize route for user %d: %v", req.UserID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to optimize route"})
+		}
+		return
+	}
+	plan.UserID = req.UserID
+	plan.Date = req.Date
+	ctx.JSON(http.StatusOK, plan)
+}
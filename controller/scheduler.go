@@ -4,20 +4,24 @@ import (
 	"caregiver-shift-tracker/logger"
 	"caregiver-shift-tracker/models"
 	"caregiver-shift-tracker/service"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // CreateSchedule godoc
 // @Summary Create a schedule
-// @Description Create a new schedule for a caregiver
+// @Description Create a new schedule for a caregiver. Tasks from the client's active care plan that apply on the shift's weekday are added automatically unless apply_care_plan=false. The response carries labor_warning when the visit adds overtime and authorization_warning when it exceeds or falls outside the client's authorized units.
 // @Tags Schedules
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param request body models.Schedule true "Schedule Info"
+// @Param apply_care_plan query bool false "Instantiate tasks from the client's active care plan (default true)"
 // @Success 201 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
@@ -40,18 +44,68 @@ func (ctrl *Controller) CreateSchedule(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Shift start time must be before end time"})
 		return
 	}
+	if req.EarliestStart != nil && req.LatestStart != nil && req.EarliestStart.After(*req.LatestStart) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "earliest_start must not be after latest_start"})
+		return
+	}
+
+	if req.ClientID != nil {
+		client, err := service.GetClientByID(ctrl.DB, *req.ClientID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Client not found"})
+			return
+		}
+		if req.ClientName == "" {
+			req.ClientName = client.FullName
+		}
+		if req.Location == "" {
+			req.Location = client.Address
+		}
+	}
 
-	if err := service.CreateSchedule(ctrl.DB, &req); err != nil {
+	applyCarePlan := ctx.DefaultQuery("apply_care_plan", "true") != "false"
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.CreateSchedule(tx, &req, applyCarePlan, GetUserTimeZone(ctx)); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_SCHEDULE, req.ID, nil, req)
+	})
+	if err != nil {
 		logger.ErrorLogger.Printf("Failed to create schedule: %v", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schedule", "details": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{
-		"message":     "Schedule created successfully",
-		"schedule_id": req.ID,
-		"user_id":     req.UserID,
-	})
+	carePlanTasks, medicationTasks := 0, 0
+	for _, task := range req.Tasks {
+		if task.TaskTemplateID != nil {
+			carePlanTasks++
+		}
+		if task.Kind == models.TASK_KIND_MEDICATION {
+			medicationTasks++
+		}
+	}
+
+	response := gin.H{
+		"message":          "Schedule created successfully",
+		"schedule_id":      req.ID,
+		"user_id":          req.UserID,
+		"care_plan_tasks":  carePlanTasks,
+		"medication_tasks": medicationTasks,
+	}
+	// Overtime and exceeded authorizations do not block scheduling; the warnings let the
+	// scheduler reconsider
+	if warning, err := ctrl.laborWarning(req); err != nil {
+		logger.ErrorLogger.Printf("Failed to check overtime for schedule %d: %v", req.ID, err)
+	} else if warning != nil {
+		response["labor_warning"] = warning
+	}
+	if warning, err := service.AuthorizationWarningFor(ctrl.DB, &req, ctrl.agencyLocation()); err != nil {
+		logger.ErrorLogger.Printf("Failed to check authorization for schedule %d: %v", req.ID, err)
+	} else if warning != nil {
+		response["authorization_warning"] = warning
+	}
+	ctx.JSON(http.StatusCreated, response)
 }
 
 // GetAllSchedules godoc
@@ -116,7 +170,7 @@ func (ctrl *Controller) GetTodaySchedules(ctx *gin.Context) {
 // @Security BearerAuth
 // @Produce json
 // @Param id path int true "Schedule ID"
-// @Success 200 {object} models.Schedule "Schedule details"
+// @Success 200 {object} models.Schedule "Schedule details, including break segments and worked/break time"
 // @Failure 400 {object} map[string]string "Invalid ID"
 // @Failure 401 {object} map[string]string
 // @Failure 403 {object} map[string]string
@@ -143,6 +197,7 @@ func (ctrl *Controller) GetScheduleDetails(ctx *gin.Context) {
 		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Schedule not assigned to user"})
 		return
 	}
+	schedule.TimeSummary = service.SummarizeVisitTime(schedule, schedule.Breaks, ctrl.breakRules(), time.Now())
 	ctx.JSON(http.StatusOK, schedule)
 }
 
@@ -159,6 +214,7 @@ func (ctrl *Controller) GetScheduleDetails(ctx *gin.Context) {
 // @Failure 400 {object} map[string]string "Invalid request"
 // @Failure 401 {object} map[string]string
 // @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string "Visit is not scheduled or is on an approved timesheet"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /api/user/schedules/{id}/start [post]
 func (ctrl *Controller) StartVisit(ctx *gin.Context) {
@@ -202,8 +258,28 @@ func (ctrl *Controller) StartVisit(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid latitude or longitude"})
 		return
 	}
+	if schedule.Status != models.SCHEDULE_STATUS_SCHEDULED {
+		ctx.JSON(http.StatusConflict, gin.H{"error": service.ErrVisitNotScheduled.Error()})
+		return
+	}
+	now := time.Now()
+	if !ctrl.ensureVisitUnlocked(ctx, schedule, &now) {
+		return
+	}
 
-	err = service.StartVisit(ctrl.DB, uint(id), req.Latitude, req.Longitude)
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.StartVisit(tx, uint(id), req.Latitude, req.Longitude); err != nil {
+			return err
+		}
+		if err := ctrl.auditScheduleChange(ctx, tx, models.AUDIT_ACTION_VISIT_START, schedule); err != nil {
+			return err
+		}
+		return ctrl.appendVisitEvent(ctx, tx, schedule.ID, nil, models.LEDGER_EVENT_VISIT_START, req)
+	})
+	if errors.Is(err, service.ErrVisitNotScheduled) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start visit"})
 		return
@@ -214,17 +290,18 @@ func (ctrl *Controller) StartVisit(ctx *gin.Context) {
 
 // EndVisit godoc
 // @Summary End visit
-// @Description End a visit for a specific schedule by ID
+// @Description End a visit for a specific schedule by ID, optionally capturing client and caregiver signatures (base64 PNG/JPEG image or vector strokes). Under a task completion policy, checkout is refused with the list of unresolved tasks unless each task is completed or has a reason, or a supervisor override token is supplied.
 // @Tags Schedules
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param id path int true "Schedule ID"
-// @Param request body models.VisitLocationRequest true "End location coordinates"
+// @Param request body models.EndVisitRequest true "End location coordinates and signatures"
 // @Success 200 {object} map[string]string "Visit ended"
 // @Failure 400 {object} map[string]string "Invalid request"
 // @Failure 401 {object} map[string]string
 // @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string "Visit is not in progress, is paused, has unresolved tasks or is on an approved timesheet"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /api/user/schedules/{id}/end [post]
 func (ctrl *Controller) EndVisit(ctx *gin.Context) {
@@ -248,23 +325,136 @@ func (ctrl *Controller) EndVisit(ctx *gin.Context) {
 		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Schedule not assigned to user"})
 		return
 	}
-	var req models.VisitLocationRequest
+	if schedule.Status != models.SCHEDULE_STATUS_IN_PROGRESS {
+		ctx.JSON(http.StatusConflict, gin.H{"error": service.ErrVisitNotInProgress.Error()})
+		return
+	}
+	if service.OpenBreak(schedule.Breaks) != nil {
+		ctx.JSON(http.StatusConflict, gin.H{"error": service.ErrVisitOnBreak.Error()})
+		return
+	}
+	now := time.Now()
+	if !ctrl.ensureVisitUnlocked(ctx, schedule, &now) {
+		return
+	}
+	var req models.EndVisitRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location data"})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location data", "details": err.Error()})
 		return
 	}
 	if req.Latitude < -90 || req.Latitude > 90 || req.Longitude < -180 || req.Longitude > 180 {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid latitude or longitude"})
 		return
 	}
-	err = service.EndVisit(ctrl.DB, uint(id), req.Latitude, req.Longitude)
+
+	signedAt := time.Now()
+	signatures := make([]models.VisitSignature, 0, len(req.Signatures))
+	hasClientSignature := false
+	for _, input := range req.Signatures {
+		sig, err := service.BuildVisitSignature(schedule.ID, input, signedAt)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature", "details": err.Error()})
+			return
+		}
+		if sig.SignerType == models.SIGNER_TYPE_CLIENT {
+			hasClientSignature = true
+		}
+		signatures = append(signatures, *sig)
+	}
+	if ctrl.Config != nil && ctrl.Config.RequireClientSignature && !hasClientSignature {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "A client or representative signature is required to end this visit"})
+		return
+	}
+	override, ok := ctrl.checkTaskCompletion(ctx, schedule, req.OverrideToken)
+	if !ok {
+		return
+	}
+
+	signatureHashes := make([]gin.H, 0, len(signatures))
+	for _, sig := range signatures {
+		signatureHashes = append(signatureHashes, gin.H{"signer_type": sig.SignerType, "signer_name": sig.SignerName, "hash": sig.Hash})
+	}
+	event := gin.H{
+		"latitude":   req.Latitude,
+		"longitude":  req.Longitude,
+		"signatures": signatureHashes,
+	}
+	if override != nil {
+		event["completion_override_id"] = override.ID
+		event["unresolved_tasks"] = service.UnresolvedTasks(schedule.Tasks)
+	}
+
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.EndVisit(tx, uint(id), req.Latitude, req.Longitude, signatures, override); err != nil {
+			return err
+		}
+		if err := ctrl.auditScheduleChange(ctx, tx, models.AUDIT_ACTION_VISIT_END, schedule); err != nil {
+			return err
+		}
+		return ctrl.appendVisitEvent(ctx, tx, schedule.ID, nil, models.LEDGER_EVENT_VISIT_END, event)
+	})
 	if err != nil {
+		if errors.Is(err, service.ErrOverrideInvalid) || errors.Is(err, service.ErrVisitNotInProgress) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end visit"})
 		return
 	}
+	ctrl.afterVisitChange(schedule)
+
 	ctx.JSON(http.StatusOK, gin.H{"message": "Visit ended"})
 }
 
+// GetVisitSignatureImage godoc
+// @Summary Download a signature image
+// @Description Download the image of a signature captured at visit end (assigned caregiver or staff)
+// @Tags Schedules
+// @Security BearerAuth
+// @Produce png
+// @Produce jpeg
+// @Param id path int true "Schedule ID"
+// @Param signatureId path int true "Signature ID"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/user/schedules/{id}/signatures/{signatureId}/image [get]
+func (ctrl *Controller) GetVisitSignatureImage(ctx *gin.Context) {
+	userID, roleID, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	scheduleID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+	signatureID, err := strconv.Atoi(ctx.Param("signatureId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature ID"})
+		return
+	}
+	schedule, err := service.GetScheduleByID(ctrl.DB, uint(scheduleID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+	if !canAccessSchedule(userID, roleID, schedule) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Schedule not assigned to user"})
+		return
+	}
+	sig, err := service.GetVisitSignature(ctrl.DB, schedule.ID, uint(signatureID))
+	if err != nil || sig.Format != models.SIGNATURE_FORMAT_IMAGE {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Signature image not found"})
+		return
+	}
+	ctx.Header("X-Signature-Hash", sig.Hash)
+	ctx.Data(http.StatusOK, sig.ContentType, sig.ImageData)
+}
+
 // GetUpcomingSchedules godoc
 // @Summary Get upcoming schedules
 // @Description Fetch all upcoming schedules for the authenticated caregiver (from today onward)
@@ -355,6 +545,7 @@ func (ctrl *Controller) GetTodayCompletedSchedules(ctx *gin.Context) {
 // @Failure 401 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/user/schedules/{id}/cancel-start [post]
 func (ctrl *Controller) CancelStartVisit(ctx *gin.Context) {
@@ -381,8 +572,31 @@ func (ctrl *Controller) CancelStartVisit(ctx *gin.Context) {
 		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Schedule not assigned to user"})
 		return
 	}
+	if schedule.Status != models.SCHEDULE_STATUS_IN_PROGRESS {
+		ctx.JSON(http.StatusConflict, gin.H{"error": service.ErrVisitNotInProgress.Error()})
+		return
+	}
+	if !ctrl.ensureVisitUnlocked(ctx, schedule) {
+		return
+	}
 
-	err = service.CancelStartVisit(ctrl.DB, uint(scheduleID))
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.CancelStartVisit(tx, uint(scheduleID)); err != nil {
+			return err
+		}
+		if err := ctrl.auditScheduleChange(ctx, tx, models.AUDIT_ACTION_VISIT_CANCEL, schedule); err != nil {
+			return err
+		}
+		return ctrl.appendVisitEvent(ctx, tx, schedule.ID, nil, models.LEDGER_EVENT_VISIT_CANCEL_START, gin.H{
+			"start_time": schedule.StartTime,
+			"start_lat":  schedule.StartLat,
+			"start_lon":  schedule.StartLon,
+		})
+	})
+	if errors.Is(err, service.ErrVisitNotInProgress) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel clock-in"})
 		return
@@ -419,7 +633,7 @@ func (ctrl *Controller) FetchSchedulesWithTasks(ctx *gin.Context) {
 
 // UpdateScheduleStatus godoc
 // @Summary Update schedule status
-// @Description Update the status of a specific schedule for the authenticated caregiver
+// @Description Mark a visit assigned to the authenticated caregiver as scheduled, cancelled or missed. Visits are started and completed through the start and end endpoints.
 // @Tags Schedules
 // @Security BearerAuth
 // @Accept json
@@ -430,6 +644,7 @@ func (ctrl *Controller) FetchSchedulesWithTasks(ctx *gin.Context) {
 // @Failure 400 {object} map[string]string "Invalid request or ID"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Access denied"
+// @Failure 409 {object} map[string]string "Visit is on an approved timesheet"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /api/user/schedules/{id}/status [put]
 func (ctrl *Controller) UpdateScheduleStatus(ctx *gin.Context) {
@@ -462,12 +677,56 @@ func (ctrl *Controller) UpdateScheduleStatus(ctx *gin.Context) {
 		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Schedule not assigned to you"})
 		return
 	}
+	if !ctrl.ensureVisitUnlocked(ctx, schedule) {
+		return
+	}
 
-	err = service.UpdateScheduleStatus(ctrl.DB, userID, uint(scheduleID), req.Status)
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.UpdateScheduleStatus(tx, userID, uint(scheduleID), req.Status); err != nil {
+			return err
+		}
+		return ctrl.auditScheduleChange(ctx, tx, models.AUDIT_ACTION_STATUS, schedule)
+	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update schedule status"})
 		return
 	}
+	ctrl.afterVisitChange(schedule)
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "Schedule status updated successfully"})
 }
+
+// auditScheduleChange records the schedule as it was before the request against its state in tx
+func (ctrl *Controller) auditScheduleChange(ctx *gin.Context, tx *gorm.DB, action string, before *models.Schedule) error {
+	after, err := service.GetScheduleByID(tx, before.ID)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to reload schedule %d for audit: %v", before.ID, err)
+		return err
+	}
+	return ctrl.recordAudit(ctx, tx, action, models.AUDIT_ENTITY_SCHEDULE, before.ID, before, after)
+}
+
+// accessibleScheduleFromParam loads the schedule in the :id param and checks the caller is its caregiver or staff,
+// writing the error response if not
+func (ctrl *Controller) accessibleScheduleFromParam(ctx *gin.Context) (*models.Schedule, int, bool) {
+	userID, roleID, err := GetUserClaimsFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return nil, 0, false
+	}
+	scheduleID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return nil, 0, false
+	}
+	schedule, err := service.GetScheduleByID(ctrl.DB, uint(scheduleID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return nil, 0, false
+	}
+	if !canAccessSchedule(userID, roleID, schedule) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Schedule not assigned to user"})
+		return nil, 0, false
+	}
+	return schedule, userID, true
+}
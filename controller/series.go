@@ -0,0 +1,314 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxSeriesGenerateWeeks caps how far ahead one request may generate a series' visits
+const maxSeriesGenerateWeeks = 26
+
+// CreateVisitSeries godoc
+// @Summary Create a recurring visit series
+// @Description Create a client's recurring visit on some days of the week (sun..sat, all days when empty) at a start time in the agency timezone. The capacity forecast counts its visits as demand until they are generated.
+// @Tags Visit Series
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Client ID"
+// @Param request body models.VisitSeriesRequest true "Series"
+// @Success 201 {object} models.VisitSeries
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/clients/{id}/visit-series [post]
+func (ctrl *Controller) CreateVisitSeries(ctx *gin.Context) {
+	userID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	clientID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+	client, err := service.GetClientByID(ctrl.DB, uint(clientID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	var req models.VisitSeriesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series data", "details": err.Error()})
+		return
+	}
+	if !ctrl.validSeriesCaregiver(ctx, req.UserID) {
+		return
+	}
+	series := models.VisitSeries{ClientID: client.ID, Active: true, CreatedBy: uint(userID)}
+	if err := service.BuildVisitSeries(&series, req, ctrl.agencyLocation()); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SaveVisitSeries(tx, &series); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_VISIT_SERIES, series.ID, nil, series)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to create visit series: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create visit series"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, series)
+}
+
+// ListClientVisitSeries godoc
+// @Summary List a client's visit series
+// @Description List a client's recurring visit series, the active ones first
+// @Tags Visit Series
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Client ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/clients/{id}/visit-series [get]
+func (ctrl *Controller) ListClientVisitSeries(ctx *gin.Context) {
+	clientID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+	series, err := service.ListVisitSeries(ctrl.DB, uint(clientID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch visit series"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"visit_series": series})
+}
+
+// GetVisitSeries godoc
+// @Summary Get a visit series
+// @Description Fetch a recurring visit series
+// @Tags Visit Series
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Series ID"
+// @Success 200 {object} models.VisitSeries
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/visit-series/{id} [get]
+func (ctrl *Controller) GetVisitSeries(ctx *gin.Context) {
+	series, ok := ctrl.loadVisitSeries(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, series)
+}
+
+// UpdateVisitSeries godoc
+// @Summary Update a visit series
+// @Description Change a recurring visit series. Visits already generated from it are not changed.
+// @Tags Visit Series
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Series ID"
+// @Param request body models.VisitSeriesRequest true "Series"
+// @Success 200 {object} models.VisitSeries
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/visit-series/{id} [put]
+func (ctrl *Controller) UpdateVisitSeries(ctx *gin.Context) {
+	before, ok := ctrl.loadVisitSeries(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.VisitSeriesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series data", "details": err.Error()})
+		return
+	}
+	if !ctrl.validSeriesCaregiver(ctx, req.UserID) {
+		return
+	}
+	series := *before
+	if err := service.BuildVisitSeries(&series, req, ctrl.agencyLocation()); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SaveVisitSeries(tx, &series); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_VISIT_SERIES, series.ID, before, series)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update visit series"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, series)
+}
+
+// DeleteVisitSeries godoc
+// @Summary Delete a visit series
+// @Description Delete a recurring visit series. Visits already generated from it stay scheduled.
+// @Tags Visit Series
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Series ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/visit-series/{id} [delete]
+func (ctrl *Controller) DeleteVisitSeries(ctx *gin.Context) {
+	before, ok := ctrl.loadVisitSeries(ctx)
+	if !ok {
+		return
+	}
+
+	err := ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.DeleteVisitSeries(tx, before); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_VISIT_SERIES, before.ID, before, nil)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete visit series"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Visit series deleted"})
+}
+
+// GenerateVisitSeries godoc
+// @Summary Generate a series' visits
+// @Description Schedule the series' visits from today up to and including the given date (at most 26 weeks ahead) for its caregiver, applying the client's care plan and medications. Days that already have a visit from the series are skipped, so generating again is safe.
+// @Tags Visit Series
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Series ID"
+// @Param request body models.VisitSeriesGenerateRequest true "Last day to generate"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/visit-series/{id}/generate [post]
+func (ctrl *Controller) GenerateVisitSeries(ctx *gin.Context) {
+	series, ok := ctrl.loadVisitSeries(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.VisitSeriesGenerateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	loc := ctrl.agencyLocation()
+	through, err := time.ParseInLocation("2006-01-02", req.Through, loc)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "through must be YYYY-MM-DD"})
+		return
+	}
+	now := time.Now()
+	to := through.AddDate(0, 0, 1)
+	if !to.After(now) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "through must not be in the past"})
+		return
+	}
+	if to.After(now.AddDate(0, 0, 7*maxSeriesGenerateWeeks)) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Visits can be generated at most 26 weeks ahead"})
+		return
+	}
+
+	var schedules []models.Schedule
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if schedules, err = service.GenerateSeriesVisits(tx, series, now, to, loc); err != nil {
+			return err
+		}
+		for _, schedule := range schedules {
+			if err := ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_SCHEDULE, schedule.ID, nil, schedule); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrSeriesInactive) || errors.Is(err, service.ErrSeriesUnassigned) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		logger.ErrorLogger.Printf("Failed to generate visits for series %d: %v", series.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate visits"})
+		return
+	}
+	scheduleIDs := make([]uint, 0, len(schedules))
+	for _, schedule := range schedules {
+		scheduleIDs = append(scheduleIDs, schedule.ID)
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"series_id": series.ID, "generated": len(scheduleIDs), "schedule_ids": scheduleIDs})
+}
+
+// loadVisitSeries fetches the series named in the path, writing the error response on failure
+func (ctrl *Controller) loadVisitSeries(ctx *gin.Context) (*models.VisitSeries, bool) {
+	seriesID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series ID"})
+		return nil, false
+	}
+	series, err := service.GetVisitSeriesByID(ctrl.DB, uint(seriesID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Visit series not found"})
+		return nil, false
+	}
+	return series, true
+}
+
+// validSeriesCaregiver checks that a series' caregiver, if any, is a caregiver account
+func (ctrl *Controller) validSeriesCaregiver(ctx *gin.Context, userID *uint) bool {
+	if userID == nil {
+		return true
+	}
+	user, err := service.GetUserByID(ctrl.DB, *userID)
+	if err != nil || user.RoleID != models.ROLE_CAREGIVER {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "user_id must be a caregiver"})
+		return false
+	}
+	return true
+}
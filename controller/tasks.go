@@ -9,31 +9,49 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // CreateTask godoc
 // @Summary Create a new task
-// @Description Creates a task for a caregiver schedule
+// @Description Creates a general task for a caregiver schedule
 // @Tags Tasks
 // @Accept json
 // @Produce json
-// @Param request body models.Task true "Task Info"
+// @Param request body models.TaskCreateRequest true "Task Info"
 // @Success 201 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /tasks [post]
 func (ctrl *Controller) CreateTask(ctx *gin.Context) {
-	var req models.Task
+	var req models.TaskCreateRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		logger.RespondRaw(ctx, http.StatusBadRequest, gin.H{"error": "Invalid task data", "details": err.Error()})
 		return
 	}
-	if err := service.CreateTask(ctrl.DB, &req); err != nil {
+	schedule, err := service.GetScheduleByID(ctrl.DB, req.ScheduleID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+	if !ctrl.ensureVisitUnlocked(ctx, schedule) {
+		return
+	}
+	task := newGeneralTask(schedule.ID, req)
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.CreateTask(tx, &task); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_TASK, task.ID, nil, task)
+	})
+	if err != nil {
 		logger.ErrorLogger.Printf("Failed to create task: %v", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task", "details": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusCreated, gin.H{"message": "Task created successfully", "task_id": req.ID})
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Task created successfully", "task_id": task.ID})
 }
 
 // AssignTasksToSchedule godoc
@@ -44,10 +62,12 @@ func (ctrl *Controller) CreateTask(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Schedule ID"
-// @Param request body object{tasks=[]models.Task} true "List of Tasks"
+// @Param request body object{tasks=[]models.TaskCreateRequest} true "List of Tasks"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /tasks/assign/{id} [post]
 func (ctrl *Controller) AssignTasksToSchedule(ctx *gin.Context) {
@@ -65,14 +85,36 @@ func (ctrl *Controller) AssignTasksToSchedule(ctx *gin.Context) {
 	}
 
 	var req struct {
-		Tasks []models.Task `json:"tasks" validate:"required,dive,required"`
+		Tasks []models.TaskCreateRequest `json:"tasks" binding:"required,dive"`
 	}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task data", "details": err.Error()})
 		return
 	}
+	schedule, err := service.GetScheduleByID(ctrl.DB, uint(scheduleID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+	if !ctrl.ensureVisitUnlocked(ctx, schedule) {
+		return
+	}
 
-	err = service.AssignTasksToSchedule(ctrl.DB, uint(scheduleID), req.Tasks)
+	tasks := make([]models.Task, len(req.Tasks))
+	for i, item := range req.Tasks {
+		tasks[i] = newGeneralTask(schedule.ID, item)
+	}
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.AssignTasksToSchedule(tx, schedule.ID, tasks); err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if err := ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_TASK, task.ID, nil, task); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign tasks", "details": err.Error()})
 		return
@@ -81,14 +123,26 @@ func (ctrl *Controller) AssignTasksToSchedule(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "Tasks assigned successfully"})
 }
 
+// newGeneralTask builds the not yet completed general task a create request describes
+func newGeneralTask(scheduleID uint, req models.TaskCreateRequest) models.Task {
+	return models.Task{
+		ScheduleID:  scheduleID,
+		Description: req.Description,
+		Status:      models.TASK_STATUS_NOT_COMPLETED,
+		Kind:        models.TASK_KIND_GENERAL,
+	}
+}
+
 // DeleteTask godoc
 // @Summary Delete a task
-// @Description Deletes a task by its ID
+// @Description Deletes a task by its ID. Medication tasks are planned from orders and cannot be deleted here.
 // @Tags Tasks
 // @Produce json
 // @Param id path int true "Task ID"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /tasks/{id} [delete]
 func (ctrl *Controller) DeleteTask(ctx *gin.Context) {
@@ -98,7 +152,29 @@ func (ctrl *Controller) DeleteTask(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
 		return
 	}
-	err = service.DeleteTask(ctrl.DB, uint(taskID))
+	before, err := service.GetTaskByID(ctrl.DB, uint(taskID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	if before.Kind == models.TASK_KIND_MEDICATION {
+		ctx.JSON(http.StatusConflict, gin.H{"error": service.ErrMedicationTask.Error()})
+		return
+	}
+	schedule, err := service.GetScheduleByID(ctrl.DB, before.ScheduleID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+	if !ctrl.ensureVisitUnlocked(ctx, schedule) {
+		return
+	}
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.DeleteTask(tx, uint(taskID)); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_TASK, uint(taskID), before, nil)
+	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task", "details": err.Error()})
 		return
@@ -108,18 +184,19 @@ func (ctrl *Controller) DeleteTask(ctx *gin.Context) {
 
 // UpdateTask godoc
 // @Summary Update a task
-// @Description Updates task details by its ID, restricted to the assigned caregiver
+// @Description Updates task details by its ID, restricted to the assigned caregiver. Tasks created from a template with required observations must be completed through /tasks/{taskId}/update.
 // @Tags Tasks
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param id path int true "Task ID"
-// @Param request body models.Task true "Updated task data"
+// @Param request body models.TaskUpdateRequest true "Updated description, status and reason"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /tasks/{id} [put]
 func (ctrl *Controller) UpdateTask(ctx *gin.Context) {
@@ -134,7 +211,7 @@ func (ctrl *Controller) UpdateTask(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
 		return
 	}
-	var req models.Task
+	var req models.TaskUpdateRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task data", "details": err.Error()})
 		return
@@ -156,12 +233,56 @@ func (ctrl *Controller) UpdateTask(ctx *gin.Context) {
 		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Task not assigned to user"})
 		return
 	}
-	req.ID = uint(taskID)
+	if !ctrl.ensureVisitUnlocked(ctx, schedule) {
+		return
+	}
+	before, err := service.GetTaskByID(ctrl.DB, uint(taskID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	if before.Kind == models.TASK_KIND_MEDICATION {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": service.ErrMedicationTask.Error()})
+		return
+	}
+	if req.Status == models.TASK_STATUS_COMPLETED && !ctrl.checkPrerequisites(ctx, before) {
+		return
+	}
+	// This body carries no observations, so a template task can only be completed here
+	// when none of its observation fields are required
+	if req.Status == models.TASK_STATUS_COMPLETED && before.TaskTemplateID != nil {
+		fields, err := service.GetObservationFields(ctrl.DB, *before.TaskTemplateID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load observation fields"})
+			return
+		}
+		if _, err := service.BuildTaskObservations(fields, nil, before, schedule, uint(userID), time.Now(), true); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error() + "; record observations through /tasks/{taskId}/update"})
+			return
+		}
+	}
+	task := *before
+	if req.Description != "" {
+		task.Description = req.Description
+	}
+	task.Status, task.Reason, task.CompletedAt, task.CompletionTiming = req.Status, req.Reason, nil, ""
 	if req.Status == models.TASK_STATUS_COMPLETED {
 		now := time.Now()
-		req.CompletedAt = &now
+		task.CompletedAt = &now
+		task.CompletionTiming = service.CompletionTiming(before, now)
 	}
-	err = service.UpdateTask(ctrl.DB, &req)
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.UpdateTask(tx, &task); err != nil {
+			return err
+		}
+		if err := ctrl.auditTaskChange(ctx, tx, models.AUDIT_ACTION_UPDATE, before); err != nil {
+			return err
+		}
+		if before.Status == req.Status {
+			return nil
+		}
+		return ctrl.appendTaskStatusEvent(ctx, tx, schedule.ID, uint(taskID), req.Status, req.Reason, task.CompletedAt)
+	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task", "details": err.Error()})
 		return
@@ -171,18 +292,19 @@ func (ctrl *Controller) UpdateTask(ctx *gin.Context) {
 
 // UpdateTaskStatus godoc
 // @Summary Update task status
-// @Description Updates the status of a task (completed or not_completed with reason), restricted to the assigned caregiver
+// @Description Updates the status of a task (completed or not_completed with reason), restricted to the assigned caregiver. Tasks created from a template can carry structured observations (e.g. blood pressure); required fields must be given when completing, and values crossing the template's thresholds raise alerts. A task cannot be completed before its prerequisites, and completing a timed task reports whether it was early, on time or late.
 // @Tags Tasks
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param taskId path int true "Task ID"
-// @Param request body object{status=string,reason=string} true "Task status and optional reason"
-// @Success 200 {object} map[string]string
+// @Param request body models.TaskStatusRequest true "Task status, optional reason and observations"
+// @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /tasks/{taskId}/update [post]
 func (ctrl *Controller) UpdateTaskStatus(ctx *gin.Context) {
@@ -197,10 +319,7 @@ func (ctrl *Controller) UpdateTaskStatus(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
 		return
 	}
-	var req struct {
-		Status string  `json:"status" validate:"required,oneof=completed not_completed"`
-		Reason *string `json:"reason"`
-	}
+	var req models.TaskStatusRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
 		return
@@ -222,15 +341,98 @@ func (ctrl *Controller) UpdateTaskStatus(ctx *gin.Context) {
 		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Task not assigned to user"})
 		return
 	}
+	if !ctrl.ensureVisitUnlocked(ctx, schedule) {
+		return
+	}
+	before, err := service.GetTaskByID(ctrl.DB, uint(taskID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	if before.Kind == models.TASK_KIND_MEDICATION {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": service.ErrMedicationTask.Error()})
+		return
+	}
+	if req.Status == models.TASK_STATUS_COMPLETED && !ctrl.checkPrerequisites(ctx, before) {
+		return
+	}
+	now := time.Now()
 	var completedAt *time.Time
+	completionTiming := ""
 	if req.Status == models.TASK_STATUS_COMPLETED {
-		now := time.Now()
 		completedAt = &now
+		completionTiming = service.CompletionTiming(before, now)
 	}
-	err = service.UpdateTaskStatus(ctrl.DB, uint(taskID), req.Status, req.Reason, completedAt)
+
+	var fields []models.ObservationField
+	if before.TaskTemplateID != nil {
+		if fields, err = service.GetObservationFields(ctrl.DB, *before.TaskTemplateID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load observation fields"})
+			return
+		}
+	}
+	observations, err := service.BuildTaskObservations(fields, req.Observations, before, schedule, uint(userID), now, req.Status == models.TASK_STATUS_COMPLETED)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var alerts []models.Alert
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if alerts, err = service.SaveTaskStatus(tx, before, req.Status, req.Reason, completedAt, fields, observations); err != nil {
+			return err
+		}
+		if err := ctrl.auditTaskChange(ctx, tx, models.AUDIT_ACTION_STATUS, before); err != nil {
+			return err
+		}
+		return ctrl.appendTaskStatusEvent(ctx, tx, schedule.ID, uint(taskID), req.Status, req.Reason, completedAt)
+	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task status", "details": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"message": "Task status updated"})
+	for _, alert := range alerts {
+		logger.InfoLogger.Printf("Observation alert %d raised for schedule %d: %s", alert.ID, schedule.ID, alert.Message)
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":           "Task status updated",
+		"completion_timing": completionTiming,
+		"observations":      observations,
+		"alerts":            alerts,
+	})
+}
+
+// checkPrerequisites writes a 409 listing the blocking tasks when the task's prerequisites are
+// not all completed, and reports whether the task may be completed
+func (ctrl *Controller) checkPrerequisites(ctx *gin.Context, task *models.Task) bool {
+	blocking, err := service.CheckPrerequisites(ctrl.DB, task)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task prerequisites"})
+		return false
+	}
+	if len(blocking) > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"error": service.ErrPrerequisitesPending.Error(), "blocking_tasks": blocking})
+		return false
+	}
+	return true
+}
+
+// auditTaskChange records the task as it was before the request against its state in tx
+func (ctrl *Controller) auditTaskChange(ctx *gin.Context, tx *gorm.DB, action string, before *models.Task) error {
+	after, err := service.GetTaskByID(tx, before.ID)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to reload task %d for audit: %v", before.ID, err)
+		return err
+	}
+	return ctrl.recordAudit(ctx, tx, action, models.AUDIT_ENTITY_TASK, before.ID, before, after)
+}
+
+// appendTaskStatusEvent records a task status change in the visit ledger within tx
+func (ctrl *Controller) appendTaskStatusEvent(ctx *gin.Context, tx *gorm.DB, scheduleID, taskID uint, status string, reason *string, completedAt *time.Time) error {
+	return ctrl.appendVisitEvent(ctx, tx, scheduleID, &taskID, models.LEDGER_EVENT_TASK_STATUS, gin.H{
+		"status":       status,
+		"reason":       reason,
+		"completed_at": completedAt,
+	})
 }
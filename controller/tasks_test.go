@@ -0,0 +1,65 @@
+package controller_test
+
+import (
+	"caregiver-shift-tracker/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCreatedTasksAreGeneral(t *testing.T) {
+	f := newVisitFixture(t)
+	started := time.Now().UTC().Add(-30 * time.Minute)
+	visit, existing := f.visit(models.SCHEDULE_STATUS_IN_PROGRESS, &started)
+	extra := fmt.Sprintf(`"kind": "medication", "task_template_id": 7, "prerequisite_ids": [%d], "status": "completed"`, existing.ID)
+
+	rec := f.request(http.MethodPost, "/tasks/", fmt.Sprintf(`{"schedule_id": %d, "description": "Fold laundry", %s}`, visit.ID, extra))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create task: got %d %s, want 201", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		TaskID uint `json:"task_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	rec = f.request(http.MethodPost, fmt.Sprintf("/tasks/assign/%d", visit.ID), fmt.Sprintf(`{"tasks": [{"description": "Water plants", %s}]}`, extra))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("assign tasks: got %d %s, want 200", rec.Code, rec.Body.String())
+	}
+
+	var tasks []models.Task
+	if err := f.db.Where("schedule_id = ? AND id <> ?", visit.ID, existing.ID).Order("id").Find(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[0].ID != created.TaskID {
+		t.Fatalf("got tasks %+v, want the created and the assigned task", tasks)
+	}
+	for _, task := range tasks {
+		if task.Kind != models.TASK_KIND_GENERAL || task.TaskTemplateID != nil || len(task.PrerequisiteIDs) != 0 ||
+			task.Status != models.TASK_STATUS_NOT_COMPLETED {
+			t.Errorf("task %q took fields from the request: kind %s, template %v, prerequisites %v, status %s",
+				task.Description, task.Kind, task.TaskTemplateID, task.PrerequisiteIDs, task.Status)
+		}
+	}
+}
+
+func TestDeleteTaskRefusesMedicationTasks(t *testing.T) {
+	f := newVisitFixture(t)
+	visit, _ := f.visit(models.SCHEDULE_STATUS_SCHEDULED, nil)
+	dose := models.Task{ScheduleID: visit.ID, Description: "Metformin 500mg", Status: models.TASK_STATUS_NOT_COMPLETED,
+		Kind: models.TASK_KIND_MEDICATION}
+	if err := f.db.Create(&dose).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rec := f.request(http.MethodDelete, fmt.Sprintf("/tasks/%d", dose.ID), "")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("deleting a medication task: got %d %s, want 409", rec.Code, rec.Body.String())
+	}
+	if err := f.db.First(&models.Task{}, dose.ID).Error; err != nil {
+		t.Fatalf("medication task was deleted: %v", err)
+	}
+}
@@ -0,0 +1,337 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// payPeriodAnchorDefault is a Monday, so weekly periods run Monday to Sunday unless configured
+const payPeriodAnchorDefault = "2024-01-01"
+
+func (ctrl *Controller) payPeriodSettings() models.PayPeriodSettings {
+	loc := ctrl.agencyLocation()
+	anchorValue := ctrl.Config.PayPeriodAnchor
+	if anchorValue == "" {
+		anchorValue = payPeriodAnchorDefault
+	}
+	anchor, err := time.ParseInLocation("2006-01-02", anchorValue, loc)
+	if err != nil {
+		logger.ErrorLogger.Printf("Invalid PAY_PERIOD_ANCHOR %q, using %s: %v", anchorValue, payPeriodAnchorDefault, err)
+		anchor, _ = time.ParseInLocation("2006-01-02", payPeriodAnchorDefault, loc)
+	}
+	periodType := models.PAY_PERIOD_WEEKLY
+	if ctrl.Config.PayPeriodType == models.PAY_PERIOD_BIWEEKLY {
+		periodType = models.PAY_PERIOD_BIWEEKLY
+	}
+	mode := ctrl.Config.TimesheetRoundingMode
+	if mode != models.ROUNDING_UP && mode != models.ROUNDING_DOWN {
+		mode = models.ROUNDING_NEAREST
+	}
+	return models.PayPeriodSettings{
+		Type:            periodType,
+		Anchor:          anchor,
+		Location:        loc,
+		RoundingMinutes: int(ctrl.Config.TimesheetRoundingMinutes),
+		RoundingMode:    mode,
+	}
+}
+
+// ensureVisitUnlocked writes a 409 and returns false when an approved timesheet covers the visit
+func (ctrl *Controller) ensureVisitUnlocked(ctx *gin.Context, schedule *models.Schedule, proposed ...*time.Time) bool {
+	err := service.EnsureVisitUnlocked(ctrl.DB, schedule, proposed...)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, service.ErrTimesheetLocked) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return false
+	}
+	logger.ErrorLogger.Printf("Failed to check timesheet lock for schedule %d: %v", schedule.ID, err)
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check timesheet lock"})
+	return false
+}
+
+// GetMyTimesheet godoc
+// @Summary Timesheet for a pay period
+// @Description The caregiver's timesheet for the pay period containing the given date, built from completed visits with rounded clock times and unpaid breaks deducted. Open timesheets are refreshed on every read.
+// @Tags Timesheets
+// @Security BearerAuth
+// @Produce json
+// @Param date query string false "Any date in the pay period (YYYY-MM-DD, agency timezone; default today)"
+// @Success 200 {object} models.Timesheet
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/timesheets/period [get]
+func (ctrl *Controller) GetMyTimesheet(ctx *gin.Context) {
+	userID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	settings := ctrl.payPeriodSettings()
+	date := time.Now()
+	if value := ctx.Query("date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, settings.Location)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return
+		}
+		date = parsed
+	}
+
+	sheet, err := service.GetTimesheetForPeriod(ctrl.DB, uint(userID), date, settings, ctrl.breakRules())
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to build timesheet for user %d: %v", userID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build timesheet"})
+		return
+	}
+	ctx.JSON(http.StatusOK, sheet)
+}
+
+// ListMyTimesheets godoc
+// @Summary List my timesheets
+// @Description List the caregiver's timesheets, most recent period first
+// @Tags Timesheets
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "open, submitted, approved or rejected"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/timesheets [get]
+func (ctrl *Controller) ListMyTimesheets(ctx *gin.Context) {
+	userID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	sheets, err := service.ListTimesheets(ctrl.DB, models.TimesheetFilter{UserID: uint(userID), Status: ctx.Query("status")})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch timesheets"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"timesheets": sheets})
+}
+
+// SubmitTimesheet godoc
+// @Summary Submit a timesheet
+// @Description Submit an open or rejected timesheet for approval once its pay period has ended. The entries are rebuilt one last time and then frozen.
+// @Tags Timesheets
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Timesheet ID"
+// @Success 200 {object} models.Timesheet
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/timesheets/{id}/submit [post]
+func (ctrl *Controller) SubmitTimesheet(ctx *gin.Context) {
+	userID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	sheet, ok := ctrl.timesheetFromParam(ctx)
+	if !ok {
+		return
+	}
+	if sheet.UserID != uint(userID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Timesheet belongs to another caregiver"})
+		return
+	}
+
+	before := *sheet
+	before.Entries = nil
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.SubmitTimesheet(tx, sheet, ctrl.payPeriodSettings(), ctrl.breakRules(), time.Now()); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, models.AUDIT_ACTION_STATUS, models.AUDIT_ENTITY_TIMESHEET, sheet.ID, before, sheet)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTimesheetNotEditable), errors.Is(err, service.ErrTimesheetPeriodOpen):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			logger.ErrorLogger.Printf("Failed to submit timesheet %d: %v", sheet.ID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit timesheet"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sheet)
+}
+
+// ListTimesheets godoc
+// @Summary List timesheets
+// @Description List caregivers' timesheets, most recent period first
+// @Tags Timesheets
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "open, submitted, approved or rejected"
+// @Param user_id query int false "Caregiver ID"
+// @Param period_start query string false "Pay period start (YYYY-MM-DD, agency timezone)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/timesheets [get]
+func (ctrl *Controller) ListTimesheets(ctx *gin.Context) {
+	filter := models.TimesheetFilter{Status: ctx.Query("status")}
+	if value := ctx.Query("user_id"); value != "" {
+		userID, err := strconv.Atoi(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+		filter.UserID = uint(userID)
+	}
+	if value := ctx.Query("period_start"); value != "" {
+		start, err := time.ParseInLocation("2006-01-02", value, ctrl.agencyLocation())
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period_start, expected YYYY-MM-DD"})
+			return
+		}
+		filter.PeriodStart = &start
+	}
+
+	sheets, err := service.ListTimesheets(ctrl.DB, filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch timesheets"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"timesheets": sheets})
+}
+
+// GetTimesheet godoc
+// @Summary Get a timesheet
+// @Description Get a timesheet with its visit entries
+// @Tags Timesheets
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Timesheet ID"
+// @Success 200 {object} models.Timesheet
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/timesheets/{id} [get]
+func (ctrl *Controller) GetTimesheet(ctx *gin.Context) {
+	sheet, ok := ctrl.timesheetFromParam(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, sheet)
+}
+
+// ApproveTimesheet godoc
+// @Summary Approve a timesheet
+// @Description Approve a submitted timesheet. Its visits, and any visit starting in its pay period, are locked against further edits.
+// @Tags Timesheets
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Timesheet ID"
+// @Param request body models.TimesheetReviewRequest false "Review note"
+// @Success 200 {object} models.Timesheet
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/timesheets/{id}/approve [post]
+func (ctrl *Controller) ApproveTimesheet(ctx *gin.Context) {
+	ctrl.reviewTimesheet(ctx, true)
+}
+
+// RejectTimesheet godoc
+// @Summary Reject a timesheet
+// @Description Send a submitted timesheet back to the caregiver, who can fix the visits and resubmit
+// @Tags Timesheets
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Timesheet ID"
+// @Param request body models.TimesheetReviewRequest false "Review note"
+// @Success 200 {object} models.Timesheet
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/timesheets/{id}/reject [post]
+func (ctrl *Controller) RejectTimesheet(ctx *gin.Context) {
+	ctrl.reviewTimesheet(ctx, false)
+}
+
+func (ctrl *Controller) reviewTimesheet(ctx *gin.Context, approve bool) {
+	reviewerID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	sheet, ok := ctrl.timesheetFromParam(ctx)
+	if !ok {
+		return
+	}
+	var req models.TimesheetReviewRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+	}
+
+	before := *sheet
+	before.Entries = nil
+	action := models.AUDIT_ACTION_REJECT
+	if approve {
+		action = models.AUDIT_ACTION_APPROVE
+	}
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.ReviewTimesheet(tx, sheet, approve, uint(reviewerID), req.Note); err != nil {
+			return err
+		}
+		return ctrl.recordAudit(ctx, tx, action, models.AUDIT_ENTITY_TIMESHEET, sheet.ID, before, sheet)
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrTimesheetNotPending) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		logger.ErrorLogger.Printf("Failed to review timesheet %d: %v", sheet.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review timesheet"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sheet)
+}
+
+func (ctrl *Controller) timesheetFromParam(ctx *gin.Context) (*models.Timesheet, bool) {
+	timesheetID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timesheet ID"})
+		return nil, false
+	}
+	sheet, err := service.GetTimesheetByID(ctrl.DB, uint(timesheetID))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Timesheet not found"})
+		return nil, false
+	}
+	return sheet, true
+}
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/service"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMyTravel godoc
+// @Summary My travel between visits
+// @Description Travel segments between the caregiver's consecutive completed visits on the same day, with total distance, time and mileage reimbursement
+// @Tags Travel
+// @Security BearerAuth
+// @Produce json
+// @Param from query string false "First day (YYYY-MM-DD)"
+// @Param to query string false "Last day (YYYY-MM-DD)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/user/travel [get]
+func (ctrl *Controller) GetMyTravel(ctx *gin.Context) {
+	userID, err := GetUserIDFromJWT(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	filter, ok := travelFilterFromQuery(ctx)
+	if !ok {
+		return
+	}
+	filter.UserID = uint(userID)
+	ctrl.respondWithTravel(ctx, filter)
+}
+
+// GetTravelReport godoc
+// @Summary Travel and mileage report
+// @Description Travel segments between consecutive completed visits with per-caregiver distance, time and reimbursement at PAYROLL_MILEAGE_RATE. Segments use routed figures when the routing provider answered, otherwise the straight line.
+// @Tags Travel
+// @Security BearerAuth
+// @Produce json
+// @Param user_id query int false "Caregiver ID"
+// @Param from query string false "First day (YYYY-MM-DD)"
+// @Param to query string false "Last day (YYYY-MM-DD)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/travel [get]
+func (ctrl *Controller) GetTravelReport(ctx *gin.Context) {
+	filter, ok := travelFilterFromQuery(ctx)
+	if !ok {
+		return
+	}
+	if value := ctx.Query("user_id"); value != "" {
+		userID, err := strconv.Atoi(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+		filter.UserID = uint(userID)
+	}
+	ctrl.respondWithTravel(ctx, filter)
+}
+
+// RebuildTravel godoc
+// @Summary Rebuild travel segments
+// @Description Recompute travel segments for a range of days, e.g. after switching routing provider or to retry legs the provider failed on. Without user_id every caregiver is rebuilt.
+// @Tags Travel
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.TravelRebuildRequest true "Days (YYYY-MM-DD, inclusive) and optional caregiver"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/travel/rebuild [post]
+func (ctrl *Controller) RebuildTravel(ctx *gin.Context) {
+	var req models.TravelRebuildRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	from, err := time.Parse("2006-01-02", req.From)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return
+	}
+	to, err := time.Parse("2006-01-02", req.To)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return
+	}
+	if to.Before(from) || to.Sub(from) > 92*24*time.Hour {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "to must be on or after from and at most 92 days later"})
+		return
+	}
+
+	var userIDs []uint
+	if req.UserID != nil {
+		userIDs = []uint{*req.UserID}
+	} else if userIDs, err = service.ListCaregiverIDs(ctrl.DB); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch caregivers"})
+		return
+	}
+
+	count, err := service.RebuildTravelRange(ctx.Request.Context(), ctrl.DB, userIDs, from, to, ctrl.agencyLocation(), ctrl.Routing, float64(ctrl.Config.TravelSpeedKmh))
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to rebuild travel segments: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebuild travel segments"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"provider": ctrl.Routing.Name(), "segments": count})
+}
+
+func (ctrl *Controller) respondWithTravel(ctx *gin.Context, filter models.TravelFilter) {
+	segments, err := service.ListTravelSegments(ctrl.DB, filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch travel"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"summaries": service.SummarizeTravel(segments, ctrl.Config.PayrollMileageRate),
+		"segments":  segments,
+	})
+}
+
+// afterVisitChange brings what is derived from a visit's status and times up to date:
+// authorization usage and the travel segments of the days it was and is on. Failures are
+// logged rather than failing the visit update; the next change retries them.
+func (ctrl *Controller) afterVisitChange(before *models.Schedule) {
+	loc := ctrl.agencyLocation()
+	if _, err := service.SyncAuthorizationUsage(ctrl.DB, before.ID, loc, ctrl.breakRules()); err != nil {
+		logger.ErrorLogger.Printf("Failed to update authorization usage for schedule %d: %v", before.ID, err)
+	}
+	if ctrl.Routing == nil {
+		return
+	}
+	if err := service.RebuildTravelForVisit(context.Background(), ctrl.DB, before, loc, ctrl.Routing, float64(ctrl.Config.TravelSpeedKmh)); err != nil {
+		logger.ErrorLogger.Printf("Failed to update travel for schedule %d: %v", before.ID, err)
+	}
+}
+
+// travelFilterFromQuery reads ?from= and ?to= as calendar days
+func travelFilterFromQuery(ctx *gin.Context) (models.TravelFilter, bool) {
+	var filter models.TravelFilter
+	for _, param := range []string{"from", "to"} {
+		value := ctx.Query(param)
+		if value == "" {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " date, expected YYYY-MM-DD"})
+			return filter, false
+		}
+		if param == "from" {
+			filter.From = &day
+		} else {
+			filter.To = &day
+		}
+	}
+	return filter, true
+}
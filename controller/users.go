@@ -52,7 +52,13 @@ func (c *Controller) RegisterUser(ctx *gin.Context) {
 		RoleID:   models.ROLE_CAREGIVER,
 	}
 
-	if _, err := service.RegisterUser(c.DB, user); err != nil {
+	err = c.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := service.RegisterUser(tx, user); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_USER, user.ID, nil, user)
+	})
+	if err != nil {
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			ctx.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
 		} else {
@@ -165,7 +171,13 @@ func (c *Controller) RegAdmin(ctx *gin.Context) {
 		RoleID:   models.ROLE_ADMIN,
 	}
 
-	if _, err := service.RegisterUser(c.DB, user); err != nil {
+	err = c.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := service.RegisterUser(tx, user); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, tx, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_USER, user.ID, nil, user)
+	})
+	if err != nil {
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			ctx.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
 		} else {
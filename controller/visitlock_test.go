@@ -0,0 +1,205 @@
+package controller_test
+
+import (
+	"caregiver-shift-tracker/config"
+	"caregiver-shift-tracker/controller"
+	"caregiver-shift-tracker/database/dbtest"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/routes"
+	"caregiver-shift-tracker/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type visitFixture struct {
+	t      *testing.T
+	db     *gorm.DB
+	config *config.Config
+	router *gin.Engine
+	user   models.User
+	token  string
+}
+
+func newVisitFixture(t *testing.T) *visitFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := dbtest.Open(t)
+	cfg := &config.Config{}
+	router := gin.New()
+	routes.SetUpRoutes(router, &controller.Controller{DB: db, GIN: router, Config: cfg}, db)
+
+	user := models.User{Email: "caregiver@example.com", Mobile: "5550100", FullName: "Casey Giver", Password: "x", RoleID: models.ROLE_CAREGIVER}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := utils.GenerateJWT(int(user.ID), models.ROLE_CAREGIVER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &visitFixture{t: t, db: db, config: cfg, router: router, user: user, token: token}
+}
+
+// visit creates a visit for the caregiver with one open task
+func (f *visitFixture) visit(status string, start *time.Time) (*models.Schedule, *models.Task) {
+	f.t.Helper()
+	schedule := models.Schedule{UserID: f.user.ID, ClientName: "Pat Client", Location: "1 Main St",
+		ShiftTime: time.Now().UTC().Add(-time.Hour), Status: status, StartTime: start}
+	if err := f.db.Create(&schedule).Error; err != nil {
+		f.t.Fatal(err)
+	}
+	task := models.Task{ScheduleID: schedule.ID, Description: "Prepare lunch", Status: models.TASK_STATUS_NOT_COMPLETED}
+	if err := f.db.Create(&task).Error; err != nil {
+		f.t.Fatal(err)
+	}
+	return &schedule, &task
+}
+
+// approveTimesheet approves a timesheet for the current pay period listing the given visits
+func (f *visitFixture) approveTimesheet(visits ...*models.Schedule) {
+	f.t.Helper()
+	now := time.Now().UTC()
+	sheet := models.Timesheet{UserID: f.user.ID, PeriodStart: now.Add(-7 * 24 * time.Hour), PeriodEnd: now.Add(7 * 24 * time.Hour),
+		Status: models.TIMESHEET_STATUS_APPROVED}
+	if err := f.db.Create(&sheet).Error; err != nil {
+		f.t.Fatal(err)
+	}
+	for _, visit := range visits {
+		entry := models.TimesheetEntry{TimesheetID: sheet.ID, ScheduleID: visit.ID, StartTime: now.Add(-time.Hour), EndTime: now}
+		if err := f.db.Create(&entry).Error; err != nil {
+			f.t.Fatal(err)
+		}
+	}
+}
+
+func (f *visitFixture) request(method, path, body string) *httptest.ResponseRecorder {
+	f.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLockedVisitCannotChange(t *testing.T) {
+	f := newVisitFixture(t)
+	started := time.Now().UTC().Add(-30 * time.Minute)
+	scheduled, _ := f.visit(models.SCHEDULE_STATUS_SCHEDULED, nil)
+	inProgress, task := f.visit(models.SCHEDULE_STATUS_IN_PROGRESS, &started)
+	f.approveTimesheet(inProgress)
+
+	location := `{"latitude": 40.7, "longitude": -74.0}`
+	cases := []struct {
+		name, method, path, body string
+	}{
+		{"start", http.MethodPost, fmt.Sprintf("/api/user/schedules/%d/start", scheduled.ID), location},
+		{"end", http.MethodPost, fmt.Sprintf("/api/user/schedules/%d/end", inProgress.ID), location},
+		{"pause", http.MethodPost, fmt.Sprintf("/api/user/schedules/%d/pause", inProgress.ID), location},
+		{"update task", http.MethodPut, fmt.Sprintf("/tasks/%d", task.ID), `{"status": "completed"}`},
+		{"update task status", http.MethodPost, fmt.Sprintf("/tasks/%d/update", task.ID), `{"status": "completed"}`},
+		{"bulk update tasks", http.MethodPatch, fmt.Sprintf("/api/user/schedules/%d/tasks", inProgress.ID),
+			fmt.Sprintf(`{"updates": [{"task_id": %d, "status": "completed"}]}`, task.ID)},
+		{"create task", http.MethodPost, "/tasks/", fmt.Sprintf(`{"schedule_id": %d, "description": "Fold laundry"}`, inProgress.ID)},
+		{"assign tasks", http.MethodPost, fmt.Sprintf("/tasks/assign/%d", inProgress.ID), `{"tasks": [{"description": "Fold laundry"}]}`},
+		{"delete task", http.MethodDelete, fmt.Sprintf("/tasks/%d", task.ID), ""},
+	}
+	for _, tc := range cases {
+		rec := f.request(tc.method, tc.path, tc.body)
+		if rec.Code != http.StatusConflict {
+			t.Errorf("%s: got %d %s, want 409", tc.name, rec.Code, rec.Body.String())
+		}
+	}
+
+	var schedules []models.Schedule
+	if err := f.db.Order("id").Find(&schedules).Error; err != nil {
+		t.Fatal(err)
+	}
+	if schedules[0].Status != models.SCHEDULE_STATUS_SCHEDULED || schedules[0].StartTime != nil {
+		t.Errorf("locked scheduled visit changed: %+v", schedules[0])
+	}
+	if schedules[1].Status != models.SCHEDULE_STATUS_IN_PROGRESS || schedules[1].EndTime != nil {
+		t.Errorf("locked visit changed: %+v", schedules[1])
+	}
+	var saved models.Task
+	if err := f.db.First(&saved, task.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != models.TASK_STATUS_NOT_COMPLETED {
+		t.Errorf("task on a locked visit was marked %s", saved.Status)
+	}
+	var tasks int64
+	f.db.Model(&models.Task{}).Where("schedule_id = ?", inProgress.ID).Count(&tasks)
+	if tasks != 1 {
+		t.Errorf("locked visit has %d tasks, want 1", tasks)
+	}
+	var breaks int64
+	f.db.Model(&models.VisitBreak{}).Count(&breaks)
+	if breaks != 0 {
+		t.Errorf("locked visit was paused")
+	}
+}
+
+func TestStartVisitOnlyStartsScheduledVisits(t *testing.T) {
+	f := newVisitFixture(t)
+	started := time.Now().UTC().Add(-2 * time.Hour).Truncate(time.Second)
+	completed, _ := f.visit(models.SCHEDULE_STATUS_COMPLETED, &started)
+	scheduled, _ := f.visit(models.SCHEDULE_STATUS_SCHEDULED, nil)
+	location := `{"latitude": 40.7, "longitude": -74.0}`
+
+	rec := f.request(http.MethodPost, fmt.Sprintf("/api/user/schedules/%d/start", completed.ID), location)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("starting a completed visit: got %d %s, want 409", rec.Code, rec.Body.String())
+	}
+	var saved models.Schedule
+	if err := f.db.First(&saved, completed.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != models.SCHEDULE_STATUS_COMPLETED || saved.StartTime == nil || !saved.StartTime.Equal(started) {
+		t.Fatalf("completed visit was restarted: status %s, start %v", saved.Status, saved.StartTime)
+	}
+
+	rec = f.request(http.MethodPost, fmt.Sprintf("/api/user/schedules/%d/start", scheduled.ID), location)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("starting a scheduled visit: got %d %s, want 200", rec.Code, rec.Body.String())
+	}
+	rec = f.request(http.MethodPost, fmt.Sprintf("/api/user/schedules/%d/start", scheduled.ID), location)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("starting a visit twice: got %d %s, want 409", rec.Code, rec.Body.String())
+	}
+}
+
+func TestEndAndCancelStartOnlyChangeVisitsInProgress(t *testing.T) {
+	f := newVisitFixture(t)
+	started := time.Now().UTC().Add(-2 * time.Hour).Truncate(time.Second)
+	ended := started.Add(time.Hour)
+	completed, _ := f.visit(models.SCHEDULE_STATUS_COMPLETED, &started)
+	if err := f.db.Model(completed).Update("end_time", ended).Error; err != nil {
+		t.Fatal(err)
+	}
+	location := `{"latitude": 40.7, "longitude": -74.0}`
+
+	rec := f.request(http.MethodPost, fmt.Sprintf("/api/user/schedules/%d/end", completed.ID), location)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("ending a completed visit: got %d %s, want 409", rec.Code, rec.Body.String())
+	}
+	rec = f.request(http.MethodPost, fmt.Sprintf("/api/user/schedules/%d/cancel-start", completed.ID), "")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("cancelling the clock-in of a completed visit: got %d %s, want 409", rec.Code, rec.Body.String())
+	}
+
+	var saved models.Schedule
+	if err := f.db.First(&saved, completed.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != models.SCHEDULE_STATUS_COMPLETED || saved.StartTime == nil || !saved.StartTime.Equal(started) ||
+		saved.EndTime == nil || !saved.EndTime.Equal(ended) {
+		t.Fatalf("completed visit was changed: status %s, start %v, end %v", saved.Status, saved.StartTime, saved.EndTime)
+	}
+}
@@ -29,12 +29,17 @@ func InitializeDB(cfg *config.Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	// Perform automatic migration for the User model
-	err = db.AutoMigrate(&models.User{}, models.Schedule{}, models.Task{})
-	if err != nil {
+	// Perform automatic migration for every model
+	if err := Migrate(db); err != nil {
 		log.Fatalf("failed to auto-migrate User model: %v", err)
 	}
 
 	// Return the database connection object
 	return db, nil
 }
+
+// Migrate creates or updates the tables for every model
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&models.User{}, models.Schedule{}, models.Task{}, models.AuditLog{}, models.VisitLedgerEntry{}, models.VisitCorrection{},
+		models.Client{}, models.EVVExportBatch{}, models.EVVSubmission{}, models.VisitSignature{}, models.Attachment{}, models.LocationPing{}, models.VisitBreak{}, models.TaskTemplate{}, models.CarePlan{}, models.CarePlanItem{}, models.ObservationField{}, models.TaskObservation{}, models.Alert{}, models.MedicationOrder{}, models.MedicationAdministration{}, models.TaskCompletionOverride{}, models.Incident{}, models.Timesheet{}, models.TimesheetEntry{}, models.LaborRuleSet{}, models.PayrollBatch{}, models.PayrollLine{}, models.PayrollBatchVisit{}, models.Payer{}, models.ServiceCode{}, models.BillingRate{}, models.Invoice{}, models.InvoiceLine{}, models.Authorization{}, models.AuthorizationUsage{}, models.TravelSegment{}, models.ReportSubscription{}, models.ReportDelivery{}, models.CaregiverAvailability{}, models.VisitSeries{})
+}
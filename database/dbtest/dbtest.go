@@ -0,0 +1,57 @@
+// Package dbtest runs an in-process MySQL-compatible server so tests can exercise
+// the real GORM queries without an external database.
+package dbtest
+
+import (
+	"caregiver-shift-tracker/database"
+	"fmt"
+	"net"
+	"testing"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open starts an empty, fully migrated database that is shut down when the test ends
+func Open(t *testing.T) *gorm.DB {
+	t.Helper()
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	provider := memory.NewDBProvider(memory.NewDatabase("care"))
+	engine := sqle.NewDefault(provider)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve a port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	srv, err := server.NewServer(server.Config{Protocol: "tcp", Address: addr}, engine, memory.NewSessionBuilder(provider), nil)
+	if err != nil {
+		t.Fatalf("failed to start test database: %v", err)
+	}
+	go srv.Start()
+	t.Cleanup(func() { srv.Close() })
+
+	dsn := fmt.Sprintf("root:@tcp(%s)/care?parseTime=true&loc=UTC", addr)
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+		// The in-memory engine cannot index the foreign keys GORM derives from associations
+		DisableForeignKeyConstraintWhenMigrating: true,
+		// Nor does it support savepoints, so nested transactions join the outer one
+		DisableNestedTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,88 @@
+// Package jobs runs periodic background work alongside the HTTP server
+package jobs
+
+import (
+	"caregiver-shift-tracker/config"
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/service"
+	"caregiver-shift-tracker/utils"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// every runs fn immediately and then on each tick until the process exits
+func every(interval time.Duration, name string, fn func(now time.Time)) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.ErrorLogger.Printf("Background job %s stopped: %v", name, r)
+			}
+		}()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		fn(time.Now())
+		for now := range ticker.C {
+			fn(now)
+		}
+	}()
+}
+
+// StartMissedDoseMonitor periodically marks overdue medication doses as missed and emails
+// customer care about them
+func StartMissedDoseMonitor(db *gorm.DB, cfg *config.Config) {
+	interval := time.Duration(cfg.MARCheckIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		return
+	}
+	grace := time.Duration(cfg.MARMissedGraceMinutes) * time.Minute
+
+	every(interval, "missed-dose-monitor", func(now time.Time) {
+		alerts, err := service.MarkMissedDoses(db, grace, now)
+		if err != nil {
+			logger.ErrorLogger.Printf("Missed dose check failed: %v", err)
+			return
+		}
+		if len(alerts) == 0 {
+			return
+		}
+
+		lines := make([]string, 0, len(alerts))
+		for _, alert := range alerts {
+			lines = append(lines, fmt.Sprintf("- Schedule %d: %s (alert %d)", *alert.ScheduleID, alert.Message, alert.ID))
+		}
+		body := "The following medication doses were not recorded:\n\n" + strings.Join(lines, "\n") +
+			"\n\nAcknowledge them under /api/admin/alerts once followed up."
+		if err := service.NotifyCustomerCare(db, fmt.Sprintf("%d missed medication dose(s)", len(alerts)), body); err != nil {
+			logger.ErrorLogger.Printf("Failed to notify customer care of missed doses: %v", err)
+		}
+	})
+}
+
+// StartReportScheduler emails report subscriptions as they come due and retries deliveries
+// that failed
+func StartReportScheduler(db *gorm.DB, cfg *config.Config) {
+	interval := time.Duration(cfg.ReportCheckIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		return
+	}
+	opts := service.ReportOptions{
+		AgencyName:   cfg.AgencyName,
+		OnTimeGrace:  time.Duration(cfg.OnTimeGraceMinutes) * time.Minute,
+		MileageRate:  cfg.PayrollMileageRate,
+		MaxAttempts:  int(cfg.ReportMaxAttempts),
+		RetryBackoff: time.Duration(cfg.ReportRetryBackoffMinutes) * time.Minute,
+		Send:         utils.SendEmailMessage,
+	}
+
+	every(interval, "report-scheduler", func(now time.Time) {
+		if _, err := service.RunDueReportSubscriptions(db, now, opts); err != nil {
+			logger.ErrorLogger.Printf("Report subscription check failed: %v", err)
+		}
+		if _, err := service.RetryDueReportDeliveries(db, now, opts); err != nil {
+			logger.ErrorLogger.Printf("Report delivery retry failed: %v", err)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package logger
 import (
 	"log"
 	"os"
+	"testing"
 )
 
 var (
@@ -11,6 +12,13 @@ var (
 )
 
 func init() {
+	// Test binaries run in each package directory; log to stderr there instead of leaving app.log behind
+	if testing.Testing() {
+		InfoLogger = log.New(os.Stderr, "INFO: ", log.Ldate|log.Ltime)
+		ErrorLogger = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+		return
+	}
+
 	file, err := os.OpenFile("app.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
 		log.Fatalf("Failed to open log file: %v", err)
@@ -4,8 +4,11 @@ import (
 	"caregiver-shift-tracker/config"
 	"caregiver-shift-tracker/controller"
 	"caregiver-shift-tracker/database"
+	"caregiver-shift-tracker/jobs"
 	"caregiver-shift-tracker/logger"
 	"caregiver-shift-tracker/routes"
+	"caregiver-shift-tracker/routing"
+	"caregiver-shift-tracker/storage"
 	"caregiver-shift-tracker/utils"
 
 	_ "caregiver-shift-tracker/docs"
@@ -52,9 +55,24 @@ func main() {
 
 	r.Use(database.DBMiddleware(db))
 
-	authService := &controller.Controller{DB: db, RDB: rdb}
+	store, err := storage.New(cfg)
+	if err != nil {
+		logger.ErrorLogger.Fatalf("Failed to initialize attachment storage: %v", err)
+	}
+	fmt.Printf("Attachment storage: %s\n", store.Driver())
+
+	router, err := routing.New(cfg)
+	if err != nil {
+		logger.ErrorLogger.Fatalf("Failed to initialize routing provider: %v", err)
+	}
+	fmt.Printf("Routing provider: %s\n", router.Name())
+
+	authService := &controller.Controller{DB: db, RDB: rdb, Config: cfg, Storage: store, Routing: router}
 	routes.SetUpRoutes(r, authService, db)
 
+	jobs.StartMissedDoseMonitor(db, cfg)
+	jobs.StartReportScheduler(db, cfg)
+
 	// Swagger endpoint
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
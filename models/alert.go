@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ALERT_TYPE_OBSERVATION = "observation"
+	ALERT_TYPE_MISSED_DOSE = "missed_dose"
+
+	ALERT_SEVERITY_WARNING  = "warning"
+	ALERT_SEVERITY_CRITICAL = "critical"
+
+	ALERT_STATUS_OPEN         = "open"
+	ALERT_STATUS_ACKNOWLEDGED = "acknowledged"
+)
+
+// Alert is something staff need to look at, raised automatically from visit data
+type Alert struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Type           string     `gorm:"type:varchar(30);not null;index" json:"type"`
+	Severity       string     `gorm:"type:varchar(10);not null" json:"severity"`
+	Status         string     `gorm:"type:varchar(15);not null;default:'open';index" json:"status"`
+	ClientID       *uint      `gorm:"index" json:"client_id,omitempty"`
+	ScheduleID     *uint      `gorm:"index" json:"schedule_id,omitempty"`
+	TaskID         *uint      `json:"task_id,omitempty"`
+	ObservationID  *uint      `json:"observation_id,omitempty"`
+	Message        string     `gorm:"type:varchar(255);not null" json:"message"`
+	AcknowledgedBy *uint      `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time `gorm:"type:datetime" json:"acknowledged_at,omitempty"`
+	Note           *string    `gorm:"type:text" json:"note,omitempty"`
+}
+
+type AlertAcknowledgeRequest struct {
+	Note string `json:"note,omitempty"`
+}
+
+type AlertFilter struct {
+	Status   string
+	Type     string
+	ClientID uint
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ANALYTICS_GROUP_NONE      = "none"
+	ANALYTICS_GROUP_CAREGIVER = "caregiver"
+	ANALYTICS_GROUP_CLIENT    = "client"
+	ANALYTICS_GROUP_DAY       = "day"
+
+	ANALYTICS_INTERVAL_DAY   = "day"
+	ANALYTICS_INTERVAL_WEEK  = "week"
+	ANALYTICS_INTERVAL_MONTH = "month"
+)
+
+// AnalyticsFilter selects the visits a report covers: those whose shift_time falls in
+// [From, To), laid out in Location
+type AnalyticsFilter struct {
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+	UserID   uint
+	ClientID uint
+}
+
+// VisitKPIs summarizes one group of visits. Missed counts visits marked missed and scheduled
+// visits never started once past their shift time; ByStatus reports statuses as stored.
+type VisitKPIs struct {
+	Group              string         `json:"group"`
+	UserID             *uint          `json:"user_id,omitempty"`
+	ClientID           *uint          `json:"client_id,omitempty"`
+	Date               string         `json:"date,omitempty"`
+	Name               string         `json:"name,omitempty"`
+	Visits             int            `json:"visits"`
+	ByStatus           map[string]int `json:"by_status"`
+	Missed             int            `json:"missed"`
+	ClockIns           int            `json:"clock_ins"`
+	OnTimeClockIns     int            `json:"on_time_clock_ins"`
+	OnTimePercent      float64        `json:"on_time_percent"`
+	LateClockIns       int            `json:"late_clock_ins"`
+	AverageLateMinutes float64        `json:"average_late_minutes"`
+}
+
+type ReasonCount struct {
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
+}
+
+// TaskKPIs covers the tasks of visits that were carried out (in progress or completed)
+type TaskKPIs struct {
+	Tasks          int           `json:"tasks"`
+	Completed      int           `json:"completed"`
+	NotCompleted   int           `json:"not_completed"`
+	CompletionRate float64       `json:"completion_rate"`
+	WithoutReason  int           `json:"without_reason"`
+	TopReasons     []ReasonCount `json:"top_reasons"`
+}
+
+// MissedTrendPoint counts the visits due in one interval and how many were missed. Visits not
+// yet due and cancelled visits are left out.
+type MissedTrendPoint struct {
+	PeriodStart string  `json:"period_start"`
+	Due         int     `json:"due"`
+	Missed      int     `json:"missed"`
+	MissedRate  float64 `json:"missed_rate"`
+}
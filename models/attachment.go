@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// AllowedAttachmentTypes are the content types accepted for visit attachments
+var AllowedAttachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// Attachment is a photo or document uploaded against a visit (and optionally one of its tasks or incidents).
+// The bytes live in the configured storage backend under StorageKey.
+type Attachment struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	ScheduleID    uint      `gorm:"not null;index" json:"schedule_id"`
+	TaskID        *uint     `gorm:"index" json:"task_id,omitempty"`
+	IncidentID    *uint     `gorm:"index" json:"incident_id,omitempty"`
+	UploadedBy    uint      `gorm:"not null" json:"uploaded_by"`
+	FileName      string    `gorm:"type:varchar(255);not null" json:"file_name"`
+	ContentType   string    `gorm:"type:varchar(100);not null" json:"content_type"`
+	SizeBytes     int64     `gorm:"not null" json:"size_bytes"`
+	SHA256        string    `gorm:"type:char(64);not null" json:"sha256"`
+	StorageDriver string    `gorm:"type:varchar(20);not null" json:"-"`
+	StorageKey    string    `gorm:"type:varchar(255);not null;uniqueIndex" json:"-"`
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AUDIT_ACTION_CREATE       = "create"
+	AUDIT_ACTION_UPDATE       = "update"
+	AUDIT_ACTION_DELETE       = "delete"
+	AUDIT_ACTION_STATUS       = "status_change"
+	AUDIT_ACTION_VISIT_START  = "visit_start"
+	AUDIT_ACTION_VISIT_END    = "visit_end"
+	AUDIT_ACTION_VISIT_CANCEL = "visit_cancel_start"
+	AUDIT_ACTION_APPROVE      = "approve"
+	AUDIT_ACTION_REJECT       = "reject"
+	AUDIT_ACTION_VISIT_PAUSE  = "visit_pause"
+	AUDIT_ACTION_VISIT_RESUME = "visit_resume"
+
+	AUDIT_ENTITY_SCHEDULE      = "schedule"
+	AUDIT_ENTITY_TASK          = "task"
+	AUDIT_ENTITY_USER          = "user"
+	AUDIT_ENTITY_CORRECTION    = "visit_correction"
+	AUDIT_ENTITY_CLIENT        = "client"
+	AUDIT_ENTITY_ATTACHMENT    = "attachment"
+	AUDIT_ENTITY_BREAK         = "visit_break"
+	AUDIT_ENTITY_TEMPLATE      = "task_template"
+	AUDIT_ENTITY_CARE_PLAN     = "care_plan"
+	AUDIT_ENTITY_ALERT         = "alert"
+	AUDIT_ENTITY_MED_ORDER     = "medication_order"
+	AUDIT_ENTITY_MED_ADMIN     = "medication_administration"
+	AUDIT_ENTITY_OVERRIDE      = "task_completion_override"
+	AUDIT_ENTITY_INCIDENT      = "incident"
+	AUDIT_ENTITY_TIMESHEET     = "timesheet"
+	AUDIT_ENTITY_LABOR_RULES   = "labor_rule_set"
+	AUDIT_ENTITY_PAYROLL       = "payroll_batch"
+	AUDIT_ENTITY_PAYER         = "payer"
+	AUDIT_ENTITY_SERVICE_CODE  = "service_code"
+	AUDIT_ENTITY_INVOICE       = "invoice"
+	AUDIT_ENTITY_AUTHORIZATION = "authorization"
+	AUDIT_ENTITY_REPORT        = "report_subscription"
+	AUDIT_ENTITY_AVAILABILITY  = "caregiver_availability"
+	AUDIT_ENTITY_VISIT_SERIES  = "visit_series"
+)
+
+// ErrAuditLogImmutable is returned when something tries to modify or remove an audit entry
+var ErrAuditLogImmutable = errors.New("audit log entries are append-only")
+
+// AuditLog is an append-only record of a single mutation made through the API
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+	ActorID    uint      `gorm:"index" json:"actor_id"`
+	ActorRole  int       `json:"actor_role"`
+	IPAddress  string    `gorm:"type:varchar(64)" json:"ip_address"`
+	Action     string    `gorm:"type:varchar(50);not null" json:"action"`
+	EntityType string    `gorm:"type:varchar(50);not null;index:idx_audit_entity" json:"entity_type"`
+	EntityID   uint      `gorm:"index:idx_audit_entity" json:"entity_id"`
+	Before     *string   `gorm:"type:json" json:"before,omitempty"`
+	After      *string   `gorm:"type:json" json:"after,omitempty"`
+	Diff       *string   `gorm:"type:json" json:"diff,omitempty"`
+	Reason     *string   `gorm:"type:text" json:"reason,omitempty"`
+}
+
+// BeforeUpdate rejects any attempt to rewrite an audit entry
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete rejects any attempt to remove an audit entry
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+type AuditLogFilter struct {
+	EntityType string
+	EntityID   uint
+	ActorID    uint
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	AUTHORIZATION_PERIOD_WEEK  = "week"
+	AUTHORIZATION_PERIOD_MONTH = "month"
+	AUTHORIZATION_PERIOD_TOTAL = "total"
+)
+
+// Authorization is a payer's approval of a number of units of one service code for a client,
+// per week, per calendar month or in total between StartDate and EndDate (inclusive). Weeks
+// run in seven-day blocks from StartDate.
+type Authorization struct {
+	ID                  uint         `gorm:"primaryKey" json:"id"`
+	CreatedAt           time.Time    `json:"created_at"`
+	UpdatedAt           time.Time    `json:"updated_at"`
+	ClientID            uint         `gorm:"not null;index" json:"client_id"`
+	PayerID             *uint        `gorm:"index" json:"payer_id,omitempty"`
+	ServiceCodeID       uint         `gorm:"not null;index" json:"service_code_id"`
+	ServiceCode         *ServiceCode `gorm:"foreignKey:ServiceCodeID" json:"service_code,omitempty"`
+	AuthorizationNumber string       `gorm:"type:varchar(50)" json:"authorization_number"`
+	Units               int          `gorm:"not null" json:"units"`
+	Period              string       `gorm:"type:enum('week','month','total');default:'week'" json:"period"`
+	StartDate           time.Time    `gorm:"type:date;not null;index" json:"start_date"`
+	EndDate             time.Time    `gorm:"type:date;not null;index" json:"end_date"`
+	Notes               string       `gorm:"type:text" json:"notes,omitempty"`
+}
+
+type AuthorizationRequest struct {
+	ClientID            uint   `json:"client_id" binding:"required"`
+	PayerID             *uint  `json:"payer_id"`
+	ServiceCodeID       uint   `json:"service_code_id" binding:"required"`
+	AuthorizationNumber string `json:"authorization_number" binding:"max=50"`
+	Units               int    `json:"units" binding:"required,min=1"`
+	Period              string `json:"period" binding:"omitempty,oneof=week month total"`
+	// StartDate and EndDate are YYYY-MM-DD; both days are covered
+	StartDate string `json:"start_date" binding:"required"`
+	EndDate   string `json:"end_date" binding:"required"`
+	Notes     string `json:"notes"`
+}
+
+// AuthorizationUsage is the units a completed visit deducted from an authorization. It is
+// rewritten whenever the visit's times change and removed if the visit is no longer completed.
+type AuthorizationUsage struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	AuthorizationID uint      `gorm:"not null;index" json:"authorization_id"`
+	ScheduleID      uint      `gorm:"not null;uniqueIndex" json:"schedule_id"`
+	VisitDate       time.Time `gorm:"type:date;not null;index" json:"visit_date"`
+	Minutes         int       `json:"minutes"`
+	Units           int       `json:"units"`
+}
+
+// AuthorizationUtilization compares an authorization's units with those used by completed
+// visits and planned by scheduled ones in the period containing the report date
+type AuthorizationUtilization struct {
+	AuthorizationID     uint      `json:"authorization_id"`
+	AuthorizationNumber string    `json:"authorization_number,omitempty"`
+	ClientID            uint      `json:"client_id"`
+	ClientName          string    `json:"client_name"`
+	ServiceCode         string    `json:"service_code"`
+	Period              string    `json:"period"`
+	PeriodStart         time.Time `json:"period_start"`
+	PeriodEnd           time.Time `json:"period_end"`
+	AuthorizedUnits     int       `json:"authorized_units"`
+	UsedUnits           int       `json:"used_units"`
+	PlannedUnits        int       `json:"planned_units"`
+	RemainingUnits      int       `json:"remaining_units"`
+	UtilizationPercent  float64   `json:"utilization_percent"`
+	OverAuthorized      bool      `json:"over_authorized"`
+}
+
+// AuthorizationWarning is returned when scheduling a visit that the client's authorization
+// does not cover, or that takes its period past the authorized units
+type AuthorizationWarning struct {
+	AuthorizationID *uint      `json:"authorization_id,omitempty"`
+	ServiceCode     string     `json:"service_code"`
+	PeriodStart     *time.Time `json:"period_start,omitempty"`
+	PeriodEnd       *time.Time `json:"period_end,omitempty"`
+	AuthorizedUnits int        `json:"authorized_units"`
+	UsedUnits       int        `json:"used_units"`
+	PlannedUnits    int        `json:"planned_units"`
+	VisitUnits      int        `json:"visit_units"`
+	Message         string     `json:"message"`
+}
+
+type AuthorizationFilter struct {
+	ClientID uint
+	// Date picks the authorizations in effect and the period reported; it is a calendar day
+	Date time.Time
+}
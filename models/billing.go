@@ -0,0 +1,132 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	UNIT_ROUNDING_NEAREST = "nearest"
+	UNIT_ROUNDING_UP      = "up"
+	UNIT_ROUNDING_DOWN    = "down"
+
+	DEFAULT_BILLING_UNIT_MINUTES = 15
+
+	INVOICE_KIND_INVOICE = "invoice"
+	INVOICE_KIND_CREDIT  = "credit"
+	INVOICE_KIND_REBILL  = "rebill"
+)
+
+// Payer is who pays for a client's care: a Medicaid program, an insurer or the client privately
+type Payer struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `gorm:"type:varchar(100);not null;uniqueIndex" json:"name" binding:"required,max=100"`
+	PayerCode string    `gorm:"type:varchar(50)" json:"payer_code" binding:"max=50"`
+	Address   string    `gorm:"type:varchar(200)" json:"address" binding:"max=200"`
+}
+
+// ServiceCode is a billable service (e.g. T1019 personal care). Visits are billed in units of
+// UnitMinutes, rounded as configured.
+type ServiceCode struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Code         string    `gorm:"type:varchar(20);not null;uniqueIndex" json:"code"`
+	Description  string    `gorm:"type:varchar(200)" json:"description"`
+	UnitMinutes  int       `gorm:"not null;default:15" json:"unit_minutes"`
+	RoundingMode string    `gorm:"type:enum('nearest','up','down');default:'nearest'" json:"rounding_mode"`
+	Active       bool      `gorm:"not null;default:true" json:"active"`
+
+	Rates []BillingRate `gorm:"foreignKey:ServiceCodeID" json:"rates,omitempty"`
+}
+
+type ServiceCodeRequest struct {
+	Code         string `json:"code" binding:"required,max=20"`
+	Description  string `json:"description" binding:"max=200"`
+	UnitMinutes  int    `json:"unit_minutes" binding:"omitempty,min=1,max=1440"`
+	RoundingMode string `json:"rounding_mode" binding:"omitempty,oneof=nearest up down"`
+	Active       *bool  `json:"active"`
+}
+
+// BillingRate is the price of one unit of a service code. A rate for a client overrides one for
+// the client's payer, which overrides the code's default rate (no payer, no client).
+type BillingRate struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	ServiceCodeID uint       `gorm:"not null;index" json:"service_code_id"`
+	PayerID       *uint      `gorm:"index" json:"payer_id,omitempty"`
+	ClientID      *uint      `gorm:"index" json:"client_id,omitempty"`
+	RatePerUnit   float64    `gorm:"type:decimal(10,2);not null" json:"rate_per_unit"`
+	EffectiveFrom time.Time  `gorm:"type:date;not null" json:"effective_from"`
+	EffectiveTo   *time.Time `gorm:"type:date" json:"effective_to,omitempty"`
+}
+
+type BillingRateRequest struct {
+	PayerID     *uint   `json:"payer_id"`
+	ClientID    *uint   `json:"client_id"`
+	RatePerUnit float64 `json:"rate_per_unit" binding:"min=0"`
+	// EffectiveFrom and EffectiveTo are YYYY-MM-DD; EffectiveTo is inclusive and optional
+	EffectiveFrom string `json:"effective_from" binding:"required"`
+	EffectiveTo   string `json:"effective_to"`
+}
+
+// Invoice bills a client's visits for a period. A corrected visit is reversed on a credit
+// invoice and billed again on a rebill invoice, both pointing at the original.
+type Invoice struct {
+	ID                uint          `gorm:"primaryKey" json:"id"`
+	CreatedAt         time.Time     `json:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+	InvoiceNumber     string        `gorm:"type:varchar(30);uniqueIndex" json:"invoice_number"`
+	Kind              string        `gorm:"type:enum('invoice','credit','rebill');default:'invoice';index" json:"kind"`
+	OriginalInvoiceID *uint         `gorm:"index" json:"original_invoice_id,omitempty"`
+	ClientID          uint          `gorm:"not null;index" json:"client_id"`
+	ClientName        string        `gorm:"type:varchar(100)" json:"client_name"`
+	PayerID           *uint         `gorm:"index" json:"payer_id,omitempty"`
+	PayerName         string        `gorm:"type:varchar(100)" json:"payer_name,omitempty"`
+	PeriodStart       time.Time     `gorm:"type:datetime;not null" json:"period_start"`
+	PeriodEnd         time.Time     `gorm:"type:datetime;not null" json:"period_end"`
+	Units             int           `json:"units"`
+	Total             float64       `gorm:"type:decimal(12,2)" json:"total"`
+	IssuedBy          uint          `json:"issued_by"`
+	Lines             []InvoiceLine `gorm:"foreignKey:InvoiceID" json:"lines,omitempty"`
+}
+
+// InvoiceLine bills one visit. VisitUpdatedAt snapshots the visit so later edits can be
+// detected; a credit line carries negative units and points at the line it reverses.
+type InvoiceLine struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	InvoiceID      uint      `gorm:"not null;index" json:"invoice_id"`
+	ScheduleID     uint      `gorm:"not null;index" json:"schedule_id"`
+	ServiceCode    string    `gorm:"type:varchar(20);not null" json:"service_code"`
+	VisitDate      time.Time `gorm:"type:date;not null" json:"visit_date"`
+	StartTime      time.Time `gorm:"type:datetime;not null" json:"start_time"`
+	EndTime        time.Time `gorm:"type:datetime;not null" json:"end_time"`
+	BilledMinutes  int       `json:"billed_minutes"`
+	Units          int       `json:"units"`
+	RatePerUnit    float64   `gorm:"type:decimal(10,2)" json:"rate_per_unit"`
+	Amount         float64   `gorm:"type:decimal(12,2)" json:"amount"`
+	VisitUpdatedAt time.Time `gorm:"type:datetime(3)" json:"visit_updated_at"`
+	CreditsLineID  *uint     `gorm:"index" json:"credits_line_id,omitempty"`
+}
+
+type InvoiceRequest struct {
+	// From and To are YYYY-MM-DD in the agency timezone; To is inclusive
+	From     string `json:"from" binding:"required"`
+	To       string `json:"to" binding:"required"`
+	ClientID *uint  `json:"client_id"`
+}
+
+// InvoiceRebillRequest lists visits to credit and rebill; when empty, every visit on the
+// invoice that changed since it was billed is used
+type InvoiceRebillRequest struct {
+	ScheduleIDs []uint `json:"schedule_ids"`
+}
+
+type InvoiceFilter struct {
+	ClientID uint
+	Kind     string
+	From     *time.Time
+	To       *time.Time
+}
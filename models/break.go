@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// VisitBreak is one pause inside an in-progress visit. An open break has no EndedAt.
+type VisitBreak struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ScheduleID uint       `gorm:"not null;index" json:"schedule_id"`
+	StartedAt  time.Time  `gorm:"type:datetime;not null" json:"started_at"`
+	EndedAt    *time.Time `gorm:"type:datetime" json:"ended_at"`
+	StartLat   *float64   `gorm:"type:decimal(10,8)" json:"start_lat"`
+	StartLon   *float64   `gorm:"type:decimal(11,8)" json:"start_lon"`
+	EndLat     *float64   `gorm:"type:decimal(10,8)" json:"end_lat"`
+	EndLon     *float64   `gorm:"type:decimal(11,8)" json:"end_lon"`
+	Reason     string     `gorm:"type:varchar(100)" json:"reason,omitempty"`
+}
+
+type BreakRequest struct {
+	Latitude  float64 `json:"latitude" binding:"required"`
+	Longitude float64 `json:"longitude" binding:"required"`
+	Reason    string  `json:"reason,omitempty" binding:"max=100"`
+}
+
+// BreakRules are the configurable limits applied to breaks. Zero disables a rule.
+type BreakRules struct {
+	MaxBreaksPerVisit     int `json:"max_breaks_per_visit"`
+	MinUnpaidMinutes      int `json:"min_unpaid_minutes"`
+	MaxBreakMinutes       int `json:"max_break_minutes"`
+	RequiredAfterMinutes  int `json:"required_after_minutes"`
+	RequiredBreakDuration int `json:"required_break_minutes"`
+}
+
+// VisitTimeSummary splits a visit's elapsed time into worked and break minutes.
+// Breaks shorter than MinUnpaidMinutes are treated as paid rest and count as worked time.
+type VisitTimeSummary struct {
+	ElapsedMinutes     int      `json:"elapsed_minutes"`
+	UnpaidBreakMinutes int      `json:"unpaid_break_minutes"`
+	PaidBreakMinutes   int      `json:"paid_break_minutes"`
+	WorkedMinutes      int      `json:"worked_minutes"`
+	BreakCount         int      `json:"break_count"`
+	OnBreak            bool     `json:"on_break"`
+	Violations         []string `json:"violations"`
+}
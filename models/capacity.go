@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	CAPACITY_BUCKET_NIGHT     = "night"
+	CAPACITY_BUCKET_MORNING   = "morning"
+	CAPACITY_BUCKET_AFTERNOON = "afternoon"
+	CAPACITY_BUCKET_EVENING   = "evening"
+
+	// CAPACITY_REGION_UNASSIGNED labels clients and availability without a region
+	CAPACITY_REGION_UNASSIGNED = "unassigned"
+)
+
+// CaregiverAvailability is a weekly window in which a caregiver can take visits in Region.
+// Times are HH:MM in the agency timezone; EndTime may be 24:00.
+type CaregiverAvailability struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	// Weekday runs 0-6 from Sunday
+	Weekday   int    `gorm:"not null" json:"weekday"`
+	StartTime string `gorm:"type:varchar(5);not null" json:"start_time"`
+	EndTime   string `gorm:"type:varchar(5);not null" json:"end_time"`
+	Region    string `gorm:"type:varchar(50);index" json:"region"`
+}
+
+type AvailabilityWindowRequest struct {
+	Weekday   int    `json:"weekday" binding:"min=0,max=6"`
+	StartTime string `json:"start_time" binding:"required"`
+	EndTime   string `json:"end_time" binding:"required"`
+	Region    string `json:"region" binding:"max=50"`
+}
+
+// AvailabilityRequest replaces all of a caregiver's availability windows
+type AvailabilityRequest struct {
+	Windows []AvailabilityWindowRequest `json:"windows" binding:"max=100,dive"`
+}
+
+// CapacityRegionWeek compares one region's forecast demand with caregiver availability for a
+// week. ShortfallHours adds up the day and time-of-day slots that are short, since spare hours
+// in one slot cannot cover another.
+type CapacityRegionWeek struct {
+	Region         string  `json:"region"`
+	ScheduledHours float64 `json:"scheduled_hours"`
+	RecurringHours float64 `json:"recurring_hours"`
+	BaselineHours  float64 `json:"baseline_hours"`
+	DemandHours    float64 `json:"demand_hours"`
+	CapacityHours  float64 `json:"capacity_hours"`
+	ShortfallHours float64 `json:"shortfall_hours"`
+	Caregivers     int     `json:"caregivers"`
+}
+
+type CapacityWeek struct {
+	WeekStart      string               `json:"week_start"`
+	DemandHours    float64              `json:"demand_hours"`
+	CapacityHours  float64              `json:"capacity_hours"`
+	ShortfallHours float64              `json:"shortfall_hours"`
+	Regions        []CapacityRegionWeek `json:"regions"`
+}
+
+// CapacityShortfall is a day and time-of-day slot in a region where forecast demand exceeds
+// the hours caregivers are available
+type CapacityShortfall struct {
+	Date           string  `json:"date"`
+	Weekday        string  `json:"weekday"`
+	Bucket         string  `json:"bucket"`
+	Region         string  `json:"region"`
+	ScheduledHours float64 `json:"scheduled_hours"`
+	RecurringHours float64 `json:"recurring_hours"`
+	BaselineHours  float64 `json:"baseline_hours"`
+	DemandHours    float64 `json:"demand_hours"`
+	CapacityHours  float64 `json:"capacity_hours"`
+	ShortfallHours float64 `json:"shortfall_hours"`
+}
+
+type CapacityForecast struct {
+	From          string              `json:"from"`
+	Weeks         []CapacityWeek      `json:"weeks"`
+	Shortfalls    []CapacityShortfall `json:"shortfalls"`
+	LookbackWeeks int                 `json:"lookback_weeks"`
+}
+
+// CapacityForecastOptions selects the forecast: Weeks weeks from the Monday From, with the
+// baseline averaged over the LookbackWeeks weeks before HistoryEnd
+type CapacityForecastOptions struct {
+	From          time.Time
+	Weeks         int
+	LookbackWeeks int
+	HistoryEnd    time.Time
+	Region        string
+	Location      *time.Location
+}
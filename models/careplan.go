@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+// DaysOfWeek are the accepted day codes for care plan items, indexed by time.Weekday
+var DaysOfWeek = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// TaskTemplate is a reusable task description coordinators pick from when building care plans
+type TaskTemplate struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+	Description  string     `gorm:"type:varchar(200);not null" json:"description" binding:"required,max=200"`
+	Category     string     `gorm:"type:varchar(50);index" json:"category,omitempty" binding:"max=50"`
+	Instructions *string    `gorm:"type:text" json:"instructions,omitempty"`
+
+	ObservationFields []ObservationField `gorm:"foreignKey:TaskTemplateID;constraint:OnDelete:CASCADE" json:"observation_fields,omitempty" binding:"-"`
+}
+
+// CarePlan groups the task templates a client needs. At most one plan per client is active,
+// and its items are turned into tasks whenever a schedule is created for that client.
+type CarePlan struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	ClientID  uint           `gorm:"not null;index" json:"client_id"`
+	Name      string         `gorm:"type:varchar(100);not null" json:"name"`
+	Active    bool           `gorm:"not null;default:false;index" json:"active"`
+	CreatedBy uint           `json:"created_by"`
+	Items     []CarePlanItem `gorm:"foreignKey:CarePlanID;constraint:OnDelete:CASCADE" json:"items"`
+}
+
+// CarePlanItem places a template in a care plan. DaysOfWeek is a comma-separated list of
+// day codes (e.g. "mon,wed,fri"); empty means every day.
+type CarePlanItem struct {
+	ID             uint         `gorm:"primaryKey" json:"id"`
+	CarePlanID     uint         `gorm:"not null;index" json:"care_plan_id"`
+	TaskTemplateID uint         `gorm:"not null;index" json:"task_template_id"`
+	DaysOfWeek     string       `gorm:"type:varchar(27)" json:"days_of_week"`
+	SortOrder      int          `gorm:"not null;default:0" json:"sort_order"`
+	TaskTemplate   TaskTemplate `gorm:"foreignKey:TaskTemplateID" json:"task_template"`
+}
+
+type CarePlanItemInput struct {
+	TaskTemplateID uint     `json:"task_template_id" binding:"required"`
+	DaysOfWeek     []string `json:"days_of_week,omitempty" binding:"dive,oneof=sun mon tue wed thu fri sat"`
+	SortOrder      int      `json:"sort_order,omitempty"`
+}
+
+type CarePlanRequest struct {
+	Name   string              `json:"name" binding:"required,max=100"`
+	Active bool                `json:"active"`
+	Items  []CarePlanItemInput `json:"items" binding:"dive"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// Client is the person receiving care (the Medicaid "member" in EVV terms)
+type Client struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+	FullName   string     `gorm:"type:varchar(100);not null" json:"full_name" binding:"required"`
+	MedicaidID string     `gorm:"type:varchar(50);index" json:"medicaid_id"`
+	Address    string     `gorm:"type:varchar(200)" json:"address"`
+	Latitude   *float64   `gorm:"type:decimal(10,8)" json:"latitude"`
+	Longitude  *float64   `gorm:"type:decimal(11,8)" json:"longitude"`
+	// PayerID is who is invoiced for the client's visits
+	PayerID *uint `gorm:"index" json:"payer_id,omitempty"`
+	// Region groups clients for capacity planning; caregiver availability names the regions
+	// it covers
+	Region string `gorm:"type:varchar(50);index" json:"region" binding:"max=50"`
+}
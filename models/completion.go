@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	// TASK_POLICY_OFF lets a visit end regardless of task state
+	TASK_POLICY_OFF = "off"
+	// TASK_POLICY_BLOCK refuses checkout while any task is unresolved
+	TASK_POLICY_BLOCK = "block"
+	// TASK_POLICY_OVERRIDE refuses checkout unless a supervisor has issued an override token
+	TASK_POLICY_OVERRIDE = "override"
+)
+
+// UnresolvedTask is a task that is neither completed nor explained with a not-completed reason
+type UnresolvedTask struct {
+	ID          uint   `json:"id"`
+	Description string `json:"description"`
+	Kind        string `json:"kind"`
+}
+
+// TaskCompletionOverride lets one checkout of a visit proceed with unresolved tasks.
+// Only the SHA-256 of the token is stored; the token itself is shown once to the supervisor.
+type TaskCompletionOverride struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ScheduleID uint       `gorm:"not null;index" json:"schedule_id"`
+	TokenHash  string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	Reason     string     `gorm:"type:text;not null" json:"reason"`
+	IssuedBy   uint       `gorm:"not null" json:"issued_by"`
+	ExpiresAt  time.Time  `gorm:"type:datetime;not null" json:"expires_at"`
+	UsedAt     *time.Time `gorm:"type:datetime" json:"used_at,omitempty"`
+}
+
+type CompletionOverrideRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	CORRECTION_STATUS_PENDING  = "pending"
+	CORRECTION_STATUS_APPROVED = "approved"
+	CORRECTION_STATUS_REJECTED = "rejected"
+
+	EVV_REASON_OTHER = "OTHER"
+)
+
+// EVVReasonCodes are the standard reason codes accepted for a manual visit correction
+var EVVReasonCodes = map[string]string{
+	"FORGOT_CLOCK_IN":      "Caregiver forgot to clock in",
+	"FORGOT_CLOCK_OUT":     "Caregiver forgot to clock out",
+	"DEVICE_FAILURE":       "Mobile device or application failure",
+	"NO_GPS_SIGNAL":        "GPS location unavailable at the service location",
+	"SERVICE_OUTSIDE_HOME": "Service provided outside the member's home",
+	"WRONG_TIME_RECORDED":  "Incorrect time recorded at clock in or out",
+	"WRONG_VISIT_SELECTED": "Caregiver clocked in or out on the wrong visit",
+	"EMERGENCY":            "Emergency prevented normal clock in or out",
+	EVV_REASON_OTHER:       "Other (note required)",
+}
+
+// VisitCorrection is a proposed manual edit to a visit's EVV times and locations.
+// The Original* fields keep the values the visit had before an approved correction was applied.
+type VisitCorrection struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ScheduleID  uint       `gorm:"not null;index" json:"schedule_id"`
+	RequestedBy uint       `gorm:"not null" json:"requested_by"`
+	ReasonCode  string     `gorm:"type:varchar(50);not null" json:"reason_code"`
+	Note        *string    `gorm:"type:text" json:"note,omitempty"`
+	Status      string     `gorm:"type:enum('pending','approved','rejected');default:'pending';index" json:"status"`
+	ReviewedBy  *uint      `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time `gorm:"type:datetime" json:"reviewed_at,omitempty"`
+	ReviewNote  *string    `gorm:"type:text" json:"review_note,omitempty"`
+
+	StartTime *time.Time `gorm:"type:datetime" json:"start_time,omitempty"`
+	EndTime   *time.Time `gorm:"type:datetime" json:"end_time,omitempty"`
+	StartLat  *float64   `gorm:"type:decimal(10,8)" json:"start_lat,omitempty"`
+	StartLon  *float64   `gorm:"type:decimal(11,8)" json:"start_lon,omitempty"`
+	EndLat    *float64   `gorm:"type:decimal(10,8)" json:"end_lat,omitempty"`
+	EndLon    *float64   `gorm:"type:decimal(11,8)" json:"end_lon,omitempty"`
+
+	OriginalStatus    string     `gorm:"type:varchar(20)" json:"original_status"`
+	OriginalStartTime *time.Time `gorm:"type:datetime" json:"original_start_time"`
+	OriginalEndTime   *time.Time `gorm:"type:datetime" json:"original_end_time"`
+	OriginalStartLat  *float64   `gorm:"type:decimal(10,8)" json:"original_start_lat"`
+	OriginalStartLon  *float64   `gorm:"type:decimal(11,8)" json:"original_start_lon"`
+	OriginalEndLat    *float64   `gorm:"type:decimal(10,8)" json:"original_end_lat"`
+	OriginalEndLon    *float64   `gorm:"type:decimal(11,8)" json:"original_end_lon"`
+}
+
+type VisitCorrectionRequest struct {
+	ReasonCode string     `json:"reason_code" binding:"required"`
+	Note       *string    `json:"note"`
+	StartTime  *time.Time `json:"start_time"`
+	EndTime    *time.Time `json:"end_time"`
+	StartLat   *float64   `json:"start_lat"`
+	StartLon   *float64   `json:"start_lon"`
+	EndLat     *float64   `json:"end_lat"`
+	EndLon     *float64   `json:"end_lon"`
+}
+
+type CorrectionReviewRequest struct {
+	ReviewNote *string `json:"review_note"`
+}
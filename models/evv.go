@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	EVV_FORMAT_CSV  = "csv"
+	EVV_FORMAT_JSON = "json"
+
+	EVV_SUBMISSION_GENERATED = "generated"
+	EVV_SUBMISSION_SUBMITTED = "submitted"
+	EVV_SUBMISSION_ACCEPTED  = "accepted"
+	EVV_SUBMISSION_REJECTED  = "rejected"
+)
+
+// EVV data elements that an export schema can map to a column or JSON key.
+// The first six cover the 21st Century Cures Act required elements.
+const (
+	EVV_ELEMENT_SERVICE_CODE     = "service_code"
+	EVV_ELEMENT_MEMBER_ID        = "member_id"
+	EVV_ELEMENT_VISIT_DATE       = "visit_date"
+	EVV_ELEMENT_LOCATION         = "location"
+	EVV_ELEMENT_CAREGIVER_ID     = "caregiver_id"
+	EVV_ELEMENT_START_TIME       = "start_time"
+	EVV_ELEMENT_END_TIME         = "end_time"
+	EVV_ELEMENT_VISIT_ID         = "visit_id"
+	EVV_ELEMENT_MEMBER_NAME      = "member_name"
+	EVV_ELEMENT_CAREGIVER_NAME   = "caregiver_name"
+	EVV_ELEMENT_START_LAT        = "start_lat"
+	EVV_ELEMENT_START_LON        = "start_lon"
+	EVV_ELEMENT_END_LAT          = "end_lat"
+	EVV_ELEMENT_END_LON          = "end_lon"
+	EVV_ELEMENT_PROVIDER_ID      = "provider_id"
+	EVV_ELEMENT_CORRECTION_CODE  = "correction_reason_code"
+	EVV_ELEMENT_SUBMISSION_TYPE  = "submission_type"
+	EVV_ELEMENT_SUBMISSION_COUNT = "submission_attempt"
+	EVV_ELEMENT_SIGNATURE_HASH   = "client_signature_hash"
+	EVV_ELEMENT_SIGNER_NAME      = "client_signer_name"
+	EVV_ELEMENT_SIGNER_RELATION  = "client_signer_relationship"
+)
+
+// EVVSchemaField maps one EVV data element to a column (CSV) or key (JSON) in a state's file layout
+type EVVSchemaField struct {
+	Name     string `json:"name"`
+	Element  string `json:"element"`
+	Layout   string `json:"layout,omitempty"`
+	Required bool   `json:"required,omitempty"`
+}
+
+// EVVExportSchema describes the file a state aggregator expects
+type EVVExportSchema struct {
+	State    string           `json:"state"`
+	Format   string           `json:"format"`
+	Timezone string           `json:"timezone,omitempty"`
+	Fields   []EVVSchemaField `json:"fields"`
+}
+
+// EVVExportBatch is one generated aggregator file
+type EVVExportBatch struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	State       string     `gorm:"type:varchar(20);not null;index" json:"state"`
+	Format      string     `gorm:"type:varchar(10);not null" json:"format"`
+	PeriodStart time.Time  `gorm:"type:datetime;not null" json:"period_start"`
+	PeriodEnd   time.Time  `gorm:"type:datetime;not null" json:"period_end"`
+	RecordCount int        `json:"record_count"`
+	CreatedBy   uint       `json:"created_by"`
+	SubmittedAt *time.Time `gorm:"type:datetime" json:"submitted_at,omitempty"`
+	Content     string     `gorm:"type:longtext" json:"-"`
+
+	Submissions []EVVSubmission `gorm:"foreignKey:BatchID" json:"submissions,omitempty"`
+}
+
+// EVVSubmission tracks one visit inside one batch and what the aggregator said about it.
+// A visit corrected or rejected after submission is picked up again by the next export.
+type EVVSubmission struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	BatchID         uint       `gorm:"not null;index" json:"batch_id"`
+	ScheduleID      uint       `gorm:"not null;index" json:"schedule_id"`
+	Attempt         int        `gorm:"not null;default:1" json:"attempt"`
+	Status          string     `gorm:"type:enum('generated','submitted','accepted','rejected');default:'generated';index" json:"status"`
+	RejectionReason *string    `gorm:"type:text" json:"rejection_reason,omitempty"`
+	RespondedAt     *time.Time `gorm:"type:datetime" json:"responded_at,omitempty"`
+}
+
+type EVVExportRequest struct {
+	State string `json:"state" binding:"required"`
+	From  string `json:"from" binding:"required"`
+	To    string `json:"to" binding:"required"`
+}
+
+// EVVAggregatorResult is the per-visit outcome reported by an aggregator
+type EVVAggregatorResult struct {
+	VisitID uint   `json:"visit_id"`
+	Status  string `json:"status" binding:"required,oneof=accepted rejected"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type EVVAggregatorResponse struct {
+	Results []EVVAggregatorResult `json:"results" binding:"required,dive"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	INCIDENT_STATUS_OPEN         = "open"
+	INCIDENT_STATUS_UNDER_REVIEW = "under_review"
+	INCIDENT_STATUS_CLOSED       = "closed"
+
+	INCIDENT_SEVERITY_LOW      = "low"
+	INCIDENT_SEVERITY_MEDIUM   = "medium"
+	INCIDENT_SEVERITY_HIGH     = "high"
+	INCIDENT_SEVERITY_CRITICAL = "critical"
+)
+
+// IncidentCategories are the accepted incident categories and their descriptions
+var IncidentCategories = map[string]string{
+	"fall":             "Fall, with or without injury",
+	"injury":           "Injury to the client or caregiver",
+	"medication_error": "Wrong drug, dose, route or time, or an omitted dose",
+	"behavioral":       "Aggressive or unsafe behaviour",
+	"abuse_neglect":    "Suspected abuse or neglect",
+	"property_damage":  "Damage to or loss of property",
+	"other":            "Other",
+}
+
+// IncidentTransitions lists the statuses an incident may move to from each status
+var IncidentTransitions = map[string][]string{
+	INCIDENT_STATUS_OPEN:         {INCIDENT_STATUS_UNDER_REVIEW},
+	INCIDENT_STATUS_UNDER_REVIEW: {INCIDENT_STATUS_OPEN, INCIDENT_STATUS_CLOSED},
+}
+
+// IsHighSeverity reports whether an incident of this severity must be escalated immediately
+func IsHighSeverity(severity string) bool {
+	return severity == INCIDENT_SEVERITY_HIGH || severity == INCIDENT_SEVERITY_CRITICAL
+}
+
+// IncidentParty is a person involved in or witnessing an incident
+type IncidentParty struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Role string `json:"role" binding:"required,max=50"`
+	Note string `json:"note,omitempty" binding:"max=500"`
+}
+
+// Incident is a fall, injury, medication error or similar event reported during a visit
+type Incident struct {
+	ID              uint            `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	ScheduleID      uint            `gorm:"not null;index" json:"schedule_id"`
+	ClientID        *uint           `gorm:"index" json:"client_id,omitempty"`
+	ReportedBy      uint            `gorm:"not null" json:"reported_by"`
+	OccurredAt      time.Time       `gorm:"type:datetime;not null;index" json:"occurred_at"`
+	Category        string          `gorm:"type:varchar(30);not null;index" json:"category"`
+	Severity        string          `gorm:"type:enum('low','medium','high','critical');not null;index" json:"severity"`
+	Status          string          `gorm:"type:enum('open','under_review','closed');default:'open';index" json:"status"`
+	Narrative       string          `gorm:"type:text;not null" json:"narrative"`
+	InvolvedParties []IncidentParty `gorm:"type:text;serializer:json" json:"involved_parties"`
+	ActionsTaken    *string         `gorm:"type:text" json:"actions_taken,omitempty"`
+	ReviewedBy      *uint           `json:"reviewed_by,omitempty"`
+	ReviewNote      *string         `gorm:"type:text" json:"review_note,omitempty"`
+	ClosedBy        *uint           `json:"closed_by,omitempty"`
+	ClosedAt        *time.Time      `gorm:"type:datetime" json:"closed_at,omitempty"`
+	Resolution      *string         `gorm:"type:text" json:"resolution,omitempty"`
+	NotifiedAt      *time.Time      `gorm:"type:datetime" json:"notified_at,omitempty"`
+	Attachments     []Attachment    `gorm:"foreignKey:IncidentID" json:"attachments,omitempty"`
+}
+
+type IncidentRequest struct {
+	OccurredAt      *time.Time      `json:"occurred_at"`
+	Category        string          `json:"category" binding:"required"`
+	Severity        string          `json:"severity" binding:"required,oneof=low medium high critical"`
+	Narrative       string          `json:"narrative" binding:"required,max=10000"`
+	InvolvedParties []IncidentParty `json:"involved_parties" binding:"omitempty,max=20,dive"`
+	ActionsTaken    *string         `json:"actions_taken"`
+	// AttachmentIDs links photos or documents already uploaded to the same visit
+	AttachmentIDs []uint `json:"attachment_ids" binding:"omitempty,max=20"`
+}
+
+type IncidentStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=open under_review closed"`
+	// Note is the review note, or the resolution when closing
+	Note string `json:"note" binding:"max=5000"`
+}
+
+type IncidentFilter struct {
+	Status   string
+	Severity string
+	Category string
+	ClientID uint
+	From     *time.Time
+	To       *time.Time
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+// LaborRuleSet holds overtime thresholds. A zero threshold disables that rule, so the default
+// federal set only has WeeklyOvertimeAfterMinutes = 2400 (40 hours).
+type LaborRuleSet struct {
+	ID                          uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt                   time.Time `json:"created_at"`
+	UpdatedAt                   time.Time `json:"updated_at"`
+	Name                        string    `gorm:"type:varchar(100);not null;uniqueIndex" json:"name"`
+	DailyOvertimeAfterMinutes   int       `gorm:"not null;default:0" json:"daily_overtime_after_minutes"`
+	DailyDoubleTimeAfterMinutes int       `gorm:"not null;default:0" json:"daily_double_time_after_minutes"`
+	WeeklyOvertimeAfterMinutes  int       `gorm:"not null;default:0" json:"weekly_overtime_after_minutes"`
+	IncludeTravel               bool      `gorm:"not null;default:true" json:"include_travel"`
+}
+
+type LaborRuleSetRequest struct {
+	Name                        string `json:"name" binding:"required,max=100"`
+	DailyOvertimeAfterMinutes   int    `json:"daily_overtime_after_minutes" binding:"min=0,max=1440"`
+	DailyDoubleTimeAfterMinutes int    `json:"daily_double_time_after_minutes" binding:"min=0,max=1440"`
+	WeeklyOvertimeAfterMinutes  int    `json:"weekly_overtime_after_minutes" binding:"min=0,max=10080"`
+	IncludeTravel               *bool  `json:"include_travel"`
+}
+
+type LaborRuleSetAssignment struct {
+	// LaborRuleSetID of nil puts the caregiver back on the default rules
+	LaborRuleSetID *uint `json:"labor_rule_set_id"`
+}
+
+// LaborDay is one agency-local day of a labor breakdown
+type LaborDay struct {
+	Date              string `json:"date"`
+	VisitMinutes      int    `json:"visit_minutes"`
+	TravelMinutes     int    `json:"travel_minutes"`
+	RegularMinutes    int    `json:"regular_minutes"`
+	OvertimeMinutes   int    `json:"overtime_minutes"`
+	DoubleTimeMinutes int    `json:"double_time_minutes"`
+}
+
+// LaborBreakdown splits a caregiver's hours in a period into regular, overtime and double time
+type LaborBreakdown struct {
+	UserID            uint         `json:"user_id"`
+	PeriodStart       time.Time    `json:"period_start"`
+	PeriodEnd         time.Time    `json:"period_end"`
+	RuleSet           LaborRuleSet `json:"rule_set"`
+	Projected         bool         `json:"projected"`
+	VisitCount        int          `json:"visit_count"`
+	VisitMinutes      int          `json:"visit_minutes"`
+	TravelMinutes     int          `json:"travel_minutes"`
+	TravelMeters      float64      `json:"travel_meters"`
+	RegularMinutes    int          `json:"regular_minutes"`
+	OvertimeMinutes   int          `json:"overtime_minutes"`
+	DoubleTimeMinutes int          `json:"double_time_minutes"`
+	Days              []LaborDay   `json:"days"`
+}
+
+// LaborWarning describes the overtime a new or proposed assignment would add to its week
+type LaborWarning struct {
+	Message                string          `json:"message"`
+	OvertimeMinutesAdded   int             `json:"overtime_minutes_added"`
+	DoubleTimeMinutesAdded int             `json:"double_time_minutes_added"`
+	Projected              *LaborBreakdown `json:"projected"`
+}
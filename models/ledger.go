@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	LEDGER_EVENT_VISIT_START        = "visit_start"
+	LEDGER_EVENT_VISIT_END          = "visit_end"
+	LEDGER_EVENT_VISIT_CANCEL_START = "visit_cancel_start"
+	LEDGER_EVENT_TASK_STATUS        = "task_status"
+	LEDGER_EVENT_VISIT_CORRECTION   = "visit_correction"
+	LEDGER_EVENT_VISIT_PAUSE        = "visit_pause"
+	LEDGER_EVENT_VISIT_RESUME       = "visit_resume"
+	LEDGER_EVENT_MEDICATION         = "medication_administration"
+	LEDGER_EVENT_INCIDENT           = "incident_reported"
+)
+
+// ErrLedgerImmutable is returned when something tries to modify or remove a visit ledger entry
+var ErrLedgerImmutable = errors.New("visit ledger entries are append-only")
+
+// LEDGER_GENESIS_HASH is the previous hash of the very first ledger entry
+var LEDGER_GENESIS_HASH = strings.Repeat("0", 64)
+
+// VisitLedgerEntry is one link in the tamper-evident chain of EVV visit events.
+// Hash covers the entry's own fields plus PrevHash, so altering or removing any
+// entry breaks every link after it. PrevHash is unique so two appends racing for
+// the same head (including the genesis entry) cannot both succeed.
+type VisitLedgerEntry struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ScheduleID uint      `gorm:"not null;index" json:"schedule_id"`
+	TaskID     *uint     `gorm:"index" json:"task_id,omitempty"`
+	EventType  string    `gorm:"type:varchar(50);not null" json:"event_type"`
+	ActorID    uint      `json:"actor_id"`
+	OccurredAt time.Time `gorm:"type:datetime(6);not null;index" json:"occurred_at"`
+	Payload    string    `gorm:"type:text;not null" json:"payload"`
+	PrevHash   string    `gorm:"type:char(64);not null;uniqueIndex" json:"prev_hash"`
+	Hash       string    `gorm:"type:char(64);not null;uniqueIndex" json:"hash"`
+}
+
+// BeforeUpdate rejects any attempt to rewrite a ledger entry
+func (e *VisitLedgerEntry) BeforeUpdate(tx *gorm.DB) error {
+	return ErrLedgerImmutable
+}
+
+// BeforeDelete rejects any attempt to remove a ledger entry
+func (e *VisitLedgerEntry) BeforeDelete(tx *gorm.DB) error {
+	return ErrLedgerImmutable
+}
+
+type LedgerFilter struct {
+	ScheduleID uint
+	From       *time.Time
+	To         *time.Time
+}
+
+// LedgerVerification reports the outcome of walking the chain
+type LedgerVerification struct {
+	Valid          bool   `json:"valid"`
+	EntriesChecked int    `json:"entries_checked"`
+	BrokenEntryID  *uint  `json:"broken_entry_id,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+)
+
+// LocationPing is one breadcrumb posted by the caregiver's device while a visit is in progress.
+// Rows are kept narrow (no soft delete or update timestamps) since a visit can produce hundreds.
+type LocationPing struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ScheduleID uint      `gorm:"not null;uniqueIndex:idx_ping_schedule_time,priority:1" json:"schedule_id"`
+	RecordedAt time.Time `gorm:"type:datetime(3);not null;uniqueIndex:idx_ping_schedule_time,priority:2" json:"recorded_at"`
+	Latitude   float64   `gorm:"type:decimal(9,6);not null" json:"latitude"`
+	Longitude  float64   `gorm:"type:decimal(9,6);not null" json:"longitude"`
+	AccuracyM  *float32  `gorm:"type:float" json:"accuracy_m,omitempty"`
+}
+
+type LocationPingInput struct {
+	Latitude   float64    `json:"latitude" binding:"required"`
+	Longitude  float64    `json:"longitude" binding:"required"`
+	AccuracyM  *float32   `json:"accuracy_m,omitempty"`
+	RecordedAt *time.Time `json:"recorded_at,omitempty"`
+}
+
+// LocationPingRequest lets the app upload pings one at a time or in batches buffered while offline
+type LocationPingRequest struct {
+	Pings []LocationPingInput `json:"pings" binding:"required,min=1,max=500,dive"`
+}
+
+// GeofenceExcursion is a stretch of the visit spent outside the client geofence
+type GeofenceExcursion struct {
+	StartedAt         time.Time `json:"started_at"`
+	EndedAt           time.Time `json:"ended_at"`
+	DurationSeconds   int64     `json:"duration_seconds"`
+	MaxDistanceMeters float64   `json:"max_distance_meters"`
+	Returned          bool      `json:"returned"`
+}
+
+// GeofenceSummary describes how a visit's breadcrumb trail relates to the client geofence.
+// Time between two pings is attributed to the position of the earlier one.
+type GeofenceSummary struct {
+	ScheduleID     uint                `json:"schedule_id"`
+	CenterLat      *float64            `json:"center_lat"`
+	CenterLon      *float64            `json:"center_lon"`
+	RadiusMeters   float64             `json:"radius_meters"`
+	PingCount      int                 `json:"ping_count"`
+	FirstPingAt    *time.Time          `json:"first_ping_at,omitempty"`
+	LastPingAt     *time.Time          `json:"last_ping_at,omitempty"`
+	InsideSeconds  int64               `json:"inside_seconds"`
+	OutsideSeconds int64               `json:"outside_seconds"`
+	Excursions     []GeofenceExcursion `json:"excursions"`
+	Flagged        bool                `json:"flagged"`
+}
+
+type GeoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	MED_ADMIN_STATUS_PENDING = "pending"
+	MED_ADMIN_STATUS_GIVEN   = "given"
+	MED_ADMIN_STATUS_REFUSED = "refused"
+	MED_ADMIN_STATUS_HELD    = "held"
+	MED_ADMIN_STATUS_MISSED  = "missed"
+)
+
+// MedicationRoutes are the accepted administration routes
+var MedicationRoutes = []string{"oral", "sublingual", "topical", "transdermal", "inhaled", "nasal", "ophthalmic", "otic", "rectal", "injection", "other"}
+
+// MedicationOrder is one drug a client takes on a schedule. AdminTimes are comma-separated
+// HH:MM times in Timezone; a dose is planned on every visit whose window covers one of them.
+type MedicationOrder struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	ClientID     uint       `gorm:"not null;index" json:"client_id"`
+	DrugName     string     `gorm:"type:varchar(100);not null" json:"drug_name"`
+	Dose         string     `gorm:"type:varchar(50);not null" json:"dose"`
+	Route        string     `gorm:"type:varchar(20);not null" json:"route"`
+	AdminTimes   string     `gorm:"type:varchar(100);not null" json:"admin_times"`
+	Timezone     string     `gorm:"type:varchar(50);not null;default:'UTC'" json:"timezone"`
+	StartDate    time.Time  `gorm:"type:date;not null" json:"start_date"`
+	EndDate      *time.Time `gorm:"type:date" json:"end_date,omitempty"`
+	Instructions *string    `gorm:"type:text" json:"instructions,omitempty"`
+	PrescribedBy string     `gorm:"type:varchar(100)" json:"prescribed_by,omitempty"`
+	Active       bool       `gorm:"not null;default:true;index" json:"active"`
+	CreatedBy    uint       `json:"created_by"`
+}
+
+// MedicationAdministration is one planned dose on a visit and what happened to it.
+// Each has a matching medication task on the schedule.
+type MedicationAdministration struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	OrderID        uint       `gorm:"not null;uniqueIndex:idx_med_admin_order_time,priority:1" json:"order_id"`
+	ClientID       uint       `gorm:"not null;index" json:"client_id"`
+	ScheduleID     uint       `gorm:"not null;index" json:"schedule_id"`
+	TaskID         uint       `gorm:"not null;index" json:"task_id"`
+	ScheduledFor   time.Time  `gorm:"type:datetime;not null;uniqueIndex:idx_med_admin_order_time,priority:2;index" json:"scheduled_for"`
+	Status         string     `gorm:"type:enum('pending','given','refused','held','missed');not null;default:'pending';index" json:"status"`
+	Reason         *string    `gorm:"type:text" json:"reason,omitempty"`
+	AdministeredAt *time.Time `gorm:"type:datetime" json:"administered_at,omitempty"`
+	RecordedBy     *uint      `json:"recorded_by,omitempty"`
+
+	Order MedicationOrder `gorm:"foreignKey:OrderID" json:"order"`
+}
+
+type MedicationOrderRequest struct {
+	DrugName     string   `json:"drug_name" binding:"required,max=100"`
+	Dose         string   `json:"dose" binding:"required,max=50"`
+	Route        string   `json:"route" binding:"required"`
+	AdminTimes   []string `json:"admin_times" binding:"required,min=1,dive,datetime=15:04"`
+	Timezone     string   `json:"timezone,omitempty"`
+	StartDate    string   `json:"start_date" binding:"required,datetime=2006-01-02"`
+	EndDate      string   `json:"end_date,omitempty" binding:"omitempty,datetime=2006-01-02"`
+	Instructions *string  `json:"instructions,omitempty"`
+	PrescribedBy string   `json:"prescribed_by,omitempty" binding:"max=100"`
+}
+
+type MedicationAdministrationRequest struct {
+	Status         string     `json:"status" binding:"required,oneof=given refused held"`
+	Reason         *string    `json:"reason,omitempty"`
+	AdministeredAt *time.Time `json:"administered_at,omitempty"`
+}
+
+// MARCell is one planned dose on the MAR grid
+type MARCell struct {
+	AdministrationID uint       `json:"administration_id"`
+	Status           string     `json:"status"`
+	AdministeredAt   *time.Time `json:"administered_at,omitempty"`
+	RecordedBy       *uint      `json:"recorded_by,omitempty"`
+	Reason           *string    `json:"reason,omitempty"`
+}
+
+// MARRow is one order at one administration time; Days[i] is day i+1 of the month (nil when no dose was planned)
+type MARRow struct {
+	Order MedicationOrder `json:"order"`
+	Time  string          `json:"time"`
+	Days  []*MARCell      `json:"days"`
+}
+
+// MARGrid is a client's medication administration record for one month
+type MARGrid struct {
+	ClientID    uint     `json:"client_id"`
+	ClientName  string   `json:"client_name"`
+	Month       string   `json:"month"`
+	DaysInMonth int      `json:"days_in_month"`
+	Rows        []MARRow `json:"rows"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	OBSERVATION_TYPE_NUMBER  = "number"
+	OBSERVATION_TYPE_BOOLEAN = "boolean"
+	OBSERVATION_TYPE_CHOICE  = "choice"
+	OBSERVATION_TYPE_TEXT    = "text"
+)
+
+// ObservationField is a structured result captured when a task made from a template is done,
+// e.g. "systolic" (number, mmHg) for "Check blood pressure". MinValue/MaxValue reject
+// implausible readings; AlertBelow/AlertAbove and AlertValues raise alerts for staff.
+type ObservationField struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	TaskTemplateID uint      `gorm:"not null;uniqueIndex:idx_template_field_key,priority:1" json:"task_template_id"`
+	Key            string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_template_field_key,priority:2" json:"key"`
+	Label          string    `gorm:"type:varchar(100);not null" json:"label"`
+	Type           string    `gorm:"type:enum('number','boolean','choice','text');not null" json:"type"`
+	Unit           string    `gorm:"type:varchar(20)" json:"unit,omitempty"`
+	Required       bool      `gorm:"not null;default:false" json:"required"`
+	MinValue       *float64  `json:"min_value,omitempty"`
+	MaxValue       *float64  `json:"max_value,omitempty"`
+	Choices        []string  `gorm:"type:text;serializer:json" json:"choices,omitempty"`
+	AlertBelow     *float64  `json:"alert_below,omitempty"`
+	AlertAbove     *float64  `json:"alert_above,omitempty"`
+	AlertValues    []string  `gorm:"type:text;serializer:json" json:"alert_values,omitempty"`
+	SortOrder      int       `gorm:"not null;default:0" json:"sort_order"`
+}
+
+type ObservationFieldInput struct {
+	Key         string   `json:"key" binding:"required,max=50"`
+	Label       string   `json:"label" binding:"required,max=100"`
+	Type        string   `json:"type" binding:"required,oneof=number boolean choice text"`
+	Unit        string   `json:"unit,omitempty" binding:"max=20"`
+	Required    bool     `json:"required"`
+	MinValue    *float64 `json:"min_value,omitempty"`
+	MaxValue    *float64 `json:"max_value,omitempty"`
+	Choices     []string `json:"choices,omitempty"`
+	AlertBelow  *float64 `json:"alert_below,omitempty"`
+	AlertAbove  *float64 `json:"alert_above,omitempty"`
+	AlertValues []string `json:"alert_values,omitempty"`
+	SortOrder   int      `json:"sort_order,omitempty"`
+}
+
+type ObservationFieldsRequest struct {
+	Fields []ObservationFieldInput `json:"fields" binding:"dive"`
+}
+
+// TaskObservation is one recorded value. Rows are never updated; recording the same task
+// again adds new rows, so ClientID gives a per-client history of every reading.
+type TaskObservation struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	TaskID      uint      `gorm:"not null;index" json:"task_id"`
+	ScheduleID  uint      `gorm:"not null;index" json:"schedule_id"`
+	ClientID    *uint     `gorm:"index:idx_observation_client_key,priority:1" json:"client_id,omitempty"`
+	FieldID     uint      `gorm:"not null" json:"field_id"`
+	Key         string    `gorm:"type:varchar(50);not null;index:idx_observation_client_key,priority:2" json:"key"`
+	Type        string    `gorm:"type:varchar(10);not null" json:"type"`
+	NumberValue *float64  `json:"number_value,omitempty"`
+	BoolValue   *bool     `json:"bool_value,omitempty"`
+	TextValue   *string   `gorm:"type:text" json:"text_value,omitempty"`
+	Unit        string    `gorm:"type:varchar(20)" json:"unit,omitempty"`
+	RecordedBy  uint      `gorm:"not null" json:"recorded_by"`
+	RecordedAt  time.Time `gorm:"type:datetime;not null;index" json:"recorded_at"`
+}
+
+// ObservationInput is a value sent with a task status update. Value must match the field
+// type: a number, true/false, one of the choices, or text.
+type ObservationInput struct {
+	Key   string      `json:"key" binding:"required"`
+	Value interface{} `json:"value" binding:"required"`
+}
+
+type TaskStatusRequest struct {
+	Status       string             `json:"status" validate:"required,oneof=completed not_completed"`
+	Reason       *string            `json:"reason"`
+	Observations []ObservationInput `json:"observations,omitempty" binding:"dive"`
+}
+
+type ObservationFilter struct {
+	ClientID uint
+	Key      string
+	From     *time.Time
+	To       *time.Time
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	// PAYROLL_ROWS_CAREGIVER writes one line per caregiver; PAYROLL_ROWS_EARNING writes one line
+	// per caregiver and earning code (regular, overtime, double time, mileage)
+	PAYROLL_ROWS_CAREGIVER = "caregiver"
+	PAYROLL_ROWS_EARNING   = "earning"
+
+	PAYROLL_BATCH_EXPORTED = "exported"
+	PAYROLL_BATCH_VOIDED   = "voided"
+
+	PAYROLL_EARNING_REGULAR     = "REG"
+	PAYROLL_EARNING_OVERTIME    = "OT"
+	PAYROLL_EARNING_DOUBLE_TIME = "DT"
+	PAYROLL_EARNING_MILEAGE     = "MILE"
+)
+
+// Payroll data elements that a layout can map to a column
+const (
+	PAYROLL_ELEMENT_BATCH_ID          = "batch_id"
+	PAYROLL_ELEMENT_COMPANY_CODE      = "company_code"
+	PAYROLL_ELEMENT_EMPLOYEE_ID       = "employee_id"
+	PAYROLL_ELEMENT_CAREGIVER_ID      = "caregiver_id"
+	PAYROLL_ELEMENT_CAREGIVER_NAME    = "caregiver_name"
+	PAYROLL_ELEMENT_PERIOD_START      = "period_start"
+	PAYROLL_ELEMENT_PERIOD_END        = "period_end"
+	PAYROLL_ELEMENT_REGULAR_HOURS     = "regular_hours"
+	PAYROLL_ELEMENT_OVERTIME_HOURS    = "overtime_hours"
+	PAYROLL_ELEMENT_DOUBLE_TIME_HOURS = "double_time_hours"
+	PAYROLL_ELEMENT_TRAVEL_HOURS      = "travel_hours"
+	PAYROLL_ELEMENT_TOTAL_HOURS       = "total_hours"
+	PAYROLL_ELEMENT_PAY_RATE          = "pay_rate"
+	PAYROLL_ELEMENT_OVERTIME_RATE     = "overtime_rate"
+	PAYROLL_ELEMENT_DOUBLE_TIME_RATE  = "double_time_rate"
+	PAYROLL_ELEMENT_GROSS_PAY         = "gross_pay"
+	PAYROLL_ELEMENT_MILEAGE           = "mileage"
+	PAYROLL_ELEMENT_MILEAGE_AMOUNT    = "mileage_amount"
+	PAYROLL_ELEMENT_VISIT_COUNT       = "visit_count"
+	PAYROLL_ELEMENT_CONSTANT          = "constant"
+
+	// Earning elements are only filled on PAYROLL_ROWS_EARNING layouts
+	PAYROLL_ELEMENT_EARNING_CODE   = "earning_code"
+	PAYROLL_ELEMENT_EARNING_HOURS  = "earning_hours"
+	PAYROLL_ELEMENT_EARNING_RATE   = "earning_rate"
+	PAYROLL_ELEMENT_EARNING_AMOUNT = "earning_amount"
+)
+
+// PayrollLayoutField maps one payroll element to a column. Layout is a Go time layout for
+// period dates; Value is the text written by a constant field.
+type PayrollLayoutField struct {
+	Name    string `json:"name"`
+	Element string `json:"element"`
+	Layout  string `json:"layout,omitempty"`
+	Value   string `json:"value,omitempty"`
+}
+
+// PayrollLayout describes the file a payroll system imports
+type PayrollLayout struct {
+	Name      string               `json:"name"`
+	Rows      string               `json:"rows"`
+	Delimiter string               `json:"delimiter,omitempty"`
+	NoHeader  bool                 `json:"no_header,omitempty"`
+	Fields    []PayrollLayoutField `json:"fields"`
+	// EarningCodes renames REG, OT, DT and MILE for the target system
+	EarningCodes map[string]string `json:"earning_codes,omitempty"`
+}
+
+// PayrollBatch is one generated payroll file. Its approved timesheets and visits cannot be
+// exported again unless the batch is voided.
+type PayrollBatch struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	BatchCode      string     `gorm:"type:varchar(40);uniqueIndex" json:"batch_code"`
+	Layout         string     `gorm:"type:varchar(40);not null" json:"layout"`
+	PeriodStart    time.Time  `gorm:"type:datetime;not null;index" json:"period_start"`
+	PeriodEnd      time.Time  `gorm:"type:datetime;not null" json:"period_end"`
+	Status         string     `gorm:"type:enum('exported','voided');default:'exported';index" json:"status"`
+	CaregiverCount int        `json:"caregiver_count"`
+	VisitCount     int        `json:"visit_count"`
+	GrossPay       float64    `gorm:"type:decimal(12,2)" json:"gross_pay"`
+	MileageAmount  float64    `gorm:"type:decimal(12,2)" json:"mileage_amount"`
+	CreatedBy      uint       `json:"created_by"`
+	VoidedBy       *uint      `json:"voided_by,omitempty"`
+	VoidedAt       *time.Time `gorm:"type:datetime" json:"voided_at,omitempty"`
+	VoidReason     *string    `gorm:"type:text" json:"void_reason,omitempty"`
+	Content        string     `gorm:"type:longtext" json:"-"`
+
+	Lines  []PayrollLine       `gorm:"foreignKey:BatchID" json:"lines,omitempty"`
+	Visits []PayrollBatchVisit `gorm:"foreignKey:BatchID" json:"visits,omitempty"`
+}
+
+// PayrollLine is one caregiver's earnings in a batch
+type PayrollLine struct {
+	ID                uint    `gorm:"primaryKey" json:"id"`
+	BatchID           uint    `gorm:"not null;index" json:"batch_id"`
+	UserID            uint    `gorm:"not null;index" json:"user_id"`
+	TimesheetID       uint    `gorm:"not null;index" json:"timesheet_id"`
+	EmployeeID        string  `gorm:"type:varchar(50)" json:"employee_id"`
+	CaregiverName     string  `gorm:"type:varchar(100)" json:"caregiver_name"`
+	VisitCount        int     `json:"visit_count"`
+	RegularMinutes    int     `json:"regular_minutes"`
+	OvertimeMinutes   int     `json:"overtime_minutes"`
+	DoubleTimeMinutes int     `json:"double_time_minutes"`
+	TravelMinutes     int     `json:"travel_minutes"`
+	PayRate           float64 `gorm:"type:decimal(10,2)" json:"pay_rate"`
+	OvertimeRate      float64 `gorm:"type:decimal(10,2)" json:"overtime_rate"`
+	DoubleTimeRate    float64 `gorm:"type:decimal(10,2)" json:"double_time_rate"`
+	GrossPay          float64 `gorm:"type:decimal(12,2)" json:"gross_pay"`
+	MileageMiles      float64 `gorm:"type:decimal(10,1)" json:"mileage_miles"`
+	MileageAmount     float64 `gorm:"type:decimal(10,2)" json:"mileage_amount"`
+}
+
+// PayrollBatchVisit records that a visit was paid in a batch
+type PayrollBatchVisit struct {
+	ID            uint `gorm:"primaryKey" json:"id"`
+	BatchID       uint `gorm:"not null;index" json:"batch_id"`
+	ScheduleID    uint `gorm:"not null;index" json:"schedule_id"`
+	UserID        uint `gorm:"not null" json:"user_id"`
+	TimesheetID   uint `gorm:"not null;index" json:"timesheet_id"`
+	WorkedMinutes int  `json:"worked_minutes"`
+}
+
+type PayrollExportRequest struct {
+	Layout string `json:"layout" binding:"required"`
+	// Date is any day in the pay period to export (YYYY-MM-DD, agency timezone)
+	Date string `json:"date" binding:"required"`
+}
+
+type PayrollVoidRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// PayrollProfileRequest sets how a caregiver is paid; omitted fields are left unchanged
+type PayrollProfileRequest struct {
+	HourlyRate        *float64 `json:"hourly_rate" binding:"omitempty,min=0"`
+	PayrollEmployeeID *string  `json:"payroll_employee_id" binding:"omitempty,max=50"`
+}
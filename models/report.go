@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	REPORT_TYPE_VISITS                    = "visits"
+	REPORT_TYPE_TASKS                     = "tasks"
+	REPORT_TYPE_MISSED_TREND              = "missed_trend"
+	REPORT_TYPE_TRAVEL                    = "travel"
+	REPORT_TYPE_AUTHORIZATION_UTILIZATION = "authorization_utilization"
+
+	REPORT_FORMAT_PDF = "pdf"
+	REPORT_FORMAT_CSV = "csv"
+
+	REPORT_PERIOD_PREVIOUS_DAY   = "previous_day"
+	REPORT_PERIOD_PREVIOUS_WEEK  = "previous_week"
+	REPORT_PERIOD_PREVIOUS_MONTH = "previous_month"
+
+	REPORT_TRIGGER_SCHEDULE = "schedule"
+	REPORT_TRIGGER_MANUAL   = "manual"
+
+	REPORT_DELIVERY_PENDING  = "pending"
+	REPORT_DELIVERY_SENT     = "sent"
+	REPORT_DELIVERY_RETRYING = "retrying"
+	REPORT_DELIVERY_FAILED   = "failed"
+)
+
+// ReportFilters narrows a subscribed report. Fields a report type does not use are ignored.
+type ReportFilters struct {
+	UserID   uint   `json:"user_id,omitempty"`
+	ClientID uint   `json:"client_id,omitempty"`
+	GroupBy  string `json:"group_by,omitempty"`
+	Interval string `json:"interval,omitempty"`
+	Top      int    `json:"top,omitempty"`
+}
+
+// ReportSubscription emails a report to its recipients on a cron-style Cadence (minute hour
+// day-of-month month day-of-week) read in Timezone. Each run covers the Period before it, so a
+// Monday 07:00 run with previous_week reports the Monday to Sunday just ended.
+type ReportSubscription struct {
+	ID         uint          `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+	Name       string        `gorm:"type:varchar(100);not null" json:"name"`
+	ReportType string        `gorm:"type:enum('visits','tasks','missed_trend','travel','authorization_utilization');not null" json:"report_type"`
+	Format     string        `gorm:"type:enum('pdf','csv');default:'pdf'" json:"format"`
+	Period     string        `gorm:"type:enum('previous_day','previous_week','previous_month');default:'previous_week'" json:"period"`
+	Filters    ReportFilters `gorm:"type:text;serializer:json" json:"filters"`
+	Recipients []string      `gorm:"type:text;serializer:json" json:"recipients"`
+	Cadence    string        `gorm:"type:varchar(100);not null" json:"cadence"`
+	Timezone   string        `gorm:"type:varchar(64);not null" json:"timezone"`
+	Active     bool          `gorm:"not null;default:true;index" json:"active"`
+	NextRunAt  *time.Time    `gorm:"index" json:"next_run_at,omitempty"`
+	LastRunAt  *time.Time    `json:"last_run_at,omitempty"`
+	CreatedBy  uint          `gorm:"not null" json:"created_by"`
+}
+
+type ReportSubscriptionRequest struct {
+	Name       string        `json:"name" binding:"required,max=100"`
+	ReportType string        `json:"report_type" binding:"required,oneof=visits tasks missed_trend travel authorization_utilization"`
+	Format     string        `json:"format" binding:"omitempty,oneof=pdf csv"`
+	Period     string        `json:"period" binding:"omitempty,oneof=previous_day previous_week previous_month"`
+	Filters    ReportFilters `json:"filters"`
+	Recipients []string      `json:"recipients" binding:"required,min=1,max=20,dive,email"`
+	Cadence    string        `json:"cadence" binding:"required,max=100"`
+	// Timezone is an IANA name; the agency timezone when empty
+	Timezone string `json:"timezone" binding:"max=64"`
+	Active   *bool  `json:"active"`
+}
+
+// ReportDelivery is one run of a subscription, covering PeriodStart to PeriodEnd inclusive.
+// Failed sends are retried with backoff until the attempt limit, after which the delivery is
+// failed and can be retried by hand.
+type ReportDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	SubscriptionID uint       `gorm:"not null;index" json:"subscription_id"`
+	Trigger        string     `gorm:"type:enum('schedule','manual');default:'schedule'" json:"trigger"`
+	PeriodStart    time.Time  `gorm:"type:date;not null" json:"period_start"`
+	PeriodEnd      time.Time  `gorm:"type:date;not null" json:"period_end"`
+	Recipients     []string   `gorm:"type:text;serializer:json" json:"recipients"`
+	Status         string     `gorm:"type:enum('pending','sent','retrying','failed');default:'pending';index" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastError      string     `gorm:"type:varchar(500)" json:"last_error,omitempty"`
+	Filename       string     `gorm:"type:varchar(150)" json:"filename,omitempty"`
+	SentAt         *time.Time `json:"sent_at,omitempty"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+type RoutePreviewRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+	// Date is YYYY-MM-DD in the agency timezone
+	Date string `json:"date" binding:"required"`
+	// ScheduleIDs are the candidate visits; when empty, the caregiver's scheduled visits that day
+	ScheduleIDs []uint `json:"schedule_ids"`
+	// StartLat and StartLon are where the caregiver sets off from, e.g. home
+	StartLat *float64 `json:"start_lat"`
+	StartLon *float64 `json:"start_lon"`
+}
+
+// RouteStop is one visit in a proposed route
+type RouteStop struct {
+	Sequence      int       `json:"sequence"`
+	ScheduleID    uint      `json:"schedule_id"`
+	ClientName    string    `json:"client_name"`
+	PlannedStart  time.Time `json:"planned_start"`
+	ProposedStart time.Time `json:"proposed_start"`
+	ProposedEnd   time.Time `json:"proposed_end"`
+	EarliestStart time.Time `json:"earliest_start"`
+	LatestStart   time.Time `json:"latest_start"`
+	// TravelMinutes and TravelMeters are from the previous stop, or from the start point
+	TravelMinutes int     `json:"travel_minutes"`
+	TravelMeters  float64 `json:"travel_meters"`
+	WaitMinutes   int     `json:"wait_minutes"`
+	LateMinutes   int     `json:"late_minutes"`
+}
+
+// RouteTotals sums a route's travel and the minutes its visits start after their latest start
+type RouteTotals struct {
+	TravelMinutes int     `json:"travel_minutes"`
+	TravelMeters  float64 `json:"travel_meters"`
+	LateMinutes   int     `json:"late_minutes"`
+}
+
+// RouteUnplaced is a candidate visit the optimizer could not route, with the reason
+type RouteUnplaced struct {
+	ScheduleID uint   `json:"schedule_id"`
+	Reason     string `json:"reason"`
+}
+
+// RoutePlan compares a proposed visit order with the current plan (visits in shift_time order)
+type RoutePlan struct {
+	UserID       uint            `json:"user_id"`
+	Date         string          `json:"date"`
+	Provider     string          `json:"provider"`
+	Feasible     bool            `json:"feasible"`
+	Stops        []RouteStop     `json:"stops"`
+	Proposed     RouteTotals     `json:"proposed"`
+	Current      RouteTotals     `json:"current"`
+	CurrentStops []RouteStop     `json:"current_stops"`
+	SavedMinutes int             `json:"saved_minutes"`
+	SavedMeters  float64         `json:"saved_meters"`
+	Unplaced     []RouteUnplaced `json:"unplaced,omitempty"`
+	// FallbackLegs counts legs the routing provider failed on, estimated as the straight line
+	FallbackLegs int `json:"fallback_legs,omitempty"`
+}
@@ -13,26 +13,48 @@ const (
 )
 
 type Schedule struct {
-	ID         uint       `gorm:"primaryKey" json:"id"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
-	DeletedAt  *time.Time `gorm:"index" json:"deleted_at,omitempty"`
-	UserID     uint       `gorm:"not null;index:idx_user_schedule" json:"user_id"`
-	ClientName string     `gorm:"type:varchar(100);not null" json:"client_name" validate:"required"`
-	Location   string     `gorm:"type:varchar(200);not null" json:"location" validate:"required"`
-	ShiftTime  time.Time  `gorm:"type:datetime;not null;index" json:"shift_time" validate:"required"`
-	Status     string     `gorm:"type:enum('scheduled','in_progress','completed','cancelled','missed');default:'scheduled'" json:"status" validate:"required,oneof=scheduled in_progress completed cancelled missed"`
-	StartTime  *time.Time `gorm:"type:datetime" json:"start_time"`
-	EndTime    *time.Time `gorm:"type:datetime" json:"end_time"`
-	StartLat   *float64   `gorm:"type:decimal(10,8)" json:"start_lat"`
-	StartLon   *float64   `gorm:"type:decimal(11,8)" json:"start_lon"`
-	EndLat     *float64   `gorm:"type:decimal(10,8)" json:"end_lat"`
-	EndLon     *float64   `gorm:"type:decimal(11,8)" json:"end_lon"`
-	Tasks      []Task     `gorm:"foreignKey:ScheduleID;constraint:OnDelete:CASCADE" json:"tasks"`
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+	UserID      uint       `gorm:"not null;index:idx_user_schedule" json:"user_id"`
+	ClientID    *uint      `gorm:"index" json:"client_id,omitempty"`
+	ClientName  string     `gorm:"type:varchar(100);not null" json:"client_name" validate:"required"`
+	Location    string     `gorm:"type:varchar(200);not null" json:"location" validate:"required"`
+	ShiftTime   time.Time  `gorm:"type:datetime;not null;index" json:"shift_time" validate:"required"`
+	Status      string     `gorm:"type:enum('scheduled','in_progress','completed','cancelled','missed');default:'scheduled'" json:"status" validate:"required,oneof=scheduled in_progress completed cancelled missed"`
+	ServiceCode string     `gorm:"type:varchar(20)" json:"service_code,omitempty"`
+	StartTime   *time.Time `gorm:"type:datetime" json:"start_time"`
+	EndTime     *time.Time `gorm:"type:datetime" json:"end_time"`
+	StartLat    *float64   `gorm:"type:decimal(10,8)" json:"start_lat"`
+	StartLon    *float64   `gorm:"type:decimal(11,8)" json:"start_lon"`
+	EndLat      *float64   `gorm:"type:decimal(10,8)" json:"end_lat"`
+	EndLon      *float64   `gorm:"type:decimal(11,8)" json:"end_lon"`
+	Tasks       []Task     `gorm:"foreignKey:ScheduleID;constraint:OnDelete:CASCADE" json:"tasks"`
+
+	// GeofenceFlagged is set when the breadcrumb trail shows the caregiver off the premises too long
+	GeofenceFlagged bool `gorm:"not null;default:false;index" json:"geofence_flagged"`
+	// DurationMinutes is the planned visit length, used to match medication times to the visit
+	DurationMinutes int `gorm:"not null;default:60" json:"duration_minutes"`
+	// EarliestStart and LatestStart bound when the visit may start if the route optimizer moves
+	// it; when unset it may move ROUTE_DEFAULT_WINDOW_MINUTES either side of ShiftTime
+	EarliestStart *time.Time `gorm:"type:datetime" json:"earliest_start,omitempty"`
+	LatestStart   *time.Time `gorm:"type:datetime" json:"latest_start,omitempty"`
+	// SeriesID is the recurring visit series the visit was generated from
+	SeriesID *uint `gorm:"index" json:"series_id,omitempty"`
+
+	Corrections []VisitCorrection `gorm:"foreignKey:ScheduleID;constraint:OnDelete:CASCADE" json:"corrections,omitempty"`
+	Signatures  []VisitSignature  `gorm:"foreignKey:ScheduleID;constraint:OnDelete:CASCADE" json:"signatures,omitempty"`
+	Breaks      []VisitBreak      `gorm:"foreignKey:ScheduleID;constraint:OnDelete:CASCADE" json:"breaks,omitempty"`
+
+	// TimeSummary is computed from the visit's times and breaks; it is not stored
+	TimeSummary *VisitTimeSummary `gorm:"-" json:"time_summary,omitempty"`
 }
 
+// ScheduleStatusUpdateRequest is the body of PUT /api/user/schedules/:id/status. A visit is only
+// started or completed through the start and end endpoints, which check location, tasks and signatures.
 type ScheduleStatusUpdateRequest struct {
-	Status string `json:"status" binding:"required,oneof=scheduled in_progress completed cancelled missed"`
+	Status string `json:"status" binding:"required,oneof=scheduled cancelled missed"`
 }
 
 type VisitLocationRequest struct {
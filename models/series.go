@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// VisitSeries is a client's recurring visit, e.g. Monday, Wednesday and Friday at 09:00 for
+// 90 minutes in the agency timezone. Visits are generated from it a few weeks at a time, and
+// the capacity forecast counts the occurrences that have not been generated yet as demand.
+type VisitSeries struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ClientID  uint      `gorm:"not null;index" json:"client_id"`
+	// UserID is the caregiver visits are generated for; a series without one still counts as demand
+	UserID *uint `gorm:"index" json:"user_id,omitempty"`
+	// DaysOfWeek is a comma-separated list of day codes (e.g. "mon,wed,fri"); empty means every day
+	DaysOfWeek      string     `gorm:"type:varchar(27)" json:"days_of_week"`
+	StartTime       string     `gorm:"type:varchar(5);not null" json:"start_time"`
+	DurationMinutes int        `gorm:"not null;default:60" json:"duration_minutes"`
+	ServiceCode     string     `gorm:"type:varchar(20)" json:"service_code,omitempty"`
+	StartDate       time.Time  `gorm:"type:date;not null" json:"start_date"`
+	EndDate         *time.Time `gorm:"type:date" json:"end_date,omitempty"`
+	Active          bool       `gorm:"not null;default:true;index" json:"active"`
+	CreatedBy       uint       `json:"created_by"`
+}
+
+type VisitSeriesRequest struct {
+	UserID          *uint    `json:"user_id,omitempty"`
+	DaysOfWeek      []string `json:"days_of_week,omitempty" binding:"dive,oneof=sun mon tue wed thu fri sat"`
+	StartTime       string   `json:"start_time" binding:"required,datetime=15:04"`
+	DurationMinutes int      `json:"duration_minutes" binding:"required,min=15,max=720"`
+	ServiceCode     string   `json:"service_code,omitempty" binding:"max=20"`
+	StartDate       string   `json:"start_date" binding:"required,datetime=2006-01-02"`
+	EndDate         string   `json:"end_date,omitempty" binding:"omitempty,datetime=2006-01-02"`
+	Active          *bool    `json:"active"`
+}
+
+// VisitSeriesGenerateRequest creates the series' visits from today up to and including Through
+type VisitSeriesGenerateRequest struct {
+	Through string `json:"through" binding:"required,datetime=2006-01-02"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	SIGNER_TYPE_CLIENT    = "client"
+	SIGNER_TYPE_CAREGIVER = "caregiver"
+
+	SIGNATURE_FORMAT_IMAGE   = "image"
+	SIGNATURE_FORMAT_STROKES = "strokes"
+)
+
+// VisitSignature is an attestation captured when a visit ends. Hash is the SHA-256 of the
+// signature data bound to the schedule, signer and signing time, so later edits are detectable.
+type VisitSignature struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	ScheduleID   uint      `gorm:"not null;index" json:"schedule_id"`
+	SignerType   string    `gorm:"type:enum('client','caregiver');not null" json:"signer_type"`
+	SignerName   string    `gorm:"type:varchar(100);not null" json:"signer_name"`
+	Relationship string    `gorm:"type:varchar(50)" json:"relationship,omitempty"`
+	Format       string    `gorm:"type:enum('image','strokes');not null" json:"format"`
+	ContentType  string    `gorm:"type:varchar(50)" json:"content_type,omitempty"`
+	ImageData    []byte    `gorm:"type:mediumblob" json:"-"`
+	Strokes      *string   `gorm:"type:mediumtext" json:"strokes,omitempty"`
+	Hash         string    `gorm:"type:char(64);not null" json:"hash"`
+	SignedAt     time.Time `gorm:"type:datetime(6);not null" json:"signed_at"`
+}
+
+// SignaturePoint is one sampled point of a pen stroke; T is milliseconds since the stroke began
+type SignaturePoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	T int64   `json:"t,omitempty"`
+}
+
+type SignatureInput struct {
+	SignerType   string             `json:"signer_type" binding:"required,oneof=client caregiver"`
+	SignerName   string             `json:"signer_name" binding:"required"`
+	Relationship string             `json:"relationship"`
+	Image        string             `json:"image"`
+	Strokes      [][]SignaturePoint `json:"strokes"`
+}
+
+type EndVisitRequest struct {
+	Latitude   float64          `json:"latitude" binding:"required"`
+	Longitude  float64          `json:"longitude" binding:"required"`
+	Signatures []SignatureInput `json:"signatures" binding:"omitempty,dive"`
+	// OverrideToken is a supervisor-issued token that allows checkout with unresolved tasks
+	OverrideToken string `json:"override_token,omitempty"`
+}
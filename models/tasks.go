@@ -7,6 +7,16 @@ import (
 const (
 	TASK_STATUS_COMPLETED     = "completed"
 	TASK_STATUS_NOT_COMPLETED = "not_completed"
+
+	TASK_KIND_GENERAL    = "general"
+	TASK_KIND_MEDICATION = "medication"
+
+	TASK_TIMING_EARLY   = "early"
+	TASK_TIMING_ON_TIME = "on_time"
+	TASK_TIMING_LATE    = "late"
+
+	// DEFAULT_TASK_WINDOW_MINUTES is the ± tolerance around a task's target time when none is set
+	DEFAULT_TASK_WINDOW_MINUTES = 30
 )
 
 type Task struct {
@@ -19,4 +29,79 @@ type Task struct {
 	Status      string     `gorm:"type:enum('completed','not_completed');default:'not_completed'" json:"status" validate:"required,oneof=completed not_completed"`
 	Reason      *string    `gorm:"type:text" json:"reason,omitempty"`
 	CompletedAt *time.Time `gorm:"type:datetime" json:"completed_at,omitempty"`
+
+	// TaskTemplateID is set when the task was instantiated from a care plan
+	TaskTemplateID *uint `gorm:"index" json:"task_template_id,omitempty"`
+	// Kind separates medication administration tasks, which are recorded through the MAR
+	Kind string `gorm:"type:varchar(20);not null;default:'general'" json:"kind"`
+
+	// Position orders the visit checklist; ties fall back to creation order
+	Position int `gorm:"not null;default:0" json:"position"`
+	// TargetTime and WindowMinutes describe when the task should be done, e.g. 10:00 ± 30m
+	TargetTime    *time.Time `gorm:"type:datetime" json:"target_time,omitempty"`
+	WindowMinutes int        `gorm:"not null;default:30" json:"window_minutes"`
+	// PrerequisiteIDs are tasks on the same visit that must be completed first
+	PrerequisiteIDs []uint `gorm:"type:text;serializer:json" json:"prerequisite_ids,omitempty"`
+	// CompletionTiming records whether a timed task was completed early, on time or late
+	CompletionTiming string `gorm:"type:varchar(10)" json:"completion_timing,omitempty"`
+}
+
+// TaskCreateRequest is a task added by hand through POST /tasks or POST /tasks/assign/:id, where
+// the schedule comes from the path. Such tasks are always general and start not completed:
+// medication tasks come from orders, template tasks from care plans and prerequisites from the
+// visit checklist.
+type TaskCreateRequest struct {
+	ScheduleID  uint   `json:"schedule_id"`
+	Description string `json:"description" binding:"required,max=200"`
+}
+
+// TaskUpdateRequest is the body of PUT /tasks/:id. Checklist settings, the medication kind and
+// the template link are managed elsewhere and cannot be changed through it.
+type TaskUpdateRequest struct {
+	Description string  `json:"description"`
+	Status      string  `json:"status" validate:"required,oneof=completed not_completed"`
+	Reason      *string `json:"reason"`
+}
+
+// ChecklistItem is a task as presented in a visit's ordered checklist
+type ChecklistItem struct {
+	Task
+	WindowStart *time.Time `json:"window_start,omitempty"`
+	WindowEnd   *time.Time `json:"window_end,omitempty"`
+	BlockedBy   []uint     `json:"blocked_by"`
+	Overdue     bool       `json:"overdue"`
+}
+
+type ChecklistItemInput struct {
+	TaskID          uint       `json:"task_id" binding:"required"`
+	Position        int        `json:"position"`
+	TargetTime      *time.Time `json:"target_time"`
+	WindowMinutes   *int       `json:"window_minutes" binding:"omitempty,min=0,max=720"`
+	PrerequisiteIDs []uint     `json:"prerequisite_ids" binding:"omitempty,max=50"`
+}
+
+type ChecklistRequest struct {
+	Items []ChecklistItemInput `json:"items" binding:"required,min=1,dive"`
+}
+
+type BulkTaskStatusItem struct {
+	TaskID       uint               `json:"task_id" binding:"required"`
+	Status       string             `json:"status" binding:"required,oneof=completed not_completed"`
+	Reason       *string            `json:"reason"`
+	Observations []ObservationInput `json:"observations,omitempty" binding:"dive"`
+}
+
+type BulkTaskStatusRequest struct {
+	Updates []BulkTaskStatusItem `json:"updates" binding:"required,min=1,max=100,dive"`
+}
+
+// BulkTaskResult is the outcome for one task in a bulk status update. When any task fails
+// validation the whole batch is rejected and Error explains each failing task.
+type BulkTaskResult struct {
+	TaskID           uint              `json:"task_id"`
+	Status           string            `json:"status,omitempty"`
+	CompletionTiming string            `json:"completion_timing,omitempty"`
+	Observations     []TaskObservation `json:"observations,omitempty"`
+	Alerts           []Alert           `json:"alerts,omitempty"`
+	Error            string            `json:"error,omitempty"`
 }
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	TIMESHEET_STATUS_OPEN      = "open"
+	TIMESHEET_STATUS_SUBMITTED = "submitted"
+	TIMESHEET_STATUS_APPROVED  = "approved"
+	TIMESHEET_STATUS_REJECTED  = "rejected"
+
+	PAY_PERIOD_WEEKLY   = "weekly"
+	PAY_PERIOD_BIWEEKLY = "biweekly"
+
+	ROUNDING_NEAREST = "nearest"
+	ROUNDING_UP      = "up"
+	ROUNDING_DOWN    = "down"
+)
+
+// PayPeriodSettings describe how pay periods are laid out and how clock times are rounded.
+// Periods start on Anchor (a date in Location) and repeat every one or two weeks.
+type PayPeriodSettings struct {
+	Type            string         `json:"type"`
+	Anchor          time.Time      `json:"anchor"`
+	Location        *time.Location `json:"-"`
+	RoundingMinutes int            `json:"rounding_minutes"`
+	RoundingMode    string         `json:"rounding_mode"`
+}
+
+// Timesheet aggregates a caregiver's completed visits for one pay period. While open or
+// rejected it is rebuilt from the visits on every read; once submitted it is frozen, and an
+// approved timesheet locks its visits against further edits.
+type Timesheet struct {
+	ID                 uint             `gorm:"primaryKey" json:"id"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+	UserID             uint             `gorm:"not null;uniqueIndex:idx_timesheet_period" json:"user_id"`
+	PeriodStart        time.Time        `gorm:"type:datetime;not null;uniqueIndex:idx_timesheet_period" json:"period_start"`
+	PeriodEnd          time.Time        `gorm:"type:datetime;not null" json:"period_end"`
+	Status             string           `gorm:"type:enum('open','submitted','approved','rejected');default:'open';index" json:"status"`
+	VisitCount         int              `gorm:"not null;default:0" json:"visit_count"`
+	WorkedMinutes      int              `gorm:"not null;default:0" json:"worked_minutes"`
+	UnpaidBreakMinutes int              `gorm:"not null;default:0" json:"unpaid_break_minutes"`
+	SubmittedAt        *time.Time       `gorm:"type:datetime" json:"submitted_at,omitempty"`
+	ReviewedBy         *uint            `json:"reviewed_by,omitempty"`
+	ReviewedAt         *time.Time       `gorm:"type:datetime" json:"reviewed_at,omitempty"`
+	ReviewNote         *string          `gorm:"type:text" json:"review_note,omitempty"`
+	Entries            []TimesheetEntry `gorm:"foreignKey:TimesheetID;constraint:OnDelete:CASCADE" json:"entries"`
+}
+
+// TimesheetEntry is one completed visit on a timesheet, with its rounded clock times
+type TimesheetEntry struct {
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	TimesheetID        uint      `gorm:"not null;index" json:"timesheet_id"`
+	ScheduleID         uint      `gorm:"not null;index" json:"schedule_id"`
+	ClientName         string    `gorm:"type:varchar(100)" json:"client_name"`
+	StartTime          time.Time `gorm:"type:datetime;not null" json:"start_time"`
+	EndTime            time.Time `gorm:"type:datetime;not null" json:"end_time"`
+	RoundedStart       time.Time `gorm:"type:datetime;not null" json:"rounded_start"`
+	RoundedEnd         time.Time `gorm:"type:datetime;not null" json:"rounded_end"`
+	UnpaidBreakMinutes int       `gorm:"not null;default:0" json:"unpaid_break_minutes"`
+	WorkedMinutes      int       `gorm:"not null;default:0" json:"worked_minutes"`
+}
+
+type TimesheetReviewRequest struct {
+	Note *string `json:"note"`
+}
+
+type TimesheetFilter struct {
+	Status      string
+	UserID      uint
+	PeriodStart *time.Time
+}
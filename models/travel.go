@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+// TravelSegment is the trip between two consecutive completed visits of a caregiver on the same
+// agency-local day, from where the first visit ended to where the next one started. Meters and
+// Minutes are what reimbursement and payroll use: the routed figures when the routing provider
+// answered, otherwise the straight line.
+type TravelSegment struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	UserID          uint      `gorm:"not null;index:idx_travel_user_date" json:"user_id"`
+	Date            time.Time `gorm:"type:date;not null;index:idx_travel_user_date" json:"date"`
+	FromScheduleID  uint      `gorm:"not null;index" json:"from_schedule_id"`
+	ToScheduleID    uint      `gorm:"not null;uniqueIndex" json:"to_schedule_id"`
+	DepartAt        time.Time `gorm:"type:datetime;not null" json:"depart_at"`
+	ArriveAt        time.Time `gorm:"type:datetime;not null;index" json:"arrive_at"`
+	FromLat         float64   `gorm:"type:decimal(10,8)" json:"from_lat"`
+	FromLon         float64   `gorm:"type:decimal(11,8)" json:"from_lon"`
+	ToLat           float64   `gorm:"type:decimal(10,8)" json:"to_lat"`
+	ToLon           float64   `gorm:"type:decimal(11,8)" json:"to_lon"`
+	StraightMeters  float64   `gorm:"type:decimal(10,1)" json:"straight_meters"`
+	StraightMinutes int       `json:"straight_minutes"`
+	Provider        string    `gorm:"type:varchar(30)" json:"provider"`
+	RoutedMeters    *float64  `gorm:"type:decimal(10,1)" json:"routed_meters,omitempty"`
+	RoutedMinutes   *int      `json:"routed_minutes,omitempty"`
+	RoutingError    string    `gorm:"type:varchar(255)" json:"routing_error,omitempty"`
+	Meters          float64   `gorm:"type:decimal(10,1)" json:"meters"`
+	Minutes         int       `json:"minutes"`
+}
+
+// TravelSummary totals a caregiver's travel segments for reimbursement
+type TravelSummary struct {
+	UserID        uint    `json:"user_id"`
+	Segments      int     `json:"segments"`
+	Meters        float64 `json:"meters"`
+	Miles         float64 `json:"miles"`
+	Minutes       int     `json:"minutes"`
+	Reimbursement float64 `json:"reimbursement"`
+}
+
+type TravelFilter struct {
+	UserID uint
+	// From and To are calendar days, both included
+	From *time.Time
+	To   *time.Time
+}
+
+type TravelRebuildRequest struct {
+	UserID *uint `json:"user_id"`
+	// From and To are YYYY-MM-DD in the agency timezone; To is inclusive
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
@@ -10,6 +10,11 @@ const (
 	ROLE_CAREGIVER     = 3
 )
 
+// IsStaffRole reports whether the role may act on other users' visits (admin or customer care)
+func IsStaffRole(roleID int) bool {
+	return roleID == ROLE_ADMIN || roleID == ROLE_CUSTOMER_CARE
+}
+
 // User represents an authenticated user
 type User struct {
 	ID           uint       `gorm:"primaryKey" json:"id"`
@@ -22,6 +27,13 @@ type User struct {
 	Password     string     `gorm:"type:varchar(100);not null" json:"password" validate:"required,min=8"`
 	RoleID       int        `gorm:"not null;default:3;index" json:"role_id" validate:"required,oneof=1 2 3"`
 	RefreshToken *string    `gorm:"type:text" json:"refresh_token,omitempty"`
+
+	// LaborRuleSetID selects the overtime rules for a caregiver; nil uses the configured defaults
+	LaborRuleSetID *uint `gorm:"index" json:"labor_rule_set_id,omitempty"`
+
+	// HourlyRate and PayrollEmployeeID feed payroll exports
+	HourlyRate        float64 `gorm:"type:decimal(10,2);not null;default:0" json:"hourly_rate"`
+	PayrollEmployeeID string  `gorm:"type:varchar(50)" json:"payroll_employee_id,omitempty"`
 }
 
 type RegisterUserRequest struct {
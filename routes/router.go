@@ -3,8 +3,8 @@ package routes
 import (
 	"caregiver-shift-tracker/controller"
 	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/utils"
 
-	// "caregiver-shift-tracker/utils"
 	"net/http"
 	"time"
 
@@ -15,7 +15,7 @@ import (
 
 func SetUpRoutes(r *gin.Engine, ctrl *controller.Controller, DB *gorm.DB) {
 	allowedMethods := []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete}
-	allowHeaders := []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Timezone"}
+	allowHeaders := []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Timezone", "X-Audit-Reason"}
 
 	// CORS
 	corsConfig := cors.Config{
@@ -63,9 +63,157 @@ func SetUpRoutes(r *gin.Engine, ctrl *controller.Controller, DB *gorm.DB) {
 		protected.GET("/user/schedules/:id", ctrl.GetScheduleDetails)
 		protected.POST("/user/schedules/:id/start", ctrl.StartVisit)
 		protected.POST("/user/schedules/:id/end", ctrl.EndVisit)
+		protected.POST("/user/schedules/:id/pause", ctrl.PauseVisit)
+		protected.POST("/user/schedules/:id/resume", ctrl.ResumeVisit)
+		protected.GET("/user/schedules/:id/signatures/:signatureId/image", ctrl.GetVisitSignatureImage)
+		protected.POST("/user/schedules/:id/attachments", ctrl.UploadAttachment)
+		protected.GET("/user/schedules/:id/attachments", ctrl.GetAttachments)
+		protected.GET("/user/schedules/:id/attachments/:attachmentId", ctrl.DownloadAttachment)
+		protected.DELETE("/user/schedules/:id/attachments/:attachmentId", ctrl.DeleteAttachment)
+		protected.POST("/user/schedules/:id/locations", ctrl.RecordLocationPings)
+		protected.GET("/user/schedules/:id/locations/summary", ctrl.GetGeofenceSummary)
+		protected.GET("/user/schedules/:id/locations/geojson", ctrl.GetLocationTrailGeoJSON)
+		protected.GET("/user/schedules/:id/observations", ctrl.GetScheduleObservations)
+		protected.GET("/user/schedules/:id/checklist", ctrl.GetVisitChecklist)
+		protected.PATCH("/user/schedules/:id/tasks", ctrl.BulkUpdateTaskStatus)
+		protected.GET("/user/schedules/:id/medications", ctrl.GetVisitMedications)
+		protected.POST("/user/medication-administrations/:id", ctrl.RecordMedicationAdministration)
+		protected.POST("/user/schedules/:id/incidents", ctrl.ReportIncident)
+		protected.GET("/user/schedules/:id/incidents", ctrl.GetScheduleIncidents)
+		protected.GET("/user/incidents/categories", ctrl.GetIncidentCategories)
 		protected.POST("/user/schedules/:id/cancel-start", ctrl.CancelStartVisit)
 		protected.GET("/user/schedules-with-tasks", ctrl.FetchSchedulesWithTasks)
 		protected.PUT("/user/schedules/:id/status", ctrl.UpdateScheduleStatus)
+		protected.POST("/user/schedules/:id/corrections", ctrl.RequestVisitCorrection)
+		protected.GET("/user/schedules/:id/corrections", ctrl.GetVisitCorrections)
+		protected.GET("/user/corrections/reason-codes", ctrl.GetCorrectionReasonCodes)
+		protected.GET("/user/timesheets", ctrl.ListMyTimesheets)
+		protected.GET("/user/timesheets/period", ctrl.GetMyTimesheet)
+		protected.POST("/user/timesheets/:id/submit", ctrl.SubmitTimesheet)
+		protected.GET("/user/labor", ctrl.GetMyLaborBreakdown)
+		protected.GET("/user/travel", ctrl.GetMyTravel)
+		protected.GET("/user/availability", ctrl.GetMyAvailability)
+		protected.PUT("/user/availability", ctrl.SetMyAvailability)
 
 	}
+
+	// Admin-only routes
+	adminRoutes := r.Group("/api/admin")
+	adminRoutes.Use(utils.AdminOnly())
+	{
+		adminRoutes.GET("/audit", ctrl.GetAuditLogs)
+		adminRoutes.GET("/ledger", ctrl.GetVisitLedger)
+		adminRoutes.GET("/ledger/verify", ctrl.VerifyVisitLedger)
+
+		adminRoutes.GET("/evv/schemas", ctrl.GetEVVSchemas)
+		adminRoutes.POST("/evv/exports", ctrl.CreateEVVExport)
+		adminRoutes.GET("/evv/exports", ctrl.ListEVVExports)
+		adminRoutes.GET("/evv/exports/:id", ctrl.GetEVVExport)
+		adminRoutes.GET("/evv/exports/:id/file", ctrl.DownloadEVVExport)
+		adminRoutes.POST("/evv/exports/:id/submit", ctrl.SubmitEVVExport)
+		adminRoutes.POST("/evv/exports/:id/results", ctrl.RecordEVVResults)
+		adminRoutes.GET("/evv/submissions", ctrl.ListEVVSubmissions)
+		adminRoutes.GET("/payroll/layouts", ctrl.GetPayrollLayouts)
+		adminRoutes.POST("/payroll/exports", ctrl.CreatePayrollExport)
+		adminRoutes.GET("/payroll/exports", ctrl.ListPayrollExports)
+		adminRoutes.GET("/payroll/exports/:id", ctrl.GetPayrollExport)
+		adminRoutes.GET("/payroll/exports/:id/file", ctrl.DownloadPayrollExport)
+		adminRoutes.POST("/payroll/exports/:id/void", ctrl.VoidPayrollExport)
+		adminRoutes.GET("/payroll/visits", ctrl.ListPayrollVisits)
+		adminRoutes.PUT("/caregivers/:id/payroll-profile", ctrl.SetPayrollProfile)
+		adminRoutes.GET("/payers", ctrl.ListPayers)
+		adminRoutes.POST("/payers", ctrl.CreatePayer)
+		adminRoutes.PUT("/payers/:id", ctrl.UpdatePayer)
+		adminRoutes.GET("/service-codes", ctrl.ListServiceCodes)
+		adminRoutes.POST("/service-codes", ctrl.CreateServiceCode)
+		adminRoutes.PUT("/service-codes/:id", ctrl.UpdateServiceCode)
+		adminRoutes.POST("/service-codes/:id/rates", ctrl.CreateBillingRate)
+		adminRoutes.POST("/invoices", ctrl.GenerateInvoices)
+		adminRoutes.GET("/invoices", ctrl.ListInvoices)
+		adminRoutes.GET("/invoices/:id", ctrl.GetInvoice)
+		adminRoutes.GET("/invoices/:id/csv", ctrl.DownloadInvoiceCSV)
+		adminRoutes.GET("/invoices/:id/pdf", ctrl.DownloadInvoicePDF)
+		adminRoutes.POST("/invoices/:id/rebill", ctrl.RebillInvoice)
+		adminRoutes.GET("/authorizations", ctrl.ListAuthorizations)
+		adminRoutes.POST("/authorizations", ctrl.CreateAuthorization)
+		adminRoutes.GET("/authorizations/utilization", ctrl.GetAuthorizationUtilization)
+		adminRoutes.PUT("/authorizations/:id", ctrl.UpdateAuthorization)
+		adminRoutes.GET("/authorizations/:id/usage", ctrl.GetAuthorizationUsage)
+		adminRoutes.GET("/travel", ctrl.GetTravelReport)
+		adminRoutes.POST("/travel/rebuild", ctrl.RebuildTravel)
+		adminRoutes.GET("/report-subscriptions", ctrl.ListReportSubscriptions)
+		adminRoutes.POST("/report-subscriptions", ctrl.CreateReportSubscription)
+		adminRoutes.PUT("/report-subscriptions/:id", ctrl.UpdateReportSubscription)
+		adminRoutes.DELETE("/report-subscriptions/:id", ctrl.DeleteReportSubscription)
+		adminRoutes.GET("/report-subscriptions/:id/deliveries", ctrl.ListReportDeliveries)
+		adminRoutes.POST("/report-subscriptions/:id/run", ctrl.RunReportSubscription)
+		adminRoutes.POST("/report-deliveries/:id/retry", ctrl.RetryReportDelivery)
+		adminRoutes.GET("/caregivers/:id/availability", ctrl.GetCaregiverAvailability)
+		adminRoutes.PUT("/caregivers/:id/availability", ctrl.SetCaregiverAvailability)
+		adminRoutes.GET("/capacity/forecast", ctrl.GetCapacityForecast)
+	}
+
+	// Staff routes (admin and customer care)
+	staffRoutes := r.Group("/api/admin")
+	staffRoutes.Use(utils.StaffOnly())
+	{
+		staffRoutes.POST("/clients", ctrl.CreateClient)
+		staffRoutes.GET("/clients", ctrl.ListClients)
+		staffRoutes.GET("/clients/:id", ctrl.GetClient)
+		staffRoutes.PUT("/clients/:id", ctrl.UpdateClient)
+		staffRoutes.GET("/clients/:id/care-plans", ctrl.ListClientCarePlans)
+		staffRoutes.POST("/clients/:id/care-plans", ctrl.CreateClientCarePlan)
+		staffRoutes.GET("/clients/:id/observations", ctrl.GetClientObservations)
+		staffRoutes.POST("/clients/:id/medications", ctrl.CreateMedicationOrder)
+		staffRoutes.GET("/clients/:id/medications", ctrl.ListMedicationOrders)
+		staffRoutes.GET("/clients/:id/mar", ctrl.GetMARGrid)
+		staffRoutes.POST("/clients/:id/visit-series", ctrl.CreateVisitSeries)
+		staffRoutes.GET("/clients/:id/visit-series", ctrl.ListClientVisitSeries)
+		staffRoutes.GET("/visit-series/:id", ctrl.GetVisitSeries)
+		staffRoutes.PUT("/visit-series/:id", ctrl.UpdateVisitSeries)
+		staffRoutes.DELETE("/visit-series/:id", ctrl.DeleteVisitSeries)
+		staffRoutes.POST("/visit-series/:id/generate", ctrl.GenerateVisitSeries)
+		staffRoutes.PUT("/medications/:id", ctrl.UpdateMedicationOrder)
+		staffRoutes.POST("/medications/:id/discontinue", ctrl.DiscontinueMedicationOrder)
+
+		staffRoutes.POST("/task-templates", ctrl.CreateTaskTemplate)
+		staffRoutes.GET("/task-templates", ctrl.ListTaskTemplates)
+		staffRoutes.PUT("/task-templates/:id", ctrl.UpdateTaskTemplate)
+		staffRoutes.DELETE("/task-templates/:id", ctrl.DeleteTaskTemplate)
+		staffRoutes.PUT("/task-templates/:id/observation-fields", ctrl.SetObservationFields)
+		staffRoutes.GET("/care-plans/:id", ctrl.GetCarePlan)
+		staffRoutes.PUT("/care-plans/:id", ctrl.UpdateCarePlan)
+		staffRoutes.POST("/care-plans/:id/activate", ctrl.ActivateCarePlan)
+		staffRoutes.POST("/schedules/:id/apply-care-plan", ctrl.ApplyCarePlanToSchedule)
+		staffRoutes.POST("/schedules/:id/completion-override", ctrl.IssueCompletionOverride)
+		staffRoutes.PUT("/schedules/:id/checklist", ctrl.SetVisitChecklist)
+
+		staffRoutes.GET("/corrections", ctrl.ListVisitCorrections)
+		staffRoutes.POST("/corrections/:id/approve", ctrl.ApproveVisitCorrection)
+		staffRoutes.POST("/corrections/:id/reject", ctrl.RejectVisitCorrection)
+
+		staffRoutes.GET("/geofence/flagged", ctrl.ListGeofenceFlaggedVisits)
+
+		staffRoutes.GET("/timesheets", ctrl.ListTimesheets)
+		staffRoutes.GET("/timesheets/:id", ctrl.GetTimesheet)
+		staffRoutes.POST("/timesheets/:id/approve", ctrl.ApproveTimesheet)
+		staffRoutes.POST("/timesheets/:id/reject", ctrl.RejectTimesheet)
+		staffRoutes.GET("/labor", ctrl.GetLaborBreakdowns)
+		staffRoutes.GET("/labor/preview", ctrl.PreviewLaborAssignment)
+		staffRoutes.POST("/routes/preview", ctrl.PreviewRoute)
+		staffRoutes.GET("/analytics/visits", ctrl.GetVisitAnalytics)
+		staffRoutes.GET("/analytics/tasks", ctrl.GetTaskAnalytics)
+		staffRoutes.GET("/analytics/missed-trend", ctrl.GetMissedVisitTrend)
+		staffRoutes.GET("/labor-rule-sets", ctrl.ListLaborRuleSets)
+		staffRoutes.POST("/labor-rule-sets", ctrl.CreateLaborRuleSet)
+		staffRoutes.PUT("/labor-rule-sets/:id", ctrl.UpdateLaborRuleSet)
+		staffRoutes.PUT("/caregivers/:id/labor-rule-set", ctrl.AssignLaborRuleSet)
+
+		staffRoutes.GET("/alerts", ctrl.ListAlerts)
+		staffRoutes.POST("/alerts/:id/acknowledge", ctrl.AcknowledgeAlert)
+
+		staffRoutes.GET("/incidents", ctrl.ListIncidents)
+		staffRoutes.GET("/incidents/:id", ctrl.GetIncident)
+		staffRoutes.POST("/incidents/:id/status", ctrl.UpdateIncidentStatus)
+	}
 }
@@ -0,0 +1,68 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OSRM asks an OSRM-compatible HTTP service for the driving route. Point ROUTING_OSRM_URL at
+// a self-hosted server, or at cmd/routing-stub for local development.
+type OSRM struct {
+	base   *url.URL
+	client *http.Client
+}
+
+func NewOSRM(baseURL string) (*OSRM, error) {
+	if baseURL == "" {
+		return nil, errors.New("OSRM routing requires ROUTING_OSRM_URL")
+	}
+	base, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid OSRM URL %q", baseURL)
+	}
+	return &OSRM{base: base, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (p *OSRM) Name() string {
+	return PROVIDER_OSRM
+}
+
+func (p *OSRM) Route(ctx context.Context, from, to Point) (Route, error) {
+	endpoint := *p.base
+	endpoint.Path += fmt.Sprintf("/route/v1/driving/%f,%f;%f,%f", from.Lon, from.Lat, to.Lon, to.Lat)
+	endpoint.RawQuery = "overview=false"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return Route{}, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Route{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Route{}, fmt.Errorf("OSRM returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Code   string `json:"code"`
+		Routes []struct {
+			Distance float64 `json:"distance"`
+			Duration float64 `json:"duration"`
+		} `json:"routes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Route{}, fmt.Errorf("invalid OSRM response: %w", err)
+	}
+	if body.Code != "Ok" || len(body.Routes) == 0 {
+		return Route{}, fmt.Errorf("OSRM found no route (%s)", body.Code)
+	}
+	return Route{Meters: body.Routes[0].Distance, Minutes: int(math.Ceil(body.Routes[0].Duration / 60))}, nil
+}
@@ -0,0 +1,43 @@
+package routing
+
+import (
+	"caregiver-shift-tracker/config"
+	"context"
+	"fmt"
+)
+
+const (
+	PROVIDER_STRAIGHT_LINE = "straight_line"
+	PROVIDER_OSRM          = "osrm"
+)
+
+// Point is a WGS84 coordinate
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Route is the distance and driving time between two points
+type Route struct {
+	Meters  float64
+	Minutes int
+}
+
+// Provider computes the route a caregiver drives between two visits
+type Provider interface {
+	// Name identifies the provider on stored travel segments
+	Name() string
+	Route(ctx context.Context, from, to Point) (Route, error)
+}
+
+// New builds the routing provider selected by ROUTING_PROVIDER (straight line by default)
+func New(cfg *config.Config) (Provider, error) {
+	switch cfg.RoutingProvider {
+	case "", PROVIDER_STRAIGHT_LINE:
+		return NewStraightLine(float64(cfg.TravelSpeedKmh)), nil
+	case PROVIDER_OSRM:
+		return NewOSRM(cfg.RoutingOSRMURL)
+	default:
+		return nil, fmt.Errorf("unknown routing provider %q", cfg.RoutingProvider)
+	}
+}
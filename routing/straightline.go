@@ -0,0 +1,33 @@
+package routing
+
+import (
+	"caregiver-shift-tracker/utils"
+	"context"
+	"math"
+)
+
+// StraightLine routes along the great circle at a constant speed. It needs no network access
+// and is what travel falls back to when another provider fails.
+type StraightLine struct {
+	speedKmh float64
+}
+
+func NewStraightLine(speedKmh float64) *StraightLine {
+	return &StraightLine{speedKmh: speedKmh}
+}
+
+func (p *StraightLine) Name() string {
+	return PROVIDER_STRAIGHT_LINE
+}
+
+func (p *StraightLine) Route(ctx context.Context, from, to Point) (Route, error) {
+	meters := utils.DistanceMeters(from.Lat, from.Lon, to.Lat, to.Lon)
+	return Route{Meters: meters, Minutes: driveMinutes(meters, p.speedKmh)}, nil
+}
+
+func driveMinutes(meters, speedKmh float64) int {
+	if speedKmh <= 0 {
+		return 0
+	}
+	return int(math.Ceil(meters / 1000 / speedKmh * 60))
+}
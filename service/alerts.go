@@ -0,0 +1,50 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrAlertAlreadyAcknowledged = errors.New("alert has already been acknowledged")
+
+// ListAlerts returns alerts matching the filter, newest first
+func ListAlerts(db *gorm.DB, filter models.AlertFilter) ([]models.Alert, error) {
+	query := db.Order("created_at DESC")
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.ClientID != 0 {
+		query = query.Where("client_id = ?", filter.ClientID)
+	}
+	var alerts []models.Alert
+	err := query.Find(&alerts).Error
+	return alerts, err
+}
+
+// GetAlertByID retrieves a single alert
+func GetAlertByID(db *gorm.DB, alertID uint) (*models.Alert, error) {
+	var alert models.Alert
+	err := db.First(&alert, "id = ?", alertID).Error
+	return &alert, err
+}
+
+// AcknowledgeAlert marks an open alert as handled by a staff member
+func AcknowledgeAlert(db *gorm.DB, alert *models.Alert, userID uint, note string) error {
+	if alert.Status != models.ALERT_STATUS_OPEN {
+		return ErrAlertAlreadyAcknowledged
+	}
+	now := time.Now()
+	alert.Status = models.ALERT_STATUS_ACKNOWLEDGED
+	alert.AcknowledgedBy = &userID
+	alert.AcknowledgedAt = &now
+	if note != "" {
+		alert.Note = &note
+	}
+	return db.Save(alert).Error
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MissedVisitGrace is how long after its shift time a visit that was never started counts as
+// missed
+const MissedVisitGrace = 5 * time.Minute
+
+// VisitKPIReport computes visit counts, on-time clock-ins and lateness for the filtered visits,
+// grouped by caregiver, client or local day (or as one row). A clock-in up to onTimeGrace after
+// the shift time is on time.
+func VisitKPIReport(db *gorm.DB, filter models.AnalyticsFilter, groupBy string, onTimeGrace time.Duration, now time.Time) ([]models.VisitKPIs, error) {
+	schedules, err := loadAnalyticsVisits(db, filter)
+	if err != nil {
+		return nil, err
+	}
+	loc := filter.Location
+
+	groups := map[string]*models.VisitKPIs{}
+	var keys []string
+	group := func(s *models.Schedule) *models.VisitKPIs {
+		var key string
+		row := models.VisitKPIs{ByStatus: map[string]int{}}
+		switch groupBy {
+		case models.ANALYTICS_GROUP_CAREGIVER:
+			userID := s.UserID
+			key, row.UserID = "user:"+itoa(userID), &userID
+		case models.ANALYTICS_GROUP_CLIENT:
+			key = "client:none"
+			if s.ClientID != nil {
+				clientID := *s.ClientID
+				key, row.ClientID = "client:"+itoa(clientID), &clientID
+			}
+			row.Name = s.ClientName
+		case models.ANALYTICS_GROUP_DAY:
+			row.Date = s.ShiftTime.In(loc).Format("2006-01-02")
+			key = row.Date
+		default:
+			key = "all"
+		}
+		if existing, ok := groups[key]; ok {
+			return existing
+		}
+		row.Group = key
+		groups[key] = &row
+		keys = append(keys, key)
+		return &row
+	}
+	if groupBy == "" || groupBy == models.ANALYTICS_GROUP_NONE {
+		group(&models.Schedule{})
+	}
+
+	lateMinutes := map[string]int{}
+	for i := range schedules {
+		s := &schedules[i]
+		row := group(s)
+		row.Visits++
+		row.ByStatus[s.Status]++
+		if visitMissed(s, now) {
+			row.Missed++
+		}
+		if s.StartTime == nil {
+			continue
+		}
+		row.ClockIns++
+		late := s.StartTime.Sub(s.ShiftTime)
+		if late <= onTimeGrace {
+			row.OnTimeClockIns++
+			continue
+		}
+		row.LateClockIns++
+		lateMinutes[row.Group] += int(late.Minutes())
+	}
+
+	if groupBy == models.ANALYTICS_GROUP_CAREGIVER {
+		if err := nameCaregiverGroups(db, groups); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(keys)
+	report := make([]models.VisitKPIs, 0, len(keys))
+	for _, key := range keys {
+		row := groups[key]
+		if row.ClockIns > 0 {
+			row.OnTimePercent = percent(row.OnTimeClockIns, row.ClockIns)
+		}
+		if row.LateClockIns > 0 {
+			row.AverageLateMinutes = roundTenth(float64(lateMinutes[key]) / float64(row.LateClockIns))
+		}
+		report = append(report, *row)
+	}
+	if groupBy == models.ANALYTICS_GROUP_CAREGIVER || groupBy == models.ANALYTICS_GROUP_CLIENT {
+		sort.SliceStable(report, func(i, j int) bool { return report[i].Name < report[j].Name })
+	}
+	return report, nil
+}
+
+// TaskKPIReport computes the task completion rate of visits that were carried out and the most
+// common reasons given for tasks not completed
+func TaskKPIReport(db *gorm.DB, filter models.AnalyticsFilter, topReasons int) (*models.TaskKPIs, error) {
+	schedules, err := loadAnalyticsVisits(db, filter)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint
+	for _, s := range schedules {
+		if s.Status == models.SCHEDULE_STATUS_COMPLETED || s.Status == models.SCHEDULE_STATUS_IN_PROGRESS {
+			ids = append(ids, s.ID)
+		}
+	}
+	kpis := &models.TaskKPIs{TopReasons: []models.ReasonCount{}}
+	if len(ids) == 0 {
+		return kpis, nil
+	}
+	var tasks []models.Task
+	if err := db.Where("schedule_id IN ?", ids).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	counts := map[string]*models.ReasonCount{}
+	for _, task := range tasks {
+		kpis.Tasks++
+		if task.Status == models.TASK_STATUS_COMPLETED {
+			kpis.Completed++
+			continue
+		}
+		kpis.NotCompleted++
+		reason := ""
+		if task.Reason != nil {
+			reason = strings.Join(strings.Fields(*task.Reason), " ")
+		}
+		if reason == "" {
+			kpis.WithoutReason++
+			continue
+		}
+		key := strings.ToLower(reason)
+		if counts[key] == nil {
+			counts[key] = &models.ReasonCount{Reason: reason}
+		}
+		counts[key].Count++
+	}
+	if kpis.Tasks > 0 {
+		kpis.CompletionRate = percent(kpis.Completed, kpis.Tasks)
+	}
+	for _, count := range counts {
+		kpis.TopReasons = append(kpis.TopReasons, *count)
+	}
+	sort.Slice(kpis.TopReasons, func(i, j int) bool {
+		a, b := kpis.TopReasons[i], kpis.TopReasons[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Reason < b.Reason
+	})
+	if len(kpis.TopReasons) > topReasons {
+		kpis.TopReasons = kpis.TopReasons[:topReasons]
+	}
+	return kpis, nil
+}
+
+// MissedVisitTrend counts due and missed visits per local day, ISO week (from Monday) or
+// calendar month across the filter's range, including intervals with no visits
+func MissedVisitTrend(db *gorm.DB, filter models.AnalyticsFilter, interval string, now time.Time) ([]models.MissedTrendPoint, error) {
+	schedules, err := loadAnalyticsVisits(db, filter)
+	if err != nil {
+		return nil, err
+	}
+	loc := filter.Location
+
+	var points []models.MissedTrendPoint
+	index := map[string]int{}
+	for start := trendBucket(filter.From.In(loc), interval); start.Before(filter.To); start = nextTrendBucket(start, interval) {
+		key := start.Format("2006-01-02")
+		index[key] = len(points)
+		points = append(points, models.MissedTrendPoint{PeriodStart: key})
+	}
+	for i := range schedules {
+		s := &schedules[i]
+		if !visitDue(s, now) {
+			continue
+		}
+		i, ok := index[trendBucket(s.ShiftTime.In(loc), interval).Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		points[i].Due++
+		if visitMissed(s, now) {
+			points[i].Missed++
+		}
+	}
+	for i := range points {
+		if points[i].Due > 0 {
+			points[i].MissedRate = percent(points[i].Missed, points[i].Due)
+		}
+	}
+	return points, nil
+}
+
+func loadAnalyticsVisits(db *gorm.DB, filter models.AnalyticsFilter) ([]models.Schedule, error) {
+	query := db.Where("shift_time >= ? AND shift_time < ? AND deleted_at IS NULL", filter.From.UTC(), filter.To.UTC())
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.ClientID != 0 {
+		query = query.Where("client_id = ?", filter.ClientID)
+	}
+	var schedules []models.Schedule
+	err := query.Order("shift_time ASC").Find(&schedules).Error
+	return schedules, err
+}
+
+// visitMissed reports visits marked missed, and scheduled visits never started once
+// MissedVisitGrace has passed (the status is only updated when a caregiver lists missed visits)
+func visitMissed(s *models.Schedule, now time.Time) bool {
+	if s.Status == models.SCHEDULE_STATUS_MISSED {
+		return true
+	}
+	return s.Status == models.SCHEDULE_STATUS_SCHEDULED && s.StartTime == nil && s.ShiftTime.Before(now.Add(-MissedVisitGrace))
+}
+
+// visitDue reports visits that should have happened by now, leaving out cancelled ones
+func visitDue(s *models.Schedule, now time.Time) bool {
+	if s.Status == models.SCHEDULE_STATUS_CANCELLED {
+		return false
+	}
+	return s.StartTime != nil || s.Status == models.SCHEDULE_STATUS_MISSED || s.ShiftTime.Before(now.Add(-MissedVisitGrace))
+}
+
+func trendBucket(t time.Time, interval string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch interval {
+	case models.ANALYTICS_INTERVAL_WEEK:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case models.ANALYTICS_INTERVAL_MONTH:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return day
+	}
+}
+
+func nextTrendBucket(t time.Time, interval string) time.Time {
+	switch interval {
+	case models.ANALYTICS_INTERVAL_WEEK:
+		return t.AddDate(0, 0, 7)
+	case models.ANALYTICS_INTERVAL_MONTH:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+func nameCaregiverGroups(db *gorm.DB, groups map[string]*models.VisitKPIs) error {
+	var ids []uint
+	for _, row := range groups {
+		if row.UserID != nil {
+			ids = append(ids, *row.UserID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var users []models.User
+	if err := db.Select("id", "full_name").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return err
+	}
+	for _, user := range users {
+		if row, ok := groups["user:"+itoa(user.ID)]; ok {
+			row.Name = user.FullName
+		}
+	}
+	return nil
+}
+
+func percent(part, whole int) float64 {
+	return roundTenth(float64(part) / float64(whole) * 100)
+}
+
+func itoa(v uint) string {
+	return strconv.FormatUint(uint64(v), 10)
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/storage"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAttachmentTooLarge     = errors.New("attachment exceeds the maximum allowed size")
+	ErrAttachmentEmpty        = errors.New("attachment is empty")
+	ErrAttachmentType         = errors.New("attachment type is not allowed")
+	ErrAttachmentTaskMismatch = errors.New("task does not belong to this schedule")
+	ErrAttachmentIncident     = errors.New("incident does not belong to this schedule")
+)
+
+// SaveAttachment validates an uploaded file, writes it to storage and records its metadata.
+// The content type is sniffed from the bytes rather than trusted from the client.
+func SaveAttachment(ctx context.Context, db *gorm.DB, store storage.Storage, file *multipart.FileHeader, attachment *models.Attachment, maxBytes int64) error {
+	if file.Size == 0 {
+		return ErrAttachmentEmpty
+	}
+	if file.Size > maxBytes {
+		return ErrAttachmentTooLarge
+	}
+
+	if attachment.TaskID != nil {
+		var count int64
+		if err := db.Model(&models.Task{}).Where("id = ? AND schedule_id = ?", *attachment.TaskID, attachment.ScheduleID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrAttachmentTaskMismatch
+		}
+	}
+	if attachment.IncidentID != nil {
+		var count int64
+		if err := db.Model(&models.Incident{}).Where("id = ? AND schedule_id = ?", *attachment.IncidentID, attachment.ScheduleID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrAttachmentIncident
+		}
+	}
+
+	// First pass: checksum and content sniffing
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(src, head)
+	hasher := sha256.New()
+	hasher.Write(head[:n])
+	if _, err := io.Copy(hasher, src); err != nil {
+		src.Close()
+		return err
+	}
+	src.Close()
+
+	contentType := http.DetectContentType(head[:n])
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if !models.AllowedAttachmentTypes[contentType] {
+		return ErrAttachmentType
+	}
+
+	key, err := attachmentKey(attachment.ScheduleID, file.Filename)
+	if err != nil {
+		return err
+	}
+
+	// Second pass: upload
+	src, err = file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := store.Put(ctx, key, src, file.Size, contentType); err != nil {
+		return fmt.Errorf("failed to store attachment: %w", err)
+	}
+
+	attachment.FileName = filepath.Base(file.Filename)
+	attachment.ContentType = contentType
+	attachment.SizeBytes = file.Size
+	attachment.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	attachment.StorageDriver = store.Driver()
+	attachment.StorageKey = key
+	if err := db.Create(attachment).Error; err != nil {
+		// Do not leave an orphaned object behind
+		store.Delete(ctx, key)
+		return err
+	}
+	return nil
+}
+
+// GetAttachments lists attachments for a schedule, oldest first
+func GetAttachments(db *gorm.DB, scheduleID uint) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := db.Where("schedule_id = ?", scheduleID).Order("created_at ASC").Find(&attachments).Error
+	return attachments, err
+}
+
+// GetAttachment retrieves one attachment belonging to a schedule
+func GetAttachment(db *gorm.DB, scheduleID, attachmentID uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := db.First(&attachment, "id = ? AND schedule_id = ?", attachmentID, scheduleID).Error
+	return &attachment, err
+}
+
+// DeleteAttachment removes the metadata row and the stored object
+func DeleteAttachment(ctx context.Context, db *gorm.DB, store storage.Storage, attachment *models.Attachment) error {
+	if err := db.Delete(&models.Attachment{}, "id = ?", attachment.ID).Error; err != nil {
+		return err
+	}
+	return store.Delete(ctx, attachment.StorageKey)
+}
+
+// attachmentKey builds a unique, path-safe storage key that keeps the original extension
+func attachmentKey(scheduleID uint, filename string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	if len(ext) > 10 || strings.ContainsAny(ext, "/\\ ") {
+		ext = ""
+	}
+	return fmt.Sprintf("schedules/%d/%s%s", scheduleID, hex.EncodeToString(random), ext), nil
+}
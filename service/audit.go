@@ -0,0 +1,124 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"encoding/json"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// auditRedactedFields are never copied into the audit trail
+var auditRedactedFields = []string{"password", "refresh_token"}
+
+// RecordAudit stores a new audit entry with before/after snapshots and the field-level diff between them
+func RecordAudit(db *gorm.DB, entry *models.AuditLog, before, after interface{}) error {
+	beforeMap, err := auditSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterMap, err := auditSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	entry.Before, err = marshalAuditJSON(beforeMap)
+	if err != nil {
+		return err
+	}
+	entry.After, err = marshalAuditJSON(afterMap)
+	if err != nil {
+		return err
+	}
+	entry.Diff, err = marshalAuditJSON(auditDiff(beforeMap, afterMap))
+	if err != nil {
+		return err
+	}
+
+	return db.Create(entry).Error
+}
+
+// GetAuditLogs returns audit entries matching the filter, newest first
+func GetAuditLogs(db *gorm.DB, filter models.AuditLogFilter) ([]models.AuditLog, int64, error) {
+	query := db.Model(&models.AuditLog{})
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", filter.To.UTC())
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	var logs []models.AuditLog
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(filter.Offset).Find(&logs).Error
+	return logs, total, err
+}
+
+// auditSnapshot flattens an entity into a JSON object with sensitive fields removed
+func auditSnapshot(v interface{}) (map[string]interface{}, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return nil, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, err
+	}
+	for _, field := range auditRedactedFields {
+		delete(snapshot, field)
+	}
+	return snapshot, nil
+}
+
+// auditDiff lists every top-level field whose value differs between the two snapshots
+func auditDiff(before, after map[string]interface{}) map[string]interface{} {
+	diff := map[string]interface{}{}
+	for key, oldValue := range before {
+		newValue, ok := after[key]
+		if !ok || !reflect.DeepEqual(oldValue, newValue) {
+			diff[key] = map[string]interface{}{"from": oldValue, "to": newValue}
+		}
+	}
+	for key, newValue := range after {
+		if _, ok := before[key]; !ok {
+			diff[key] = map[string]interface{}{"from": nil, "to": newValue}
+		}
+	}
+	if len(diff) == 0 {
+		return nil
+	}
+	return diff
+}
+
+func marshalAuditJSON(v map[string]interface{}) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	s := string(raw)
+	return &s, nil
+}
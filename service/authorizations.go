@@ -0,0 +1,350 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrAuthorizationInvalid = errors.New("invalid authorization")
+
+func authorizationError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrAuthorizationInvalid, fmt.Sprintf(format, args...))
+}
+
+// ApplyAuthorizationRequest copies a request onto an authorization. Dates are calendar days.
+func ApplyAuthorizationRequest(auth *models.Authorization, req models.AuthorizationRequest) error {
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return authorizationError("start_date must be YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil {
+		return authorizationError("end_date must be YYYY-MM-DD")
+	}
+	if end.Before(start) {
+		return authorizationError("end_date must not be before start_date")
+	}
+	auth.ClientID = req.ClientID
+	auth.PayerID = req.PayerID
+	auth.ServiceCodeID = req.ServiceCodeID
+	auth.ServiceCode = nil
+	auth.AuthorizationNumber = strings.TrimSpace(req.AuthorizationNumber)
+	auth.Units = req.Units
+	auth.Period = req.Period
+	if auth.Period == "" {
+		auth.Period = models.AUTHORIZATION_PERIOD_WEEK
+	}
+	auth.StartDate = start
+	auth.EndDate = end
+	auth.Notes = req.Notes
+	return nil
+}
+
+// SaveAuthorization creates or updates an authorization
+func SaveAuthorization(db *gorm.DB, auth *models.Authorization) error {
+	return db.Omit("ServiceCode").Save(auth).Error
+}
+
+// GetAuthorizationByID retrieves an authorization with its service code
+func GetAuthorizationByID(db *gorm.DB, id uint) (*models.Authorization, error) {
+	var auth models.Authorization
+	err := db.Preload("ServiceCode").First(&auth, "id = ?", id).Error
+	return &auth, err
+}
+
+// ListAuthorizations returns authorizations, newest first, optionally for one client and only
+// those in effect on a day
+func ListAuthorizations(db *gorm.DB, clientID uint, activeOn *time.Time) ([]models.Authorization, error) {
+	query := db.Preload("ServiceCode").Order("start_date DESC, id DESC")
+	if clientID != 0 {
+		query = query.Where("client_id = ?", clientID)
+	}
+	if activeOn != nil {
+		day := activeOn.Format("2006-01-02")
+		query = query.Where("start_date <= ? AND end_date >= ?", day, day)
+	}
+	var auths []models.Authorization
+	err := query.Find(&auths).Error
+	return auths, err
+}
+
+// ListAuthorizationUsage returns the visits that deducted units from an authorization
+func ListAuthorizationUsage(db *gorm.DB, authorizationID uint) ([]models.AuthorizationUsage, error) {
+	var usage []models.AuthorizationUsage
+	err := db.Where("authorization_id = ?", authorizationID).Order("visit_date ASC, id ASC").Find(&usage).Error
+	return usage, err
+}
+
+// AuthorizationPeriod returns the calendar days [start, end) of the authorization period
+// containing day, clipped to the authorization's dates
+func AuthorizationPeriod(auth *models.Authorization, day time.Time) (time.Time, time.Time) {
+	first := dateOnly(auth.StartDate, time.UTC)
+	last := dateOnly(auth.EndDate, time.UTC).AddDate(0, 0, 1)
+	start, end := first, last
+	switch auth.Period {
+	case models.AUTHORIZATION_PERIOD_WEEK:
+		weeks := int(day.Sub(first).Hours()/24) / 7
+		start = first.AddDate(0, 0, weeks*7)
+		end = start.AddDate(0, 0, 7)
+	case models.AUTHORIZATION_PERIOD_MONTH:
+		start = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 1, 0)
+	}
+	if start.Before(first) {
+		start = first
+	}
+	if end.After(last) {
+		end = last
+	}
+	return start, end
+}
+
+// SyncAuthorizationUsage records the units a visit deducts from its client's authorization,
+// replacing any earlier deduction. Visits that are not completed, or that no authorization
+// covers, deduct nothing and nil is returned.
+func SyncAuthorizationUsage(db *gorm.DB, scheduleID uint, loc *time.Location, breakRules models.BreakRules) (*models.AuthorizationUsage, error) {
+	var schedule models.Schedule
+	err := db.Preload("Breaks", func(db *gorm.DB) *gorm.DB { return db.Order("started_at ASC") }).
+		First(&schedule, "id = ?", scheduleID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var usage *models.AuthorizationUsage
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("schedule_id = ?", schedule.ID).Delete(&models.AuthorizationUsage{}).Error; err != nil {
+			return err
+		}
+		if schedule.Status != models.SCHEDULE_STATUS_COMPLETED || schedule.StartTime == nil || schedule.EndTime == nil ||
+			schedule.ClientID == nil || schedule.ServiceCode == "" {
+			return nil
+		}
+		day := calendarDay(*schedule.StartTime, loc)
+		auth, err := findAuthorization(tx, *schedule.ClientID, schedule.ServiceCode, day)
+		if err != nil || auth == nil {
+			return err
+		}
+		minutes := SummarizeVisitTime(&schedule, schedule.Breaks, breakRules, *schedule.EndTime).WorkedMinutes
+		usage = &models.AuthorizationUsage{
+			AuthorizationID: auth.ID,
+			ScheduleID:      schedule.ID,
+			VisitDate:       day,
+			Minutes:         minutes,
+			Units:           BillableUnits(minutes, auth.ServiceCode.UnitMinutes, auth.ServiceCode.RoundingMode),
+		}
+		return tx.Create(usage).Error
+	})
+	return usage, err
+}
+
+// RecalculateAuthorizationUsage re-syncs every visit the authorization covers or was charged
+// for, so visits completed before it was entered or changed are counted against it
+func RecalculateAuthorizationUsage(db *gorm.DB, auth *models.Authorization, loc *time.Location, breakRules models.BreakRules) error {
+	var scheduleIDs []uint
+	if err := db.Model(&models.AuthorizationUsage{}).Where("authorization_id = ?", auth.ID).Pluck("schedule_id", &scheduleIDs).Error; err != nil {
+		return err
+	}
+	code, err := GetServiceCodeByID(db, auth.ServiceCodeID)
+	if err != nil {
+		return err
+	}
+	from := localMidnight(dateOnly(auth.StartDate, time.UTC), loc)
+	to := localMidnight(dateOnly(auth.EndDate, time.UTC).AddDate(0, 0, 1), loc)
+	var covered []uint
+	err = db.Model(&models.Schedule{}).
+		Where("client_id = ? AND service_code = ? AND status = ? AND start_time >= ? AND start_time < ?",
+			auth.ClientID, code.Code, models.SCHEDULE_STATUS_COMPLETED, from.UTC(), to.UTC()).
+		Pluck("id", &covered).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range dedupeUints(append(scheduleIDs, covered...)) {
+		if _, err := SyncAuthorizationUsage(db, id, loc, breakRules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuthorizationUtilizationReport reports each authorization in effect on filter.Date against
+// the units used and planned in its current period
+func AuthorizationUtilizationReport(db *gorm.DB, filter models.AuthorizationFilter, loc *time.Location) ([]models.AuthorizationUtilization, error) {
+	auths, err := ListAuthorizations(db, filter.ClientID, &filter.Date)
+	if err != nil {
+		return nil, err
+	}
+	clientIDs := make([]uint, 0, len(auths))
+	for _, auth := range auths {
+		clientIDs = append(clientIDs, auth.ClientID)
+	}
+	names := map[uint]string{}
+	if len(clientIDs) > 0 {
+		var clients []models.Client
+		if err := db.Where("id IN ?", dedupeUints(clientIDs)).Find(&clients).Error; err != nil {
+			return nil, err
+		}
+		for _, client := range clients {
+			names[client.ID] = client.FullName
+		}
+	}
+
+	report := make([]models.AuthorizationUtilization, 0, len(auths))
+	for i := range auths {
+		auth := &auths[i]
+		start, end := AuthorizationPeriod(auth, filter.Date)
+		used, err := authorizationUnitsUsed(db, auth.ID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		planned, err := authorizationUnitsPlanned(db, auth, start, end, loc, 0)
+		if err != nil {
+			return nil, err
+		}
+		row := models.AuthorizationUtilization{
+			AuthorizationID:     auth.ID,
+			AuthorizationNumber: auth.AuthorizationNumber,
+			ClientID:            auth.ClientID,
+			ClientName:          names[auth.ClientID],
+			Period:              auth.Period,
+			PeriodStart:         start,
+			PeriodEnd:           end.AddDate(0, 0, -1),
+			AuthorizedUnits:     auth.Units,
+			UsedUnits:           used,
+			PlannedUnits:        planned,
+			RemainingUnits:      auth.Units - used - planned,
+			OverAuthorized:      used+planned > auth.Units,
+		}
+		if auth.ServiceCode != nil {
+			row.ServiceCode = auth.ServiceCode.Code
+		}
+		if auth.Units > 0 {
+			row.UtilizationPercent = math.Round(float64(used)/float64(auth.Units)*1000) / 10
+		}
+		report = append(report, row)
+	}
+	return report, nil
+}
+
+// AuthorizationWarningFor checks a scheduled visit against its client's authorization. It
+// returns nil when the visit is covered, or when the client has no authorizations at all.
+func AuthorizationWarningFor(db *gorm.DB, schedule *models.Schedule, loc *time.Location) (*models.AuthorizationWarning, error) {
+	if schedule.ClientID == nil || schedule.ServiceCode == "" {
+		return nil, nil
+	}
+	code := strings.ToUpper(schedule.ServiceCode)
+	day := calendarDay(schedule.ShiftTime, loc)
+	auth, err := findAuthorization(db, *schedule.ClientID, code, day)
+	if err != nil {
+		return nil, err
+	}
+	if auth == nil {
+		var count int64
+		if err := db.Model(&models.Authorization{}).Where("client_id = ?", *schedule.ClientID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, nil
+		}
+		return &models.AuthorizationWarning{
+			ServiceCode: code,
+			Message:     fmt.Sprintf("No %s authorization covers %s", code, day.Format("2006-01-02")),
+		}, nil
+	}
+
+	start, end := AuthorizationPeriod(auth, day)
+	used, err := authorizationUnitsUsed(db, auth.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	planned, err := authorizationUnitsPlanned(db, auth, start, end, loc, schedule.ID)
+	if err != nil {
+		return nil, err
+	}
+	visitUnits := BillableUnits(int(visitDuration(schedule).Minutes()), auth.ServiceCode.UnitMinutes, auth.ServiceCode.RoundingMode)
+	if used+planned+visitUnits <= auth.Units {
+		return nil, nil
+	}
+	last := end.AddDate(0, 0, -1)
+	return &models.AuthorizationWarning{
+		AuthorizationID: &auth.ID,
+		ServiceCode:     code,
+		PeriodStart:     &start,
+		PeriodEnd:       &last,
+		AuthorizedUnits: auth.Units,
+		UsedUnits:       used,
+		PlannedUnits:    planned,
+		VisitUnits:      visitUnits,
+		Message: fmt.Sprintf("%d %s units used or planned for %s to %s exceed the %d authorized",
+			used+planned+visitUnits, code, start.Format("2006-01-02"), last.Format("2006-01-02"), auth.Units),
+	}, nil
+}
+
+// findAuthorization returns the authorization for a client's service code in effect on day,
+// preferring the most recently started, or nil when there is none
+func findAuthorization(db *gorm.DB, clientID uint, code string, day time.Time) (*models.Authorization, error) {
+	d := day.Format("2006-01-02")
+	var auth models.Authorization
+	err := db.Preload("ServiceCode").
+		Joins("JOIN service_codes ON service_codes.id = authorizations.service_code_id").
+		Where("authorizations.client_id = ? AND service_codes.code = ? AND authorizations.start_date <= ? AND authorizations.end_date >= ?",
+			clientID, strings.ToUpper(code), d, d).
+		Order("authorizations.start_date DESC, authorizations.id DESC").
+		First(&auth).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// authorizationUnitsUsed sums the units completed visits deducted in [start, end)
+func authorizationUnitsUsed(db *gorm.DB, authorizationID uint, start, end time.Time) (int, error) {
+	var used int
+	err := db.Model(&models.AuthorizationUsage{}).
+		Where("authorization_id = ? AND visit_date >= ? AND visit_date < ?", authorizationID, start.Format("2006-01-02"), end.Format("2006-01-02")).
+		Select("COALESCE(SUM(units), 0)").Scan(&used).Error
+	return used, err
+}
+
+// authorizationUnitsPlanned sums the units of visits not yet completed in [start, end) at their
+// planned length
+func authorizationUnitsPlanned(db *gorm.DB, auth *models.Authorization, start, end time.Time, loc *time.Location, excludeScheduleID uint) (int, error) {
+	if auth.ServiceCode == nil {
+		return 0, nil
+	}
+	query := db.Where("client_id = ? AND service_code = ? AND status IN ? AND shift_time >= ? AND shift_time < ?",
+		auth.ClientID, auth.ServiceCode.Code,
+		[]string{models.SCHEDULE_STATUS_SCHEDULED, models.SCHEDULE_STATUS_IN_PROGRESS},
+		localMidnight(start, loc).UTC(), localMidnight(end, loc).UTC())
+	if excludeScheduleID != 0 {
+		query = query.Where("id <> ?", excludeScheduleID)
+	}
+	var schedules []models.Schedule
+	if err := query.Find(&schedules).Error; err != nil {
+		return 0, err
+	}
+	planned := 0
+	for i := range schedules {
+		planned += BillableUnits(int(visitDuration(&schedules[i]).Minutes()), auth.ServiceCode.UnitMinutes, auth.ServiceCode.RoundingMode)
+	}
+	return planned, nil
+}
+
+// calendarDay returns the agency-local day of t as midnight UTC, the form DATE columns use
+func calendarDay(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// localMidnight is the start of a calendar day in loc
+func localMidnight(day time.Time, loc *time.Location) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,666 @@
+package service
+
+import (
+	"bytes"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/utils"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrBillingNoVisits         = errors.New("no completed visits are waiting to be invoiced for this period")
+	ErrBillingRateInvalid      = errors.New("effective_to must not be before effective_from")
+	ErrInvoiceNotRebillable    = errors.New("credit invoices cannot be rebilled")
+	ErrInvoiceNothingToRebill  = errors.New("no visit on this invoice has changed since it was billed")
+	ErrInvoiceVisitNotBillable = errors.New("visit is not on this invoice or has already been credited")
+)
+
+// BillingSkipped is a visit left out of invoicing, with the reason
+type BillingSkipped struct {
+	ScheduleID uint   `json:"schedule_id"`
+	Reason     string `json:"reason"`
+}
+
+// ListPayers returns every payer by name
+func ListPayers(db *gorm.DB) ([]models.Payer, error) {
+	var payers []models.Payer
+	err := db.Order("name ASC").Find(&payers).Error
+	return payers, err
+}
+
+// GetPayerByID retrieves a single payer
+func GetPayerByID(db *gorm.DB, payerID uint) (*models.Payer, error) {
+	var payer models.Payer
+	err := db.First(&payer, "id = ?", payerID).Error
+	return &payer, err
+}
+
+// SavePayer creates or updates a payer
+func SavePayer(db *gorm.DB, payer *models.Payer) error {
+	return db.Save(payer).Error
+}
+
+// ListServiceCodes returns every service code with its rates
+func ListServiceCodes(db *gorm.DB) ([]models.ServiceCode, error) {
+	var codes []models.ServiceCode
+	err := db.Preload("Rates", func(db *gorm.DB) *gorm.DB { return db.Order("effective_from DESC, id DESC") }).
+		Order("code ASC").Find(&codes).Error
+	return codes, err
+}
+
+// GetServiceCodeByID retrieves a service code with its rates
+func GetServiceCodeByID(db *gorm.DB, id uint) (*models.ServiceCode, error) {
+	var code models.ServiceCode
+	err := db.Preload("Rates", func(db *gorm.DB) *gorm.DB { return db.Order("effective_from DESC, id DESC") }).
+		First(&code, "id = ?", id).Error
+	return &code, err
+}
+
+// ApplyServiceCodeRequest copies a request onto a service code, filling defaults
+func ApplyServiceCodeRequest(code *models.ServiceCode, req models.ServiceCodeRequest) {
+	code.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	code.Description = req.Description
+	code.UnitMinutes = req.UnitMinutes
+	if code.UnitMinutes == 0 {
+		code.UnitMinutes = models.DEFAULT_BILLING_UNIT_MINUTES
+	}
+	code.RoundingMode = req.RoundingMode
+	if code.RoundingMode == "" {
+		code.RoundingMode = models.UNIT_ROUNDING_NEAREST
+	}
+	code.Active = req.Active == nil || *req.Active
+}
+
+// SaveServiceCode creates or updates a service code. Active is written explicitly because
+// gorm skips false booleans that have a column default.
+func SaveServiceCode(db *gorm.DB, code *models.ServiceCode) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Rates").Save(code).Error; err != nil {
+			return err
+		}
+		return tx.Model(code).Update("active", code.Active).Error
+	})
+}
+
+// BuildBillingRate turns a request into a rate for the service code. Dates are calendar days.
+func BuildBillingRate(serviceCodeID uint, req models.BillingRateRequest) (*models.BillingRate, error) {
+	from, err := time.Parse("2006-01-02", req.EffectiveFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid effective_from, expected YYYY-MM-DD")
+	}
+	rate := &models.BillingRate{
+		ServiceCodeID: serviceCodeID,
+		PayerID:       req.PayerID,
+		ClientID:      req.ClientID,
+		RatePerUnit:   roundCents(req.RatePerUnit),
+		EffectiveFrom: from,
+	}
+	if req.EffectiveTo != "" {
+		to, err := time.Parse("2006-01-02", req.EffectiveTo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid effective_to, expected YYYY-MM-DD")
+		}
+		if to.Before(from) {
+			return nil, ErrBillingRateInvalid
+		}
+		rate.EffectiveTo = &to
+	}
+	return rate, nil
+}
+
+// CreateBillingRate stores a new rate
+func CreateBillingRate(db *gorm.DB, rate *models.BillingRate) error {
+	return db.Create(rate).Error
+}
+
+// ResolveBillingRate picks the rate that applies to a client and payer on a day: client rates
+// beat payer rates, which beat the default, and the latest effective date wins a tie
+func ResolveBillingRate(rates []models.BillingRate, clientID uint, payerID *uint, day time.Time) *models.BillingRate {
+	var (
+		best      *models.BillingRate
+		bestScore = -1
+	)
+	for i := range rates {
+		rate := &rates[i]
+		if day.Before(dateOnly(rate.EffectiveFrom, time.UTC)) || (rate.EffectiveTo != nil && day.After(dateOnly(*rate.EffectiveTo, time.UTC))) {
+			continue
+		}
+		score := 0
+		if rate.ClientID != nil {
+			if *rate.ClientID != clientID {
+				continue
+			}
+			score += 2
+		}
+		if rate.PayerID != nil {
+			if payerID == nil || *rate.PayerID != *payerID {
+				continue
+			}
+			score++
+		}
+		if score > bestScore || (score == bestScore && rate.EffectiveFrom.After(best.EffectiveFrom)) {
+			best, bestScore = rate, score
+		}
+	}
+	return best
+}
+
+// BillableUnits converts minutes to billing units. Nearest rounds half a unit up, so with
+// 15-minute units 8 minutes or more bill as one unit.
+func BillableUnits(minutes, unitMinutes int, mode string) int {
+	if unitMinutes <= 0 || minutes <= 0 {
+		return 0
+	}
+	switch mode {
+	case models.UNIT_ROUNDING_UP:
+		return (minutes + unitMinutes - 1) / unitMinutes
+	case models.UNIT_ROUNDING_DOWN:
+		return minutes / unitMinutes
+	default:
+		return (minutes*2 + unitMinutes) / (unitMinutes * 2)
+	}
+}
+
+// billingContext holds what pricing a visit needs
+type billingContext struct {
+	codes      map[string]models.ServiceCode
+	clients    map[uint]models.Client
+	loc        *time.Location
+	breakRules models.BreakRules
+}
+
+func loadBillingContext(db *gorm.DB, clientIDs []uint, loc *time.Location, breakRules models.BreakRules) (*billingContext, error) {
+	codes, err := ListServiceCodes(db)
+	if err != nil {
+		return nil, err
+	}
+	bc := &billingContext{codes: map[string]models.ServiceCode{}, clients: map[uint]models.Client{}, loc: loc, breakRules: breakRules}
+	for _, code := range codes {
+		bc.codes[code.Code] = code
+	}
+	if len(clientIDs) > 0 {
+		var clients []models.Client
+		if err := db.Where("id IN ?", dedupeUints(clientIDs)).Find(&clients).Error; err != nil {
+			return nil, err
+		}
+		for _, client := range clients {
+			bc.clients[client.ID] = client
+		}
+	}
+	return bc, nil
+}
+
+// priceVisit builds the invoice line for a completed visit, or says why it cannot be billed
+func (bc *billingContext) priceVisit(schedule *models.Schedule) (*models.InvoiceLine, string) {
+	if schedule.Status != models.SCHEDULE_STATUS_COMPLETED || schedule.StartTime == nil || schedule.EndTime == nil {
+		return nil, "visit is not completed"
+	}
+	if schedule.ClientID == nil {
+		return nil, "visit has no client"
+	}
+	if schedule.ServiceCode == "" {
+		return nil, "visit has no service code"
+	}
+	code, ok := bc.codes[strings.ToUpper(schedule.ServiceCode)]
+	if !ok {
+		return nil, "unknown service code " + schedule.ServiceCode
+	}
+	if !code.Active {
+		return nil, "service code " + code.Code + " is inactive"
+	}
+
+	local := schedule.StartTime.In(bc.loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	rate := ResolveBillingRate(code.Rates, *schedule.ClientID, bc.clients[*schedule.ClientID].PayerID, day)
+	if rate == nil {
+		return nil, fmt.Sprintf("no %s rate on %s", code.Code, day.Format("2006-01-02"))
+	}
+
+	minutes := SummarizeVisitTime(schedule, schedule.Breaks, bc.breakRules, *schedule.EndTime).WorkedMinutes
+	units := BillableUnits(minutes, code.UnitMinutes, code.RoundingMode)
+	return &models.InvoiceLine{
+		ScheduleID:     schedule.ID,
+		ServiceCode:    code.Code,
+		VisitDate:      day,
+		StartTime:      *schedule.StartTime,
+		EndTime:        *schedule.EndTime,
+		BilledMinutes:  minutes,
+		Units:          units,
+		RatePerUnit:    rate.RatePerUnit,
+		Amount:         roundCents(float64(units) * rate.RatePerUnit),
+		VisitUpdatedAt: schedule.UpdatedAt,
+	}, ""
+}
+
+// GenerateInvoices bills every completed visit that started in [from, to) and is not already on
+// an invoice, one invoice per client. Visits that cannot be priced are returned as skipped.
+// The visits are locked while they are billed, so a concurrent run waits for this one and then
+// finds them on its invoices instead of billing them twice.
+func GenerateInvoices(db *gorm.DB, from, to time.Time, clientID *uint, loc *time.Location, breakRules models.BreakRules, issuedBy uint) ([]models.Invoice, []BillingSkipped, error) {
+	var invoices []models.Invoice
+	var skipped []BillingSkipped
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Breaks", func(db *gorm.DB) *gorm.DB { return db.Order("started_at ASC") }).
+			Where("status = ? AND client_id IS NOT NULL AND start_time >= ? AND start_time < ? AND end_time IS NOT NULL",
+				models.SCHEDULE_STATUS_COMPLETED, from.UTC(), to.UTC()).
+			Order("start_time ASC")
+		if clientID != nil {
+			query = query.Where("client_id = ?", *clientID)
+		}
+		var schedules []models.Schedule
+		if err := query.Find(&schedules).Error; err != nil {
+			return err
+		}
+
+		ids := make([]uint, 0, len(schedules))
+		var clientIDs []uint
+		for _, s := range schedules {
+			ids = append(ids, s.ID)
+			clientIDs = append(clientIDs, *s.ClientID)
+		}
+		billed, err := activeInvoiceLines(tx, ids)
+		if err != nil {
+			return err
+		}
+		bc, err := loadBillingContext(tx, clientIDs, loc, breakRules)
+		if err != nil {
+			return err
+		}
+
+		byClient := map[uint][]models.InvoiceLine{}
+		var order []uint
+		for i := range schedules {
+			schedule := &schedules[i]
+			if _, ok := billed[schedule.ID]; ok {
+				continue
+			}
+			line, reason := bc.priceVisit(schedule)
+			if line == nil {
+				skipped = append(skipped, BillingSkipped{ScheduleID: schedule.ID, Reason: reason})
+				continue
+			}
+			if _, ok := byClient[*schedule.ClientID]; !ok {
+				order = append(order, *schedule.ClientID)
+			}
+			byClient[*schedule.ClientID] = append(byClient[*schedule.ClientID], *line)
+		}
+		if len(order) == 0 {
+			return ErrBillingNoVisits
+		}
+
+		payers, err := payerNames(tx)
+		if err != nil {
+			return err
+		}
+		invoices = make([]models.Invoice, 0, len(order))
+		for _, id := range order {
+			client := bc.clients[id]
+			invoice := newInvoice(models.INVOICE_KIND_INVOICE, client, payers, from, to, issuedBy)
+			if err := createInvoice(tx, &invoice, byClient[id]); err != nil {
+				return err
+			}
+			invoices = append(invoices, invoice)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, skipped, err
+	}
+	return invoices, skipped, nil
+}
+
+// RebillInvoice reverses visits on an invoice with a credit invoice and bills them again at
+// their current times and rates on a rebill invoice. Without scheduleIDs, every visit whose
+// price changed since it was billed is used. A visit that can no longer be billed, e.g. one
+// that was cancelled, is only credited.
+func RebillInvoice(db *gorm.DB, invoice *models.Invoice, scheduleIDs []uint, loc *time.Location, breakRules models.BreakRules, issuedBy uint) (*models.Invoice, *models.Invoice, error) {
+	if invoice.Kind == models.INVOICE_KIND_CREDIT {
+		return nil, nil, ErrInvoiceNotRebillable
+	}
+	ids := make([]uint, 0, len(invoice.Lines))
+	for _, line := range invoice.Lines {
+		ids = append(ids, line.ScheduleID)
+	}
+	active, err := activeInvoiceLines(db, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	onInvoice := map[uint]models.InvoiceLine{}
+	for _, line := range invoice.Lines {
+		if current, ok := active[line.ScheduleID]; ok && current.ID == line.ID {
+			onInvoice[line.ScheduleID] = line
+		}
+	}
+
+	var schedules []models.Schedule
+	if err := db.Preload("Breaks", func(db *gorm.DB) *gorm.DB { return db.Order("started_at ASC") }).
+		Where("id IN ?", ids).Find(&schedules).Error; err != nil {
+		return nil, nil, err
+	}
+	byID := map[uint]*models.Schedule{}
+	for i := range schedules {
+		byID[schedules[i].ID] = &schedules[i]
+	}
+	bc, err := loadBillingContext(db, []uint{invoice.ClientID}, loc, breakRules)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var credits, rebills []models.InvoiceLine
+	reprice := func(line models.InvoiceLine) {
+		credit := line
+		credit.ID = 0
+		credit.InvoiceID = 0
+		credit.Units = -line.Units
+		credit.Amount = -line.Amount
+		credit.CreditsLineID = &line.ID
+		credits = append(credits, credit)
+		if schedule, ok := byID[line.ScheduleID]; ok {
+			if repriced, _ := bc.priceVisit(schedule); repriced != nil {
+				rebills = append(rebills, *repriced)
+			}
+		}
+	}
+	if len(scheduleIDs) > 0 {
+		for _, id := range dedupeUints(scheduleIDs) {
+			line, ok := onInvoice[id]
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: %d", ErrInvoiceVisitNotBillable, id)
+			}
+			reprice(line)
+		}
+	} else {
+		for _, line := range invoice.Lines {
+			if _, ok := onInvoice[line.ScheduleID]; !ok {
+				continue
+			}
+			schedule, ok := byID[line.ScheduleID]
+			var repriced *models.InvoiceLine
+			if ok {
+				repriced, _ = bc.priceVisit(schedule)
+			}
+			if repriced == nil || invoiceLineChanged(line, *repriced) {
+				reprice(line)
+			}
+		}
+	}
+	if len(credits) == 0 {
+		return nil, nil, ErrInvoiceNothingToRebill
+	}
+
+	payers, err := payerNames(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := bc.clients[invoice.ClientID]
+	credit := newInvoice(models.INVOICE_KIND_CREDIT, client, payers, invoice.PeriodStart, invoice.PeriodEnd, issuedBy)
+	credit.OriginalInvoiceID = &invoice.ID
+	// The credit goes to whoever was billed originally, even if the client changed payer since
+	credit.PayerID, credit.PayerName = invoice.PayerID, invoice.PayerName
+	var rebill *models.Invoice
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := createInvoice(tx, &credit, credits); err != nil {
+			return err
+		}
+		if len(rebills) == 0 {
+			return nil
+		}
+		next := newInvoice(models.INVOICE_KIND_REBILL, client, payers, invoice.PeriodStart, invoice.PeriodEnd, issuedBy)
+		next.OriginalInvoiceID = credit.OriginalInvoiceID
+		rebill = &next
+		return createInvoice(tx, rebill, rebills)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &credit, rebill, nil
+}
+
+// GetInvoiceByID retrieves an invoice with its lines in visit order
+func GetInvoiceByID(db *gorm.DB, invoiceID uint) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := db.Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("start_time ASC, id ASC") }).
+		First(&invoice, "id = ?", invoiceID).Error
+	return &invoice, err
+}
+
+// ListInvoices returns invoices matching the filter, newest first
+func ListInvoices(db *gorm.DB, filter models.InvoiceFilter) ([]models.Invoice, error) {
+	query := db.Order("id DESC")
+	if filter.ClientID != 0 {
+		query = query.Where("client_id = ?", filter.ClientID)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.From != nil {
+		query = query.Where("period_end > ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("period_start <= ?", filter.To.UTC())
+	}
+	var invoices []models.Invoice
+	err := query.Find(&invoices).Error
+	return invoices, err
+}
+
+// InvoiceCSV renders an invoice's lines as CSV, one row per visit
+func InvoiceCSV(invoice *models.Invoice, loc *time.Location) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"InvoiceNumber", "Kind", "OriginalInvoiceID", "ClientID", "ClientName", "Payer", "VisitID", "VisitDate", "ServiceCode", "StartTime", "EndTime", "BilledMinutes", "Units", "RatePerUnit", "Amount"}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	original := ""
+	if invoice.OriginalInvoiceID != nil {
+		original = strconv.FormatUint(uint64(*invoice.OriginalInvoiceID), 10)
+	}
+	for _, line := range invoice.Lines {
+		record := []string{
+			invoice.InvoiceNumber,
+			invoice.Kind,
+			original,
+			strconv.FormatUint(uint64(invoice.ClientID), 10),
+			invoice.ClientName,
+			invoice.PayerName,
+			strconv.FormatUint(uint64(line.ScheduleID), 10),
+			line.VisitDate.Format("2006-01-02"),
+			line.ServiceCode,
+			line.StartTime.In(loc).Format(time.RFC3339),
+			line.EndTime.In(loc).Format(time.RFC3339),
+			strconv.Itoa(line.BilledMinutes),
+			strconv.Itoa(line.Units),
+			formatMoney(line.RatePerUnit),
+			formatMoney(line.Amount),
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// InvoicePDF renders a printable invoice
+func InvoicePDF(invoice *models.Invoice, agencyName string, loc *time.Location) []byte {
+	const (
+		left       = 50.0
+		top        = 742.0
+		bottom     = 60.0
+		lineHeight = 14.0
+	)
+	columns := []struct {
+		title string
+		x     float64
+		right bool
+	}{
+		{"Date", left, false}, {"Service", 120, false}, {"Time", 180, false}, {"Minutes", 340, true},
+		{"Units", 390, true}, {"Rate", 460, true}, {"Amount", 560, true},
+	}
+	cell := func(pdf *utils.PDF, col int, y float64, bold bool, s string) {
+		x := columns[col].x
+		if columns[col].right {
+			x -= utils.TextWidth(s, 9)
+		}
+		pdf.Text(x, y, 9, bold, s)
+	}
+	tableHeader := func(pdf *utils.PDF, y float64) float64 {
+		for i, column := range columns {
+			cell(pdf, i, y, true, column.title)
+		}
+		pdf.Line(left, y-4, 562, y-4)
+		return y - lineHeight - 4
+	}
+
+	title := "INVOICE"
+	switch invoice.Kind {
+	case models.INVOICE_KIND_CREDIT:
+		title = "CREDIT NOTE"
+	case models.INVOICE_KIND_REBILL:
+		title = "INVOICE (REBILL)"
+	}
+	pdf := utils.NewPDF()
+	y := top
+	pdf.Text(left, y, 18, true, title)
+	if agencyName != "" {
+		pdf.Text(562-utils.TextWidth(agencyName, 11), y, 11, true, agencyName)
+	}
+	y -= 28
+	details := []string{
+		"Invoice number: " + invoice.InvoiceNumber,
+		"Issued: " + invoice.CreatedAt.In(loc).Format("2006-01-02"),
+		"Client: " + invoice.ClientName,
+		"Period: " + invoice.PeriodStart.In(loc).Format("2006-01-02") + " to " + invoice.PeriodEnd.In(loc).AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+	if invoice.PayerName != "" {
+		details = append(details, "Bill to: "+invoice.PayerName)
+	}
+	if invoice.OriginalInvoiceID != nil {
+		details = append(details, fmt.Sprintf("Adjusts invoice ID: %d", *invoice.OriginalInvoiceID))
+	}
+	for _, detail := range details {
+		pdf.Text(left, y, 10, false, detail)
+		y -= lineHeight
+	}
+	y -= 10
+	y = tableHeader(pdf, y)
+
+	for _, line := range invoice.Lines {
+		if y < bottom+lineHeight*2 {
+			pdf.AddPage()
+			y = tableHeader(pdf, top)
+		}
+		cell(pdf, 0, y, false, line.VisitDate.Format("2006-01-02"))
+		cell(pdf, 1, y, false, line.ServiceCode)
+		cell(pdf, 2, y, false, line.StartTime.In(loc).Format("15:04")+" - "+line.EndTime.In(loc).Format("15:04"))
+		cell(pdf, 3, y, false, strconv.Itoa(line.BilledMinutes))
+		cell(pdf, 4, y, false, strconv.Itoa(line.Units))
+		cell(pdf, 5, y, false, formatMoney(line.RatePerUnit))
+		cell(pdf, 6, y, false, formatMoney(line.Amount))
+		y -= lineHeight
+	}
+	pdf.Line(left, y+lineHeight-4, 562, y+lineHeight-4)
+	y -= 4
+	cell(pdf, 3, y, true, "Total")
+	cell(pdf, 4, y, true, strconv.Itoa(invoice.Units))
+	cell(pdf, 6, y, true, formatMoney(invoice.Total))
+	return pdf.Bytes()
+}
+
+// activeInvoiceLines returns, per visit, the charge line that currently bills it: the newest
+// line that is not itself a credit and has not been reversed by one
+func activeInvoiceLines(db *gorm.DB, scheduleIDs []uint) (map[uint]models.InvoiceLine, error) {
+	active := map[uint]models.InvoiceLine{}
+	if len(scheduleIDs) == 0 {
+		return active, nil
+	}
+	var lines []models.InvoiceLine
+	err := db.Where("schedule_id IN ? AND credits_line_id IS NULL", scheduleIDs).
+		Where("NOT EXISTS (SELECT 1 FROM invoice_lines AS credits WHERE credits.credits_line_id = invoice_lines.id)").
+		Order("id ASC").
+		Find(&lines).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		active[line.ScheduleID] = line
+	}
+	return active, nil
+}
+
+// invoiceLineChanged reports whether repricing a visit gives a different charge
+func invoiceLineChanged(billed, repriced models.InvoiceLine) bool {
+	return billed.ServiceCode != repriced.ServiceCode ||
+		billed.Units != repriced.Units ||
+		billed.RatePerUnit != repriced.RatePerUnit ||
+		!billed.StartTime.Equal(repriced.StartTime) ||
+		!billed.EndTime.Equal(repriced.EndTime)
+}
+
+func newInvoice(kind string, client models.Client, payers map[uint]string, from, to time.Time, issuedBy uint) models.Invoice {
+	invoice := models.Invoice{
+		Kind:        kind,
+		ClientID:    client.ID,
+		ClientName:  client.FullName,
+		PayerID:     client.PayerID,
+		PeriodStart: from.UTC(),
+		PeriodEnd:   to.UTC(),
+		IssuedBy:    issuedBy,
+	}
+	if client.PayerID != nil {
+		invoice.PayerName = payers[*client.PayerID]
+	}
+	return invoice
+}
+
+// createInvoice stores an invoice and its lines, numbering it from its ID
+func createInvoice(tx *gorm.DB, invoice *models.Invoice, lines []models.InvoiceLine) error {
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].StartTime.Before(lines[j].StartTime) })
+	for _, line := range lines {
+		invoice.Units += line.Units
+		invoice.Total += line.Amount
+	}
+	invoice.Total = roundCents(invoice.Total)
+	if err := tx.Create(invoice).Error; err != nil {
+		return err
+	}
+	prefix := "INV"
+	if invoice.Kind == models.INVOICE_KIND_CREDIT {
+		prefix = "CRN"
+	}
+	invoice.InvoiceNumber = fmt.Sprintf("%s-%06d", prefix, invoice.ID)
+	if err := tx.Model(invoice).Update("invoice_number", invoice.InvoiceNumber).Error; err != nil {
+		return err
+	}
+	for i := range lines {
+		lines[i].InvoiceID = invoice.ID
+	}
+	if err := tx.Create(&lines).Error; err != nil {
+		return err
+	}
+	invoice.Lines = lines
+	return nil
+}
+
+func payerNames(db *gorm.DB) (map[uint]string, error) {
+	payers, err := ListPayers(db)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(payers))
+	for _, payer := range payers {
+		names[payer.ID] = payer.Name
+	}
+	return names, nil
+}
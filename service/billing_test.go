@@ -0,0 +1,91 @@
+package service
+
+import (
+	"caregiver-shift-tracker/database/dbtest"
+	"caregiver-shift-tracker/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBillableUnits(t *testing.T) {
+	cases := []struct {
+		name        string
+		minutes     int
+		unitMinutes int
+		mode        string
+		want        int
+	}{
+		{"nearest below half a unit", 7, 15, models.UNIT_ROUNDING_NEAREST, 0},
+		{"nearest at half a unit", 8, 15, models.UNIT_ROUNDING_NEAREST, 1},
+		{"nearest even unit length at exactly half", 15, 30, models.UNIT_ROUNDING_NEAREST, 1},
+		{"nearest just under half of an even unit", 14, 30, models.UNIT_ROUNDING_NEAREST, 0},
+		{"nearest after whole units", 52, 15, models.UNIT_ROUNDING_NEAREST, 3},
+		{"nearest rounds the last partial unit up", 53, 15, models.UNIT_ROUNDING_NEAREST, 4},
+		{"unknown mode rounds to nearest", 8, 15, "", 1},
+		{"up from one minute", 1, 15, models.UNIT_ROUNDING_UP, 1},
+		{"up on an exact multiple", 45, 15, models.UNIT_ROUNDING_UP, 3},
+		{"up past an exact multiple", 46, 15, models.UNIT_ROUNDING_UP, 4},
+		{"down under one unit", 14, 15, models.UNIT_ROUNDING_DOWN, 0},
+		{"down just under two units", 29, 15, models.UNIT_ROUNDING_DOWN, 1},
+		{"one-minute units", 61, 1, models.UNIT_ROUNDING_NEAREST, 61},
+		{"hour units", 89, 60, models.UNIT_ROUNDING_NEAREST, 1},
+		{"hour units at half", 90, 60, models.UNIT_ROUNDING_NEAREST, 2},
+		{"no minutes", 0, 15, models.UNIT_ROUNDING_UP, 0},
+		{"negative minutes", -10, 15, models.UNIT_ROUNDING_UP, 0},
+		{"no unit length", 60, 0, models.UNIT_ROUNDING_NEAREST, 0},
+	}
+	for _, tc := range cases {
+		if got := BillableUnits(tc.minutes, tc.unitMinutes, tc.mode); got != tc.want {
+			t.Errorf("%s: BillableUnits(%d, %d, %q) = %d, want %d", tc.name, tc.minutes, tc.unitMinutes, tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestGenerateInvoicesDoesNotBillAVisitTwice(t *testing.T) {
+	db := dbtest.Open(t)
+	caregiver := models.User{Email: "cg@example.com", Mobile: "5550100", FullName: "Casey Giver", Password: "x", RoleID: models.ROLE_CAREGIVER}
+	if err := db.Create(&caregiver).Error; err != nil {
+		t.Fatal(err)
+	}
+	client := models.Client{FullName: "Pat Client"}
+	if err := db.Create(&client).Error; err != nil {
+		t.Fatal(err)
+	}
+	code := models.ServiceCode{Code: "T1019", UnitMinutes: 15, RoundingMode: models.UNIT_ROUNDING_NEAREST, Active: true}
+	if err := db.Create(&code).Error; err != nil {
+		t.Fatal(err)
+	}
+	rate := models.BillingRate{ServiceCodeID: code.ID, RatePerUnit: 6.5, EffectiveFrom: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if err := db.Create(&rate).Error; err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	visit := models.Schedule{UserID: caregiver.ID, ClientID: &client.ID, ClientName: client.FullName, Location: "1 Main St",
+		ServiceCode: code.Code, ShiftTime: start, Status: models.SCHEDULE_STATUS_COMPLETED, StartTime: &start, EndTime: &end}
+	if err := db.Create(&visit).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := start.Truncate(24*time.Hour), start.Truncate(24*time.Hour).Add(24*time.Hour)
+	invoices, skipped, err := GenerateInvoices(db, from, to, nil, time.UTC, models.BreakRules{}, caregiver.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != 1 || len(skipped) != 0 {
+		t.Fatalf("got %d invoices and %d skipped visits, want 1 and 0", len(invoices), len(skipped))
+	}
+	if lines := invoices[0].Lines; len(lines) != 1 || lines[0].ScheduleID != visit.ID || lines[0].Units != 4 || lines[0].Amount != 26 {
+		t.Fatalf("unexpected invoice lines %+v", lines)
+	}
+
+	if _, _, err := GenerateInvoices(db, from, to, nil, time.UTC, models.BreakRules{}, caregiver.ID); !errors.Is(err, ErrBillingNoVisits) {
+		t.Fatalf("second run: got %v, want %v", err, ErrBillingNoVisits)
+	}
+	var lines int64
+	db.Model(&models.InvoiceLine{}).Where("schedule_id = ?", visit.ID).Count(&lines)
+	if lines != 1 {
+		t.Fatalf("visit is on %d invoice lines, want 1", lines)
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrBreakVisitNotInProgress = errors.New("breaks can only be taken while the visit is in progress")
+	ErrBreakAlreadyOpen        = errors.New("the visit is already paused")
+	ErrBreakNotOpen            = errors.New("the visit is not paused")
+	ErrBreakLimitReached       = errors.New("the maximum number of breaks for this visit has been reached")
+	ErrVisitOnBreak            = errors.New("resume the visit before ending it")
+)
+
+// PauseVisit opens a break segment. The schedule row is locked so two pauses cannot race.
+func PauseVisit(db *gorm.DB, scheduleID uint, lat, lon float64, reason string, rules models.BreakRules) (*models.VisitBreak, error) {
+	var visitBreak *models.VisitBreak
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var schedule models.Schedule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, scheduleID).Error; err != nil {
+			return err
+		}
+		if schedule.Status != models.SCHEDULE_STATUS_IN_PROGRESS {
+			return ErrBreakVisitNotInProgress
+		}
+
+		var breaks []models.VisitBreak
+		if err := tx.Where("schedule_id = ?", scheduleID).Find(&breaks).Error; err != nil {
+			return err
+		}
+		if OpenBreak(breaks) != nil {
+			return ErrBreakAlreadyOpen
+		}
+		if rules.MaxBreaksPerVisit > 0 && len(breaks) >= rules.MaxBreaksPerVisit {
+			return ErrBreakLimitReached
+		}
+
+		visitBreak = &models.VisitBreak{
+			ScheduleID: scheduleID,
+			StartedAt:  time.Now(),
+			StartLat:   &lat,
+			StartLon:   &lon,
+			Reason:     reason,
+		}
+		return tx.Create(visitBreak).Error
+	})
+	return visitBreak, err
+}
+
+// ResumeVisit closes the open break segment
+func ResumeVisit(db *gorm.DB, scheduleID uint, lat, lon float64) (*models.VisitBreak, error) {
+	var visitBreak models.VisitBreak
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("schedule_id = ? AND ended_at IS NULL", scheduleID).
+			First(&visitBreak).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrBreakNotOpen
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		visitBreak.EndedAt = &now
+		visitBreak.EndLat = &lat
+		visitBreak.EndLon = &lon
+		return tx.Save(&visitBreak).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &visitBreak, nil
+}
+
+// OpenBreak returns the break that has not been resumed yet, if any
+func OpenBreak(breaks []models.VisitBreak) *models.VisitBreak {
+	for i := range breaks {
+		if breaks[i].EndedAt == nil {
+			return &breaks[i]
+		}
+	}
+	return nil
+}
+
+// SummarizeVisitTime works out worked and break minutes for a visit and checks the break rules.
+// Visits still in progress are measured up to now; an open break runs up to the same point.
+func SummarizeVisitTime(schedule *models.Schedule, breaks []models.VisitBreak, rules models.BreakRules, now time.Time) *models.VisitTimeSummary {
+	summary := &models.VisitTimeSummary{Violations: []string{}}
+	if schedule.StartTime == nil {
+		return summary
+	}
+	visitEnd := now
+	if schedule.EndTime != nil {
+		visitEnd = *schedule.EndTime
+	}
+	if visitEnd.Before(*schedule.StartTime) {
+		return summary
+	}
+	summary.ElapsedMinutes = minutesBetween(*schedule.StartTime, visitEnd)
+	summary.BreakCount = len(breaks)
+
+	// workFrom marks the end of the last break that counts toward the required-break rule
+	workFrom := *schedule.StartTime
+	longestStretch := 0
+	for i, b := range breaks {
+		end := visitEnd
+		if b.EndedAt != nil {
+			end = *b.EndedAt
+		} else {
+			summary.OnBreak = schedule.EndTime == nil
+		}
+		minutes := minutesBetween(b.StartedAt, end)
+
+		if rules.MinUnpaidMinutes > 0 && minutes < rules.MinUnpaidMinutes {
+			summary.PaidBreakMinutes += minutes
+		} else {
+			summary.UnpaidBreakMinutes += minutes
+		}
+		if rules.MaxBreakMinutes > 0 && minutes > rules.MaxBreakMinutes {
+			summary.Violations = append(summary.Violations,
+				fmt.Sprintf("break %d lasted %d minutes (maximum %d)", i+1, minutes, rules.MaxBreakMinutes))
+		}
+		if minutes >= rules.RequiredBreakDuration && (rules.MinUnpaidMinutes == 0 || minutes >= rules.MinUnpaidMinutes) {
+			if stretch := minutesBetween(workFrom, b.StartedAt); stretch > longestStretch {
+				longestStretch = stretch
+			}
+			workFrom = end
+		}
+	}
+	if stretch := minutesBetween(workFrom, visitEnd); stretch > longestStretch {
+		longestStretch = stretch
+	}
+
+	summary.WorkedMinutes = summary.ElapsedMinutes - summary.UnpaidBreakMinutes
+	if rules.RequiredAfterMinutes > 0 && longestStretch > rules.RequiredAfterMinutes {
+		summary.Violations = append(summary.Violations,
+			fmt.Sprintf("worked %d minutes without a break of at least %d minutes (required after %d)",
+				longestStretch, rules.RequiredBreakDuration, rules.RequiredAfterMinutes))
+	}
+	return summary
+}
+
+func minutesBetween(from, to time.Time) int {
+	if to.Before(from) {
+		return 0
+	}
+	return int(to.Sub(from).Minutes())
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCadence = errors.New("invalid cadence")
+
+// cadenceMacros are the cron shorthands accepted in place of the five fields
+var cadenceMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// Cadence is a parsed cron expression: minute, hour, day of month, month and day of week.
+// Fields take *, numbers, ranges (1-5), steps (*/15, 8-18/2) and comma lists; day of week
+// runs 0-6 from Sunday and also accepts 7 for Sunday. As in cron, when both day fields are
+// restricted (neither starts with *) a day matching either one qualifies. Times that do not
+// exist because the clocks go forward are skipped.
+type Cadence struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	anyDay   bool
+	anyWeek  bool
+}
+
+// ParseCadence parses a five-field cron expression or one of @hourly, @daily, @weekly and
+// @monthly
+func ParseCadence(expr string) (*Cadence, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cadenceMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields (minute hour day month weekday), got %d", ErrInvalidCadence, len(fields))
+	}
+
+	var c Cadence
+	var err error
+	if c.minutes, err = parseCadenceField(fields[0], "minute", 0, 59); err != nil {
+		return nil, err
+	}
+	if c.hours, err = parseCadenceField(fields[1], "hour", 0, 23); err != nil {
+		return nil, err
+	}
+	if c.days, err = parseCadenceField(fields[2], "day of month", 1, 31); err != nil {
+		return nil, err
+	}
+	if c.months, err = parseCadenceField(fields[3], "month", 1, 12); err != nil {
+		return nil, err
+	}
+	if c.weekdays, err = parseCadenceField(fields[4], "day of week", 0, 7); err != nil {
+		return nil, err
+	}
+	if c.weekdays&(1<<7) != 0 {
+		c.weekdays |= 1
+	}
+	c.anyDay = strings.HasPrefix(fields[2], "*")
+	c.anyWeek = strings.HasPrefix(fields[4], "*")
+	return &c, nil
+}
+
+// Next returns the first minute strictly after after, in loc, that the cadence matches. It
+// returns the zero time when nothing matches within five years, e.g. for 30 February.
+func (c *Cadence) Next(after time.Time, loc *time.Location) time.Time {
+	local := after.In(loc)
+	limit := local.Year() + 5
+	t := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute()+1, 0, 0, loc)
+	for t.Year() <= limit {
+		// Stepping by wall clock rather than duration keeps a repeated DST hour from
+		// matching twice
+		switch {
+		case !has(c.months, int(t.Month())):
+			t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc), time.Hour)
+		case !c.dayMatches(t):
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc), time.Hour)
+		case !has(c.hours, t.Hour()):
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc), time.Hour)
+		case !has(c.minutes, t.Minute()) || !t.After(after):
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc), time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// advance moves t on to next, or by step when next falls in a DST gap and time.Date
+// normalised it back to t or earlier
+func advance(t, next time.Time, step time.Duration) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(step)
+}
+
+func (c *Cadence) dayMatches(t time.Time) bool {
+	day := has(c.days, t.Day())
+	weekday := has(c.weekdays, int(t.Weekday()))
+	if c.anyDay || c.anyWeek {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+// parseCadenceField turns one cron field into a bit set of the values it allows
+func parseCadenceField(field, name string, min, max int) (uint64, error) {
+	invalid := func() (uint64, error) {
+		return 0, fmt.Errorf("%w: bad %s field %q (allowed %d-%d)", ErrInvalidCadence, name, field, min, max)
+	}
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return invalid()
+			}
+			rangePart, step = part[:i], n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			a, errA := strconv.Atoi(bounds[0])
+			b, errB := strconv.Atoi(bounds[1])
+			if errA != nil || errB != nil || a > b {
+				return invalid()
+			}
+			lo, hi = a, b
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return invalid()
+			}
+			lo, hi = n, n
+			if step > 1 {
+				// "5/15" means every 15 starting at 5
+				hi = max
+			}
+		}
+		if lo < min || hi > max {
+			return invalid()
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
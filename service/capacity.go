@@ -0,0 +1,403 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrAvailabilityInvalid = errors.New("invalid availability")
+
+// capacityBuckets are the time-of-day slots demand and capacity are compared in, as hours of
+// the day [start, end)
+var capacityBuckets = []struct {
+	name       string
+	start, end int
+}{
+	{models.CAPACITY_BUCKET_NIGHT, 0, 6},
+	{models.CAPACITY_BUCKET_MORNING, 6, 12},
+	{models.CAPACITY_BUCKET_AFTERNOON, 12, 18},
+	{models.CAPACITY_BUCKET_EVENING, 18, 24},
+}
+
+// BuildAvailability validates the requested windows. Windows on the same weekday may not
+// overlap, whatever their region, as a caregiver can only be in one place.
+func BuildAvailability(userID uint, req models.AvailabilityRequest) ([]models.CaregiverAvailability, error) {
+	type span struct{ start, end int }
+	byDay := map[int][]span{}
+	windows := make([]models.CaregiverAvailability, 0, len(req.Windows))
+	for _, w := range req.Windows {
+		start, err := parseClock(w.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(w.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		if start >= 24*60 || end <= start {
+			return nil, fmt.Errorf("%w: %s-%s must start before it ends, within one day", ErrAvailabilityInvalid, w.StartTime, w.EndTime)
+		}
+		for _, other := range byDay[w.Weekday] {
+			if start < other.end && other.start < end {
+				return nil, fmt.Errorf("%w: windows on %s overlap", ErrAvailabilityInvalid, time.Weekday(w.Weekday))
+			}
+		}
+		byDay[w.Weekday] = append(byDay[w.Weekday], span{start, end})
+		windows = append(windows, models.CaregiverAvailability{
+			UserID:    userID,
+			Weekday:   w.Weekday,
+			StartTime: formatClock(start),
+			EndTime:   formatClock(end),
+			Region:    strings.TrimSpace(w.Region),
+		})
+	}
+	return windows, nil
+}
+
+// ReplaceAvailability swaps a caregiver's availability for windows
+func ReplaceAvailability(db *gorm.DB, userID uint, windows []models.CaregiverAvailability) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.CaregiverAvailability{}).Error; err != nil {
+			return err
+		}
+		if len(windows) == 0 {
+			return nil
+		}
+		return tx.Create(&windows).Error
+	})
+}
+
+// ListAvailability returns a caregiver's windows by weekday and time
+func ListAvailability(db *gorm.DB, userID uint) ([]models.CaregiverAvailability, error) {
+	var windows []models.CaregiverAvailability
+	err := db.Where("user_id = ?", userID).Order("weekday ASC, start_time ASC").Find(&windows).Error
+	return windows, err
+}
+
+// capacitySlot is a region's weekday and time-of-day bucket in the weekly pattern
+type capacitySlot struct {
+	region  string
+	weekday time.Weekday
+	bucket  int
+}
+
+// CapacityForecastReport forecasts the caregiver hours needed per region, day and time of day
+// for the weeks ahead and compares them with caregiver availability. Demand in each slot is
+// the larger of the hours already scheduled plus the recurring visits of active series not
+// generated yet, and the baseline, the average of the same slot over the lookback weeks, so
+// regular work not booked yet is still counted.
+func CapacityForecastReport(db *gorm.DB, opts models.CapacityForecastOptions) (*models.CapacityForecast, error) {
+	loc := opts.Location
+	regions, err := clientRegions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Baseline: average weekly hours per slot over the lookback weeks
+	historyStart := opts.HistoryEnd.AddDate(0, 0, -7*opts.LookbackWeeks)
+	history, err := capacityVisits(db, historyStart, opts.HistoryEnd)
+	if err != nil {
+		return nil, err
+	}
+	baseline := map[capacitySlot]float64{}
+	for i := range history {
+		s := &history[i]
+		region, ok := visitRegion(s, regions)
+		if !ok {
+			// Visits for clients no longer served do not predict future work
+			continue
+		}
+		start, end := capacityVisitSpan(s)
+		if end.After(opts.HistoryEnd) {
+			end = opts.HistoryEnd
+		}
+		spreadOverBuckets(start, end, loc, func(day time.Time, bucket int, minutes float64) {
+			if !day.Before(historyStart) {
+				baseline[capacitySlot{region, day.Weekday(), bucket}] += minutes / 60 / float64(opts.LookbackWeeks)
+			}
+		})
+	}
+
+	// Scheduled: hours already booked on each forecast day
+	forecastEnd := opts.From.AddDate(0, 0, 7*opts.Weeks)
+	booked, err := capacityVisits(db, opts.From, forecastEnd)
+	if err != nil {
+		return nil, err
+	}
+	type daySlot struct {
+		region string
+		date   string
+		bucket int
+	}
+	scheduled := map[daySlot]float64{}
+	for i := range booked {
+		s := &booked[i]
+		region, ok := visitRegion(s, regions)
+		if !ok {
+			region = models.CAPACITY_REGION_UNASSIGNED
+		}
+		start, end := capacityVisitSpan(s)
+		if end.After(forecastEnd) {
+			end = forecastEnd
+		}
+		spreadOverBuckets(start, end, loc, func(day time.Time, bucket int, minutes float64) {
+			scheduled[daySlot{region, day.Format("2006-01-02"), bucket}] += minutes / 60
+		})
+	}
+
+	// Recurring: visits the active series will produce on days they have not been generated for
+	var series []models.VisitSeries
+	if err := db.Where("active = ?", true).Find(&series).Error; err != nil {
+		return nil, err
+	}
+	seriesIDs := make([]uint, 0, len(series))
+	for _, vs := range series {
+		seriesIDs = append(seriesIDs, vs.ID)
+	}
+	generated, err := seriesVisitDays(db, seriesIDs, opts.From, forecastEnd, loc)
+	if err != nil {
+		return nil, err
+	}
+	recurring := map[daySlot]float64{}
+	for i := range series {
+		vs := &series[i]
+		region, ok := regions[vs.ClientID]
+		if !ok {
+			continue
+		}
+		for _, at := range SeriesOccurrences(vs, opts.From, forecastEnd, loc) {
+			if generated[seriesDay{vs.ID, at.Format("2006-01-02")}] {
+				continue
+			}
+			end := at.Add(time.Duration(vs.DurationMinutes) * time.Minute)
+			if end.After(forecastEnd) {
+				end = forecastEnd
+			}
+			spreadOverBuckets(at, end, loc, func(day time.Time, bucket int, minutes float64) {
+				recurring[daySlot{region, day.Format("2006-01-02"), bucket}] += minutes / 60
+			})
+		}
+	}
+
+	// Capacity: the weekly availability pattern
+	var windows []models.CaregiverAvailability
+	err = db.Joins("JOIN users ON users.id = caregiver_availabilities.user_id AND users.deleted_at IS NULL").
+		Find(&windows).Error
+	if err != nil {
+		return nil, err
+	}
+	capacity := map[capacitySlot]float64{}
+	caregivers := map[string]map[uint]bool{}
+	for _, w := range windows {
+		region := w.Region
+		if region == "" {
+			region = models.CAPACITY_REGION_UNASSIGNED
+		}
+		start, _ := parseClock(w.StartTime)
+		end, _ := parseClock(w.EndTime)
+		for b, bucket := range capacityBuckets {
+			from, to := max(start, bucket.start*60), min(end, bucket.end*60)
+			if to > from {
+				capacity[capacitySlot{region, time.Weekday(w.Weekday), b}] += float64(to-from) / 60
+			}
+		}
+		if caregivers[region] == nil {
+			caregivers[region] = map[uint]bool{}
+		}
+		caregivers[region][w.UserID] = true
+	}
+
+	regionSet := map[string]bool{}
+	for slot := range baseline {
+		regionSet[slot.region] = true
+	}
+	for slot := range capacity {
+		regionSet[slot.region] = true
+	}
+	for slot := range scheduled {
+		regionSet[slot.region] = true
+	}
+	for slot := range recurring {
+		regionSet[slot.region] = true
+	}
+	var regionNames []string
+	for region := range regionSet {
+		if opts.Region == "" || strings.EqualFold(region, opts.Region) {
+			regionNames = append(regionNames, region)
+		}
+	}
+	sort.Strings(regionNames)
+
+	forecast := &models.CapacityForecast{
+		From:          opts.From.Format("2006-01-02"),
+		LookbackWeeks: opts.LookbackWeeks,
+		Weeks:         make([]models.CapacityWeek, 0, opts.Weeks),
+		Shortfalls:    []models.CapacityShortfall{},
+	}
+	for w := 0; w < opts.Weeks; w++ {
+		weekStart := opts.From.AddDate(0, 0, 7*w)
+		week := models.CapacityWeek{WeekStart: weekStart.Format("2006-01-02"), Regions: make([]models.CapacityRegionWeek, 0, len(regionNames))}
+		for _, region := range regionNames {
+			row := models.CapacityRegionWeek{Region: region, Caregivers: len(caregivers[region])}
+			for d := 0; d < 7; d++ {
+				day := weekStart.AddDate(0, 0, d)
+				date := day.Format("2006-01-02")
+				for b := range capacityBuckets {
+					slot := capacitySlot{region, day.Weekday(), b}
+					booked := scheduled[daySlot{region, date, b}]
+					planned := recurring[daySlot{region, date, b}]
+					demand := booked + planned
+					if baseline[slot] > demand {
+						demand = baseline[slot]
+					}
+					row.ScheduledHours += booked
+					row.RecurringHours += planned
+					row.BaselineHours += baseline[slot]
+					row.DemandHours += demand
+					row.CapacityHours += capacity[slot]
+
+					short := roundTenth(demand - capacity[slot])
+					if short <= 0 {
+						continue
+					}
+					row.ShortfallHours += short
+					forecast.Shortfalls = append(forecast.Shortfalls, models.CapacityShortfall{
+						Date:           date,
+						Weekday:        day.Weekday().String(),
+						Bucket:         capacityBuckets[b].name,
+						Region:         region,
+						ScheduledHours: roundTenth(booked),
+						RecurringHours: roundTenth(planned),
+						BaselineHours:  roundTenth(baseline[slot]),
+						DemandHours:    roundTenth(demand),
+						CapacityHours:  roundTenth(capacity[slot]),
+						ShortfallHours: short,
+					})
+				}
+			}
+			row.ScheduledHours = roundTenth(row.ScheduledHours)
+			row.RecurringHours = roundTenth(row.RecurringHours)
+			row.BaselineHours = roundTenth(row.BaselineHours)
+			row.DemandHours = roundTenth(row.DemandHours)
+			row.CapacityHours = roundTenth(row.CapacityHours)
+			row.ShortfallHours = roundTenth(row.ShortfallHours)
+			week.DemandHours += row.DemandHours
+			week.CapacityHours += row.CapacityHours
+			week.ShortfallHours += row.ShortfallHours
+			week.Regions = append(week.Regions, row)
+		}
+		week.DemandHours = roundTenth(week.DemandHours)
+		week.CapacityHours = roundTenth(week.CapacityHours)
+		week.ShortfallHours = roundTenth(week.ShortfallHours)
+		forecast.Weeks = append(forecast.Weeks, week)
+	}
+	sort.SliceStable(forecast.Shortfalls, func(i, j int) bool {
+		a, b := forecast.Shortfalls[i], forecast.Shortfalls[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		return capacityBucketIndex(a.Bucket) < capacityBucketIndex(b.Bucket)
+	})
+	return forecast, nil
+}
+
+// clientRegions maps each active client to its region
+func clientRegions(db *gorm.DB) (map[uint]string, error) {
+	var clients []models.Client
+	if err := db.Select("id", "region").Where("deleted_at IS NULL").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	regions := make(map[uint]string, len(clients))
+	for _, client := range clients {
+		region := strings.TrimSpace(client.Region)
+		if region == "" {
+			region = models.CAPACITY_REGION_UNASSIGNED
+		}
+		regions[client.ID] = region
+	}
+	return regions, nil
+}
+
+// visitRegion is the region of the visit's client; visits without a client are unassigned.
+// It reports false when the client is no longer active.
+func visitRegion(s *models.Schedule, regions map[uint]string) (string, bool) {
+	if s.ClientID == nil {
+		return models.CAPACITY_REGION_UNASSIGNED, true
+	}
+	region, ok := regions[*s.ClientID]
+	return region, ok
+}
+
+// capacityVisits loads the visits, other than cancelled ones, with a shift time in [from, to)
+func capacityVisits(db *gorm.DB, from, to time.Time) ([]models.Schedule, error) {
+	var schedules []models.Schedule
+	err := db.Select("id", "client_id", "shift_time", "start_time", "end_time", "duration_minutes", "status").
+		Where("shift_time >= ? AND shift_time < ? AND deleted_at IS NULL AND status <> ?", from.UTC(), to.UTC(), models.SCHEDULE_STATUS_CANCELLED).
+		Find(&schedules).Error
+	return schedules, err
+}
+
+// capacityVisitSpan is the time a visit took, or for one not finished, its planned time
+func capacityVisitSpan(s *models.Schedule) (time.Time, time.Time) {
+	if s.StartTime != nil && s.EndTime != nil && s.EndTime.After(*s.StartTime) {
+		return *s.StartTime, *s.EndTime
+	}
+	return s.ShiftTime, s.ShiftTime.Add(visitDuration(s))
+}
+
+// spreadOverBuckets splits [start, end) at local midnights and bucket boundaries, calling add
+// with each piece's local day, bucket and length in minutes
+func spreadOverBuckets(start, end time.Time, loc *time.Location, add func(day time.Time, bucket int, minutes float64)) {
+	for start.Before(end) {
+		local := start.In(loc)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		bucket := 0
+		for b, slot := range capacityBuckets {
+			if local.Hour() >= slot.start && local.Hour() < slot.end {
+				bucket = b
+			}
+		}
+		boundary := time.Date(local.Year(), local.Month(), local.Day(), capacityBuckets[bucket].end, 0, 0, 0, loc)
+		if !boundary.After(start) {
+			boundary = start.Add(time.Hour)
+		}
+		if boundary.After(end) {
+			boundary = end
+		}
+		add(day, bucket, boundary.Sub(start).Minutes())
+		start = boundary
+	}
+}
+
+func capacityBucketIndex(name string) int {
+	for i, bucket := range capacityBuckets {
+		if bucket.name == name {
+			return i
+		}
+	}
+	return len(capacityBuckets)
+}
+
+// parseClock reads HH:MM as minutes after midnight, allowing 24:00
+func parseClock(value string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) == 2 && len(parts[1]) == 2 {
+		h, errH := strconv.Atoi(parts[0])
+		m, errM := strconv.Atoi(parts[1])
+		if errH == nil && errM == nil && h >= 0 && m >= 0 && m < 60 && (h < 24 || h == 24 && m == 0) {
+			return h*60 + m, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: time %q must be HH:MM", ErrAvailabilityInvalid, value)
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"caregiver-shift-tracker/database/dbtest"
+	"caregiver-shift-tracker/models"
+	"testing"
+	"time"
+)
+
+func TestCapacityForecastCountsRecurringSeries(t *testing.T) {
+	db := dbtest.Open(t)
+	loc, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caregiver := models.User{Email: "cg@example.com", Mobile: "5550100", FullName: "Casey Giver", Password: "x", RoleID: models.ROLE_CAREGIVER}
+	if err := db.Create(&caregiver).Error; err != nil {
+		t.Fatal(err)
+	}
+	client := models.Client{FullName: "Pat Client", Address: "1 Main St", Region: "north"}
+	if err := db.Create(&client).Error; err != nil {
+		t.Fatal(err)
+	}
+	// One hour of Monday morning availability against a 90 minute Monday visit
+	window := models.CaregiverAvailability{UserID: caregiver.ID, Weekday: int(time.Monday), StartTime: "09:00", EndTime: "10:00", Region: "north"}
+	if err := db.Create(&window).Error; err != nil {
+		t.Fatal(err)
+	}
+	var series models.VisitSeries
+	err = BuildVisitSeries(&series, models.VisitSeriesRequest{UserID: &caregiver.ID, DaysOfWeek: []string{"mon"},
+		StartTime: "09:00", DurationMinutes: 90, StartDate: "2025-03-01"}, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series.ClientID, series.Active = client.ID, true
+	if err := SaveVisitSeries(db, &series); err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Date(2025, 3, 10, 0, 0, 0, 0, loc)
+	opts := models.CapacityForecastOptions{From: from, Weeks: 3, LookbackWeeks: 4, HistoryEnd: from, Location: loc}
+	check := func(stage string, wantScheduled, wantRecurring []float64) {
+		t.Helper()
+		forecast, err := CapacityForecastReport(db, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(forecast.Shortfalls) != 3 {
+			t.Fatalf("%s: %d shortfalls, want one each Monday: %+v", stage, len(forecast.Shortfalls), forecast.Shortfalls)
+		}
+		for w, shortfall := range forecast.Shortfalls {
+			date := from.AddDate(0, 0, 7*w).Format("2006-01-02")
+			if shortfall.Date != date || shortfall.Bucket != "morning" || shortfall.Region != "north" ||
+				shortfall.DemandHours != 1.5 || shortfall.CapacityHours != 1 || shortfall.ShortfallHours != 0.5 {
+				t.Fatalf("%s: week %d shortfall %+v, want 0.5h on the morning of %s", stage, w, shortfall, date)
+			}
+			if shortfall.ScheduledHours != wantScheduled[w] || shortfall.RecurringHours != wantRecurring[w] {
+				t.Fatalf("%s: week %d scheduled %.1fh and recurring %.1fh, want %.1fh and %.1fh", stage, w,
+					shortfall.ScheduledHours, shortfall.RecurringHours, wantScheduled[w], wantRecurring[w])
+			}
+		}
+	}
+
+	// Nothing is scheduled yet, so all of the demand comes from the series
+	check("before generating", []float64{0, 0, 0}, []float64{1.5, 1.5, 1.5})
+
+	// Generated visits replace their occurrences rather than adding to them
+	created, err := GenerateSeriesVisits(db, &series, from, from.AddDate(0, 0, 7), loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || !created[0].ShiftTime.Equal(time.Date(2025, 3, 10, 9, 0, 0, 0, loc)) {
+		t.Fatalf("generated %+v, want one visit at 09:00 on 2025-03-10", created)
+	}
+	check("after generating the first week", []float64{1.5, 0, 0}, []float64{0, 1.5, 1.5})
+
+	// Generating again skips the day that already has a visit, even after it was moved
+	moved := time.Date(2025, 3, 10, 14, 0, 0, 0, loc)
+	if err := db.Model(&created[0]).Update("shift_time", moved.UTC()).Error; err != nil {
+		t.Fatal(err)
+	}
+	again, err := GenerateSeriesVisits(db, &series, from, from.AddDate(0, 0, 7), loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 0 {
+		t.Fatalf("generating again created %d visits, want none", len(again))
+	}
+}
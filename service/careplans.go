@@ -0,0 +1,265 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("task template not found")
+	ErrTemplateInUse    = errors.New("task template is used by a care plan")
+)
+
+// CreateTaskTemplate adds a reusable task to the template library
+func CreateTaskTemplate(db *gorm.DB, template *models.TaskTemplate) error {
+	return db.Omit("ObservationFields").Create(template).Error
+}
+
+// ListTaskTemplates returns the template library, optionally narrowed to one category
+func ListTaskTemplates(db *gorm.DB, category string) ([]models.TaskTemplate, error) {
+	query := db.Preload("ObservationFields", orderObservationFields).Order("category ASC, description ASC")
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	var templates []models.TaskTemplate
+	err := query.Find(&templates).Error
+	return templates, err
+}
+
+// GetTaskTemplateByID retrieves a single template
+func GetTaskTemplateByID(db *gorm.DB, templateID uint) (*models.TaskTemplate, error) {
+	var template models.TaskTemplate
+	err := db.Preload("ObservationFields", orderObservationFields).First(&template, "id = ?", templateID).Error
+	return &template, err
+}
+
+// UpdateTaskTemplate saves changes to a template. Tasks already created from it keep their wording.
+func UpdateTaskTemplate(db *gorm.DB, template *models.TaskTemplate) error {
+	return db.Omit("ObservationFields").Save(template).Error
+}
+
+// DeleteTaskTemplate removes a template that no care plan refers to
+func DeleteTaskTemplate(db *gorm.DB, templateID uint) error {
+	var count int64
+	if err := db.Model(&models.CarePlanItem{}).Where("task_template_id = ?", templateID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTemplateInUse
+	}
+	return db.Delete(&models.TaskTemplate{}, "id = ?", templateID).Error
+}
+
+// ListCarePlans returns a client's care plans, active first
+func ListCarePlans(db *gorm.DB, clientID uint) ([]models.CarePlan, error) {
+	var plans []models.CarePlan
+	err := preloadCarePlanItems(db).
+		Where("client_id = ?", clientID).
+		Order("active DESC, updated_at DESC").
+		Find(&plans).Error
+	return plans, err
+}
+
+// GetCarePlanByID retrieves a care plan with its items and templates
+func GetCarePlanByID(db *gorm.DB, planID uint) (*models.CarePlan, error) {
+	var plan models.CarePlan
+	err := preloadCarePlanItems(db).First(&plan, "id = ?", planID).Error
+	return &plan, err
+}
+
+// GetActiveCarePlan returns the client's active care plan, or nil when there is none
+func GetActiveCarePlan(db *gorm.DB, clientID uint) (*models.CarePlan, error) {
+	var plan models.CarePlan
+	err := preloadCarePlanItems(db).Where("client_id = ? AND active = ?", clientID, true).First(&plan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// CreateCarePlan saves a new care plan for a client. Making it active deactivates the client's other plans.
+func CreateCarePlan(db *gorm.DB, clientID uint, req models.CarePlanRequest, createdBy uint) (*models.CarePlan, error) {
+	plan := &models.CarePlan{ClientID: clientID, Name: req.Name, Active: req.Active, CreatedBy: createdBy}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		items, err := buildCarePlanItems(tx, req.Items)
+		if err != nil {
+			return err
+		}
+		if plan.Active {
+			if err := deactivateCarePlans(tx, clientID); err != nil {
+				return err
+			}
+		}
+		plan.Items = items
+		return tx.Create(plan).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetCarePlanByID(db, plan.ID)
+}
+
+// UpdateCarePlan renames the plan and replaces its items
+func UpdateCarePlan(db *gorm.DB, plan *models.CarePlan, req models.CarePlanRequest) (*models.CarePlan, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		items, err := buildCarePlanItems(tx, req.Items)
+		if err != nil {
+			return err
+		}
+		if req.Active && !plan.Active {
+			if err := deactivateCarePlans(tx, plan.ClientID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("care_plan_id = ?", plan.ID).Delete(&models.CarePlanItem{}).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].CarePlanID = plan.ID
+		}
+		if len(items) > 0 {
+			if err := tx.Create(&items).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.CarePlan{}).Where("id = ?", plan.ID).
+			Updates(map[string]interface{}{"name": req.Name, "active": req.Active}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetCarePlanByID(db, plan.ID)
+}
+
+// ActivateCarePlan makes the plan the client's only active plan
+func ActivateCarePlan(db *gorm.DB, plan *models.CarePlan) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := deactivateCarePlans(tx, plan.ClientID); err != nil {
+			return err
+		}
+		return tx.Model(&models.CarePlan{}).Where("id = ?", plan.ID).Update("active", true).Error
+	})
+}
+
+// CarePlanTasks instantiates the plan's items that apply on the given weekday, skipping
+// templates listed in exclude (already on the visit)
+func CarePlanTasks(plan *models.CarePlan, weekday time.Weekday, exclude map[uint]bool) []models.Task {
+	var tasks []models.Task
+	for _, item := range plan.Items {
+		if exclude[item.TaskTemplateID] || !appliesOnDay(item.DaysOfWeek, weekday) {
+			continue
+		}
+		templateID := item.TaskTemplateID
+		tasks = append(tasks, models.Task{
+			Description:    item.TaskTemplate.Description,
+			Status:         models.TASK_STATUS_NOT_COMPLETED,
+			TaskTemplateID: &templateID,
+			Position:       item.SortOrder,
+		})
+	}
+	return tasks
+}
+
+// ApplyCarePlan adds tasks from the client's active care plan to a schedule. The weekday is
+// taken from the shift time in loc. Templates already on the schedule are not added twice,
+// so coordinators can re-apply a plan after editing it.
+func ApplyCarePlan(db *gorm.DB, schedule *models.Schedule, loc *time.Location) ([]models.Task, error) {
+	if schedule.ClientID == nil {
+		return nil, nil
+	}
+	plan, err := GetActiveCarePlan(db, *schedule.ClientID)
+	if err != nil || plan == nil {
+		return nil, err
+	}
+
+	var existing []models.Task
+	if err := db.Where("schedule_id = ? AND task_template_id IS NOT NULL", schedule.ID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	exclude := make(map[uint]bool, len(existing))
+	for _, task := range existing {
+		exclude[*task.TaskTemplateID] = true
+	}
+
+	tasks := CarePlanTasks(plan, schedule.ShiftTime.In(loc).Weekday(), exclude)
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+	for i := range tasks {
+		tasks[i].ScheduleID = schedule.ID
+	}
+	if err := db.Create(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func orderObservationFields(db *gorm.DB) *gorm.DB {
+	return db.Order("sort_order ASC, id ASC")
+}
+
+func preloadCarePlanItems(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort_order ASC, id ASC")
+	}).Preload("Items.TaskTemplate")
+}
+
+func deactivateCarePlans(db *gorm.DB, clientID uint) error {
+	return db.Model(&models.CarePlan{}).Where("client_id = ? AND active = ?", clientID, true).Update("active", false).Error
+}
+
+func buildCarePlanItems(db *gorm.DB, inputs []models.CarePlanItemInput) ([]models.CarePlanItem, error) {
+	items := make([]models.CarePlanItem, 0, len(inputs))
+	for _, input := range inputs {
+		var count int64
+		if err := db.Model(&models.TaskTemplate{}).Where("id = ?", input.TaskTemplateID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrTemplateNotFound
+		}
+		items = append(items, models.CarePlanItem{
+			TaskTemplateID: input.TaskTemplateID,
+			DaysOfWeek:     normalizeDaysOfWeek(input.DaysOfWeek),
+			SortOrder:      input.SortOrder,
+		})
+	}
+	return items, nil
+}
+
+// normalizeDaysOfWeek stores day codes in week order without duplicates
+func normalizeDaysOfWeek(days []string) string {
+	selected := make(map[string]bool, len(days))
+	for _, day := range days {
+		selected[strings.ToLower(day)] = true
+	}
+	var ordered []string
+	for _, day := range models.DaysOfWeek {
+		if selected[day] {
+			ordered = append(ordered, day)
+		}
+	}
+	if len(ordered) == len(models.DaysOfWeek) {
+		return ""
+	}
+	return strings.Join(ordered, ",")
+}
+
+func appliesOnDay(days string, weekday time.Weekday) bool {
+	if days == "" {
+		return true
+	}
+	for _, day := range strings.Split(days, ",") {
+		if day == models.DaysOfWeek[weekday] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrChecklistTask         = errors.New("task does not belong to this schedule")
+	ErrChecklistPrerequisite = errors.New("prerequisite must be a different task on the same schedule")
+	ErrChecklistCycle        = errors.New("task prerequisites form a cycle")
+	ErrPrerequisitesPending  = errors.New("complete this task's prerequisites first")
+)
+
+// orderTasks is the Preload scope that returns a visit's tasks in checklist order
+func orderTasks(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC, id ASC")
+}
+
+// TaskWindow returns the time window around a task's target time, or nils when it has none
+func TaskWindow(task *models.Task) (*time.Time, *time.Time) {
+	if task.TargetTime == nil {
+		return nil, nil
+	}
+	window := time.Duration(task.WindowMinutes) * time.Minute
+	start, end := task.TargetTime.Add(-window), task.TargetTime.Add(window)
+	return &start, &end
+}
+
+// CompletionTiming classifies a completion against the task's window. Untimed tasks get "".
+func CompletionTiming(task *models.Task, completedAt time.Time) string {
+	start, end := TaskWindow(task)
+	switch {
+	case start == nil:
+		return ""
+	case completedAt.Before(*start):
+		return models.TASK_TIMING_EARLY
+	case completedAt.After(*end):
+		return models.TASK_TIMING_LATE
+	default:
+		return models.TASK_TIMING_ON_TIME
+	}
+}
+
+// UnmetPrerequisites lists the task's prerequisites among siblings that are not yet completed
+func UnmetPrerequisites(task *models.Task, siblings []models.Task) []models.UnresolvedTask {
+	byID := make(map[uint]models.Task, len(siblings))
+	for _, sibling := range siblings {
+		byID[sibling.ID] = sibling
+	}
+	unmet := []models.UnresolvedTask{}
+	for _, id := range task.PrerequisiteIDs {
+		prerequisite, ok := byID[id]
+		if !ok || prerequisite.Status == models.TASK_STATUS_COMPLETED {
+			continue
+		}
+		unmet = append(unmet, models.UnresolvedTask{ID: prerequisite.ID, Description: prerequisite.Description, Kind: prerequisite.Kind})
+	}
+	return unmet
+}
+
+// CheckPrerequisites loads the task's visit and returns its prerequisites that are still open
+func CheckPrerequisites(db *gorm.DB, task *models.Task) ([]models.UnresolvedTask, error) {
+	if len(task.PrerequisiteIDs) == 0 {
+		return nil, nil
+	}
+	var siblings []models.Task
+	if err := db.Where("schedule_id = ? AND id IN ?", task.ScheduleID, task.PrerequisiteIDs).Find(&siblings).Error; err != nil {
+		return nil, err
+	}
+	return UnmetPrerequisites(task, siblings), nil
+}
+
+// BuildChecklist turns a visit's tasks into its ordered checklist as of now
+func BuildChecklist(tasks []models.Task, now time.Time) []models.ChecklistItem {
+	items := make([]models.ChecklistItem, 0, len(tasks))
+	for i := range tasks {
+		task := &tasks[i]
+		item := models.ChecklistItem{Task: *task, BlockedBy: []uint{}}
+		item.WindowStart, item.WindowEnd = TaskWindow(task)
+		if task.Status != models.TASK_STATUS_COMPLETED {
+			for _, unmet := range UnmetPrerequisites(task, tasks) {
+				item.BlockedBy = append(item.BlockedBy, unmet.ID)
+			}
+			item.Overdue = task.Reason == nil && item.WindowEnd != nil && now.After(*item.WindowEnd)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// GetScheduleTasks returns a visit's tasks in checklist order
+func GetScheduleTasks(db *gorm.DB, scheduleID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	err := orderTasks(db).Where("schedule_id = ?", scheduleID).Find(&tasks).Error
+	return tasks, err
+}
+
+// SetChecklist updates the order, time windows and prerequisites of a visit's tasks. Tasks not
+// listed keep their current settings; the resulting prerequisite graph must be acyclic.
+func SetChecklist(db *gorm.DB, scheduleID uint, items []models.ChecklistItemInput) error {
+	tasks, err := GetScheduleTasks(db, scheduleID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint]*models.Task, len(tasks))
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+	}
+
+	for _, item := range items {
+		task, ok := byID[item.TaskID]
+		if !ok {
+			return ErrChecklistTask
+		}
+		for _, id := range item.PrerequisiteIDs {
+			if _, ok := byID[id]; !ok || id == item.TaskID {
+				return ErrChecklistPrerequisite
+			}
+		}
+		task.Position = item.Position
+		task.TargetTime = item.TargetTime
+		if item.WindowMinutes != nil {
+			task.WindowMinutes = *item.WindowMinutes
+		}
+		task.PrerequisiteIDs = dedupeUints(item.PrerequisiteIDs)
+	}
+	if hasPrerequisiteCycle(byID) {
+		return ErrChecklistCycle
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			task := byID[item.TaskID]
+			err := tx.Model(task).Select("position", "target_time", "window_minutes", "prerequisite_ids").
+				Updates(models.Task{
+					Position:        task.Position,
+					TargetTime:      task.TargetTime,
+					WindowMinutes:   task.WindowMinutes,
+					PrerequisiteIDs: task.PrerequisiteIDs,
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// hasPrerequisiteCycle runs a depth-first search over the prerequisite edges
+func hasPrerequisiteCycle(tasks map[uint]*models.Task) bool {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[uint]int, len(tasks))
+	var visit func(id uint) bool
+	visit = func(id uint) bool {
+		switch state[id] {
+		case visiting:
+			return true
+		case done:
+			return false
+		}
+		state[id] = visiting
+		if task, ok := tasks[id]; ok {
+			for _, next := range task.PrerequisiteIDs {
+				if visit(next) {
+					return true
+				}
+			}
+		}
+		state[id] = done
+		return false
+	}
+	for id := range tasks {
+		if visit(id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+
+	"gorm.io/gorm"
+)
+
+// CreateClient adds a new client to the database
+func CreateClient(db *gorm.DB, client *models.Client) error {
+	return db.Create(client).Error
+}
+
+// GetClientByID retrieves a single client
+func GetClientByID(db *gorm.DB, clientID uint) (*models.Client, error) {
+	var client models.Client
+	err := db.First(&client, "id = ?", clientID).Error
+	return &client, err
+}
+
+// ListClients returns all clients ordered by name
+func ListClients(db *gorm.DB) ([]models.Client, error) {
+	var clients []models.Client
+	err := db.Order("full_name ASC").Find(&clients).Error
+	return clients, err
+}
+
+// UpdateClient saves changes to an existing client
+func UpdateClient(db *gorm.DB, client *models.Client) error {
+	return db.Save(client).Error
+}
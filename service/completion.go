@@ -0,0 +1,77 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTasksUnresolved    = errors.New("every task must be completed or given a reason before ending the visit")
+	ErrOverrideInvalid    = errors.New("override token is invalid, expired or already used")
+	ErrOverrideVisitEnded = errors.New("visit has already ended")
+)
+
+// UnresolvedTasks lists tasks that are neither completed nor explained with a not-completed reason
+func UnresolvedTasks(tasks []models.Task) []models.UnresolvedTask {
+	unresolved := []models.UnresolvedTask{}
+	for _, task := range tasks {
+		if task.Status == models.TASK_STATUS_COMPLETED {
+			continue
+		}
+		if task.Reason != nil && strings.TrimSpace(*task.Reason) != "" {
+			continue
+		}
+		unresolved = append(unresolved, models.UnresolvedTask{ID: task.ID, Description: task.Description, Kind: task.Kind})
+	}
+	return unresolved
+}
+
+// IssueCompletionOverride creates a single-use override for a visit and returns the plain token
+func IssueCompletionOverride(db *gorm.DB, schedule *models.Schedule, issuedBy uint, reason string, ttl time.Duration) (string, *models.TaskCompletionOverride, error) {
+	if schedule.EndTime != nil {
+		return "", nil, ErrOverrideVisitEnded
+	}
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(random)
+	override := models.TaskCompletionOverride{
+		ScheduleID: schedule.ID,
+		TokenHash:  hashOverrideToken(token),
+		Reason:     reason,
+		IssuedBy:   issuedBy,
+		ExpiresAt:  time.Now().Add(ttl),
+	}
+	if err := db.Create(&override).Error; err != nil {
+		return "", nil, err
+	}
+	return token, &override, nil
+}
+
+// FindCompletionOverride returns the unused, unexpired override matching token for the visit
+func FindCompletionOverride(db *gorm.DB, scheduleID uint, token string, now time.Time) (*models.TaskCompletionOverride, error) {
+	var override models.TaskCompletionOverride
+	err := db.Where("schedule_id = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?",
+		scheduleID, hashOverrideToken(token), now).
+		First(&override).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOverrideInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+func hashOverrideToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCorrectionNotPending   = errors.New("correction has already been reviewed")
+	ErrCorrectionAlreadyOpen  = errors.New("a correction for this visit is already awaiting review")
+	ErrCorrectionInvalidTimes = errors.New("corrected start time must be before end time")
+)
+
+// CreateVisitCorrection stores a pending correction together with the visit's current values
+func CreateVisitCorrection(db *gorm.DB, schedule *models.Schedule, correction *models.VisitCorrection) error {
+	var pending int64
+	if err := db.Model(&models.VisitCorrection{}).
+		Where("schedule_id = ? AND status = ?", schedule.ID, models.CORRECTION_STATUS_PENDING).
+		Count(&pending).Error; err != nil {
+		return err
+	}
+	if pending > 0 {
+		return ErrCorrectionAlreadyOpen
+	}
+
+	if err := validateCorrectedTimes(schedule, correction); err != nil {
+		return err
+	}
+
+	correction.ScheduleID = schedule.ID
+	correction.Status = models.CORRECTION_STATUS_PENDING
+	captureOriginalVisitValues(schedule, correction)
+	return db.Create(correction).Error
+}
+
+// GetVisitCorrections lists corrections for a schedule, newest first
+func GetVisitCorrections(db *gorm.DB, scheduleID uint) ([]models.VisitCorrection, error) {
+	var corrections []models.VisitCorrection
+	err := db.Where("schedule_id = ?", scheduleID).Order("created_at DESC").Find(&corrections).Error
+	return corrections, err
+}
+
+// ListCorrectionsByStatus lists corrections across all schedules, oldest first so the review queue is FIFO
+func ListCorrectionsByStatus(db *gorm.DB, status string) ([]models.VisitCorrection, error) {
+	var corrections []models.VisitCorrection
+	query := db.Order("created_at ASC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&corrections).Error
+	return corrections, err
+}
+
+func GetVisitCorrectionByID(db *gorm.DB, correctionID uint) (*models.VisitCorrection, error) {
+	var correction models.VisitCorrection
+	err := db.First(&correction, "id = ?", correctionID).Error
+	return &correction, err
+}
+
+// ApproveVisitCorrection applies the corrected values to the schedule and marks the correction approved.
+// A correction that supplies an end time on a visit with a start time completes the visit.
+func ApproveVisitCorrection(db *gorm.DB, correctionID, reviewerID uint, reviewNote *string) (*models.VisitCorrection, error) {
+	var correction models.VisitCorrection
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&correction, "id = ?", correctionID).Error; err != nil {
+			return err
+		}
+		if correction.Status != models.CORRECTION_STATUS_PENDING {
+			return ErrCorrectionNotPending
+		}
+
+		var schedule models.Schedule
+		if err := tx.First(&schedule, "id = ?", correction.ScheduleID).Error; err != nil {
+			return err
+		}
+		if err := validateCorrectedTimes(&schedule, &correction); err != nil {
+			return err
+		}
+		if err := EnsureVisitUnlocked(tx, &schedule, correction.StartTime); err != nil {
+			return err
+		}
+
+		// Snapshot again in case the visit changed while the correction was pending
+		captureOriginalVisitValues(&schedule, &correction)
+
+		updates := map[string]interface{}{}
+		if correction.StartTime != nil {
+			updates["start_time"] = *correction.StartTime
+		}
+		if correction.EndTime != nil {
+			updates["end_time"] = *correction.EndTime
+		}
+		if correction.StartLat != nil && correction.StartLon != nil {
+			updates["start_lat"] = *correction.StartLat
+			updates["start_lon"] = *correction.StartLon
+		}
+		if correction.EndLat != nil && correction.EndLon != nil {
+			updates["end_lat"] = *correction.EndLat
+			updates["end_lon"] = *correction.EndLon
+		}
+		hasStart := correction.StartTime != nil || schedule.StartTime != nil
+		if correction.EndTime != nil && hasStart {
+			updates["status"] = models.SCHEDULE_STATUS_COMPLETED
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&models.Schedule{}).Where("id = ?", schedule.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		return finishCorrectionReview(tx, &correction, models.CORRECTION_STATUS_APPROVED, reviewerID, reviewNote)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &correction, nil
+}
+
+// RejectVisitCorrection marks a pending correction rejected without touching the schedule
+func RejectVisitCorrection(db *gorm.DB, correctionID, reviewerID uint, reviewNote *string) (*models.VisitCorrection, error) {
+	var correction models.VisitCorrection
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&correction, "id = ?", correctionID).Error; err != nil {
+			return err
+		}
+		if correction.Status != models.CORRECTION_STATUS_PENDING {
+			return ErrCorrectionNotPending
+		}
+		return finishCorrectionReview(tx, &correction, models.CORRECTION_STATUS_REJECTED, reviewerID, reviewNote)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &correction, nil
+}
+
+func finishCorrectionReview(tx *gorm.DB, correction *models.VisitCorrection, status string, reviewerID uint, reviewNote *string) error {
+	now := time.Now()
+	correction.Status = status
+	correction.ReviewedBy = &reviewerID
+	correction.ReviewedAt = &now
+	correction.ReviewNote = reviewNote
+	return tx.Save(correction).Error
+}
+
+// validateCorrectedTimes checks the visit's times as they would be after the correction
+func validateCorrectedTimes(schedule *models.Schedule, correction *models.VisitCorrection) error {
+	start := schedule.StartTime
+	if correction.StartTime != nil {
+		start = correction.StartTime
+	}
+	end := schedule.EndTime
+	if correction.EndTime != nil {
+		end = correction.EndTime
+	}
+	if start != nil && end != nil && !start.Before(*end) {
+		return ErrCorrectionInvalidTimes
+	}
+	return nil
+}
+
+func captureOriginalVisitValues(schedule *models.Schedule, correction *models.VisitCorrection) {
+	correction.OriginalStatus = schedule.Status
+	correction.OriginalStartTime = schedule.StartTime
+	correction.OriginalEndTime = schedule.EndTime
+	correction.OriginalStartLat = schedule.StartLat
+	correction.OriginalStartLon = schedule.StartLon
+	correction.OriginalEndLat = schedule.EndLat
+	correction.OriginalEndLon = schedule.EndLon
+}
@@ -0,0 +1,553 @@
+package service
+
+import (
+	"bytes"
+	"caregiver-shift-tracker/models"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEVVUnknownState    = errors.New("no EVV export schema configured for this state")
+	ErrEVVNoVisits        = errors.New("no completed visits are waiting to be exported for this period")
+	ErrEVVNoAggregatorURL = errors.New("EVV aggregator URL is not configured")
+)
+
+// defaultEVVSchemas are used when EVV_SCHEMA_FILE is not set. States with their own
+// layouts are configured by pointing EVV_SCHEMA_FILE at a JSON array of schemas.
+var defaultEVVSchemas = []models.EVVExportSchema{
+	{
+		State:  "DEFAULT",
+		Format: models.EVV_FORMAT_CSV,
+		Fields: []models.EVVSchemaField{
+			{Name: "VisitID", Element: models.EVV_ELEMENT_VISIT_ID, Required: true},
+			{Name: "ProviderID", Element: models.EVV_ELEMENT_PROVIDER_ID},
+			{Name: "ServiceCode", Element: models.EVV_ELEMENT_SERVICE_CODE, Required: true},
+			{Name: "MemberID", Element: models.EVV_ELEMENT_MEMBER_ID, Required: true},
+			{Name: "MemberName", Element: models.EVV_ELEMENT_MEMBER_NAME},
+			{Name: "VisitDate", Element: models.EVV_ELEMENT_VISIT_DATE, Required: true},
+			{Name: "ServiceLocation", Element: models.EVV_ELEMENT_LOCATION, Required: true},
+			{Name: "StartLatitude", Element: models.EVV_ELEMENT_START_LAT},
+			{Name: "StartLongitude", Element: models.EVV_ELEMENT_START_LON},
+			{Name: "EndLatitude", Element: models.EVV_ELEMENT_END_LAT},
+			{Name: "EndLongitude", Element: models.EVV_ELEMENT_END_LON},
+			{Name: "CaregiverID", Element: models.EVV_ELEMENT_CAREGIVER_ID, Required: true},
+			{Name: "CaregiverName", Element: models.EVV_ELEMENT_CAREGIVER_NAME},
+			{Name: "StartTime", Element: models.EVV_ELEMENT_START_TIME, Required: true},
+			{Name: "EndTime", Element: models.EVV_ELEMENT_END_TIME, Required: true},
+			{Name: "ReasonCode", Element: models.EVV_ELEMENT_CORRECTION_CODE},
+			{Name: "SignerName", Element: models.EVV_ELEMENT_SIGNER_NAME},
+			{Name: "SignerRelationship", Element: models.EVV_ELEMENT_SIGNER_RELATION},
+			{Name: "SignatureHash", Element: models.EVV_ELEMENT_SIGNATURE_HASH},
+			{Name: "SubmissionType", Element: models.EVV_ELEMENT_SUBMISSION_TYPE},
+		},
+	},
+	{
+		State:  "DEFAULT_JSON",
+		Format: models.EVV_FORMAT_JSON,
+		Fields: []models.EVVSchemaField{
+			{Name: "visit_id", Element: models.EVV_ELEMENT_VISIT_ID, Required: true},
+			{Name: "service_type", Element: models.EVV_ELEMENT_SERVICE_CODE, Required: true},
+			{Name: "member_id", Element: models.EVV_ELEMENT_MEMBER_ID, Required: true},
+			{Name: "date_of_service", Element: models.EVV_ELEMENT_VISIT_DATE, Required: true},
+			{Name: "location", Element: models.EVV_ELEMENT_LOCATION, Required: true},
+			{Name: "caregiver_id", Element: models.EVV_ELEMENT_CAREGIVER_ID, Required: true},
+			{Name: "start_time", Element: models.EVV_ELEMENT_START_TIME, Required: true},
+			{Name: "end_time", Element: models.EVV_ELEMENT_END_TIME, Required: true},
+			{Name: "reason_code", Element: models.EVV_ELEMENT_CORRECTION_CODE},
+			{Name: "client_signature_hash", Element: models.EVV_ELEMENT_SIGNATURE_HASH},
+			{Name: "attempt", Element: models.EVV_ELEMENT_SUBMISSION_COUNT},
+		},
+	},
+}
+
+var knownEVVElements = map[string]bool{
+	models.EVV_ELEMENT_SERVICE_CODE: true, models.EVV_ELEMENT_MEMBER_ID: true, models.EVV_ELEMENT_VISIT_DATE: true,
+	models.EVV_ELEMENT_LOCATION: true, models.EVV_ELEMENT_CAREGIVER_ID: true, models.EVV_ELEMENT_START_TIME: true,
+	models.EVV_ELEMENT_END_TIME: true, models.EVV_ELEMENT_VISIT_ID: true, models.EVV_ELEMENT_MEMBER_NAME: true,
+	models.EVV_ELEMENT_CAREGIVER_NAME: true, models.EVV_ELEMENT_START_LAT: true, models.EVV_ELEMENT_START_LON: true,
+	models.EVV_ELEMENT_END_LAT: true, models.EVV_ELEMENT_END_LON: true, models.EVV_ELEMENT_PROVIDER_ID: true,
+	models.EVV_ELEMENT_CORRECTION_CODE: true, models.EVV_ELEMENT_SUBMISSION_TYPE: true, models.EVV_ELEMENT_SUBMISSION_COUNT: true,
+	models.EVV_ELEMENT_SIGNATURE_HASH: true, models.EVV_ELEMENT_SIGNER_NAME: true, models.EVV_ELEMENT_SIGNER_RELATION: true,
+}
+
+// LoadEVVSchemas returns the configured export schemas keyed by state code
+func LoadEVVSchemas(path string) (map[string]models.EVVExportSchema, error) {
+	schemas := defaultEVVSchemas
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read EVV schema file: %w", err)
+		}
+		if err := json.Unmarshal(raw, &schemas); err != nil {
+			return nil, fmt.Errorf("failed to parse EVV schema file: %w", err)
+		}
+	}
+
+	byState := make(map[string]models.EVVExportSchema, len(schemas))
+	for _, schema := range schemas {
+		if err := validateEVVSchema(schema); err != nil {
+			return nil, err
+		}
+		byState[strings.ToUpper(schema.State)] = schema
+	}
+	return byState, nil
+}
+
+func validateEVVSchema(schema models.EVVExportSchema) error {
+	if schema.State == "" {
+		return errors.New("EVV schema is missing a state")
+	}
+	if schema.Format != models.EVV_FORMAT_CSV && schema.Format != models.EVV_FORMAT_JSON {
+		return fmt.Errorf("EVV schema %s: unsupported format %q", schema.State, schema.Format)
+	}
+	if schema.Timezone != "" {
+		if _, err := time.LoadLocation(schema.Timezone); err != nil {
+			return fmt.Errorf("EVV schema %s: invalid timezone %q", schema.State, schema.Timezone)
+		}
+	}
+	hasVisitID := false
+	for _, field := range schema.Fields {
+		if !knownEVVElements[field.Element] {
+			return fmt.Errorf("EVV schema %s: unknown element %q", schema.State, field.Element)
+		}
+		if field.Element == models.EVV_ELEMENT_VISIT_ID {
+			hasVisitID = true
+		}
+	}
+	if !hasVisitID {
+		return fmt.Errorf("EVV schema %s: a visit_id field is required to match aggregator responses", schema.State)
+	}
+	return nil
+}
+
+// evvVisit is a completed visit together with everything needed to render its EVV record
+type evvVisit struct {
+	schedule        models.Schedule
+	client          *models.Client
+	caregiver       *models.User
+	attempt         int
+	correctionCode  string
+	clientSignature *models.VisitSignature
+}
+
+// EVVSkippedVisit is a visit left out of an export because a required element is missing
+type EVVSkippedVisit struct {
+	ScheduleID uint   `json:"schedule_id"`
+	Reason     string `json:"reason"`
+}
+
+// CreateEVVExportBatch renders every completed visit in the period that has not yet been
+// accepted (new visits, rejected visits and visits corrected since their last submission)
+// into a single aggregator file and records a submission row per visit.
+func CreateEVVExportBatch(db *gorm.DB, schema models.EVVExportSchema, providerID string, from, to time.Time, createdBy uint) (*models.EVVExportBatch, []EVVSkippedVisit, error) {
+	visits, err := selectVisitsForEVVExport(db, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		records [][]string
+		skipped []EVVSkippedVisit
+		batched []evvVisit
+	)
+	for _, visit := range visits {
+		record, missing := renderEVVRecord(schema, providerID, visit)
+		if missing != "" {
+			skipped = append(skipped, EVVSkippedVisit{ScheduleID: visit.schedule.ID, Reason: "missing " + missing})
+			continue
+		}
+		records = append(records, record)
+		batched = append(batched, visit)
+	}
+	if len(records) == 0 {
+		return nil, skipped, ErrEVVNoVisits
+	}
+
+	content, err := encodeEVVFile(schema, records)
+	if err != nil {
+		return nil, skipped, err
+	}
+
+	batch := models.EVVExportBatch{
+		State:       strings.ToUpper(schema.State),
+		Format:      schema.Format,
+		PeriodStart: from.UTC(),
+		PeriodEnd:   to.UTC(),
+		RecordCount: len(records),
+		CreatedBy:   createdBy,
+		Content:     string(content),
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+		for _, visit := range batched {
+			submission := models.EVVSubmission{
+				BatchID:    batch.ID,
+				ScheduleID: visit.schedule.ID,
+				Attempt:    visit.attempt,
+				Status:     models.EVV_SUBMISSION_GENERATED,
+			}
+			if err := tx.Create(&submission).Error; err != nil {
+				return err
+			}
+			batch.Submissions = append(batch.Submissions, submission)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, skipped, err
+	}
+	return &batch, skipped, nil
+}
+
+// GetEVVExportBatch loads a batch with its submissions
+func GetEVVExportBatch(db *gorm.DB, batchID uint) (*models.EVVExportBatch, error) {
+	var batch models.EVVExportBatch
+	err := db.Preload("Submissions").First(&batch, "id = ?", batchID).Error
+	return &batch, err
+}
+
+// ListEVVExportBatches lists batches newest first
+func ListEVVExportBatches(db *gorm.DB) ([]models.EVVExportBatch, error) {
+	var batches []models.EVVExportBatch
+	err := db.Order("created_at DESC").Find(&batches).Error
+	return batches, err
+}
+
+// ListEVVSubmissions lists per-visit submission records, newest first
+func ListEVVSubmissions(db *gorm.DB, scheduleID uint, status string) ([]models.EVVSubmission, error) {
+	query := db.Order("id DESC")
+	if scheduleID != 0 {
+		query = query.Where("schedule_id = ?", scheduleID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var submissions []models.EVVSubmission
+	err := query.Find(&submissions).Error
+	return submissions, err
+}
+
+// SubmitEVVBatch posts a batch file to the aggregator. A 200 response carrying results is
+// applied immediately; a 202 leaves the visits as submitted until results are recorded.
+func SubmitEVVBatch(db *gorm.DB, batch *models.EVVExportBatch, schema models.EVVExportSchema, aggregatorURL string) error {
+	if aggregatorURL == "" {
+		return ErrEVVNoAggregatorURL
+	}
+
+	req, err := http.NewRequest(http.MethodPost, aggregatorURL, strings.NewReader(batch.Content))
+	if err != nil {
+		return err
+	}
+	if batch.Format == models.EVV_FORMAT_JSON {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "text/csv")
+	}
+	req.Header.Set("X-EVV-State", batch.State)
+	req.Header.Set("X-EVV-Batch-ID", strconv.FormatUint(uint64(batch.ID), 10))
+	req.Header.Set("X-EVV-Visit-ID-Field", evvVisitIDFieldName(schema))
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach EVV aggregator: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("EVV aggregator returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	now := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.EVVExportBatch{}).Where("id = ?", batch.ID).Update("submitted_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.EVVSubmission{}).
+			Where("batch_id = ? AND status = ?", batch.ID, models.EVV_SUBMISSION_GENERATED).
+			Update("status", models.EVV_SUBMISSION_SUBMITTED).Error
+	})
+	if err != nil {
+		return err
+	}
+	batch.SubmittedAt = &now
+
+	if resp.StatusCode == http.StatusOK && len(body) > 0 {
+		var result models.EVVAggregatorResponse
+		if err := json.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("failed to parse EVV aggregator response: %w", err)
+		}
+		return ApplyEVVResults(db, batch.ID, result.Results)
+	}
+	return nil
+}
+
+// ApplyEVVResults records accepted/rejected outcomes for the visits in a batch
+func ApplyEVVResults(db *gorm.DB, batchID uint, results []models.EVVAggregatorResult) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, result := range results {
+			updates := map[string]interface{}{
+				"status":       result.Status,
+				"responded_at": now,
+			}
+			if result.Status == models.EVV_SUBMISSION_REJECTED {
+				updates["rejection_reason"] = result.Reason
+			}
+			res := tx.Model(&models.EVVSubmission{}).
+				Where("batch_id = ? AND schedule_id = ?", batchID, result.VisitID).
+				Updates(updates)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return fmt.Errorf("visit %d is not part of batch %d", result.VisitID, batchID)
+			}
+		}
+		return nil
+	})
+}
+
+// selectVisitsForEVVExport returns completed visits in the period that still need to reach the aggregator
+func selectVisitsForEVVExport(db *gorm.DB, from, to time.Time) ([]evvVisit, error) {
+	var schedules []models.Schedule
+	err := db.Preload("Corrections", "status = ?", models.CORRECTION_STATUS_APPROVED).
+		Preload("Signatures", func(db *gorm.DB) *gorm.DB {
+			return omitSignatureImages(db).Where("signer_type = ?", models.SIGNER_TYPE_CLIENT)
+		}).
+		Where("status = ? AND shift_time BETWEEN ? AND ?", models.SCHEDULE_STATUS_COMPLETED, from.UTC(), to.UTC()).
+		Order("shift_time ASC").
+		Find(&schedules).Error
+	if err != nil || len(schedules) == 0 {
+		return nil, err
+	}
+
+	scheduleIDs := make([]uint, 0, len(schedules))
+	clientIDs := []uint{}
+	userIDs := []uint{}
+	for _, s := range schedules {
+		scheduleIDs = append(scheduleIDs, s.ID)
+		userIDs = append(userIDs, s.UserID)
+		if s.ClientID != nil {
+			clientIDs = append(clientIDs, *s.ClientID)
+		}
+	}
+
+	var submissions []models.EVVSubmission
+	if err := db.Where("schedule_id IN ?", scheduleIDs).Order("id ASC").Find(&submissions).Error; err != nil {
+		return nil, err
+	}
+	latest := map[uint]models.EVVSubmission{}
+	attempts := map[uint]int{}
+	for _, sub := range submissions {
+		latest[sub.ScheduleID] = sub
+		attempts[sub.ScheduleID]++
+	}
+
+	clients := map[uint]*models.Client{}
+	if len(clientIDs) > 0 {
+		var rows []models.Client
+		if err := db.Where("id IN ?", clientIDs).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			clients[rows[i].ID] = &rows[i]
+		}
+	}
+	users := map[uint]*models.User{}
+	var userRows []models.User
+	if err := db.Where("id IN ?", userIDs).Find(&userRows).Error; err != nil {
+		return nil, err
+	}
+	for i := range userRows {
+		users[userRows[i].ID] = &userRows[i]
+	}
+
+	var visits []evvVisit
+	for _, s := range schedules {
+		if last, ok := latest[s.ID]; ok {
+			if last.Status != models.EVV_SUBMISSION_REJECTED && !correctedSince(s.Corrections, last.CreatedAt) {
+				continue
+			}
+		}
+
+		visit := evvVisit{schedule: s, caregiver: users[s.UserID], attempt: attempts[s.ID] + 1}
+		if s.ClientID != nil {
+			visit.client = clients[*s.ClientID]
+		}
+		for _, c := range s.Corrections {
+			visit.correctionCode = c.ReasonCode
+		}
+		for i := range s.Signatures {
+			visit.clientSignature = &s.Signatures[i]
+		}
+		visits = append(visits, visit)
+	}
+	return visits, nil
+}
+
+// correctedSince reports whether any of the visit's approved corrections was reviewed after the
+// given submission. Other writes to the schedule row (breaks, travel, billing) do not change
+// the EVV record and so never cause a resubmission.
+func correctedSince(corrections []models.VisitCorrection, submittedAt time.Time) bool {
+	for _, c := range corrections {
+		if c.ReviewedAt != nil && c.ReviewedAt.After(submittedAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderEVVRecord returns the visit's values in schema order, or the name of the first missing required field
+func renderEVVRecord(schema models.EVVExportSchema, providerID string, visit evvVisit) ([]string, string) {
+	loc := time.UTC
+	if schema.Timezone != "" {
+		loc, _ = time.LoadLocation(schema.Timezone)
+	}
+
+	s := visit.schedule
+	record := make([]string, len(schema.Fields))
+	for i, field := range schema.Fields {
+		var value string
+		switch field.Element {
+		case models.EVV_ELEMENT_VISIT_ID:
+			value = strconv.FormatUint(uint64(s.ID), 10)
+		case models.EVV_ELEMENT_PROVIDER_ID:
+			value = providerID
+		case models.EVV_ELEMENT_SERVICE_CODE:
+			value = s.ServiceCode
+		case models.EVV_ELEMENT_MEMBER_ID:
+			if visit.client != nil {
+				value = visit.client.MedicaidID
+			}
+		case models.EVV_ELEMENT_MEMBER_NAME:
+			value = s.ClientName
+			if visit.client != nil {
+				value = visit.client.FullName
+			}
+		case models.EVV_ELEMENT_VISIT_DATE:
+			value = formatEVVTime(s.StartTime, loc, field.Layout, "2006-01-02")
+		case models.EVV_ELEMENT_LOCATION:
+			value = s.Location
+		case models.EVV_ELEMENT_CAREGIVER_ID:
+			value = strconv.FormatUint(uint64(s.UserID), 10)
+		case models.EVV_ELEMENT_CAREGIVER_NAME:
+			if visit.caregiver != nil {
+				value = visit.caregiver.FullName
+			}
+		case models.EVV_ELEMENT_START_TIME:
+			value = formatEVVTime(s.StartTime, loc, field.Layout, time.RFC3339)
+		case models.EVV_ELEMENT_END_TIME:
+			value = formatEVVTime(s.EndTime, loc, field.Layout, time.RFC3339)
+		case models.EVV_ELEMENT_START_LAT:
+			value = formatEVVCoordinate(s.StartLat)
+		case models.EVV_ELEMENT_START_LON:
+			value = formatEVVCoordinate(s.StartLon)
+		case models.EVV_ELEMENT_END_LAT:
+			value = formatEVVCoordinate(s.EndLat)
+		case models.EVV_ELEMENT_END_LON:
+			value = formatEVVCoordinate(s.EndLon)
+		case models.EVV_ELEMENT_CORRECTION_CODE:
+			value = visit.correctionCode
+		case models.EVV_ELEMENT_SUBMISSION_TYPE:
+			value = "NEW"
+			if visit.attempt > 1 {
+				value = "RESUBMISSION"
+			}
+		case models.EVV_ELEMENT_SUBMISSION_COUNT:
+			value = strconv.Itoa(visit.attempt)
+		case models.EVV_ELEMENT_SIGNATURE_HASH:
+			if visit.clientSignature != nil {
+				value = visit.clientSignature.Hash
+			}
+		case models.EVV_ELEMENT_SIGNER_NAME:
+			if visit.clientSignature != nil {
+				value = visit.clientSignature.SignerName
+			}
+		case models.EVV_ELEMENT_SIGNER_RELATION:
+			if visit.clientSignature != nil {
+				value = visit.clientSignature.Relationship
+			}
+		}
+		if field.Required && value == "" {
+			return nil, field.Name
+		}
+		record[i] = value
+	}
+	return record, ""
+}
+
+func encodeEVVFile(schema models.EVVExportSchema, records [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	if schema.Format == models.EVV_FORMAT_JSON {
+		visits := make([]map[string]string, 0, len(records))
+		for _, record := range records {
+			row := make(map[string]string, len(record))
+			for i, field := range schema.Fields {
+				row[field.Name] = record[i]
+			}
+			visits = append(visits, row)
+		}
+		err := json.NewEncoder(&buf).Encode(map[string]interface{}{
+			"state":  strings.ToUpper(schema.State),
+			"visits": visits,
+		})
+		return buf.Bytes(), err
+	}
+
+	w := csv.NewWriter(&buf)
+	header := make([]string, len(schema.Fields))
+	for i, field := range schema.Fields {
+		header[i] = field.Name
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func evvVisitIDFieldName(schema models.EVVExportSchema) string {
+	for _, field := range schema.Fields {
+		if field.Element == models.EVV_ELEMENT_VISIT_ID {
+			return field.Name
+		}
+	}
+	return ""
+}
+
+func formatEVVTime(t *time.Time, loc *time.Location, layout, fallback string) string {
+	if t == nil {
+		return ""
+	}
+	if layout == "" {
+		layout = fallback
+	}
+	return t.In(loc).Format(layout)
+}
+
+func formatEVVCoordinate(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', 6, 64)
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"caregiver-shift-tracker/database/dbtest"
+	"caregiver-shift-tracker/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// acceptedVisit stores a completed visit whose only EVV submission was accepted an hour ago
+func acceptedVisit(t *testing.T, db *gorm.DB, shift time.Time) (*models.Schedule, *models.EVVSubmission) {
+	t.Helper()
+	caregiver := models.User{Email: "cg@example.com", Mobile: "5550100", FullName: "Casey Giver", Password: "x", RoleID: models.ROLE_CAREGIVER}
+	if err := db.Create(&caregiver).Error; err != nil {
+		t.Fatal(err)
+	}
+	start, end := shift, shift.Add(time.Hour)
+	schedule := models.Schedule{UserID: caregiver.ID, ClientName: "Pat Client", Location: "1 Main St", ShiftTime: shift,
+		Status: models.SCHEDULE_STATUS_COMPLETED, StartTime: &start, EndTime: &end}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+	submission := models.EVVSubmission{BatchID: 1, ScheduleID: schedule.ID, Attempt: 1, Status: models.EVV_SUBMISSION_ACCEPTED,
+		CreatedAt: time.Now().UTC().Add(-time.Hour)}
+	if err := db.Create(&submission).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &schedule, &submission
+}
+
+func TestSelectVisitsForEVVExportIgnoresNonCorrectionUpdates(t *testing.T) {
+	db := dbtest.Open(t)
+	shift := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	schedule, _ := acceptedVisit(t, db, shift)
+
+	// Breaks, travel and billing all touch the schedule row after it has been accepted
+	if err := db.Model(schedule).Update("geofence_flagged", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	visits, err := selectVisitsForEVVExport(db, shift.Add(-time.Hour), shift.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visits) != 0 {
+		t.Fatalf("accepted visit was selected again after an unrelated update: %d visits", len(visits))
+	}
+}
+
+func TestSelectVisitsForEVVExportResubmitsApprovedCorrections(t *testing.T) {
+	db := dbtest.Open(t)
+	shift := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	schedule, submission := acceptedVisit(t, db, shift)
+
+	reviewedBefore := submission.CreatedAt.Add(-time.Hour)
+	reviewedAfter := submission.CreatedAt.Add(30 * time.Minute)
+	corrections := []models.VisitCorrection{
+		{ScheduleID: schedule.ID, RequestedBy: schedule.UserID, ReasonCode: "WRONG_TIME_RECORDED", Status: models.CORRECTION_STATUS_APPROVED, ReviewedAt: &reviewedBefore},
+		{ScheduleID: schedule.ID, RequestedBy: schedule.UserID, ReasonCode: "NO_GPS_SIGNAL", Status: models.CORRECTION_STATUS_REJECTED, ReviewedAt: &reviewedAfter},
+	}
+	if err := db.Create(&corrections).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	visits, err := selectVisitsForEVVExport(db, shift.Add(-time.Hour), shift.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visits) != 0 {
+		t.Fatalf("visit resubmitted without a correction approved since it was accepted: %d visits", len(visits))
+	}
+
+	approved := models.VisitCorrection{ScheduleID: schedule.ID, RequestedBy: schedule.UserID, ReasonCode: "WRONG_TIME_RECORDED",
+		Status: models.CORRECTION_STATUS_APPROVED, ReviewedAt: &reviewedAfter}
+	if err := db.Create(&approved).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	visits, err = selectVisitsForEVVExport(db, shift.Add(-time.Hour), shift.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visits) != 1 || visits[0].attempt != 2 {
+		t.Fatalf("expected the corrected visit to be resubmitted as attempt 2, got %+v", visits)
+	}
+}
+
+// aggregatorStub is a minimal EVV aggregator that records each batch it receives and answers
+// with a result per visit, rejecting the visit IDs listed in reject
+type aggregatorStub struct {
+	status int
+	reject map[string]string
+
+	mu       sync.Mutex
+	requests []aggregatorRequest
+}
+
+type aggregatorRequest struct {
+	header http.Header
+	body   struct {
+		State  string              `json:"state"`
+		Visits []map[string]string `json:"visits"`
+	}
+}
+
+func newAggregatorStub(t *testing.T, status int, reject map[string]string) (*aggregatorStub, *httptest.Server) {
+	stub := &aggregatorStub{status: status, reject: reject}
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	return stub, srv
+}
+
+func (s *aggregatorStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req aggregatorRequest
+	req.header = r.Header.Clone()
+	if err := json.NewDecoder(r.Body).Decode(&req.body); err != nil {
+		http.Error(w, "malformed batch", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	if s.status != http.StatusOK {
+		http.Error(w, http.StatusText(s.status), s.status)
+		return
+	}
+	var response models.EVVAggregatorResponse
+	for _, visit := range req.body.Visits {
+		visitID := visit[r.Header.Get("X-EVV-Visit-ID-Field")]
+		id, _ := strconv.Atoi(visitID)
+		result := models.EVVAggregatorResult{VisitID: uint(id), Status: models.EVV_SUBMISSION_ACCEPTED}
+		if reason, ok := s.reject[visitID]; ok {
+			result.Status, result.Reason = models.EVV_SUBMISSION_REJECTED, reason
+		}
+		response.Results = append(response.Results, result)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// evvExportBatch stores two completed visits for a client and renders them with the default JSON schema
+func evvExportBatch(t *testing.T, db *gorm.DB, shift time.Time) (*models.EVVExportBatch, models.EVVExportSchema, []models.Schedule) {
+	t.Helper()
+	caregiver := models.User{Email: "cg@example.com", Mobile: "5550100", FullName: "Casey Giver", Password: "x", RoleID: models.ROLE_CAREGIVER}
+	if err := db.Create(&caregiver).Error; err != nil {
+		t.Fatal(err)
+	}
+	client := models.Client{FullName: "Pat Client", MedicaidID: "MC12345"}
+	if err := db.Create(&client).Error; err != nil {
+		t.Fatal(err)
+	}
+	var schedules []models.Schedule
+	for i := 0; i < 2; i++ {
+		start := shift.Add(time.Duration(i) * 24 * time.Hour)
+		end := start.Add(time.Hour)
+		schedules = append(schedules, models.Schedule{UserID: caregiver.ID, ClientID: &client.ID, ClientName: client.FullName,
+			Location: "1 Main St", ServiceCode: "T1019", ShiftTime: start, Status: models.SCHEDULE_STATUS_COMPLETED,
+			StartTime: &start, EndTime: &end})
+	}
+	if err := db.Create(&schedules).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	schemas, err := LoadEVVSchemas("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := schemas["DEFAULT_JSON"]
+	batch, skipped, err := CreateEVVExportBatch(db, schema, "PRV1", shift.Add(-time.Hour), shift.Add(48*time.Hour), caregiver.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 0 {
+		t.Fatalf("visits skipped from the export: %+v", skipped)
+	}
+	return batch, schema, schedules
+}
+
+func TestSubmitEVVBatchAppliesAggregatorResults(t *testing.T) {
+	db := dbtest.Open(t)
+	shift := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	batch, schema, schedules := evvExportBatch(t, db, shift)
+	accepted, rejected := schedules[0], schedules[1]
+	rejectedID := strconv.FormatUint(uint64(rejected.ID), 10)
+	stub, srv := newAggregatorStub(t, http.StatusOK, map[string]string{rejectedID: "MEMBER_NOT_ELIGIBLE"})
+
+	if err := SubmitEVVBatch(db, batch, schema, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stub.requests) != 1 {
+		t.Fatalf("aggregator received %d requests, want 1", len(stub.requests))
+	}
+	req := stub.requests[0]
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type %q, want application/json", got)
+	}
+	if got := req.header.Get("X-EVV-Batch-ID"); got != strconv.FormatUint(uint64(batch.ID), 10) {
+		t.Errorf("X-EVV-Batch-ID %q, want %d", got, batch.ID)
+	}
+	if req.header.Get("X-EVV-State") != "DEFAULT_JSON" || req.body.State != "DEFAULT_JSON" {
+		t.Errorf("batch sent for state %q (header %q), want DEFAULT_JSON", req.body.State, req.header.Get("X-EVV-State"))
+	}
+	if len(req.body.Visits) != 2 {
+		t.Fatalf("aggregator received %d visits, want 2", len(req.body.Visits))
+	}
+	for i, visit := range req.body.Visits {
+		want := map[string]string{
+			"visit_id":        strconv.FormatUint(uint64(schedules[i].ID), 10),
+			"service_type":    "T1019",
+			"member_id":       "MC12345",
+			"date_of_service": schedules[i].StartTime.Format("2006-01-02"),
+			"start_time":      schedules[i].StartTime.Format(time.RFC3339),
+			"end_time":        schedules[i].EndTime.Format(time.RFC3339),
+			"attempt":         "1",
+		}
+		for key, value := range want {
+			if visit[key] != value {
+				t.Errorf("visit %d: %s = %q, want %q", i, key, visit[key], value)
+			}
+		}
+	}
+
+	saved, err := GetEVVExportBatch(db, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.SubmittedAt == nil {
+		t.Error("batch was not marked submitted")
+	}
+	if len(saved.Submissions) != 2 {
+		t.Fatalf("batch has %d submissions, want 2", len(saved.Submissions))
+	}
+	for _, sub := range saved.Submissions {
+		switch sub.ScheduleID {
+		case accepted.ID:
+			if sub.Status != models.EVV_SUBMISSION_ACCEPTED || sub.RespondedAt == nil {
+				t.Errorf("accepted visit recorded as %s, responded %v", sub.Status, sub.RespondedAt)
+			}
+		case rejected.ID:
+			if sub.Status != models.EVV_SUBMISSION_REJECTED || sub.RejectionReason == nil || *sub.RejectionReason != "MEMBER_NOT_ELIGIBLE" {
+				t.Errorf("rejected visit recorded as %s, reason %v", sub.Status, sub.RejectionReason)
+			}
+		}
+	}
+
+	// Only the rejected visit goes into the next export, as a second attempt
+	visits, err := selectVisitsForEVVExport(db, shift.Add(-time.Hour), shift.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visits) != 1 || visits[0].schedule.ID != rejected.ID || visits[0].attempt != 2 {
+		t.Fatalf("expected only the rejected visit to be resubmitted as attempt 2, got %+v", visits)
+	}
+}
+
+func TestSubmitEVVBatchKeepsVisitsGeneratedWhenAggregatorFails(t *testing.T) {
+	db := dbtest.Open(t)
+	batch, schema, _ := evvExportBatch(t, db, time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC))
+	_, srv := newAggregatorStub(t, http.StatusServiceUnavailable, nil)
+
+	if err := SubmitEVVBatch(db, batch, schema, srv.URL); err == nil {
+		t.Fatal("expected an error when the aggregator is unavailable")
+	}
+	saved, err := GetEVVExportBatch(db, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.SubmittedAt != nil {
+		t.Error("batch was marked submitted although the aggregator refused it")
+	}
+	for _, sub := range saved.Submissions {
+		if sub.Status != models.EVV_SUBMISSION_GENERATED {
+			t.Errorf("visit %d recorded as %s, want generated", sub.ScheduleID, sub.Status)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrIncidentCategory         = errors.New("unknown incident category")
+	ErrIncidentOccurredAt       = errors.New("occurred_at cannot be in the future")
+	ErrIncidentAttachment       = errors.New("attachment does not belong to this visit")
+	ErrIncidentTransition       = errors.New("incident cannot move to that status")
+	ErrIncidentResolutionNeeded = errors.New("a resolution note is required to close an incident")
+)
+
+// BuildIncident validates a report and fills in the incident fields. OccurredAt defaults to now.
+func BuildIncident(incident *models.Incident, req models.IncidentRequest, now time.Time) error {
+	if _, ok := models.IncidentCategories[req.Category]; !ok {
+		return ErrIncidentCategory
+	}
+	occurredAt := now
+	if req.OccurredAt != nil {
+		if req.OccurredAt.After(now) {
+			return ErrIncidentOccurredAt
+		}
+		occurredAt = *req.OccurredAt
+	}
+	incident.OccurredAt = occurredAt.UTC()
+	incident.Category = req.Category
+	incident.Severity = req.Severity
+	incident.Narrative = strings.TrimSpace(req.Narrative)
+	incident.InvolvedParties = req.InvolvedParties
+	if incident.InvolvedParties == nil {
+		incident.InvolvedParties = []models.IncidentParty{}
+	}
+	incident.ActionsTaken = req.ActionsTaken
+	incident.Status = models.INCIDENT_STATUS_OPEN
+	return nil
+}
+
+// CreateIncident stores a new incident and links the listed attachments of the same visit to it
+func CreateIncident(db *gorm.DB, incident *models.Incident, attachmentIDs []uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(incident).Error; err != nil {
+			return err
+		}
+		if len(attachmentIDs) == 0 {
+			return nil
+		}
+		result := tx.Model(&models.Attachment{}).
+			Where("id IN ? AND schedule_id = ? AND incident_id IS NULL", attachmentIDs, incident.ScheduleID).
+			Update("incident_id", incident.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(dedupeUints(attachmentIDs))) {
+			return ErrIncidentAttachment
+		}
+		return nil
+	})
+}
+
+// GetIncidentByID retrieves an incident with its attachments
+func GetIncidentByID(db *gorm.DB, incidentID uint) (*models.Incident, error) {
+	var incident models.Incident
+	err := db.Preload("Attachments").First(&incident, "id = ?", incidentID).Error
+	return &incident, err
+}
+
+// GetScheduleIncidents lists the incidents reported on a visit, oldest first
+func GetScheduleIncidents(db *gorm.DB, scheduleID uint) ([]models.Incident, error) {
+	var incidents []models.Incident
+	err := db.Preload("Attachments").Where("schedule_id = ?", scheduleID).Order("occurred_at ASC").Find(&incidents).Error
+	return incidents, err
+}
+
+// ListIncidents returns incidents matching the filter, newest first
+func ListIncidents(db *gorm.DB, filter models.IncidentFilter) ([]models.Incident, error) {
+	query := db.Order("occurred_at DESC")
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Severity != "" {
+		query = query.Where("severity = ?", filter.Severity)
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.ClientID != 0 {
+		query = query.Where("client_id = ?", filter.ClientID)
+	}
+	if filter.From != nil {
+		query = query.Where("occurred_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("occurred_at <= ?", filter.To.UTC())
+	}
+	var incidents []models.Incident
+	err := query.Find(&incidents).Error
+	return incidents, err
+}
+
+// TransitionIncident moves an incident through the open → under review → closed workflow
+func TransitionIncident(db *gorm.DB, incident *models.Incident, status, note string, userID uint) error {
+	allowed := false
+	for _, next := range models.IncidentTransitions[incident.Status] {
+		if next == status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return ErrIncidentTransition
+	}
+	note = strings.TrimSpace(note)
+
+	switch status {
+	case models.INCIDENT_STATUS_UNDER_REVIEW:
+		incident.ReviewedBy = &userID
+		if note != "" {
+			incident.ReviewNote = &note
+		}
+	case models.INCIDENT_STATUS_CLOSED:
+		if note == "" {
+			return ErrIncidentResolutionNeeded
+		}
+		now := time.Now()
+		incident.ClosedBy = &userID
+		incident.ClosedAt = &now
+		incident.Resolution = &note
+	case models.INCIDENT_STATUS_OPEN:
+		if note != "" {
+			incident.ReviewNote = &note
+		}
+	}
+	incident.Status = status
+	return db.Omit("Attachments").Save(incident).Error
+}
+
+// NotifyHighSeverityIncident emails customer care about a high or critical incident and
+// records when the notification went out
+func NotifyHighSeverityIncident(db *gorm.DB, incident *models.Incident, schedule *models.Schedule) error {
+	subject := fmt.Sprintf("[%s] Incident #%d: %s during visit for %s",
+		strings.ToUpper(incident.Severity), incident.ID, models.IncidentCategories[incident.Category], schedule.ClientName)
+	body := fmt.Sprintf(
+		"A %s severity incident was reported.\n\nCategory: %s\nClient: %s\nVisit: #%d at %s\nOccurred at: %s\nReported by user: %d\n\n%s\n",
+		incident.Severity, incident.Category, schedule.ClientName, schedule.ID, schedule.Location,
+		incident.OccurredAt.Format(time.RFC3339), incident.ReportedBy, incident.Narrative)
+	if incident.ActionsTaken != nil {
+		body += "\nActions taken: " + *incident.ActionsTaken + "\n"
+	}
+	if err := NotifyCustomerCare(db, subject, body); err != nil {
+		return err
+	}
+	now := time.Now()
+	incident.NotifiedAt = &now
+	return db.Model(incident).Update("notified_at", now).Error
+}
+
+func dedupeUints(values []uint) []uint {
+	seen := make(map[uint]bool, len(values))
+	unique := make([]uint, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
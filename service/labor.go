@@ -0,0 +1,527 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/utils"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrLaborRulesInvalid is returned when a rule set's thresholds contradict each other
+var ErrLaborRulesInvalid = errors.New("daily double time must start after daily overtime")
+
+// TravelEstimate is the distance and time between the end of one visit and the start of the next
+type TravelEstimate struct {
+	Meters  float64
+	Minutes int
+}
+
+// TravelEstimator estimates travel between two consecutive visits of a caregiver
+type TravelEstimator func(prev, next *models.Schedule) TravelEstimate
+
+// LaborOptions control which visits a labor calculation covers
+type LaborOptions struct {
+	// IncludePlanned adds scheduled visits at their planned time and duration
+	IncludePlanned bool
+	// ExcludeScheduleID leaves one visit out, e.g. to compare with and without a new assignment
+	ExcludeScheduleID uint
+	// Extra visits are added as if they were planned (used to preview an assignment)
+	Extra []models.Schedule
+	// Travel estimates time between visits; nil uses the stored travel segments, estimating
+	// legs without one with StraightLineTravel at SpeedKmh
+	Travel   TravelEstimator
+	SpeedKmh float64
+	Now      time.Time
+}
+
+// laborUnit is a stretch of paid time on a single agency-local day
+type laborUnit struct {
+	at      time.Time
+	minutes int
+	travel  bool
+}
+
+// StraightLineTravel estimates travel as the haversine distance between the previous visit's
+// end point and the next visit's start point, driven at speedKmh. Visits without coordinates
+// fall back to their client's; when a point is still unknown no travel is counted.
+func StraightLineTravel(clients map[uint]models.Client, speedKmh float64) TravelEstimator {
+	return func(prev, next *models.Schedule) TravelEstimate {
+		fromLat, fromLon, ok1 := visitEndPoint(prev, clients)
+		toLat, toLon, ok2 := visitStartPoint(next, clients)
+		if !ok1 || !ok2 || speedKmh <= 0 {
+			return TravelEstimate{}
+		}
+		meters := utils.DistanceMeters(fromLat, fromLon, toLat, toLon)
+		return TravelEstimate{Meters: meters, Minutes: int(math.Ceil(meters / 1000 / speedKmh * 60))}
+	}
+}
+
+// WorkweekFor returns the 7-day workweek containing t. Workweeks start on the pay period
+// boundary, so a biweekly period holds exactly two of them.
+func WorkweekFor(settings models.PayPeriodSettings, t time.Time) (time.Time, time.Time) {
+	periodStart, _ := PayPeriodFor(settings, t)
+	weeks := calendarDaysBetween(periodStart, t, settings.Location) / 7
+	start := periodStart.AddDate(0, 0, weeks*7)
+	return start, start.AddDate(0, 0, 7)
+}
+
+// ListLaborRuleSets returns every rule set by name
+func ListLaborRuleSets(db *gorm.DB) ([]models.LaborRuleSet, error) {
+	var sets []models.LaborRuleSet
+	err := db.Order("name ASC").Find(&sets).Error
+	return sets, err
+}
+
+// GetLaborRuleSetByID retrieves a single rule set
+func GetLaborRuleSetByID(db *gorm.DB, id uint) (*models.LaborRuleSet, error) {
+	var rules models.LaborRuleSet
+	err := db.First(&rules, "id = ?", id).Error
+	return &rules, err
+}
+
+// ApplyLaborRuleSetRequest copies a request onto a rule set and checks its thresholds
+func ApplyLaborRuleSetRequest(rules *models.LaborRuleSet, req models.LaborRuleSetRequest) error {
+	if req.DailyOvertimeAfterMinutes > 0 && req.DailyDoubleTimeAfterMinutes > 0 &&
+		req.DailyDoubleTimeAfterMinutes <= req.DailyOvertimeAfterMinutes {
+		return ErrLaborRulesInvalid
+	}
+	rules.Name = strings.TrimSpace(req.Name)
+	rules.DailyOvertimeAfterMinutes = req.DailyOvertimeAfterMinutes
+	rules.DailyDoubleTimeAfterMinutes = req.DailyDoubleTimeAfterMinutes
+	rules.WeeklyOvertimeAfterMinutes = req.WeeklyOvertimeAfterMinutes
+	rules.IncludeTravel = req.IncludeTravel == nil || *req.IncludeTravel
+	return nil
+}
+
+// SaveLaborRuleSet creates or updates a rule set. IncludeTravel is written explicitly because
+// gorm skips false booleans that have a column default.
+func SaveLaborRuleSet(db *gorm.DB, rules *models.LaborRuleSet) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(rules).Error; err != nil {
+			return err
+		}
+		return tx.Model(rules).Update("include_travel", rules.IncludeTravel).Error
+	})
+}
+
+// AssignLaborRuleSet puts a caregiver on a rule set, or back on the defaults when id is nil
+func AssignLaborRuleSet(db *gorm.DB, userID uint, id *uint) error {
+	return db.Model(&models.User{}).Where("id = ?", userID).Update("labor_rule_set_id", id).Error
+}
+
+// ListCaregiverIDs returns the IDs of every caregiver account
+func ListCaregiverIDs(db *gorm.DB) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&models.User{}).Where("role_id = ?", models.ROLE_CAREGIVER).Order("id ASC").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// GetLaborRuleSet returns the caregiver's assigned rule set, or fallback when none is assigned
+func GetLaborRuleSet(db *gorm.DB, userID uint, fallback models.LaborRuleSet) (models.LaborRuleSet, error) {
+	var user models.User
+	if err := db.Select("id", "labor_rule_set_id").First(&user, "id = ?", userID).Error; err != nil {
+		return fallback, err
+	}
+	if user.LaborRuleSetID == nil {
+		return fallback, nil
+	}
+	var rules models.LaborRuleSet
+	if err := db.First(&rules, "id = ?", *user.LaborRuleSetID).Error; err != nil {
+		return fallback, err
+	}
+	return rules, nil
+}
+
+// LoadLaborVisits returns the caregiver's visits in [start, end): visits that have started, by
+// their start time, and when includePlanned is set, visits still scheduled, by their shift time
+func LoadLaborVisits(db *gorm.DB, userID uint, start, end time.Time, includePlanned bool) ([]models.Schedule, error) {
+	query := db.Preload("Breaks", func(db *gorm.DB) *gorm.DB { return db.Order("started_at ASC") }).
+		Where("user_id = ?", userID)
+	started := []string{models.SCHEDULE_STATUS_COMPLETED, models.SCHEDULE_STATUS_IN_PROGRESS}
+	if includePlanned {
+		query = query.Where("(status IN ? AND start_time >= ? AND start_time < ?) OR (status = ? AND shift_time >= ? AND shift_time < ?)",
+			started, start.UTC(), end.UTC(), models.SCHEDULE_STATUS_SCHEDULED, start.UTC(), end.UTC())
+	} else {
+		query = query.Where("status IN ? AND start_time >= ? AND start_time < ?", started, start.UTC(), end.UTC())
+	}
+	var schedules []models.Schedule
+	err := query.Find(&schedules).Error
+	return schedules, err
+}
+
+// LoadVisitClients returns the clients of the given visits keyed by ID
+func LoadVisitClients(db *gorm.DB, schedules []models.Schedule) (map[uint]models.Client, error) {
+	var ids []uint
+	for _, s := range schedules {
+		if s.ClientID != nil {
+			ids = append(ids, *s.ClientID)
+		}
+	}
+	clients := make(map[uint]models.Client, len(ids))
+	if len(ids) == 0 {
+		return clients, nil
+	}
+	var rows []models.Client
+	if err := db.Where("id IN ?", dedupeUints(ids)).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range rows {
+		clients[c.ID] = c
+	}
+	return clients, nil
+}
+
+// ComputeLabor works out a caregiver's regular, overtime and double-time minutes for the period
+// [start, end), which must begin on a workweek boundary. Daily thresholds apply per agency-local
+// day and the weekly threshold to the regular minutes of each 7-day workweek from start.
+func ComputeLabor(db *gorm.DB, userID uint, start, end time.Time, loc *time.Location, rules models.LaborRuleSet, breakRules models.BreakRules, opts LaborOptions) (*models.LaborBreakdown, error) {
+	visits, err := LoadLaborVisits(db, userID, start, end, opts.IncludePlanned)
+	if err != nil {
+		return nil, err
+	}
+	schedules := make([]models.Schedule, 0, len(visits)+len(opts.Extra))
+	for _, v := range visits {
+		if opts.ExcludeScheduleID == 0 || v.ID != opts.ExcludeScheduleID {
+			schedules = append(schedules, v)
+		}
+	}
+	schedules = append(schedules, opts.Extra...)
+
+	travel := opts.Travel
+	if travel == nil {
+		clients, err := LoadVisitClients(db, schedules)
+		if err != nil {
+			return nil, err
+		}
+		segments, err := travelSegmentsArriving(db, userID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		travel = StoredTravel(segments, StraightLineTravel(clients, opts.SpeedKmh))
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	laborVisits := make([]LaborVisit, 0, len(schedules))
+	for i := range schedules {
+		from, to, worked := laborVisitSpan(&schedules[i], breakRules, now)
+		laborVisits = append(laborVisits, LaborVisit{Schedule: &schedules[i], Start: from, End: to, WorkedMinutes: worked})
+	}
+	breakdown := SummarizeLabor(laborVisits, start, end, loc, rules, travel)
+	breakdown.UserID = userID
+	breakdown.Projected = opts.IncludePlanned || len(opts.Extra) > 0
+	return breakdown, nil
+}
+
+// LaborVisit is a visit's paid span as the labor engine sees it
+type LaborVisit struct {
+	Schedule      *models.Schedule
+	Start         time.Time
+	End           time.Time
+	WorkedMinutes int
+}
+
+// SummarizeLabor allocates the visits' worked time, plus travel between consecutive visits on
+// the same day, to regular, overtime and double time under the rule set. A visit that runs past
+// local midnight counts toward each day only with the time worked on it. The distance between
+// those visits is reported even when travel time is not paid, for mileage.
+func SummarizeLabor(visits []LaborVisit, start, end time.Time, loc *time.Location, rules models.LaborRuleSet, travel TravelEstimator) *models.LaborBreakdown {
+	sort.Slice(visits, func(i, j int) bool { return visits[i].Start.Before(visits[j].Start) })
+
+	breakdown := &models.LaborBreakdown{PeriodStart: start, PeriodEnd: end, RuleSet: rules, Days: []models.LaborDay{}}
+	var units []laborUnit
+	for i, visit := range visits {
+		if i > 0 {
+			prev := visits[i-1]
+			if sameLocalDay(prev.End, visit.Start, loc) && !visit.Start.Before(prev.End) {
+				estimate := travel(prev.Schedule, visit.Schedule)
+				breakdown.TravelMeters += estimate.Meters
+				minutes := estimate.Minutes
+				if gap := minutesBetween(prev.End, visit.Start); minutes > gap {
+					minutes = gap
+				}
+				if minutes > 0 && rules.IncludeTravel {
+					units = append(units, laborUnit{at: prev.End, minutes: minutes, travel: true})
+				}
+			}
+		}
+		units = append(units, laborVisitUnits(visit, loc)...)
+		breakdown.VisitCount++
+	}
+
+	days := map[string]*models.LaborDay{}
+	var order []string
+	weekRegular := map[int]int{}
+	for _, unit := range units {
+		local := unit.at.In(loc)
+		key := local.Format("2006-01-02")
+		day, ok := days[key]
+		if !ok {
+			day = &models.LaborDay{Date: key}
+			days[key] = day
+			order = append(order, key)
+		}
+
+		before := day.RegularMinutes + day.OvertimeMinutes + day.DoubleTimeMinutes
+		regular, overtime, doubleTime := splitDailyMinutes(before, unit.minutes, rules)
+		week := calendarDaysBetween(start, local, loc) / 7
+		if rules.WeeklyOvertimeAfterMinutes > 0 {
+			available := rules.WeeklyOvertimeAfterMinutes - weekRegular[week]
+			if available < 0 {
+				available = 0
+			}
+			if regular > available {
+				overtime += regular - available
+				regular = available
+			}
+		}
+		weekRegular[week] += regular
+
+		day.RegularMinutes += regular
+		day.OvertimeMinutes += overtime
+		day.DoubleTimeMinutes += doubleTime
+		if unit.travel {
+			day.TravelMinutes += unit.minutes
+			breakdown.TravelMinutes += unit.minutes
+		} else {
+			day.VisitMinutes += unit.minutes
+			breakdown.VisitMinutes += unit.minutes
+		}
+		breakdown.RegularMinutes += regular
+		breakdown.OvertimeMinutes += overtime
+		breakdown.DoubleTimeMinutes += doubleTime
+	}
+	sort.Strings(order)
+	for _, key := range order {
+		breakdown.Days = append(breakdown.Days, *days[key])
+	}
+	return breakdown
+}
+
+// TimesheetLabor allocates a timesheet's entries the way payroll pays them: rounded clock
+// times with unpaid breaks deducted, plus the recorded travel between consecutive visits
+func TimesheetLabor(db *gorm.DB, sheet *models.Timesheet, loc *time.Location, rules models.LaborRuleSet, speedKmh float64) (*models.LaborBreakdown, error) {
+	ids := make([]uint, 0, len(sheet.Entries))
+	for _, entry := range sheet.Entries {
+		ids = append(ids, entry.ScheduleID)
+	}
+	var schedules []models.Schedule
+	if len(ids) > 0 {
+		if err := db.Preload("Breaks").Where("id IN ?", ids).Find(&schedules).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[uint]*models.Schedule, len(schedules))
+	for i := range schedules {
+		byID[schedules[i].ID] = &schedules[i]
+	}
+	clients, err := LoadVisitClients(db, schedules)
+	if err != nil {
+		return nil, err
+	}
+
+	visits := make([]LaborVisit, 0, len(sheet.Entries))
+	for _, entry := range sheet.Entries {
+		schedule, ok := byID[entry.ScheduleID]
+		if !ok {
+			schedule = &models.Schedule{ID: entry.ScheduleID}
+		}
+		visits = append(visits, LaborVisit{Schedule: schedule, Start: entry.RoundedStart, End: entry.RoundedEnd, WorkedMinutes: entry.WorkedMinutes})
+	}
+	segments, err := travelSegmentsArriving(db, sheet.UserID, sheet.PeriodStart, sheet.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+	travel := StoredTravel(segments, StraightLineTravel(clients, speedKmh))
+	breakdown := SummarizeLabor(visits, sheet.PeriodStart.In(loc), sheet.PeriodEnd.In(loc), loc, rules, travel)
+	breakdown.UserID = sheet.UserID
+	return breakdown, nil
+}
+
+// LaborWarningFor compares a week's labor with and without an assignment and describes any
+// overtime or double time it adds. It returns nil when the assignment adds none.
+func LaborWarningFor(without, with *models.LaborBreakdown) *models.LaborWarning {
+	overtime := with.OvertimeMinutes - without.OvertimeMinutes
+	doubleTime := with.DoubleTimeMinutes - without.DoubleTimeMinutes
+	if overtime <= 0 && doubleTime <= 0 {
+		return nil
+	}
+	return &models.LaborWarning{
+		Message: fmt.Sprintf("assignment adds %d overtime and %d double-time minutes to the caregiver's week",
+			overtime, doubleTime),
+		OvertimeMinutesAdded:   overtime,
+		DoubleTimeMinutesAdded: doubleTime,
+		Projected:              with,
+	}
+}
+
+// laborVisitUnits splits a visit's worked minutes at each local midnight it runs past. Time not
+// worked (unpaid breaks, or clock rounding on a timesheet) comes off the days the breaks were
+// taken on, or off each day by its share of the visit when there were no breaks.
+func laborVisitUnits(visit LaborVisit, loc *time.Location) []laborUnit {
+	var (
+		starts        []time.Time
+		spans, breaks []float64
+		elapsed       float64
+		breakTotal    float64
+	)
+	for from := visit.Start; from.Before(visit.End); {
+		local := from.In(loc)
+		to := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+		if to.After(visit.End) {
+			to = visit.End
+		}
+		span, onBreak := to.Sub(from).Minutes(), breakMinutesWithin(visit, from, to)
+		starts, spans, breaks = append(starts, from), append(spans, span), append(breaks, onBreak)
+		elapsed += span
+		breakTotal += onBreak
+		from = to
+	}
+	if len(starts) < 2 {
+		return []laborUnit{{at: visit.Start, minutes: visit.WorkedMinutes}}
+	}
+
+	notWorked := elapsed - float64(visit.WorkedMinutes)
+	worked := make([]float64, len(starts))
+	var total float64
+	for i := range starts {
+		share := spans[i] / elapsed
+		if breakTotal > 0 {
+			share = breaks[i] / breakTotal
+		}
+		worked[i] = math.Min(math.Max(spans[i]-notWorked*share, 0), spans[i])
+		total += worked[i]
+	}
+	if total == 0 {
+		return []laborUnit{{at: visit.Start, minutes: visit.WorkedMinutes}}
+	}
+
+	// Round the running total so the days add up to exactly the visit's worked minutes
+	units := make([]laborUnit, len(starts))
+	var cumulative float64
+	assigned := 0
+	for i, at := range starts {
+		cumulative += worked[i] * float64(visit.WorkedMinutes) / total
+		minutes := int(math.Round(cumulative)) - assigned
+		assigned += minutes
+		units[i] = laborUnit{at: at, minutes: minutes}
+	}
+	return units
+}
+
+// breakMinutesWithin returns how much of [from, to) the visit spent on a break. A break still
+// open runs to the end of the visit.
+func breakMinutesWithin(visit LaborVisit, from, to time.Time) float64 {
+	if visit.Schedule == nil {
+		return 0
+	}
+	var minutes float64
+	for _, b := range visit.Schedule.Breaks {
+		start, end := b.StartedAt, visit.End
+		if b.EndedAt != nil {
+			end = *b.EndedAt
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			minutes += end.Sub(start).Minutes()
+		}
+	}
+	return minutes
+}
+
+// splitDailyMinutes divides minutes worked after `before` minutes already worked that day into
+// regular, overtime and double time according to the daily thresholds
+func splitDailyMinutes(before, minutes int, rules models.LaborRuleSet) (int, int, int) {
+	const unlimited = math.MaxInt32
+	overtimeAt, doubleTimeAt := unlimited, unlimited
+	if rules.DailyOvertimeAfterMinutes > 0 {
+		overtimeAt = rules.DailyOvertimeAfterMinutes
+	}
+	if rules.DailyDoubleTimeAfterMinutes > 0 {
+		doubleTimeAt = rules.DailyDoubleTimeAfterMinutes
+	}
+	if overtimeAt > doubleTimeAt {
+		overtimeAt = doubleTimeAt
+	}
+	portion := func(from, to int) int {
+		lo, hi := before, before+minutes
+		if lo < from {
+			lo = from
+		}
+		if hi > to {
+			hi = to
+		}
+		if hi < lo {
+			return 0
+		}
+		return hi - lo
+	}
+	return portion(0, overtimeAt), portion(overtimeAt, doubleTimeAt), portion(doubleTimeAt, unlimited)
+}
+
+// laborVisitSpan returns when a visit runs and how many minutes of it are paid work. Visits that
+// have not started use their shift time and planned duration.
+func laborVisitSpan(s *models.Schedule, breakRules models.BreakRules, now time.Time) (time.Time, time.Time, int) {
+	if s.StartTime == nil {
+		end := s.ShiftTime.Add(visitDuration(s))
+		return s.ShiftTime, end, minutesBetween(s.ShiftTime, end)
+	}
+	end := now
+	if s.EndTime != nil {
+		end = *s.EndTime
+	} else if planned := s.StartTime.Add(visitDuration(s)); planned.After(now) {
+		end = planned
+	}
+	summary := SummarizeVisitTime(s, s.Breaks, breakRules, end)
+	return *s.StartTime, end, summary.WorkedMinutes
+}
+
+func visitStartPoint(s *models.Schedule, clients map[uint]models.Client) (float64, float64, bool) {
+	if s.StartLat != nil && s.StartLon != nil {
+		return *s.StartLat, *s.StartLon, true
+	}
+	return clientPoint(s, clients)
+}
+
+func visitEndPoint(s *models.Schedule, clients map[uint]models.Client) (float64, float64, bool) {
+	if s.EndLat != nil && s.EndLon != nil {
+		return *s.EndLat, *s.EndLon, true
+	}
+	return clientPoint(s, clients)
+}
+
+func clientPoint(s *models.Schedule, clients map[uint]models.Client) (float64, float64, bool) {
+	if s.ClientID == nil {
+		return 0, 0, false
+	}
+	client, ok := clients[*s.ClientID]
+	if !ok || client.Latitude == nil || client.Longitude == nil {
+		return 0, 0, false
+	}
+	return *client.Latitude, *client.Longitude, true
+}
+
+// calendarDaysBetween counts calendar days from a to b in loc, ignoring DST changes
+func calendarDaysBetween(a, b time.Time, loc *time.Location) int {
+	la, lb := a.In(loc), b.In(loc)
+	da := time.Date(la.Year(), la.Month(), la.Day(), 0, 0, 0, 0, time.UTC)
+	db := time.Date(lb.Year(), lb.Month(), lb.Day(), 0, 0, 0, 0, time.UTC)
+	return int(db.Sub(da).Hours() / 24)
+}
+
+func sameLocalDay(a, b time.Time, loc *time.Location) bool {
+	return a.In(loc).Format("2006-01-02") == b.In(loc).Format("2006-01-02")
+}
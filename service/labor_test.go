@@ -0,0 +1,158 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// laborVisit builds a completed visit from agency-local "2006-01-02 15:04" times, with its
+// breaks given as start and end pairs. Every break is unpaid.
+func laborVisit(t *testing.T, loc *time.Location, start, end string, breaks ...[2]string) LaborVisit {
+	t.Helper()
+	parse := func(value string) time.Time {
+		at, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return at
+	}
+	from, to := parse(start), parse(end)
+	schedule := &models.Schedule{StartTime: &from, EndTime: &to}
+	worked := minutesBetween(from, to)
+	for _, b := range breaks {
+		startedAt, endedAt := parse(b[0]), parse(b[1])
+		schedule.Breaks = append(schedule.Breaks, models.VisitBreak{StartedAt: startedAt, EndedAt: &endedAt})
+		worked -= minutesBetween(startedAt, endedAt)
+	}
+	return LaborVisit{Schedule: schedule, Start: from, End: to, WorkedMinutes: worked}
+}
+
+func TestSummarizeLaborSplitsVisitsAtLocalMidnight(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	daily := models.LaborRuleSet{DailyOvertimeAfterMinutes: 480, DailyDoubleTimeAfterMinutes: 600}
+	weekly := models.LaborRuleSet{WeeklyOvertimeAfterMinutes: 2400}
+
+	// Periods start on Monday 2025-03-03 and run two workweeks
+	cases := []struct {
+		name   string
+		rules  models.LaborRuleSet
+		visits func(t *testing.T) []LaborVisit
+		want   []models.LaborDay
+	}{
+		{
+			name:  "day visit past daily double time",
+			rules: daily,
+			visits: func(t *testing.T) []LaborVisit {
+				return []LaborVisit{laborVisit(t, loc, "2025-03-04 08:00", "2025-03-04 20:00")}
+			},
+			want: []models.LaborDay{
+				{Date: "2025-03-04", VisitMinutes: 720, RegularMinutes: 480, OvertimeMinutes: 120, DoubleTimeMinutes: 120},
+			},
+		},
+		{
+			name:  "overnight visit counts toward each day it covers",
+			rules: daily,
+			visits: func(t *testing.T) []LaborVisit {
+				return []LaborVisit{
+					laborVisit(t, loc, "2025-03-04 08:00", "2025-03-04 14:00"),
+					laborVisit(t, loc, "2025-03-04 20:00", "2025-03-05 04:00"),
+				}
+			},
+			want: []models.LaborDay{
+				{Date: "2025-03-04", VisitMinutes: 600, RegularMinutes: 480, OvertimeMinutes: 120},
+				{Date: "2025-03-05", VisitMinutes: 240, RegularMinutes: 240},
+			},
+		},
+		{
+			name:  "overnight visit under both daily thresholds",
+			rules: daily,
+			visits: func(t *testing.T) []LaborVisit {
+				return []LaborVisit{laborVisit(t, loc, "2025-03-04 18:00", "2025-03-05 06:00")}
+			},
+			want: []models.LaborDay{
+				{Date: "2025-03-04", VisitMinutes: 360, RegularMinutes: 360},
+				{Date: "2025-03-05", VisitMinutes: 360, RegularMinutes: 360},
+			},
+		},
+		{
+			name:  "unpaid break after midnight comes off the second day",
+			rules: daily,
+			visits: func(t *testing.T) []LaborVisit {
+				return []LaborVisit{laborVisit(t, loc, "2025-03-04 22:00", "2025-03-05 06:00",
+					[2]string{"2025-03-05 01:00", "2025-03-05 01:30"})}
+			},
+			want: []models.LaborDay{
+				{Date: "2025-03-04", VisitMinutes: 120, RegularMinutes: 120},
+				{Date: "2025-03-05", VisitMinutes: 330, RegularMinutes: 330},
+			},
+		},
+		{
+			name:  "unpaid break across midnight is split between the days",
+			rules: daily,
+			visits: func(t *testing.T) []LaborVisit {
+				return []LaborVisit{laborVisit(t, loc, "2025-03-04 20:00", "2025-03-05 04:00",
+					[2]string{"2025-03-04 23:45", "2025-03-05 00:30"})}
+			},
+			want: []models.LaborDay{
+				{Date: "2025-03-04", VisitMinutes: 225, RegularMinutes: 225},
+				{Date: "2025-03-05", VisitMinutes: 210, RegularMinutes: 210},
+			},
+		},
+		{
+			name:  "overnight visit across the workweek boundary",
+			rules: weekly,
+			visits: func(t *testing.T) []LaborVisit {
+				visits := []LaborVisit{}
+				for _, day := range []string{"03", "04", "05", "06", "07"} {
+					visits = append(visits, laborVisit(t, loc, "2025-03-"+day+" 08:00", "2025-03-"+day+" 16:00"))
+				}
+				return append(visits, laborVisit(t, loc, "2025-03-09 22:00", "2025-03-10 02:00"))
+			},
+			want: []models.LaborDay{
+				{Date: "2025-03-03", VisitMinutes: 480, RegularMinutes: 480},
+				{Date: "2025-03-04", VisitMinutes: 480, RegularMinutes: 480},
+				{Date: "2025-03-05", VisitMinutes: 480, RegularMinutes: 480},
+				{Date: "2025-03-06", VisitMinutes: 480, RegularMinutes: 480},
+				{Date: "2025-03-07", VisitMinutes: 480, RegularMinutes: 480},
+				{Date: "2025-03-09", VisitMinutes: 120, OvertimeMinutes: 120},
+				{Date: "2025-03-10", VisitMinutes: 120, RegularMinutes: 120},
+			},
+		},
+		{
+			name:  "overnight visit on the night clocks go forward",
+			rules: daily,
+			visits: func(t *testing.T) []LaborVisit {
+				return []LaborVisit{laborVisit(t, loc, "2025-03-08 22:00", "2025-03-09 06:00")}
+			},
+			want: []models.LaborDay{
+				{Date: "2025-03-08", VisitMinutes: 120, RegularMinutes: 120},
+				{Date: "2025-03-09", VisitMinutes: 300, RegularMinutes: 300},
+			},
+		},
+	}
+
+	start := time.Date(2025, 3, 3, 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 14)
+	noTravel := func(prev, next *models.Schedule) TravelEstimate { return TravelEstimate{} }
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			visits := tc.visits(t)
+			breakdown := SummarizeLabor(visits, start, end, loc, tc.rules, noTravel)
+			if !reflect.DeepEqual(breakdown.Days, tc.want) {
+				t.Fatalf("days\n got %+v\nwant %+v", breakdown.Days, tc.want)
+			}
+			worked := 0
+			for _, visit := range visits {
+				worked += visit.WorkedMinutes
+			}
+			if total := breakdown.RegularMinutes + breakdown.OvertimeMinutes + breakdown.DoubleTimeMinutes; total != worked {
+				t.Fatalf("allocated %d minutes, visits worked %d", total, worked)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ledgerAppendAttempts bounds how often an append is retried after losing a race for the head
+const ledgerAppendAttempts = 5
+
+// AppendVisitEvent adds an event to the end of the visit ledger, chaining it to the current head.
+// The head lock cannot cover an empty table, so a concurrent append that chained to the same
+// head is caught by the unique prev_hash index and retried against the new head. Called with
+// the visit change's transaction, each attempt runs in a savepoint so a lost race leaves the
+// rest of that transaction intact.
+func AppendVisitEvent(db *gorm.DB, scheduleID uint, taskID *uint, eventType string, actorID uint, payload interface{}) (*models.VisitLedgerEntry, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := models.VisitLedgerEntry{
+		ScheduleID: scheduleID,
+		TaskID:     taskID,
+		EventType:  eventType,
+		ActorID:    actorID,
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond),
+		Payload:    string(raw),
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = appendLedgerEntry(db, &entry)
+		if err == nil {
+			return &entry, nil
+		}
+		if !isDuplicateKey(err) || attempt == ledgerAppendAttempts {
+			return nil, err
+		}
+		entry.ID = 0
+	}
+}
+
+func appendLedgerEntry(db *gorm.DB, entry *models.VisitLedgerEntry) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Lock the current head so concurrent appends wait rather than fork the chain
+		var head models.VisitLedgerEntry
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Order("id DESC").First(&head).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			entry.PrevHash = models.LEDGER_GENESIS_HASH
+		case err != nil:
+			return err
+		default:
+			entry.PrevHash = head.Hash
+		}
+
+		entry.Hash = ledgerEntryHash(entry)
+		return tx.Create(entry).Error
+	})
+}
+
+// isDuplicateKey reports whether err is a unique index violation
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.Is(err, gorm.ErrDuplicatedKey) || (errors.As(err, &mysqlErr) && mysqlErr.Number == 1062)
+}
+
+// GetVisitLedger lists ledger entries in chain order
+func GetVisitLedger(db *gorm.DB, filter models.LedgerFilter) ([]models.VisitLedgerEntry, error) {
+	var entries []models.VisitLedgerEntry
+	err := ledgerQuery(db, filter).Order("id ASC").Find(&entries).Error
+	return entries, err
+}
+
+// VerifyVisitLedger recomputes every hash in the selected part of the chain and
+// checks each entry links to the entry immediately before it, stopping at the first broken link
+func VerifyVisitLedger(db *gorm.DB, filter models.LedgerFilter) (*models.LedgerVerification, error) {
+	entries, err := GetVisitLedger(db, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.LedgerVerification{Valid: true}
+	var previous *models.VisitLedgerEntry
+	for i := range entries {
+		entry := &entries[i]
+
+		if computed := ledgerEntryHash(entry); computed != entry.Hash {
+			return brokenLedger(result, entry, "entry contents do not match its hash"), nil
+		}
+
+		// Entries selected by schedule are not contiguous, so look up the true predecessor
+		// unless it is the entry we just checked
+		if previous == nil || filter.ScheduleID != 0 {
+			previous, err = ledgerPredecessor(db, entry.ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		expectedPrev := models.LEDGER_GENESIS_HASH
+		if previous != nil {
+			expectedPrev = previous.Hash
+		}
+		if entry.PrevHash != expectedPrev {
+			return brokenLedger(result, entry, "previous hash does not match the preceding entry"), nil
+		}
+
+		result.EntriesChecked++
+		previous = entry
+	}
+
+	return result, nil
+}
+
+func ledgerQuery(db *gorm.DB, filter models.LedgerFilter) *gorm.DB {
+	query := db.Model(&models.VisitLedgerEntry{})
+	if filter.ScheduleID != 0 {
+		query = query.Where("schedule_id = ?", filter.ScheduleID)
+	}
+	if filter.From != nil {
+		query = query.Where("occurred_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("occurred_at <= ?", filter.To.UTC())
+	}
+	return query
+}
+
+func ledgerPredecessor(db *gorm.DB, entryID uint) (*models.VisitLedgerEntry, error) {
+	var previous models.VisitLedgerEntry
+	err := db.Where("id < ?", entryID).Order("id DESC").First(&previous).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+func brokenLedger(result *models.LedgerVerification, entry *models.VisitLedgerEntry, reason string) *models.LedgerVerification {
+	id := entry.ID
+	result.Valid = false
+	result.BrokenEntryID = &id
+	result.Reason = reason
+	return result
+}
+
+// ledgerEntryHash is SHA-256 over the previous hash and every recorded field of the entry
+func ledgerEntryHash(entry *models.VisitLedgerEntry) string {
+	taskID := ""
+	if entry.TaskID != nil {
+		taskID = strconv.FormatUint(uint64(*entry.TaskID), 10)
+	}
+	data := fmt.Sprintf("%s|%d|%s|%s|%d|%s|%s",
+		entry.PrevHash,
+		entry.ScheduleID,
+		taskID,
+		entry.EventType,
+		entry.ActorID,
+		entry.OccurredAt.UTC().Format(time.RFC3339Nano),
+		entry.Payload,
+	)
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/utils"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPingVisitNotInProgress = errors.New("location pings are only accepted while the visit is in progress")
+	ErrPingInvalidCoordinates = errors.New("invalid latitude or longitude")
+	ErrPingInvalidTime        = errors.New("ping recorded_at is outside the visit")
+)
+
+// pingClockSkew is how far ahead of the server clock a device timestamp may be
+const pingClockSkew = 2 * time.Minute
+
+// GeofenceSettings controls the geofence radius and how long a caregiver may be off the premises
+type GeofenceSettings struct {
+	RadiusMeters float64
+	MaxExcursion time.Duration
+}
+
+// RecordLocationPings stores a batch of breadcrumbs for an in-progress visit and refreshes the
+// visit's geofence flag. Pings re-sent after a flaky upload are ignored via the (schedule, time) key.
+func RecordLocationPings(db *gorm.DB, schedule *models.Schedule, inputs []models.LocationPingInput, settings GeofenceSettings) (int, error) {
+	if schedule.Status != models.SCHEDULE_STATUS_IN_PROGRESS || schedule.StartTime == nil {
+		return 0, ErrPingVisitNotInProgress
+	}
+
+	now := time.Now()
+	pings := make([]models.LocationPing, 0, len(inputs))
+	for _, input := range inputs {
+		if !utils.ValidCoordinates(input.Latitude, input.Longitude) {
+			return 0, ErrPingInvalidCoordinates
+		}
+		recordedAt := now
+		if input.RecordedAt != nil {
+			recordedAt = *input.RecordedAt
+		}
+		if recordedAt.Before(*schedule.StartTime) || recordedAt.After(now.Add(pingClockSkew)) {
+			return 0, ErrPingInvalidTime
+		}
+		pings = append(pings, models.LocationPing{
+			ScheduleID: schedule.ID,
+			RecordedAt: recordedAt.UTC().Truncate(time.Millisecond),
+			Latitude:   input.Latitude,
+			Longitude:  input.Longitude,
+			AccuracyM:  input.AccuracyM,
+		})
+	}
+
+	var stored int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&pings, 100)
+		if result.Error != nil {
+			return result.Error
+		}
+		stored = result.RowsAffected
+		return refreshGeofenceFlag(tx, schedule, settings)
+	})
+	return int(stored), err
+}
+
+// GetLocationPings returns a visit's breadcrumbs in the order they were recorded
+func GetLocationPings(db *gorm.DB, scheduleID uint) ([]models.LocationPing, error) {
+	var pings []models.LocationPing
+	err := db.Where("schedule_id = ?", scheduleID).Order("recorded_at ASC").Find(&pings).Error
+	return pings, err
+}
+
+// GeofenceCenter is the client's geocoded address, falling back to where the visit was started
+func GeofenceCenter(db *gorm.DB, schedule *models.Schedule) (*float64, *float64) {
+	if schedule.ClientID != nil {
+		var client models.Client
+		if err := db.Select("latitude", "longitude").First(&client, *schedule.ClientID).Error; err == nil &&
+			client.Latitude != nil && client.Longitude != nil {
+			return client.Latitude, client.Longitude
+		}
+	}
+	return schedule.StartLat, schedule.StartLon
+}
+
+// GetGeofenceSummary loads a visit's trail and measures it against the geofence
+func GetGeofenceSummary(db *gorm.DB, schedule *models.Schedule, settings GeofenceSettings) (*models.GeofenceSummary, []models.LocationPing, error) {
+	pings, err := GetLocationPings(db, schedule.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	lat, lon := GeofenceCenter(db, schedule)
+	return SummarizeGeofence(schedule.ID, pings, lat, lon, settings), pings, nil
+}
+
+// SummarizeGeofence walks time-ordered pings and splits the visit into time inside and outside
+// the geofence. Any excursion at least settings.MaxExcursion long flags the visit.
+func SummarizeGeofence(scheduleID uint, pings []models.LocationPing, centerLat, centerLon *float64, settings GeofenceSettings) *models.GeofenceSummary {
+	summary := &models.GeofenceSummary{
+		ScheduleID:   scheduleID,
+		CenterLat:    centerLat,
+		CenterLon:    centerLon,
+		RadiusMeters: settings.RadiusMeters,
+		PingCount:    len(pings),
+		Excursions:   []models.GeofenceExcursion{},
+	}
+	if len(pings) == 0 {
+		return summary
+	}
+	first, last := pings[0].RecordedAt, pings[len(pings)-1].RecordedAt
+	summary.FirstPingAt, summary.LastPingAt = &first, &last
+	if centerLat == nil || centerLon == nil {
+		return summary
+	}
+
+	var current *models.GeofenceExcursion
+	for i, ping := range pings {
+		distance := utils.DistanceMeters(*centerLat, *centerLon, ping.Latitude, ping.Longitude)
+		inside := distance <= settings.RadiusMeters
+
+		if inside && current != nil {
+			current.EndedAt = ping.RecordedAt
+			current.Returned = true
+			summary.Excursions = append(summary.Excursions, *current)
+			current = nil
+		} else if !inside {
+			if current == nil {
+				current = &models.GeofenceExcursion{StartedAt: ping.RecordedAt}
+			}
+			if distance > current.MaxDistanceMeters {
+				current.MaxDistanceMeters = distance
+			}
+		}
+
+		if i+1 < len(pings) {
+			seconds := int64(pings[i+1].RecordedAt.Sub(ping.RecordedAt).Seconds())
+			if inside {
+				summary.InsideSeconds += seconds
+			} else {
+				summary.OutsideSeconds += seconds
+			}
+		}
+	}
+	if current != nil {
+		current.EndedAt = last
+		summary.Excursions = append(summary.Excursions, *current)
+	}
+
+	for i := range summary.Excursions {
+		excursion := &summary.Excursions[i]
+		excursion.DurationSeconds = int64(excursion.EndedAt.Sub(excursion.StartedAt).Seconds())
+		if settings.MaxExcursion > 0 && excursion.EndedAt.Sub(excursion.StartedAt) >= settings.MaxExcursion {
+			summary.Flagged = true
+		}
+	}
+	return summary
+}
+
+func refreshGeofenceFlag(db *gorm.DB, schedule *models.Schedule, settings GeofenceSettings) error {
+	summary, _, err := GetGeofenceSummary(db, schedule, settings)
+	if err != nil {
+		return err
+	}
+	if summary.Flagged == schedule.GeofenceFlagged {
+		return nil
+	}
+	schedule.GeofenceFlagged = summary.Flagged
+	return db.Model(&models.Schedule{}).Where("id = ?", schedule.ID).Update("geofence_flagged", summary.Flagged).Error
+}
+
+// ListGeofenceFlaggedSchedules returns visits where the caregiver left the premises for too long
+func ListGeofenceFlaggedSchedules(db *gorm.DB, from, to *time.Time) ([]models.Schedule, error) {
+	query := db.Where("geofence_flagged = ?", true)
+	if from != nil {
+		query = query.Where("shift_time >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("shift_time <= ?", *to)
+	}
+	var schedules []models.Schedule
+	err := query.Order("shift_time DESC").Find(&schedules).Error
+	return schedules, err
+}
+
+// BuildTrailGeoJSON renders a visit's trail as a GeoJSON FeatureCollection: the path as a
+// LineString, each ping as a Point, and the geofence center (if known) with its radius.
+// GeoJSON positions are [longitude, latitude].
+func BuildTrailGeoJSON(schedule *models.Schedule, pings []models.LocationPing, summary *models.GeofenceSummary) models.GeoJSONFeatureCollection {
+	collection := models.GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []models.GeoJSONFeature{}}
+
+	if len(pings) > 0 {
+		line := make([][]float64, 0, len(pings))
+		times := make([]time.Time, 0, len(pings))
+		for _, ping := range pings {
+			line = append(line, []float64{ping.Longitude, ping.Latitude})
+			times = append(times, ping.RecordedAt)
+		}
+		collection.Features = append(collection.Features, models.GeoJSONFeature{
+			Type:     "Feature",
+			Geometry: models.GeoJSONGeometry{Type: "LineString", Coordinates: line},
+			Properties: map[string]interface{}{
+				"kind":        "trail",
+				"schedule_id": schedule.ID,
+				"user_id":     schedule.UserID,
+				"coordTimes":  times,
+				"flagged":     summary.Flagged,
+			},
+		})
+	}
+
+	for _, ping := range pings {
+		properties := map[string]interface{}{
+			"kind":        "ping",
+			"recorded_at": ping.RecordedAt,
+		}
+		if ping.AccuracyM != nil {
+			properties["accuracy_m"] = *ping.AccuracyM
+		}
+		if summary.CenterLat != nil && summary.CenterLon != nil {
+			distance := utils.DistanceMeters(*summary.CenterLat, *summary.CenterLon, ping.Latitude, ping.Longitude)
+			properties["distance_m"] = distance
+			properties["inside_geofence"] = distance <= summary.RadiusMeters
+		}
+		collection.Features = append(collection.Features, models.GeoJSONFeature{
+			Type:       "Feature",
+			Geometry:   models.GeoJSONGeometry{Type: "Point", Coordinates: []float64{ping.Longitude, ping.Latitude}},
+			Properties: properties,
+		})
+	}
+
+	if summary.CenterLat != nil && summary.CenterLon != nil {
+		collection.Features = append(collection.Features, models.GeoJSONFeature{
+			Type:     "Feature",
+			Geometry: models.GeoJSONGeometry{Type: "Point", Coordinates: []float64{*summary.CenterLon, *summary.CenterLat}},
+			Properties: map[string]interface{}{
+				"kind":          "geofence",
+				"radius_meters": summary.RadiusMeters,
+			},
+		})
+	}
+	return collection
+}
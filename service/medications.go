@@ -0,0 +1,454 @@
+package service
+
+import (
+	"bytes"
+	"caregiver-shift-tracker/models"
+	"errors"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMedicationRoute        = errors.New("unknown medication route")
+	ErrMedicationTimezone     = errors.New("unknown timezone")
+	ErrMedicationDates        = errors.New("end_date must not be before start_date")
+	ErrAdministrationRecorded = errors.New("this dose has already been recorded")
+	ErrAdministrationReason   = errors.New("a reason is required when a dose is refused or held")
+	ErrMedicationTask         = errors.New("medication tasks are recorded through the medication administration record")
+)
+
+// BuildMedicationOrder validates a request and fills in an order. defaultTZ is used when the
+// request has no timezone.
+func BuildMedicationOrder(order *models.MedicationOrder, req models.MedicationOrderRequest, defaultTZ *time.Location) error {
+	route := strings.ToLower(req.Route)
+	if !containsString(models.MedicationRoutes, route) {
+		return ErrMedicationRoute
+	}
+	tz := req.Timezone
+	if tz == "" {
+		tz = defaultTZ.String()
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return ErrMedicationTimezone
+	}
+	start, _ := time.ParseInLocation("2006-01-02", req.StartDate, loc)
+	var end *time.Time
+	if req.EndDate != "" {
+		e, _ := time.ParseInLocation("2006-01-02", req.EndDate, loc)
+		if e.Before(start) {
+			return ErrMedicationDates
+		}
+		end = &e
+	}
+
+	times := append([]string(nil), req.AdminTimes...)
+	sort.Strings(times)
+	order.DrugName = req.DrugName
+	order.Dose = req.Dose
+	order.Route = route
+	order.AdminTimes = strings.Join(dedupeStrings(times), ",")
+	order.Timezone = loc.String()
+	order.StartDate = start
+	order.EndDate = end
+	order.Instructions = req.Instructions
+	order.PrescribedBy = req.PrescribedBy
+	return nil
+}
+
+// CreateMedicationOrder saves an order and plans its doses on the client's upcoming visits
+func CreateMedicationOrder(db *gorm.DB, order *models.MedicationOrder) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		return replanMedicationOrder(tx, order)
+	})
+}
+
+// UpdateMedicationOrder saves changes and re-plans pending doses on upcoming visits
+func UpdateMedicationOrder(db *gorm.DB, order *models.MedicationOrder) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(order).Error; err != nil {
+			return err
+		}
+		return replanMedicationOrder(tx, order)
+	})
+}
+
+// ListMedicationOrders returns a client's orders, active first
+func ListMedicationOrders(db *gorm.DB, clientID uint) ([]models.MedicationOrder, error) {
+	var orders []models.MedicationOrder
+	err := db.Where("client_id = ?", clientID).Order("active DESC, drug_name ASC").Find(&orders).Error
+	return orders, err
+}
+
+// GetMedicationOrderByID retrieves a single order
+func GetMedicationOrderByID(db *gorm.DB, orderID uint) (*models.MedicationOrder, error) {
+	var order models.MedicationOrder
+	err := db.First(&order, "id = ?", orderID).Error
+	return &order, err
+}
+
+// GetScheduleAdministrations returns the doses planned on a visit
+func GetScheduleAdministrations(db *gorm.DB, scheduleID uint) ([]models.MedicationAdministration, error) {
+	var administrations []models.MedicationAdministration
+	err := db.Preload("Order").Where("schedule_id = ?", scheduleID).Order("scheduled_for ASC").Find(&administrations).Error
+	return administrations, err
+}
+
+// GetAdministrationByID retrieves a single planned dose with its order
+func GetAdministrationByID(db *gorm.DB, administrationID uint) (*models.MedicationAdministration, error) {
+	var administration models.MedicationAdministration
+	err := db.Preload("Order").First(&administration, "id = ?", administrationID).Error
+	return &administration, err
+}
+
+// PlanMedicationTasks creates a medication task and planned dose for every active order time
+// that falls inside the visit window (shift time plus planned duration). Doses already planned
+// are left alone, so this is safe to call again.
+func PlanMedicationTasks(db *gorm.DB, schedule *models.Schedule) ([]models.Task, error) {
+	if schedule.ClientID == nil {
+		return nil, nil
+	}
+	var orders []models.MedicationOrder
+	if err := db.Where("client_id = ? AND active = ?", *schedule.ClientID, true).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+
+	var tasks []models.Task
+	for i := range orders {
+		created, err := planOrderOnSchedule(db, &orders[i], schedule)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, created...)
+	}
+	return tasks, nil
+}
+
+func planOrderOnSchedule(db *gorm.DB, order *models.MedicationOrder, schedule *models.Schedule) ([]models.Task, error) {
+	var tasks []models.Task
+	for _, dose := range dosesInWindow(order, schedule.ShiftTime, visitDuration(schedule)) {
+		var count int64
+		if err := db.Model(&models.MedicationAdministration{}).
+			Where("order_id = ? AND scheduled_for = ?", order.ID, dose).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			continue
+		}
+
+		loc := orderLocation(order)
+		task := models.Task{
+			ScheduleID:  schedule.ID,
+			Description: truncate(fmt.Sprintf("Give %s %s (%s) at %s", order.DrugName, order.Dose, order.Route, dose.In(loc).Format("15:04")), 200),
+			Status:      models.TASK_STATUS_NOT_COMPLETED,
+			Kind:        models.TASK_KIND_MEDICATION,
+			TargetTime:  &dose,
+		}
+		if err := db.Create(&task).Error; err != nil {
+			return nil, err
+		}
+		administration := models.MedicationAdministration{
+			OrderID:      order.ID,
+			ClientID:     order.ClientID,
+			ScheduleID:   schedule.ID,
+			TaskID:       task.ID,
+			ScheduledFor: dose,
+			Status:       models.MED_ADMIN_STATUS_PENDING,
+		}
+		if err := db.Create(&administration).Error; err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// replanMedicationOrder drops pending doses of the order on visits that have not started and
+// plans them again from the current order, so edits and discontinuations carry forward
+func replanMedicationOrder(db *gorm.DB, order *models.MedicationOrder) error {
+	var pending []models.MedicationAdministration
+	err := db.Joins("JOIN schedules ON schedules.id = medication_administrations.schedule_id").
+		Where("medication_administrations.order_id = ? AND medication_administrations.status = ? AND schedules.status = ?",
+			order.ID, models.MED_ADMIN_STATUS_PENDING, models.SCHEDULE_STATUS_SCHEDULED).
+		Find(&pending).Error
+	if err != nil {
+		return err
+	}
+	for _, administration := range pending {
+		if err := db.Delete(&models.Task{}, "id = ?", administration.TaskID).Error; err != nil {
+			return err
+		}
+		if err := db.Delete(&administration).Error; err != nil {
+			return err
+		}
+	}
+	if !order.Active {
+		return nil
+	}
+
+	var schedules []models.Schedule
+	err = db.Where("client_id = ? AND status = ? AND shift_time >= ?", order.ClientID, models.SCHEDULE_STATUS_SCHEDULED, time.Now().Add(-24*time.Hour)).
+		Find(&schedules).Error
+	if err != nil {
+		return err
+	}
+	for i := range schedules {
+		if _, err := planOrderOnSchedule(db, order, &schedules[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordAdministration stores what happened to a dose and mirrors it onto the medication task
+func RecordAdministration(db *gorm.DB, administration *models.MedicationAdministration, req models.MedicationAdministrationRequest, recordedBy uint) error {
+	if administration.Status != models.MED_ADMIN_STATUS_PENDING && administration.Status != models.MED_ADMIN_STATUS_MISSED {
+		return ErrAdministrationRecorded
+	}
+	if req.Status != models.MED_ADMIN_STATUS_GIVEN && (req.Reason == nil || strings.TrimSpace(*req.Reason) == "") {
+		return ErrAdministrationReason
+	}
+
+	now := time.Now()
+	administeredAt := now
+	if req.AdministeredAt != nil {
+		administeredAt = *req.AdministeredAt
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		administration.Status = req.Status
+		administration.Reason = req.Reason
+		administration.RecordedBy = &recordedBy
+		if req.Status == models.MED_ADMIN_STATUS_GIVEN {
+			administration.AdministeredAt = &administeredAt
+		}
+		if err := tx.Omit("Order").Save(administration).Error; err != nil {
+			return err
+		}
+
+		var task models.Task
+		if err := tx.First(&task, "id = ?", administration.TaskID).Error; err != nil {
+			return err
+		}
+		if req.Status == models.MED_ADMIN_STATUS_GIVEN {
+			return UpdateTaskStatus(tx, &task, models.TASK_STATUS_COMPLETED, nil, &administeredAt)
+		}
+		reason := fmt.Sprintf("%s: %s", req.Status, *req.Reason)
+		return UpdateTaskStatus(tx, &task, models.TASK_STATUS_NOT_COMPLETED, &reason, nil)
+	})
+}
+
+// MarkMissedDoses flags pending doses whose time passed more than grace ago and opens a
+// critical alert for each one
+func MarkMissedDoses(db *gorm.DB, grace time.Duration, now time.Time) ([]models.Alert, error) {
+	var overdue []models.MedicationAdministration
+	err := db.Preload("Order").
+		Where("status = ? AND scheduled_for < ?", models.MED_ADMIN_STATUS_PENDING, now.Add(-grace)).
+		Find(&overdue).Error
+	if err != nil || len(overdue) == 0 {
+		return nil, err
+	}
+
+	alerts := make([]models.Alert, 0, len(overdue))
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, administration := range overdue {
+			result := tx.Model(&models.MedicationAdministration{}).
+				Where("id = ? AND status = ?", administration.ID, models.MED_ADMIN_STATUS_PENDING).
+				Update("status", models.MED_ADMIN_STATUS_MISSED)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			clientID, scheduleID, taskID := administration.ClientID, administration.ScheduleID, administration.TaskID
+			loc := orderLocation(&administration.Order)
+			alert := models.Alert{
+				Type:       models.ALERT_TYPE_MISSED_DOSE,
+				Severity:   models.ALERT_SEVERITY_CRITICAL,
+				Status:     models.ALERT_STATUS_OPEN,
+				ClientID:   &clientID,
+				ScheduleID: &scheduleID,
+				TaskID:     &taskID,
+				Message: truncate(fmt.Sprintf("Missed dose: %s %s due %s",
+					administration.Order.DrugName, administration.Order.Dose,
+					administration.ScheduledFor.In(loc).Format("2006-01-02 15:04 MST")), 255),
+			}
+			if err := tx.Create(&alert).Error; err != nil {
+				return err
+			}
+			alerts = append(alerts, alert)
+		}
+		return nil
+	})
+	return alerts, err
+}
+
+// BuildMARGrid lays out a client's doses for the month containing monthStart (in loc) as one
+// row per order and administration time, with a cell per day of the month
+func BuildMARGrid(db *gorm.DB, client *models.Client, monthStart time.Time, loc *time.Location) (*models.MARGrid, error) {
+	monthStart = time.Date(monthStart.Year(), monthStart.Month(), 1, 0, 0, 0, 0, loc)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	days := monthEnd.AddDate(0, 0, -1).Day()
+
+	var administrations []models.MedicationAdministration
+	err := db.Preload("Order").
+		Where("client_id = ? AND scheduled_for >= ? AND scheduled_for < ?", client.ID, monthStart.UTC(), monthEnd.UTC()).
+		Order("scheduled_for ASC").
+		Find(&administrations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	grid := &models.MARGrid{
+		ClientID:    client.ID,
+		ClientName:  client.FullName,
+		Month:       monthStart.Format("2006-01"),
+		DaysInMonth: days,
+		Rows:        []models.MARRow{},
+	}
+	rowIndex := make(map[string]int)
+	for _, administration := range administrations {
+		local := administration.ScheduledFor.In(loc)
+		key := fmt.Sprintf("%d|%s", administration.OrderID, local.Format("15:04"))
+		index, ok := rowIndex[key]
+		if !ok {
+			index = len(grid.Rows)
+			rowIndex[key] = index
+			grid.Rows = append(grid.Rows, models.MARRow{
+				Order: administration.Order,
+				Time:  local.Format("15:04"),
+				Days:  make([]*models.MARCell, days),
+			})
+		}
+		grid.Rows[index].Days[local.Day()-1] = &models.MARCell{
+			AdministrationID: administration.ID,
+			Status:           administration.Status,
+			AdministeredAt:   administration.AdministeredAt,
+			RecordedBy:       administration.RecordedBy,
+			Reason:           administration.Reason,
+		}
+	}
+	sort.SliceStable(grid.Rows, func(i, j int) bool {
+		if grid.Rows[i].Order.DrugName != grid.Rows[j].Order.DrugName {
+			return grid.Rows[i].Order.DrugName < grid.Rows[j].Order.DrugName
+		}
+		return grid.Rows[i].Time < grid.Rows[j].Time
+	})
+	return grid, nil
+}
+
+var marStatusCodes = map[string]string{
+	models.MED_ADMIN_STATUS_PENDING: "·",
+	models.MED_ADMIN_STATUS_GIVEN:   "G",
+	models.MED_ADMIN_STATUS_REFUSED: "R",
+	models.MED_ADMIN_STATUS_HELD:    "H",
+	models.MED_ADMIN_STATUS_MISSED:  "M",
+}
+
+var marTemplate = template.Must(template.New("mar").Funcs(template.FuncMap{
+	"code": func(cell *models.MARCell) string {
+		if cell == nil {
+			return ""
+		}
+		return marStatusCodes[cell.Status]
+	},
+	"days": func(n int) []int {
+		days := make([]int, n)
+		for i := range days {
+			days[i] = i + 1
+		}
+		return days
+	},
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>MAR {{.ClientName}} {{.Month}}</title>
+<style>
+body{font-family:sans-serif;font-size:11px}
+table{border-collapse:collapse;width:100%}
+th,td{border:1px solid #444;padding:2px;text-align:center}
+td.med{text-align:left;white-space:nowrap}
+@page{size:landscape}
+</style></head><body>
+<h2>Medication Administration Record</h2>
+<p><strong>Client:</strong> {{.ClientName}} &nbsp; <strong>Month:</strong> {{.Month}}</p>
+<table>
+<tr><th>Medication</th><th>Time</th>{{range days .DaysInMonth}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><td class="med">{{.Order.DrugName}} {{.Order.Dose}} ({{.Order.Route}})</td><td>{{.Time}}</td>{{range .Days}}<td>{{code .}}</td>{{end}}</tr>
+{{else}}<tr><td colspan="33">No doses planned this month</td></tr>
+{{end}}</table>
+<p>G = given, R = refused, H = held, M = missed, · = not yet recorded</p>
+</body></html>
+`))
+
+// RenderMARGridHTML renders the grid as a printable HTML page
+func RenderMARGridHTML(grid *models.MARGrid) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := marTemplate.Execute(&buf, grid); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// dosesInWindow lists the order's dose times (UTC) between start and start+duration
+func dosesInWindow(order *models.MedicationOrder, start time.Time, duration time.Duration) []time.Time {
+	loc := orderLocation(order)
+	end := start.Add(duration)
+	localStart := start.In(loc)
+
+	var doses []time.Time
+	for day := time.Date(localStart.Year(), localStart.Month(), localStart.Day(), 0, 0, 0, 0, loc); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if day.Before(dateOnly(order.StartDate, loc)) || (order.EndDate != nil && day.After(dateOnly(*order.EndDate, loc))) {
+			continue
+		}
+		for _, hhmm := range strings.Split(order.AdminTimes, ",") {
+			clock, err := time.Parse("15:04", hhmm)
+			if err != nil {
+				continue
+			}
+			dose := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+			if !dose.Before(start) && dose.Before(end) {
+				doses = append(doses, dose.UTC())
+			}
+		}
+	}
+	return doses
+}
+
+func orderLocation(order *models.MedicationOrder) *time.Location {
+	loc, err := time.LoadLocation(order.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func visitDuration(schedule *models.Schedule) time.Duration {
+	if schedule.DurationMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(schedule.DurationMinutes) * time.Minute
+}
+
+// dateOnly re-reads a DATE column value as midnight in loc
+func dateOnly(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+func dedupeStrings(values []string) []string {
+	out := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
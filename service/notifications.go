@@ -0,0 +1,24 @@
+package service
+
+import (
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/utils"
+
+	"gorm.io/gorm"
+)
+
+// NotifyCustomerCare emails every customer care user. Delivery failures are logged per
+// recipient so one bad address does not stop the rest.
+func NotifyCustomerCare(db *gorm.DB, subject, body string) error {
+	var users []models.User
+	if err := db.Select("id", "email").Where("role_id = ?", models.ROLE_CUSTOMER_CARE).Find(&users).Error; err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := utils.SendEmail(user.Email, subject, body); err != nil {
+			logger.ErrorLogger.Printf("Failed to notify customer care user %d: %v", user.ID, err)
+		}
+	}
+	return nil
+}
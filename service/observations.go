@@ -0,0 +1,324 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrObservationInvalid wraps every validation failure for observation fields and values
+var ErrObservationInvalid = errors.New("invalid observation")
+
+func observationError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrObservationInvalid, fmt.Sprintf(format, args...))
+}
+
+// GetObservationFields returns a template's observation fields in display order
+func GetObservationFields(db *gorm.DB, templateID uint) ([]models.ObservationField, error) {
+	var fields []models.ObservationField
+	err := orderObservationFields(db).Where("task_template_id = ?", templateID).Find(&fields).Error
+	return fields, err
+}
+
+// SetObservationFields replaces a template's observation fields. Observations already
+// recorded keep their key, type and unit, so history stays readable after a change.
+func SetObservationFields(db *gorm.DB, templateID uint, inputs []models.ObservationFieldInput) ([]models.ObservationField, error) {
+	fields := make([]models.ObservationField, 0, len(inputs))
+	seen := make(map[string]bool, len(inputs))
+	for _, input := range inputs {
+		key := strings.ToLower(strings.TrimSpace(input.Key))
+		if seen[key] {
+			return nil, observationError("duplicate field key %q", key)
+		}
+		seen[key] = true
+		if input.MinValue != nil && input.MaxValue != nil && *input.MinValue > *input.MaxValue {
+			return nil, observationError("%s: min_value is greater than max_value", key)
+		}
+		if input.Type == models.OBSERVATION_TYPE_CHOICE && len(input.Choices) == 0 {
+			return nil, observationError("%s: choice fields need at least one choice", key)
+		}
+		if input.Type != models.OBSERVATION_TYPE_NUMBER && (input.MinValue != nil || input.MaxValue != nil || input.AlertBelow != nil || input.AlertAbove != nil) {
+			return nil, observationError("%s: ranges and numeric thresholds only apply to number fields", key)
+		}
+		fields = append(fields, models.ObservationField{
+			TaskTemplateID: templateID,
+			Key:            key,
+			Label:          input.Label,
+			Type:           input.Type,
+			Unit:           input.Unit,
+			Required:       input.Required,
+			MinValue:       input.MinValue,
+			MaxValue:       input.MaxValue,
+			Choices:        input.Choices,
+			AlertBelow:     input.AlertBelow,
+			AlertAbove:     input.AlertAbove,
+			AlertValues:    input.AlertValues,
+			SortOrder:      input.SortOrder,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_template_id = ?", templateID).Delete(&models.ObservationField{}).Error; err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			return nil
+		}
+		return tx.Create(&fields).Error
+	})
+	return fields, err
+}
+
+// BuildTaskObservations checks submitted values against the task's fields and turns them into
+// rows ready to save. Required fields must be present when requireAll is set (task completed).
+func BuildTaskObservations(fields []models.ObservationField, inputs []models.ObservationInput, task *models.Task, schedule *models.Schedule, recordedBy uint, recordedAt time.Time, requireAll bool) ([]models.TaskObservation, error) {
+	byKey := make(map[string]models.ObservationField, len(fields))
+	for _, field := range fields {
+		byKey[field.Key] = field
+	}
+
+	provided := make(map[string]bool, len(inputs))
+	observations := make([]models.TaskObservation, 0, len(inputs))
+	for _, input := range inputs {
+		key := strings.ToLower(strings.TrimSpace(input.Key))
+		field, ok := byKey[key]
+		if !ok {
+			return nil, observationError("unknown field %q", input.Key)
+		}
+		if provided[key] {
+			return nil, observationError("%s given more than once", key)
+		}
+		provided[key] = true
+
+		observation := models.TaskObservation{
+			TaskID:     task.ID,
+			ScheduleID: schedule.ID,
+			ClientID:   schedule.ClientID,
+			FieldID:    field.ID,
+			Key:        field.Key,
+			Type:       field.Type,
+			Unit:       field.Unit,
+			RecordedBy: recordedBy,
+			RecordedAt: recordedAt,
+		}
+		if err := setObservationValue(&observation, field, input.Value); err != nil {
+			return nil, err
+		}
+		observations = append(observations, observation)
+	}
+
+	if requireAll {
+		for _, field := range fields {
+			if field.Required && !provided[field.Key] {
+				return nil, observationError("%s is required", field.Key)
+			}
+		}
+	}
+	return observations, nil
+}
+
+func setObservationValue(observation *models.TaskObservation, field models.ObservationField, value interface{}) error {
+	switch field.Type {
+	case models.OBSERVATION_TYPE_NUMBER:
+		var number float64
+		switch v := value.(type) {
+		case float64:
+			number = v
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return observationError("%s must be a number", field.Key)
+			}
+			number = parsed
+		default:
+			return observationError("%s must be a number", field.Key)
+		}
+		if field.MinValue != nil && number < *field.MinValue {
+			return observationError("%s must be at least %g", field.Key, *field.MinValue)
+		}
+		if field.MaxValue != nil && number > *field.MaxValue {
+			return observationError("%s must be at most %g", field.Key, *field.MaxValue)
+		}
+		observation.NumberValue = &number
+	case models.OBSERVATION_TYPE_BOOLEAN:
+		b, ok := value.(bool)
+		if !ok {
+			return observationError("%s must be true or false", field.Key)
+		}
+		observation.BoolValue = &b
+	case models.OBSERVATION_TYPE_CHOICE:
+		s, ok := value.(string)
+		if !ok || !containsString(field.Choices, s) {
+			return observationError("%s must be one of: %s", field.Key, strings.Join(field.Choices, ", "))
+		}
+		observation.TextValue = &s
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return observationError("%s must be text", field.Key)
+		}
+		observation.TextValue = &s
+	}
+	return nil
+}
+
+// ObservationAlertMessage returns why an observation crosses its field's alert thresholds,
+// or "" when it does not
+func ObservationAlertMessage(field models.ObservationField, observation models.TaskObservation) string {
+	label := field.Label
+	switch {
+	case observation.NumberValue != nil:
+		value := *observation.NumberValue
+		if field.AlertAbove != nil && value > *field.AlertAbove {
+			return fmt.Sprintf("%s %g%s is above %g", label, value, unitSuffix(field.Unit), *field.AlertAbove)
+		}
+		if field.AlertBelow != nil && value < *field.AlertBelow {
+			return fmt.Sprintf("%s %g%s is below %g", label, value, unitSuffix(field.Unit), *field.AlertBelow)
+		}
+	case observation.BoolValue != nil:
+		if containsString(field.AlertValues, strconv.FormatBool(*observation.BoolValue)) {
+			return fmt.Sprintf("%s: %t", label, *observation.BoolValue)
+		}
+	case observation.TextValue != nil && field.Type == models.OBSERVATION_TYPE_CHOICE:
+		if containsString(field.AlertValues, *observation.TextValue) {
+			return fmt.Sprintf("%s: %s", label, *observation.TextValue)
+		}
+	}
+	return ""
+}
+
+// SaveTaskStatus updates a task's status and stores its observations in one transaction,
+// raising an alert for every observation that crosses a threshold
+func SaveTaskStatus(db *gorm.DB, task *models.Task, status string, reason *string, completedAt *time.Time, fields []models.ObservationField, observations []models.TaskObservation) ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		alerts, err = saveTaskStatus(tx, task, status, reason, completedAt, fields, observations)
+		return err
+	})
+	return alerts, err
+}
+
+// TaskStatusChange is one validated status change in a bulk update
+type TaskStatusChange struct {
+	Task         *models.Task
+	Status       string
+	Reason       *string
+	CompletedAt  *time.Time
+	Fields       []models.ObservationField
+	Observations []models.TaskObservation
+}
+
+// SaveTaskStatuses applies several status changes in one transaction; if any fails, none are
+// kept. The alerts raised for each change are returned in the same order as changes.
+func SaveTaskStatuses(db *gorm.DB, changes []TaskStatusChange) ([][]models.Alert, error) {
+	alerts := make([][]models.Alert, len(changes))
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i, change := range changes {
+			var err error
+			alerts[i], err = saveTaskStatus(tx, change.Task, change.Status, change.Reason, change.CompletedAt, change.Fields, change.Observations)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return alerts, err
+}
+
+func saveTaskStatus(tx *gorm.DB, task *models.Task, status string, reason *string, completedAt *time.Time, fields []models.ObservationField, observations []models.TaskObservation) ([]models.Alert, error) {
+	byID := make(map[uint]models.ObservationField, len(fields))
+	for _, field := range fields {
+		byID[field.ID] = field
+	}
+
+	if err := UpdateTaskStatus(tx, task, status, reason, completedAt); err != nil {
+		return nil, err
+	}
+	if len(observations) == 0 {
+		return nil, nil
+	}
+	if err := tx.Create(&observations).Error; err != nil {
+		return nil, err
+	}
+	var alerts []models.Alert
+	for _, observation := range observations {
+		message := ObservationAlertMessage(byID[observation.FieldID], observation)
+		if message == "" {
+			continue
+		}
+		taskID, scheduleID, observationID := observation.TaskID, observation.ScheduleID, observation.ID
+		alerts = append(alerts, models.Alert{
+			Type:          models.ALERT_TYPE_OBSERVATION,
+			Severity:      models.ALERT_SEVERITY_WARNING,
+			Status:        models.ALERT_STATUS_OPEN,
+			ClientID:      observation.ClientID,
+			ScheduleID:    &scheduleID,
+			TaskID:        &taskID,
+			ObservationID: &observationID,
+			Message:       truncate(message, 255),
+		})
+	}
+	if len(alerts) == 0 {
+		return nil, nil
+	}
+	if err := tx.Create(&alerts).Error; err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// ListObservations returns recorded observations, newest first
+func ListObservations(db *gorm.DB, filter models.ObservationFilter) ([]models.TaskObservation, error) {
+	query := db.Order("recorded_at DESC, id DESC")
+	if filter.ClientID != 0 {
+		query = query.Where("client_id = ?", filter.ClientID)
+	}
+	if filter.Key != "" {
+		query = query.Where("`key` = ?", strings.ToLower(filter.Key))
+	}
+	if filter.From != nil {
+		query = query.Where("recorded_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("recorded_at <= ?", *filter.To)
+	}
+	var observations []models.TaskObservation
+	err := query.Find(&observations).Error
+	return observations, err
+}
+
+// GetScheduleObservations returns the observations recorded during a visit
+func GetScheduleObservations(db *gorm.DB, scheduleID uint) ([]models.TaskObservation, error) {
+	var observations []models.TaskObservation
+	err := db.Where("schedule_id = ?", scheduleID).Order("recorded_at ASC, id ASC").Find(&observations).Error
+	return observations, err
+}
+
+func unitSuffix(unit string) string {
+	if unit == "" {
+		return ""
+	}
+	return " " + unit
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
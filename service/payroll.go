@@ -0,0 +1,604 @@
+package service
+
+import (
+	"bytes"
+	"caregiver-shift-tracker/models"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPayrollUnknownLayout   = errors.New("no payroll layout configured with this name")
+	ErrPayrollNoTimesheets    = errors.New("no approved timesheets are waiting to be exported for this period")
+	ErrPayrollAlreadyExported = errors.New("every approved timesheet in this period is already in a payroll batch; void the batch to export it again")
+	ErrPayrollBatchVoided     = errors.New("payroll batch has already been voided")
+)
+
+// defaultPayrollLayouts are used when PAYROLL_LAYOUT_FILE is not set. GENERIC carries every
+// element; ADP and PAYCHEX follow the column order of those systems' hours import files.
+var defaultPayrollLayouts = []models.PayrollLayout{
+	{
+		Name: "GENERIC",
+		Rows: models.PAYROLL_ROWS_CAREGIVER,
+		Fields: []models.PayrollLayoutField{
+			{Name: "BatchID", Element: models.PAYROLL_ELEMENT_BATCH_ID},
+			{Name: "EmployeeID", Element: models.PAYROLL_ELEMENT_EMPLOYEE_ID},
+			{Name: "CaregiverID", Element: models.PAYROLL_ELEMENT_CAREGIVER_ID},
+			{Name: "CaregiverName", Element: models.PAYROLL_ELEMENT_CAREGIVER_NAME},
+			{Name: "PeriodStart", Element: models.PAYROLL_ELEMENT_PERIOD_START},
+			{Name: "PeriodEnd", Element: models.PAYROLL_ELEMENT_PERIOD_END},
+			{Name: "RegularHours", Element: models.PAYROLL_ELEMENT_REGULAR_HOURS},
+			{Name: "OvertimeHours", Element: models.PAYROLL_ELEMENT_OVERTIME_HOURS},
+			{Name: "DoubleTimeHours", Element: models.PAYROLL_ELEMENT_DOUBLE_TIME_HOURS},
+			{Name: "TravelHours", Element: models.PAYROLL_ELEMENT_TRAVEL_HOURS},
+			{Name: "TotalHours", Element: models.PAYROLL_ELEMENT_TOTAL_HOURS},
+			{Name: "PayRate", Element: models.PAYROLL_ELEMENT_PAY_RATE},
+			{Name: "OvertimeRate", Element: models.PAYROLL_ELEMENT_OVERTIME_RATE},
+			{Name: "DoubleTimeRate", Element: models.PAYROLL_ELEMENT_DOUBLE_TIME_RATE},
+			{Name: "GrossPay", Element: models.PAYROLL_ELEMENT_GROSS_PAY},
+			{Name: "Mileage", Element: models.PAYROLL_ELEMENT_MILEAGE},
+			{Name: "MileageAmount", Element: models.PAYROLL_ELEMENT_MILEAGE_AMOUNT},
+			{Name: "VisitCount", Element: models.PAYROLL_ELEMENT_VISIT_COUNT},
+		},
+	},
+	{
+		Name: "ADP",
+		Rows: models.PAYROLL_ROWS_CAREGIVER,
+		Fields: []models.PayrollLayoutField{
+			{Name: "Co Code", Element: models.PAYROLL_ELEMENT_COMPANY_CODE},
+			{Name: "Batch ID", Element: models.PAYROLL_ELEMENT_BATCH_ID},
+			{Name: "File #", Element: models.PAYROLL_ELEMENT_EMPLOYEE_ID},
+			{Name: "Rate 1", Element: models.PAYROLL_ELEMENT_PAY_RATE},
+			{Name: "Reg Hours", Element: models.PAYROLL_ELEMENT_REGULAR_HOURS},
+			{Name: "O/T Hours", Element: models.PAYROLL_ELEMENT_OVERTIME_HOURS},
+			{Name: "Hours 3 Code", Element: models.PAYROLL_ELEMENT_CONSTANT, Value: "DT"},
+			{Name: "Hours 3 Amount", Element: models.PAYROLL_ELEMENT_DOUBLE_TIME_HOURS},
+			{Name: "Earnings 3 Code", Element: models.PAYROLL_ELEMENT_CONSTANT, Value: "MIL"},
+			{Name: "Earnings 3 Amount", Element: models.PAYROLL_ELEMENT_MILEAGE_AMOUNT},
+		},
+	},
+	{
+		Name: "PAYCHEX",
+		Rows: models.PAYROLL_ROWS_EARNING,
+		Fields: []models.PayrollLayoutField{
+			{Name: "Client ID", Element: models.PAYROLL_ELEMENT_COMPANY_CODE},
+			{Name: "Worker ID", Element: models.PAYROLL_ELEMENT_EMPLOYEE_ID},
+			{Name: "Pay Component", Element: models.PAYROLL_ELEMENT_EARNING_CODE},
+			{Name: "Rate", Element: models.PAYROLL_ELEMENT_EARNING_RATE},
+			{Name: "Hours", Element: models.PAYROLL_ELEMENT_EARNING_HOURS},
+			{Name: "Amount", Element: models.PAYROLL_ELEMENT_EARNING_AMOUNT},
+			{Name: "Line Date", Element: models.PAYROLL_ELEMENT_PERIOD_END, Layout: "01/02/2006"},
+		},
+		EarningCodes: map[string]string{
+			models.PAYROLL_EARNING_REGULAR:     "Hourly",
+			models.PAYROLL_EARNING_OVERTIME:    "Overtime",
+			models.PAYROLL_EARNING_DOUBLE_TIME: "Double Time",
+			models.PAYROLL_EARNING_MILEAGE:     "Mileage Reimb",
+		},
+	},
+}
+
+var knownPayrollElements = map[string]bool{
+	models.PAYROLL_ELEMENT_BATCH_ID: true, models.PAYROLL_ELEMENT_COMPANY_CODE: true, models.PAYROLL_ELEMENT_EMPLOYEE_ID: true,
+	models.PAYROLL_ELEMENT_CAREGIVER_ID: true, models.PAYROLL_ELEMENT_CAREGIVER_NAME: true, models.PAYROLL_ELEMENT_PERIOD_START: true,
+	models.PAYROLL_ELEMENT_PERIOD_END: true, models.PAYROLL_ELEMENT_REGULAR_HOURS: true, models.PAYROLL_ELEMENT_OVERTIME_HOURS: true,
+	models.PAYROLL_ELEMENT_DOUBLE_TIME_HOURS: true, models.PAYROLL_ELEMENT_TRAVEL_HOURS: true, models.PAYROLL_ELEMENT_TOTAL_HOURS: true,
+	models.PAYROLL_ELEMENT_PAY_RATE: true, models.PAYROLL_ELEMENT_OVERTIME_RATE: true, models.PAYROLL_ELEMENT_DOUBLE_TIME_RATE: true,
+	models.PAYROLL_ELEMENT_GROSS_PAY: true, models.PAYROLL_ELEMENT_MILEAGE: true, models.PAYROLL_ELEMENT_MILEAGE_AMOUNT: true,
+	models.PAYROLL_ELEMENT_VISIT_COUNT: true, models.PAYROLL_ELEMENT_CONSTANT: true,
+}
+
+var payrollEarningElements = map[string]bool{
+	models.PAYROLL_ELEMENT_EARNING_CODE: true, models.PAYROLL_ELEMENT_EARNING_HOURS: true,
+	models.PAYROLL_ELEMENT_EARNING_RATE: true, models.PAYROLL_ELEMENT_EARNING_AMOUNT: true,
+}
+
+// PayrollOptions carry the agency-wide settings a payroll export is priced with
+type PayrollOptions struct {
+	CompanyCode          string
+	OvertimeMultiplier   float64
+	DoubleTimeMultiplier float64
+	// MileageRate is paid per mile of travel between consecutive visits
+	MileageRate  float64
+	Location     *time.Location
+	DefaultRules models.LaborRuleSet
+	SpeedKmh     float64
+}
+
+// PayrollSkipped is a caregiver left out of an export, with the reason
+type PayrollSkipped struct {
+	UserID      uint   `json:"user_id"`
+	TimesheetID uint   `json:"timesheet_id"`
+	Reason      string `json:"reason"`
+}
+
+// payrollEarning is one earning code's hours and amount on an earning-per-row layout
+type payrollEarning struct {
+	code   string
+	hours  string
+	rate   string
+	amount string
+}
+
+// LoadPayrollLayouts returns the configured payroll layouts keyed by upper-case name
+func LoadPayrollLayouts(path string) (map[string]models.PayrollLayout, error) {
+	layouts := defaultPayrollLayouts
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read payroll layout file: %w", err)
+		}
+		if err := json.Unmarshal(raw, &layouts); err != nil {
+			return nil, fmt.Errorf("failed to parse payroll layout file: %w", err)
+		}
+	}
+
+	byName := make(map[string]models.PayrollLayout, len(layouts))
+	for _, layout := range layouts {
+		if err := validatePayrollLayout(layout); err != nil {
+			return nil, err
+		}
+		byName[strings.ToUpper(layout.Name)] = layout
+	}
+	return byName, nil
+}
+
+func validatePayrollLayout(layout models.PayrollLayout) error {
+	if layout.Name == "" {
+		return errors.New("payroll layout is missing a name")
+	}
+	if layout.Rows != models.PAYROLL_ROWS_CAREGIVER && layout.Rows != models.PAYROLL_ROWS_EARNING {
+		return fmt.Errorf("payroll layout %s: rows must be %q or %q", layout.Name, models.PAYROLL_ROWS_CAREGIVER, models.PAYROLL_ROWS_EARNING)
+	}
+	if utf8.RuneCountInString(layout.Delimiter) > 1 {
+		return fmt.Errorf("payroll layout %s: delimiter must be a single character", layout.Name)
+	}
+	if len(layout.Fields) == 0 {
+		return fmt.Errorf("payroll layout %s has no fields", layout.Name)
+	}
+	for _, field := range layout.Fields {
+		if payrollEarningElements[field.Element] {
+			if layout.Rows != models.PAYROLL_ROWS_EARNING {
+				return fmt.Errorf("payroll layout %s: %q needs rows %q", layout.Name, field.Element, models.PAYROLL_ROWS_EARNING)
+			}
+			continue
+		}
+		if !knownPayrollElements[field.Element] {
+			return fmt.Errorf("payroll layout %s: unknown element %q", layout.Name, field.Element)
+		}
+	}
+	return nil
+}
+
+// CreatePayrollBatch exports every approved timesheet of the pay period starting at
+// periodStart that is not already in a batch. Each caregiver's hours are split into regular,
+// overtime and double time under their labor rule set, priced at their hourly rate, and the
+// visits paid are recorded against the batch.
+func CreatePayrollBatch(db *gorm.DB, layout models.PayrollLayout, periodStart, periodEnd time.Time, opts PayrollOptions, createdBy uint) (*models.PayrollBatch, []PayrollSkipped, error) {
+	var sheets []models.Timesheet
+	err := db.Preload("Entries", func(db *gorm.DB) *gorm.DB { return db.Order("start_time ASC") }).
+		Where("period_start = ?", periodStart.UTC()).
+		Order("user_id ASC").
+		Find(&sheets).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	exported, err := exportedTimesheets(db, sheets)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		skipped         []PayrollSkipped
+		eligible        []models.Timesheet
+		alreadyExported bool
+	)
+	for _, sheet := range sheets {
+		switch {
+		case exported[sheet.ID] != "":
+			alreadyExported = true
+			skipped = append(skipped, PayrollSkipped{UserID: sheet.UserID, TimesheetID: sheet.ID, Reason: "already exported in batch " + exported[sheet.ID]})
+		case sheet.Status != models.TIMESHEET_STATUS_APPROVED:
+			skipped = append(skipped, PayrollSkipped{UserID: sheet.UserID, TimesheetID: sheet.ID, Reason: "timesheet is " + sheet.Status})
+		case len(sheet.Entries) == 0:
+			skipped = append(skipped, PayrollSkipped{UserID: sheet.UserID, TimesheetID: sheet.ID, Reason: "timesheet has no visits"})
+		default:
+			eligible = append(eligible, sheet)
+		}
+	}
+	if len(eligible) == 0 {
+		if alreadyExported {
+			return nil, skipped, ErrPayrollAlreadyExported
+		}
+		return nil, skipped, ErrPayrollNoTimesheets
+	}
+
+	var userIDs, timesheetIDs []uint
+	for _, sheet := range eligible {
+		userIDs = append(userIDs, sheet.UserID)
+		timesheetIDs = append(timesheetIDs, sheet.ID)
+	}
+	var users []models.User
+	if err := db.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, skipped, err
+	}
+	usersByID := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+
+	batch := models.PayrollBatch{
+		Layout:      strings.ToUpper(layout.Name),
+		PeriodStart: periodStart.UTC(),
+		PeriodEnd:   periodEnd.UTC(),
+		Status:      models.PAYROLL_BATCH_EXPORTED,
+		CreatedBy:   createdBy,
+	}
+	var visits []models.PayrollBatchVisit
+	for i := range eligible {
+		sheet := &eligible[i]
+		rules, err := GetLaborRuleSet(db, sheet.UserID, opts.DefaultRules)
+		if err != nil {
+			return nil, skipped, err
+		}
+		labor, err := TimesheetLabor(db, sheet, opts.Location, rules, opts.SpeedKmh)
+		if err != nil {
+			return nil, skipped, err
+		}
+		line := buildPayrollLine(usersByID[sheet.UserID], sheet, labor, opts)
+		batch.Lines = append(batch.Lines, line)
+		batch.GrossPay += line.GrossPay
+		batch.MileageAmount += line.MileageAmount
+		batch.VisitCount += line.VisitCount
+		for _, entry := range sheet.Entries {
+			visits = append(visits, models.PayrollBatchVisit{
+				ScheduleID:    entry.ScheduleID,
+				UserID:        sheet.UserID,
+				TimesheetID:   sheet.ID,
+				WorkedMinutes: entry.WorkedMinutes,
+			})
+		}
+	}
+	batch.CaregiverCount = len(batch.Lines)
+	batch.GrossPay = roundCents(batch.GrossPay)
+	batch.MileageAmount = roundCents(batch.MileageAmount)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Lock the timesheets so two exports of the same period cannot both include them
+		var locked []models.Timesheet
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", timesheetIDs).Find(&locked).Error; err != nil {
+			return err
+		}
+		again, err := exportedTimesheets(tx, locked)
+		if err != nil {
+			return err
+		}
+		if len(again) > 0 {
+			return ErrPayrollAlreadyExported
+		}
+
+		lines := batch.Lines
+		batch.Lines = nil
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+		batch.BatchCode = fmt.Sprintf("PR%s-%d", periodStart.Format("20060102"), batch.ID)
+		for i := range lines {
+			lines[i].BatchID = batch.ID
+		}
+		for i := range visits {
+			visits[i].BatchID = batch.ID
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&visits).Error; err != nil {
+			return err
+		}
+		batch.Lines, batch.Visits = lines, visits
+
+		content, err := encodePayrollFile(layout, &batch, opts)
+		if err != nil {
+			return err
+		}
+		batch.Content = string(content)
+		return tx.Model(&batch).Updates(map[string]interface{}{"batch_code": batch.BatchCode, "content": batch.Content}).Error
+	})
+	if err != nil {
+		return nil, skipped, err
+	}
+	return &batch, skipped, nil
+}
+
+// GetPayrollBatch loads a batch with its earnings lines and visits
+func GetPayrollBatch(db *gorm.DB, batchID uint) (*models.PayrollBatch, error) {
+	var batch models.PayrollBatch
+	err := db.Preload("Lines").Preload("Visits").First(&batch, "id = ?", batchID).Error
+	return &batch, err
+}
+
+// ListPayrollBatches lists batches newest first
+func ListPayrollBatches(db *gorm.DB, status string) ([]models.PayrollBatch, error) {
+	query := db.Order("created_at DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var batches []models.PayrollBatch
+	err := query.Find(&batches).Error
+	return batches, err
+}
+
+// ListPayrollBatchVisits returns which batches paid a visit or a caregiver's visits, newest first
+func ListPayrollBatchVisits(db *gorm.DB, scheduleID, userID uint) ([]models.PayrollBatchVisit, error) {
+	query := db.Order("id DESC")
+	if scheduleID != 0 {
+		query = query.Where("schedule_id = ?", scheduleID)
+	}
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	var visits []models.PayrollBatchVisit
+	err := query.Find(&visits).Error
+	return visits, err
+}
+
+// VoidPayrollBatch marks a batch as not sent to payroll so its timesheets can be exported again.
+// The batch, its lines and its visit records are kept for the audit trail.
+func VoidPayrollBatch(db *gorm.DB, batch *models.PayrollBatch, voidedBy uint, reason string) error {
+	if batch.Status == models.PAYROLL_BATCH_VOIDED {
+		return ErrPayrollBatchVoided
+	}
+	now := time.Now()
+	res := db.Model(&models.PayrollBatch{}).
+		Where("id = ? AND status = ?", batch.ID, models.PAYROLL_BATCH_EXPORTED).
+		Updates(map[string]interface{}{
+			"status":      models.PAYROLL_BATCH_VOIDED,
+			"voided_by":   voidedBy,
+			"voided_at":   now,
+			"void_reason": reason,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPayrollBatchVoided
+	}
+	batch.Status = models.PAYROLL_BATCH_VOIDED
+	batch.VoidedBy = &voidedBy
+	batch.VoidedAt = &now
+	batch.VoidReason = &reason
+	return nil
+}
+
+// SetPayrollProfile updates a caregiver's hourly rate and payroll employee ID
+func SetPayrollProfile(db *gorm.DB, user *models.User, req models.PayrollProfileRequest) error {
+	updates := map[string]interface{}{}
+	if req.HourlyRate != nil {
+		updates["hourly_rate"] = roundCents(*req.HourlyRate)
+	}
+	if req.PayrollEmployeeID != nil {
+		updates["payroll_employee_id"] = strings.TrimSpace(*req.PayrollEmployeeID)
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := db.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	return db.First(user, "id = ?", user.ID).Error
+}
+
+// exportedTimesheets maps timesheets already in a batch that has not been voided to that batch's code
+func exportedTimesheets(db *gorm.DB, sheets []models.Timesheet) (map[uint]string, error) {
+	exported := map[uint]string{}
+	if len(sheets) == 0 {
+		return exported, nil
+	}
+	ids := make([]uint, 0, len(sheets))
+	for _, sheet := range sheets {
+		ids = append(ids, sheet.ID)
+	}
+	var rows []struct {
+		TimesheetID uint
+		BatchCode   string
+	}
+	err := db.Table("payroll_lines").
+		Select("payroll_lines.timesheet_id, payroll_batches.batch_code").
+		Joins("JOIN payroll_batches ON payroll_batches.id = payroll_lines.batch_id").
+		Where("payroll_lines.timesheet_id IN ? AND payroll_batches.status = ?", ids, models.PAYROLL_BATCH_EXPORTED).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		exported[row.TimesheetID] = row.BatchCode
+	}
+	return exported, nil
+}
+
+func buildPayrollLine(user models.User, sheet *models.Timesheet, labor *models.LaborBreakdown, opts PayrollOptions) models.PayrollLine {
+	line := models.PayrollLine{
+		UserID:            sheet.UserID,
+		TimesheetID:       sheet.ID,
+		EmployeeID:        user.PayrollEmployeeID,
+		CaregiverName:     user.FullName,
+		VisitCount:        len(sheet.Entries),
+		RegularMinutes:    labor.RegularMinutes,
+		OvertimeMinutes:   labor.OvertimeMinutes,
+		DoubleTimeMinutes: labor.DoubleTimeMinutes,
+		TravelMinutes:     labor.TravelMinutes,
+		PayRate:           user.HourlyRate,
+		OvertimeRate:      roundCents(user.HourlyRate * opts.OvertimeMultiplier),
+		DoubleTimeRate:    roundCents(user.HourlyRate * opts.DoubleTimeMultiplier),
+		MileageMiles:      math.Round(labor.TravelMeters/metersPerMile*10) / 10,
+	}
+	if line.EmployeeID == "" {
+		line.EmployeeID = strconv.FormatUint(uint64(sheet.UserID), 10)
+	}
+	line.MileageAmount = roundCents(line.MileageMiles * opts.MileageRate)
+	line.GrossPay = roundCents(hours(line.RegularMinutes)*line.PayRate +
+		hours(line.OvertimeMinutes)*line.OvertimeRate +
+		hours(line.DoubleTimeMinutes)*line.DoubleTimeRate)
+	return line
+}
+
+const metersPerMile = 1609.344
+
+func encodePayrollFile(layout models.PayrollLayout, batch *models.PayrollBatch, opts PayrollOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if layout.Delimiter != "" {
+		w.Comma, _ = utf8.DecodeRuneInString(layout.Delimiter)
+	}
+	if !layout.NoHeader {
+		header := make([]string, len(layout.Fields))
+		for i, field := range layout.Fields {
+			header[i] = field.Name
+		}
+		if err := w.Write(header); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, line := range batch.Lines {
+		if layout.Rows == models.PAYROLL_ROWS_CAREGIVER {
+			if err := w.Write(renderPayrollRecord(layout, batch, line, nil, opts)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, earning := range payrollEarnings(layout, line) {
+			if err := w.Write(renderPayrollRecord(layout, batch, line, &earning, opts)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// payrollEarnings lists a line's non-zero earnings in REG, OT, DT, MILE order
+func payrollEarnings(layout models.PayrollLayout, line models.PayrollLine) []payrollEarning {
+	code := func(c string) string {
+		if renamed, ok := layout.EarningCodes[c]; ok {
+			return renamed
+		}
+		return c
+	}
+	var earnings []payrollEarning
+	add := func(c string, minutes int, rate float64) {
+		if minutes > 0 {
+			earnings = append(earnings, payrollEarning{
+				code:   code(c),
+				hours:  formatHours(minutes),
+				rate:   formatMoney(rate),
+				amount: formatMoney(roundCents(hours(minutes) * rate)),
+			})
+		}
+	}
+	add(models.PAYROLL_EARNING_REGULAR, line.RegularMinutes, line.PayRate)
+	add(models.PAYROLL_EARNING_OVERTIME, line.OvertimeMinutes, line.OvertimeRate)
+	add(models.PAYROLL_EARNING_DOUBLE_TIME, line.DoubleTimeMinutes, line.DoubleTimeRate)
+	if line.MileageAmount > 0 {
+		earnings = append(earnings, payrollEarning{code: code(models.PAYROLL_EARNING_MILEAGE), amount: formatMoney(line.MileageAmount)})
+	}
+	return earnings
+}
+
+func renderPayrollRecord(layout models.PayrollLayout, batch *models.PayrollBatch, line models.PayrollLine, earning *payrollEarning, opts PayrollOptions) []string {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	record := make([]string, len(layout.Fields))
+	for i, field := range layout.Fields {
+		var value string
+		switch field.Element {
+		case models.PAYROLL_ELEMENT_BATCH_ID:
+			value = batch.BatchCode
+		case models.PAYROLL_ELEMENT_COMPANY_CODE:
+			value = opts.CompanyCode
+		case models.PAYROLL_ELEMENT_EMPLOYEE_ID:
+			value = line.EmployeeID
+		case models.PAYROLL_ELEMENT_CAREGIVER_ID:
+			value = strconv.FormatUint(uint64(line.UserID), 10)
+		case models.PAYROLL_ELEMENT_CAREGIVER_NAME:
+			value = line.CaregiverName
+		case models.PAYROLL_ELEMENT_PERIOD_START:
+			value = formatPayrollDate(batch.PeriodStart, loc, field.Layout)
+		case models.PAYROLL_ELEMENT_PERIOD_END:
+			// The period end is exclusive; payroll systems expect the last day worked
+			value = formatPayrollDate(batch.PeriodEnd.In(loc).AddDate(0, 0, -1), loc, field.Layout)
+		case models.PAYROLL_ELEMENT_REGULAR_HOURS:
+			value = formatHours(line.RegularMinutes)
+		case models.PAYROLL_ELEMENT_OVERTIME_HOURS:
+			value = formatHours(line.OvertimeMinutes)
+		case models.PAYROLL_ELEMENT_DOUBLE_TIME_HOURS:
+			value = formatHours(line.DoubleTimeMinutes)
+		case models.PAYROLL_ELEMENT_TRAVEL_HOURS:
+			value = formatHours(line.TravelMinutes)
+		case models.PAYROLL_ELEMENT_TOTAL_HOURS:
+			value = formatHours(line.RegularMinutes + line.OvertimeMinutes + line.DoubleTimeMinutes)
+		case models.PAYROLL_ELEMENT_PAY_RATE:
+			value = formatMoney(line.PayRate)
+		case models.PAYROLL_ELEMENT_OVERTIME_RATE:
+			value = formatMoney(line.OvertimeRate)
+		case models.PAYROLL_ELEMENT_DOUBLE_TIME_RATE:
+			value = formatMoney(line.DoubleTimeRate)
+		case models.PAYROLL_ELEMENT_GROSS_PAY:
+			value = formatMoney(line.GrossPay)
+		case models.PAYROLL_ELEMENT_MILEAGE:
+			value = strconv.FormatFloat(line.MileageMiles, 'f', 1, 64)
+		case models.PAYROLL_ELEMENT_MILEAGE_AMOUNT:
+			value = formatMoney(line.MileageAmount)
+		case models.PAYROLL_ELEMENT_VISIT_COUNT:
+			value = strconv.Itoa(line.VisitCount)
+		case models.PAYROLL_ELEMENT_CONSTANT:
+			value = field.Value
+		case models.PAYROLL_ELEMENT_EARNING_CODE:
+			value = earning.code
+		case models.PAYROLL_ELEMENT_EARNING_HOURS:
+			value = earning.hours
+		case models.PAYROLL_ELEMENT_EARNING_RATE:
+			value = earning.rate
+		case models.PAYROLL_ELEMENT_EARNING_AMOUNT:
+			value = earning.amount
+		}
+		record[i] = value
+	}
+	return record
+}
+
+func formatPayrollDate(t time.Time, loc *time.Location, layout string) string {
+	if layout == "" {
+		layout = "2006-01-02"
+	}
+	return t.In(loc).Format(layout)
+}
+
+func hours(minutes int) float64 {
+	return float64(minutes) / 60
+}
+
+func formatHours(minutes int) string {
+	return strconv.FormatFloat(hours(minutes), 'f', 2, 64)
+}
+
+func formatMoney(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,693 @@
+package service
+
+import (
+	"bytes"
+	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/utils"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidReportSubscription = errors.New("invalid report subscription")
+	ErrReportDeliverySent        = errors.New("report delivery was already sent")
+	ErrReportDeliveryInProgress  = errors.New("report delivery is being attempted")
+	ErrReportNotSent             = errors.New("report was not sent")
+)
+
+// reportClaimLease is how long a worker holds a delivery it is attempting. An attempt cut short
+// by a restart is picked up again once the lease runs out.
+const reportClaimLease = 10 * time.Minute
+
+// reportEmailRows caps the rows shown in the email body; the attachment has them all
+const reportEmailRows = 50
+
+// ReportOptions are the settings report rendering and delivery take from configuration
+type ReportOptions struct {
+	AgencyName   string
+	OnTimeGrace  time.Duration
+	MileageRate  float64
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	Send         func(msg utils.EmailMessage) error
+}
+
+// ApplyReportSubscriptionRequest validates req and copies it onto sub, scheduling the next run
+// after now when the subscription is active
+func ApplyReportSubscriptionRequest(sub *models.ReportSubscription, req models.ReportSubscriptionRequest, defaultTimezone string, now time.Time) error {
+	timezone := strings.TrimSpace(req.Timezone)
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidReportSubscription, timezone)
+	}
+	cadence, err := ParseCadence(req.Cadence)
+	if err != nil {
+		return err
+	}
+	next := cadence.Next(now, loc)
+	if next.IsZero() {
+		return fmt.Errorf("%w: %q never matches a date", ErrInvalidCadence, req.Cadence)
+	}
+
+	filters := req.Filters
+	switch filters.GroupBy {
+	case "", models.ANALYTICS_GROUP_NONE, models.ANALYTICS_GROUP_CAREGIVER, models.ANALYTICS_GROUP_CLIENT, models.ANALYTICS_GROUP_DAY:
+	default:
+		return fmt.Errorf("%w: group_by must be none, caregiver, client or day", ErrInvalidReportSubscription)
+	}
+	switch filters.Interval {
+	case "", models.ANALYTICS_INTERVAL_DAY, models.ANALYTICS_INTERVAL_WEEK, models.ANALYTICS_INTERVAL_MONTH:
+	default:
+		return fmt.Errorf("%w: interval must be day, week or month", ErrInvalidReportSubscription)
+	}
+	if filters.Top < 0 || filters.Top > 50 {
+		return fmt.Errorf("%w: top must be between 1 and 50", ErrInvalidReportSubscription)
+	}
+
+	seen := map[string]bool{}
+	recipients := make([]string, 0, len(req.Recipients))
+	for _, email := range req.Recipients {
+		email = strings.ToLower(strings.TrimSpace(email))
+		if !seen[email] {
+			seen[email] = true
+			recipients = append(recipients, email)
+		}
+	}
+
+	sub.Name = req.Name
+	sub.ReportType = req.ReportType
+	sub.Format = req.Format
+	if sub.Format == "" {
+		sub.Format = models.REPORT_FORMAT_PDF
+	}
+	sub.Period = req.Period
+	if sub.Period == "" {
+		sub.Period = models.REPORT_PERIOD_PREVIOUS_WEEK
+	}
+	sub.Filters = filters
+	sub.Recipients = recipients
+	sub.Cadence = strings.Join(strings.Fields(req.Cadence), " ")
+	sub.Timezone = loc.String()
+	if req.Active != nil {
+		sub.Active = *req.Active
+	} else if sub.ID == 0 {
+		sub.Active = true
+	}
+	sub.NextRunAt = nil
+	if sub.Active {
+		sub.NextRunAt = &next
+	}
+	return nil
+}
+
+func SaveReportSubscription(db *gorm.DB, sub *models.ReportSubscription) error {
+	// Select("*") so switching a subscription off clears next_run_at
+	return db.Select("*").Save(sub).Error
+}
+
+func GetReportSubscriptionByID(db *gorm.DB, id uint) (*models.ReportSubscription, error) {
+	var sub models.ReportSubscription
+	err := db.First(&sub, "id = ?", id).Error
+	return &sub, err
+}
+
+func ListReportSubscriptions(db *gorm.DB) ([]models.ReportSubscription, error) {
+	var subs []models.ReportSubscription
+	err := db.Order("name ASC, id ASC").Find(&subs).Error
+	return subs, err
+}
+
+// DeleteReportSubscription removes a subscription together with its delivery history
+func DeleteReportSubscription(db *gorm.DB, sub *models.ReportSubscription) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("subscription_id = ?", sub.ID).Delete(&models.ReportDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(sub).Error
+	})
+}
+
+// ListReportDeliveries returns a subscription's most recent deliveries, newest first
+func ListReportDeliveries(db *gorm.DB, subscriptionID uint, limit int) ([]models.ReportDelivery, error) {
+	var deliveries []models.ReportDelivery
+	err := db.Where("subscription_id = ?", subscriptionID).Order("id DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func GetReportDeliveryByID(db *gorm.DB, id uint) (*models.ReportDelivery, error) {
+	var delivery models.ReportDelivery
+	err := db.First(&delivery, "id = ?", id).Error
+	return &delivery, err
+}
+
+// ReportPeriod returns the days a run at runAt reports on, as midnights in loc with the end
+// excluded: yesterday, the Monday to Sunday before this week, or last calendar month
+func ReportPeriod(period string, runAt time.Time, loc *time.Location) (time.Time, time.Time) {
+	local := runAt.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	switch period {
+	case models.REPORT_PERIOD_PREVIOUS_DAY:
+		return today.AddDate(0, 0, -1), today
+	case models.REPORT_PERIOD_PREVIOUS_MONTH:
+		first := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+		return first.AddDate(0, -1, 0), first
+	default:
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return monday.AddDate(0, 0, -7), monday
+	}
+}
+
+// RunDueReportSubscriptions starts a delivery for each active subscription whose run time has
+// come and attempts it. Runs missed while the server was down are caught up with one delivery.
+// It returns the number of deliveries started.
+func RunDueReportSubscriptions(db *gorm.DB, now time.Time, opts ReportOptions) (int, error) {
+	var subs []models.ReportSubscription
+	if err := db.Where("active = ? AND next_run_at <= ?", true, now).Find(&subs).Error; err != nil {
+		return 0, err
+	}
+	started := 0
+	for i := range subs {
+		sub := &subs[i]
+		delivery, err := startScheduledDelivery(db, sub, now)
+		if err != nil {
+			logger.ErrorLogger.Printf("Failed to start report subscription %d: %v", sub.ID, err)
+			continue
+		}
+		if delivery == nil {
+			continue
+		}
+		started++
+		if err := attemptReportDelivery(db, sub, delivery, now, opts); err != nil {
+			logger.ErrorLogger.Printf("Report delivery %d for subscription %d failed: %v", delivery.ID, sub.ID, err)
+		}
+	}
+	return started, nil
+}
+
+// RetryDueReportDeliveries attempts every delivery whose retry time has passed, along with any
+// whose attempt was interrupted. It returns the number attempted.
+func RetryDueReportDeliveries(db *gorm.DB, now time.Time, opts ReportOptions) (int, error) {
+	var deliveries []models.ReportDelivery
+	err := db.Where("status IN ? AND next_attempt_at <= ?", []string{models.REPORT_DELIVERY_PENDING, models.REPORT_DELIVERY_RETRYING}, now).
+		Order("next_attempt_at ASC").Find(&deliveries).Error
+	if err != nil {
+		return 0, err
+	}
+	attempted := 0
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		claimed, err := claimReportDelivery(db, delivery, now)
+		if err != nil {
+			return attempted, err
+		}
+		if !claimed {
+			continue
+		}
+		sub, err := GetReportSubscriptionByID(db, delivery.SubscriptionID)
+		if err != nil {
+			return attempted, err
+		}
+		attempted++
+		if err := attemptReportDelivery(db, sub, delivery, now, opts); err != nil {
+			logger.ErrorLogger.Printf("Report delivery %d for subscription %d failed again: %v", delivery.ID, sub.ID, err)
+		}
+	}
+	return attempted, nil
+}
+
+// RunReportSubscription delivers a subscription's report now for the period ending before
+// now, outside its cadence. The delivery is returned even when sending failed, along with the
+// error; it is then retried like a scheduled one.
+func RunReportSubscription(db *gorm.DB, sub *models.ReportSubscription, now time.Time, opts ReportOptions) (*models.ReportDelivery, error) {
+	loc, err := time.LoadLocation(sub.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	start, end := ReportPeriod(sub.Period, now, loc)
+	delivery := newReportDelivery(sub, models.REPORT_TRIGGER_MANUAL, start, end, loc, now)
+	if err := db.Create(delivery).Error; err != nil {
+		return nil, err
+	}
+	return delivery, attemptReportDelivery(db, sub, delivery, now, opts)
+}
+
+// RetryReportDelivery attempts a failed or retrying delivery once more straight away
+func RetryReportDelivery(db *gorm.DB, delivery *models.ReportDelivery, now time.Time, opts ReportOptions) error {
+	if delivery.Status == models.REPORT_DELIVERY_SENT {
+		return ErrReportDeliverySent
+	}
+	claimed, err := claimReportDelivery(db, delivery, now)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrReportDeliveryInProgress
+	}
+	sub, err := GetReportSubscriptionByID(db, delivery.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	return attemptReportDelivery(db, sub, delivery, now, opts)
+}
+
+// startScheduledDelivery moves the subscription on to its next run and records a delivery for
+// the run that came due. It returns nil when another worker got there first.
+func startScheduledDelivery(db *gorm.DB, sub *models.ReportSubscription, now time.Time) (*models.ReportDelivery, error) {
+	loc, err := time.LoadLocation(sub.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	cadence, err := ParseCadence(sub.Cadence)
+	if err != nil {
+		return nil, err
+	}
+	runAt := *sub.NextRunAt
+	next := cadence.Next(now, loc)
+
+	var delivery *models.ReportDelivery
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var nextRunAt *time.Time
+		if !next.IsZero() {
+			nextRunAt = &next
+		}
+		result := tx.Model(&models.ReportSubscription{}).
+			Where("id = ? AND active = ? AND next_run_at <= ?", sub.ID, true, now).
+			Updates(map[string]interface{}{"next_run_at": nextRunAt, "last_run_at": runAt})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		sub.NextRunAt, sub.LastRunAt = nextRunAt, &runAt
+
+		start, end := ReportPeriod(sub.Period, runAt, loc)
+		delivery = newReportDelivery(sub, models.REPORT_TRIGGER_SCHEDULE, start, end, loc, now)
+		return tx.Create(delivery).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// newReportDelivery returns a pending delivery already claimed by the caller
+func newReportDelivery(sub *models.ReportSubscription, trigger string, start, end time.Time, loc *time.Location, now time.Time) *models.ReportDelivery {
+	lease := now.Add(reportClaimLease)
+	return &models.ReportDelivery{
+		SubscriptionID: sub.ID,
+		Trigger:        trigger,
+		PeriodStart:    calendarDay(start, loc),
+		PeriodEnd:      calendarDay(end.AddDate(0, 0, -1), loc),
+		Recipients:     sub.Recipients,
+		Status:         models.REPORT_DELIVERY_PENDING,
+		NextAttemptAt:  &lease,
+	}
+}
+
+// claimReportDelivery takes a delivery for one attempt unless another worker holds it
+func claimReportDelivery(db *gorm.DB, delivery *models.ReportDelivery, now time.Time) (bool, error) {
+	lease := now.Add(reportClaimLease)
+	result := db.Model(&models.ReportDelivery{}).
+		Where("id = ? AND status <> ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", delivery.ID, models.REPORT_DELIVERY_SENT, now).
+		Update("next_attempt_at", lease)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	delivery.NextAttemptAt = &lease
+	return true, nil
+}
+
+// attemptReportDelivery renders and sends the report and records the outcome, returning
+// ErrReportNotSent when it failed. The next attempt then waits RetryBackoff, doubling each
+// time, until MaxAttempts is reached.
+func attemptReportDelivery(db *gorm.DB, sub *models.ReportSubscription, delivery *models.ReportDelivery, now time.Time, opts ReportOptions) error {
+	delivery.Attempts++
+	sendErr := sendReport(db, sub, delivery, now, opts)
+	if sendErr == nil {
+		sentAt := time.Now()
+		delivery.Status = models.REPORT_DELIVERY_SENT
+		delivery.SentAt = &sentAt
+		delivery.NextAttemptAt = nil
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = truncate(sendErr.Error(), 500)
+		maxAttempts := opts.MaxAttempts
+		if maxAttempts < 1 {
+			maxAttempts = 1
+		}
+		if delivery.Attempts >= maxAttempts {
+			delivery.Status = models.REPORT_DELIVERY_FAILED
+			delivery.NextAttemptAt = nil
+		} else {
+			retryAt := now.Add(opts.RetryBackoff << uint(delivery.Attempts-1))
+			delivery.Status = models.REPORT_DELIVERY_RETRYING
+			delivery.NextAttemptAt = &retryAt
+		}
+	}
+	if err := db.Select("*").Save(delivery).Error; err != nil {
+		return err
+	}
+	if sendErr != nil {
+		return fmt.Errorf("%w: %v", ErrReportNotSent, sendErr)
+	}
+	return nil
+}
+
+// sendReport builds the report for the delivery's period and emails it with the report
+// attached
+func sendReport(db *gorm.DB, sub *models.ReportSubscription, delivery *models.ReportDelivery, now time.Time, opts ReportOptions) error {
+	loc, err := time.LoadLocation(sub.Timezone)
+	if err != nil {
+		return err
+	}
+	start := localMidnight(delivery.PeriodStart, loc)
+	end := localMidnight(delivery.PeriodEnd, loc).AddDate(0, 0, 1)
+	table, err := buildReportTable(db, sub, start, end, loc, now, opts)
+	if err != nil {
+		return fmt.Errorf("failed to build report: %w", err)
+	}
+
+	attachment := utils.EmailAttachment{
+		Filename: fmt.Sprintf("%s_%s_%s.%s", sub.ReportType, delivery.PeriodStart.Format("2006-01-02"), delivery.PeriodEnd.Format("2006-01-02"), sub.Format),
+	}
+	if sub.Format == models.REPORT_FORMAT_CSV {
+		attachment.ContentType = "text/csv"
+		if attachment.Data, err = table.csv(); err != nil {
+			return fmt.Errorf("failed to render report: %w", err)
+		}
+	} else {
+		attachment.ContentType = "application/pdf"
+		attachment.Data = table.pdf(opts.AgencyName)
+	}
+	delivery.Filename = attachment.Filename
+
+	if opts.Send == nil {
+		return errors.New("no email sender configured")
+	}
+	msg := table.email(sub.Name, delivery.Recipients, attachment)
+	return opts.Send(msg)
+}
+
+// reportTable is a report laid out once for the CSV, PDF and email renderings
+type reportTable struct {
+	title   string
+	period  string
+	summary []string
+	header  []string
+	rows    [][]string
+}
+
+func buildReportTable(db *gorm.DB, sub *models.ReportSubscription, start, end time.Time, loc *time.Location, now time.Time, opts ReportOptions) (*reportTable, error) {
+	table := &reportTable{period: start.Format("2006-01-02") + " to " + end.AddDate(0, 0, -1).Format("2006-01-02")}
+	filter := models.AnalyticsFilter{From: start, To: end, Location: loc, UserID: sub.Filters.UserID, ClientID: sub.Filters.ClientID}
+
+	switch sub.ReportType {
+	case models.REPORT_TYPE_VISITS:
+		groupBy := sub.Filters.GroupBy
+		if groupBy == "" {
+			groupBy = models.ANALYTICS_GROUP_NONE
+		}
+		kpis, err := VisitKPIReport(db, filter, groupBy, opts.OnTimeGrace, now)
+		if err != nil {
+			return nil, err
+		}
+		table.title = "Visit KPIs"
+		table.header = []string{"Group", "Name", "Visits", "Completed", "Cancelled", "Missed", "Clock-ins", "On time", "On time %", "Late", "Avg late min"}
+		for _, row := range kpis {
+			name := row.Name
+			if row.Date != "" {
+				name = row.Date
+			}
+			table.rows = append(table.rows, []string{
+				row.Group, name, strconv.Itoa(row.Visits),
+				strconv.Itoa(row.ByStatus[models.SCHEDULE_STATUS_COMPLETED]), strconv.Itoa(row.ByStatus[models.SCHEDULE_STATUS_CANCELLED]),
+				strconv.Itoa(row.Missed), strconv.Itoa(row.ClockIns), strconv.Itoa(row.OnTimeClockIns),
+				formatFloat(row.OnTimePercent), strconv.Itoa(row.LateClockIns), formatFloat(row.AverageLateMinutes),
+			})
+		}
+
+	case models.REPORT_TYPE_TASKS:
+		top := sub.Filters.Top
+		if top == 0 {
+			top = 5
+		}
+		kpis, err := TaskKPIReport(db, filter, top)
+		if err != nil {
+			return nil, err
+		}
+		table.title = "Task completion"
+		table.summary = []string{
+			fmt.Sprintf("Tasks: %d", kpis.Tasks),
+			fmt.Sprintf("Completed: %d (%s%%)", kpis.Completed, formatFloat(kpis.CompletionRate)),
+			fmt.Sprintf("Not completed: %d, of which %d without a reason", kpis.NotCompleted, kpis.WithoutReason),
+		}
+		table.header = []string{"Reason not completed", "Tasks"}
+		for _, reason := range kpis.TopReasons {
+			table.rows = append(table.rows, []string{reason.Reason, strconv.Itoa(reason.Count)})
+		}
+
+	case models.REPORT_TYPE_MISSED_TREND:
+		interval := sub.Filters.Interval
+		if interval == "" {
+			interval = models.ANALYTICS_INTERVAL_DAY
+		}
+		points, err := MissedVisitTrend(db, filter, interval, now)
+		if err != nil {
+			return nil, err
+		}
+		table.title = "Missed visits by " + interval
+		table.header = []string{"Period start", "Due", "Missed", "Missed %"}
+		due, missed := 0, 0
+		for _, point := range points {
+			due += point.Due
+			missed += point.Missed
+			table.rows = append(table.rows, []string{point.PeriodStart, strconv.Itoa(point.Due), strconv.Itoa(point.Missed), formatFloat(point.MissedRate)})
+		}
+		if due > 0 {
+			table.summary = []string{fmt.Sprintf("%d of %d visits due were missed (%s%%)", missed, due, formatFloat(percent(missed, due)))}
+		}
+
+	case models.REPORT_TYPE_TRAVEL:
+		from, to := calendarDay(start, loc), calendarDay(end.AddDate(0, 0, -1), loc)
+		segments, err := ListTravelSegments(db, models.TravelFilter{UserID: sub.Filters.UserID, From: &from, To: &to})
+		if err != nil {
+			return nil, err
+		}
+		summaries := SummarizeTravel(segments, opts.MileageRate)
+		names, err := caregiverNames(db, summaries)
+		if err != nil {
+			return nil, err
+		}
+		table.title = "Travel and mileage"
+		table.header = []string{"Caregiver ID", "Caregiver", "Trips", "Miles", "Minutes", "Reimbursement"}
+		var miles, reimbursement float64
+		for _, summary := range summaries {
+			miles += summary.Miles
+			reimbursement += summary.Reimbursement
+			table.rows = append(table.rows, []string{
+				itoa(summary.UserID), names[summary.UserID], strconv.Itoa(summary.Segments), formatFloat(summary.Miles),
+				strconv.Itoa(summary.Minutes), formatMoney(summary.Reimbursement),
+			})
+		}
+		table.summary = []string{fmt.Sprintf("%s miles, %s reimbursement", formatFloat(roundTenth(miles)), formatMoney(roundCents(reimbursement)))}
+
+	case models.REPORT_TYPE_AUTHORIZATION_UTILIZATION:
+		// Authorizations are reported for the periods containing the last day covered
+		day := calendarDay(end.AddDate(0, 0, -1), loc)
+		report, err := AuthorizationUtilizationReport(db, models.AuthorizationFilter{ClientID: sub.Filters.ClientID, Date: day}, loc)
+		if err != nil {
+			return nil, err
+		}
+		table.title = "Authorization utilization"
+		table.period = "As of " + day.Format("2006-01-02")
+		table.header = []string{"Authorization", "Client", "Service", "Period", "From", "To", "Authorized", "Used", "Planned", "Remaining", "Used %"}
+		over := 0
+		for _, row := range report {
+			if row.OverAuthorized {
+				over++
+			}
+			number := row.AuthorizationNumber
+			if number == "" {
+				number = "#" + itoa(row.AuthorizationID)
+			}
+			table.rows = append(table.rows, []string{
+				number, row.ClientName, row.ServiceCode, row.Period, row.PeriodStart.Format("2006-01-02"), row.PeriodEnd.Format("2006-01-02"),
+				strconv.Itoa(row.AuthorizedUnits), strconv.Itoa(row.UsedUnits), strconv.Itoa(row.PlannedUnits),
+				strconv.Itoa(row.RemainingUnits), formatFloat(row.UtilizationPercent),
+			})
+		}
+		table.summary = []string{fmt.Sprintf("%d authorization(s), %d over their authorized units", len(report), over)}
+
+	default:
+		return nil, fmt.Errorf("%w: unknown report type %q", ErrInvalidReportSubscription, sub.ReportType)
+	}
+	return table, nil
+}
+
+func (t *reportTable) csv() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(t.header); err != nil {
+		return nil, err
+	}
+	if err := w.WriteAll(t.rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), w.Error()
+}
+
+// pdf lays the table out across the page, shrinking the font when the columns would not fit
+func (t *reportTable) pdf(agencyName string) []byte {
+	const (
+		left   = 50.0
+		right  = 562.0
+		top    = 742.0
+		bottom = 60.0
+		gap    = 8.0
+	)
+	size := 9.0
+	widths := make([]float64, len(t.header))
+	for i := range t.header {
+		widths[i] = utils.TextWidth(t.header[i], size)
+		for _, row := range t.rows {
+			if w := utils.TextWidth(row[i], size); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	total := gap * float64(len(widths)-1)
+	for _, w := range widths {
+		total += w
+	}
+	scale := 1.0
+	if total > right-left {
+		scale = (right - left) / total
+		size *= scale
+	}
+	lineHeight := size + 5
+	tableRow := func(pdf *utils.PDF, y float64, bold bool, cells []string) {
+		x := left
+		for i, cell := range cells {
+			pdf.Text(x, y, size, bold, cell)
+			x += (widths[i] + gap) * scale
+		}
+	}
+	tableHeader := func(pdf *utils.PDF, y float64) float64 {
+		tableRow(pdf, y, true, t.header)
+		pdf.Line(left, y-4, right, y-4)
+		return y - lineHeight - 4
+	}
+
+	pdf := utils.NewPDF()
+	y := top
+	pdf.Text(left, y, 16, true, t.title)
+	if agencyName != "" {
+		pdf.Text(right-utils.TextWidth(agencyName, 11), y, 11, true, agencyName)
+	}
+	y -= 24
+	for _, line := range append([]string{t.period}, t.summary...) {
+		pdf.Text(left, y, 10, false, line)
+		y -= 14
+	}
+	y -= 10
+	y = tableHeader(pdf, y)
+	for _, row := range t.rows {
+		if y < bottom {
+			pdf.AddPage()
+			y = tableHeader(pdf, top)
+		}
+		tableRow(pdf, y, false, row)
+		y -= lineHeight
+	}
+	if len(t.rows) == 0 {
+		pdf.Text(left, y, 10, false, "No data for this period.")
+	}
+	return pdf.Bytes()
+}
+
+// email summarises the report in the message body and attaches the full rendering
+func (t *reportTable) email(name string, recipients []string, attachment utils.EmailAttachment) utils.EmailMessage {
+	var text, body strings.Builder
+	fmt.Fprintf(&text, "%s\n%s\n\n", t.title, t.period)
+	fmt.Fprintf(&body, "<h2>%s</h2>\n<p>%s</p>\n", html.EscapeString(t.title), html.EscapeString(t.period))
+	if len(t.summary) > 0 {
+		body.WriteString("<ul>\n")
+		for _, line := range t.summary {
+			fmt.Fprintf(&text, "%s\n", line)
+			fmt.Fprintf(&body, "<li>%s</li>\n", html.EscapeString(line))
+		}
+		body.WriteString("</ul>\n")
+		text.WriteString("\n")
+	}
+
+	if len(t.rows) == 0 {
+		text.WriteString("No data for this period.\n")
+		body.WriteString("<p>No data for this period.</p>\n")
+	} else {
+		body.WriteString("<table border=\"1\" cellpadding=\"4\" cellspacing=\"0\" style=\"border-collapse:collapse\">\n<tr>")
+		for _, cell := range t.header {
+			fmt.Fprintf(&body, "<th>%s</th>", html.EscapeString(cell))
+		}
+		body.WriteString("</tr>\n")
+		for i, row := range t.rows {
+			if i == reportEmailRows {
+				break
+			}
+			body.WriteString("<tr>")
+			for _, cell := range row {
+				fmt.Fprintf(&body, "<td>%s</td>", html.EscapeString(cell))
+			}
+			body.WriteString("</tr>\n")
+		}
+		body.WriteString("</table>\n")
+		if len(t.rows) > reportEmailRows {
+			fmt.Fprintf(&body, "<p>Showing %d of %d rows.</p>\n", reportEmailRows, len(t.rows))
+		}
+		fmt.Fprintf(&text, "%d row(s) in the attached report.\n", len(t.rows))
+	}
+	fmt.Fprintf(&text, "\nAttachment: %s\n", attachment.Filename)
+	fmt.Fprintf(&body, "<p>The full report is attached as %s.</p>\n", html.EscapeString(attachment.Filename))
+
+	return utils.EmailMessage{
+		To:          recipients,
+		Subject:     fmt.Sprintf("%s: %s", name, t.period),
+		Text:        text.String(),
+		HTML:        body.String(),
+		Attachments: []utils.EmailAttachment{attachment},
+	}
+}
+
+func caregiverNames(db *gorm.DB, summaries []models.TravelSummary) (map[uint]string, error) {
+	names := map[uint]string{}
+	if len(summaries) == 0 {
+		return names, nil
+	}
+	ids := make([]uint, 0, len(summaries))
+	for _, summary := range summaries {
+		ids = append(ids, summary.UserID)
+	}
+	var users []models.User
+	if err := db.Select("id", "full_name").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		names[user.ID] = user.FullName
+	}
+	return names, nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
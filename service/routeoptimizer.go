@@ -0,0 +1,386 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/routing"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxRouteVisits bounds the candidates of one optimization; the travel matrix grows with the
+// square of it
+const MaxRouteVisits = 25
+
+var (
+	ErrRouteTooManyVisits = fmt.Errorf("at most %d visits can be optimized at once", MaxRouteVisits)
+	ErrRouteNoVisits      = errors.New("no scheduled visits to optimize")
+)
+
+// RouteOptions control a route optimization
+type RouteOptions struct {
+	Provider routing.Provider
+	// Fallback is used for legs the provider fails on
+	Fallback routing.Provider
+	// DefaultWindow is how far a visit without its own window may move from its shift_time
+	DefaultWindow time.Duration
+	// Origin is where the caregiver sets off from; travel from it counts toward the route
+	Origin *routing.Point
+}
+
+// routeVisit is a candidate visit as the optimizer sees it
+type routeVisit struct {
+	schedule *models.Schedule
+	point    routing.Point
+	planned  time.Time
+	earliest time.Time
+	latest   time.Time
+	duration time.Duration
+}
+
+// routeMatrix holds travel between candidates; index len(visits) is the origin when set
+type routeMatrix [][]routing.Route
+
+// routeScore orders routes: fewer late minutes first, then less travel time, then distance
+type routeScore struct {
+	late    int
+	minutes int
+	meters  float64
+}
+
+func (a routeScore) better(b routeScore) bool {
+	if a.late != b.late {
+		return a.late < b.late
+	}
+	if a.minutes != b.minutes {
+		return a.minutes < b.minutes
+	}
+	return a.meters < b.meters-0.5
+}
+
+// LoadRouteCandidates returns the visits to optimize: the given schedules, or the caregiver's
+// scheduled visits on the agency-local day
+func LoadRouteCandidates(db *gorm.DB, userID uint, day time.Time, loc *time.Location, scheduleIDs []uint) ([]models.Schedule, error) {
+	query := db.Where("user_id = ? AND status = ?", userID, models.SCHEDULE_STATUS_SCHEDULED)
+	if len(scheduleIDs) > 0 {
+		query = query.Where("id IN ?", scheduleIDs)
+	} else {
+		from := localMidnight(day, loc)
+		query = query.Where("shift_time >= ? AND shift_time < ?", from.UTC(), from.AddDate(0, 0, 1).UTC())
+	}
+	var schedules []models.Schedule
+	err := query.Order("shift_time ASC, id ASC").Find(&schedules).Error
+	return schedules, err
+}
+
+// OptimizeRoute proposes an order and start times for a caregiver's visits that minimizes
+// travel while starting each visit inside its window. It builds orders by deadline, by the
+// current plan and by nearest neighbour from every visit, improves each with relocate and
+// 2-opt moves, and keeps the best; start times are then pushed back towards the planned ones
+// as far as the rest of the route allows. Visits without coordinates are left unplaced.
+func OptimizeRoute(ctx context.Context, schedules []models.Schedule, clients map[uint]models.Client, opts RouteOptions) (*models.RoutePlan, error) {
+	if len(schedules) == 0 {
+		return nil, ErrRouteNoVisits
+	}
+	if len(schedules) > MaxRouteVisits {
+		return nil, ErrRouteTooManyVisits
+	}
+
+	plan := &models.RoutePlan{Provider: opts.Provider.Name()}
+	var visits []routeVisit
+	for i := range schedules {
+		s := &schedules[i]
+		lat, lon, ok := visitStartPoint(s, clients)
+		if !ok {
+			plan.Unplaced = append(plan.Unplaced, models.RouteUnplaced{ScheduleID: s.ID, Reason: "visit and client have no coordinates"})
+			continue
+		}
+		earliest, latest := s.ShiftTime.Add(-opts.DefaultWindow), s.ShiftTime.Add(opts.DefaultWindow)
+		if s.EarliestStart != nil {
+			earliest = *s.EarliestStart
+		}
+		if s.LatestStart != nil {
+			latest = *s.LatestStart
+		}
+		visits = append(visits, routeVisit{
+			schedule: s,
+			point:    routing.Point{Lat: lat, Lon: lon},
+			planned:  s.ShiftTime,
+			earliest: earliest,
+			latest:   latest,
+			duration: visitDuration(s),
+		})
+	}
+	if len(visits) == 0 {
+		return plan, nil
+	}
+
+	matrix, fallbacks, err := buildRouteMatrix(ctx, visits, opts)
+	if err != nil {
+		return nil, err
+	}
+	plan.FallbackLegs = fallbacks
+
+	current := make([]int, len(visits))
+	for i := range current {
+		current[i] = i
+	}
+	starts := [][]int{current, deadlineOrder(visits)}
+	for i := range visits {
+		starts = append(starts, nearestNeighbourOrder(visits, matrix, i))
+	}
+	var (
+		best      []int
+		bestScore routeScore
+	)
+	for _, start := range starts {
+		order := improveRoute(start, visits, matrix, opts.Origin != nil)
+		_, score := timeRoute(order, visits, matrix, opts.Origin != nil)
+		if best == nil || score.better(bestScore) {
+			best, bestScore = order, score
+		}
+	}
+
+	plan.Stops, _ = timeRoute(best, visits, matrix, opts.Origin != nil)
+	plan.Proposed = routeTotals(plan.Stops)
+	plan.Feasible = plan.Proposed.LateMinutes == 0
+	plan.CurrentStops = currentRoute(visits, matrix, opts.Origin != nil)
+	plan.Current = routeTotals(plan.CurrentStops)
+	plan.SavedMinutes = plan.Current.TravelMinutes - plan.Proposed.TravelMinutes
+	plan.SavedMeters = roundTenth(plan.Current.TravelMeters - plan.Proposed.TravelMeters)
+	return plan, nil
+}
+
+// buildRouteMatrix asks the provider for every leg, falling back for legs it fails on
+func buildRouteMatrix(ctx context.Context, visits []routeVisit, opts RouteOptions) (routeMatrix, int, error) {
+	points := make([]routing.Point, 0, len(visits)+1)
+	for _, v := range visits {
+		points = append(points, v.point)
+	}
+	if opts.Origin != nil {
+		points = append(points, *opts.Origin)
+	}
+	fallbacks := 0
+	matrix := make(routeMatrix, len(points))
+	for i := range points {
+		matrix[i] = make([]routing.Route, len(points))
+		for j := range points {
+			if i == j {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, 0, err
+			}
+			route, err := opts.Provider.Route(ctx, points[i], points[j])
+			if err != nil {
+				fallbacks++
+				if route, err = opts.Fallback.Route(ctx, points[i], points[j]); err != nil {
+					return nil, 0, err
+				}
+			}
+			matrix[i][j] = route
+		}
+	}
+	return matrix, fallbacks, nil
+}
+
+// timeRoute lays out an order: each visit starts when the caregiver arrives or its window
+// opens, whichever is later, and is late by however far that is past its latest start. A
+// backward pass then moves starts later, towards the planned time, without delaying the next
+// visit or passing the latest start.
+func timeRoute(order []int, visits []routeVisit, matrix routeMatrix, hasOrigin bool) ([]models.RouteStop, routeScore) {
+	stops := make([]models.RouteStop, len(order))
+	var score routeScore
+	var prevEnd time.Time
+	for k, idx := range order {
+		v := visits[idx]
+		leg := routing.Route{}
+		if k > 0 {
+			leg = matrix[order[k-1]][idx]
+		} else if hasOrigin {
+			leg = matrix[len(visits)][idx]
+		}
+		start := v.earliest
+		stop := models.RouteStop{ScheduleID: v.schedule.ID, TravelMinutes: leg.Minutes, TravelMeters: roundTenth(leg.Meters)}
+		if k > 0 {
+			if arrive := prevEnd.Add(time.Duration(leg.Minutes) * time.Minute); arrive.After(start) {
+				start = arrive
+			}
+		}
+		stop.LateMinutes = minutesBetween(v.latest, start)
+		stop.ProposedStart = start
+		prevEnd = start.Add(v.duration)
+		stops[k] = stop
+		score.late += stop.LateMinutes
+		score.minutes += leg.Minutes
+		score.meters += leg.Meters
+	}
+
+	for k := len(order) - 1; k >= 0; k-- {
+		v := visits[order[k]]
+		limit := v.latest
+		if k < len(order)-1 {
+			next := stops[k+1].ProposedStart.Add(-time.Duration(stops[k+1].TravelMinutes) * time.Minute).Add(-v.duration)
+			if next.Before(limit) {
+				limit = next
+			}
+		}
+		target := v.planned
+		if target.After(limit) {
+			target = limit
+		}
+		if target.After(stops[k].ProposedStart) {
+			stops[k].ProposedStart = target
+		}
+	}
+	for k, idx := range order {
+		v := visits[idx]
+		stops[k].Sequence = k + 1
+		stops[k].ClientName = v.schedule.ClientName
+		stops[k].PlannedStart = v.planned
+		stops[k].EarliestStart = v.earliest
+		stops[k].LatestStart = v.latest
+		stops[k].ProposedEnd = stops[k].ProposedStart.Add(v.duration)
+		if k > 0 {
+			arrive := stops[k-1].ProposedEnd.Add(time.Duration(stops[k].TravelMinutes) * time.Minute)
+			stops[k].WaitMinutes = minutesBetween(arrive, stops[k].ProposedStart)
+		}
+	}
+	return stops, score
+}
+
+// currentRoute reports the visits at their planned times in shift_time order. A visit the
+// caregiver cannot reach by its planned start counts as late by the difference.
+func currentRoute(visits []routeVisit, matrix routeMatrix, hasOrigin bool) []models.RouteStop {
+	order := make([]int, len(visits))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return visits[order[a]].planned.Before(visits[order[b]].planned) })
+	stops := make([]models.RouteStop, len(order))
+	for k, idx := range order {
+		v := visits[idx]
+		leg := routing.Route{}
+		if k > 0 {
+			leg = matrix[order[k-1]][idx]
+		} else if hasOrigin {
+			leg = matrix[len(visits)][idx]
+		}
+		stop := models.RouteStop{
+			Sequence:      k + 1,
+			ScheduleID:    v.schedule.ID,
+			ClientName:    v.schedule.ClientName,
+			PlannedStart:  v.planned,
+			ProposedStart: v.planned,
+			ProposedEnd:   v.planned.Add(v.duration),
+			EarliestStart: v.earliest,
+			LatestStart:   v.latest,
+			TravelMinutes: leg.Minutes,
+			TravelMeters:  roundTenth(leg.Meters),
+		}
+		if k > 0 {
+			arrive := stops[k-1].ProposedEnd.Add(time.Duration(leg.Minutes) * time.Minute)
+			stop.WaitMinutes = minutesBetween(arrive, v.planned)
+			stop.LateMinutes = minutesBetween(v.planned, arrive)
+		}
+		stops[k] = stop
+	}
+	return stops
+}
+
+// improveRoute applies relocate and 2-opt moves while they improve the route
+func improveRoute(start []int, visits []routeVisit, matrix routeMatrix, hasOrigin bool) []int {
+	order := append([]int(nil), start...)
+	_, score := timeRoute(order, visits, matrix, hasOrigin)
+	candidate := make([]int, len(order))
+	for improved := true; improved; {
+		improved = false
+		// Relocate: move one visit to another position
+		for i := 0; i < len(order) && !improved; i++ {
+			for j := 0; j < len(order) && !improved; j++ {
+				if i == j {
+					continue
+				}
+				relocate(candidate, order, i, j)
+				if _, s := timeRoute(candidate, visits, matrix, hasOrigin); s.better(score) {
+					copy(order, candidate)
+					score, improved = s, true
+				}
+			}
+		}
+		// 2-opt: reverse a stretch of the route
+		for i := 0; i < len(order)-1 && !improved; i++ {
+			for j := i + 1; j < len(order) && !improved; j++ {
+				copy(candidate, order)
+				for a, b := i, j; a < b; a, b = a+1, b-1 {
+					candidate[a], candidate[b] = candidate[b], candidate[a]
+				}
+				if _, s := timeRoute(candidate, visits, matrix, hasOrigin); s.better(score) {
+					copy(order, candidate)
+					score, improved = s, true
+				}
+			}
+		}
+	}
+	return order
+}
+
+// relocate writes order into dst with the element at from moved to position to
+func relocate(dst, order []int, from, to int) {
+	moved := order[from]
+	rest := make([]int, 0, len(order)-1)
+	rest = append(rest, order[:from]...)
+	rest = append(rest, order[from+1:]...)
+	copy(dst, rest[:to])
+	dst[to] = moved
+	copy(dst[to+1:], rest[to:])
+}
+
+// deadlineOrder sorts visits by latest start, then earliest start
+func deadlineOrder(visits []routeVisit) []int {
+	order := make([]int, len(visits))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		va, vb := visits[order[a]], visits[order[b]]
+		if !va.latest.Equal(vb.latest) {
+			return va.latest.Before(vb.latest)
+		}
+		return va.earliest.Before(vb.earliest)
+	})
+	return order
+}
+
+// nearestNeighbourOrder starts at first and repeatedly drives to the closest unvisited visit
+func nearestNeighbourOrder(visits []routeVisit, matrix routeMatrix, first int) []int {
+	order := []int{first}
+	used := make([]bool, len(visits))
+	used[first] = true
+	for len(order) < len(visits) {
+		last, next := order[len(order)-1], -1
+		for j := range visits {
+			if !used[j] && (next < 0 || matrix[last][j].Minutes < matrix[last][next].Minutes) {
+				next = j
+			}
+		}
+		used[next] = true
+		order = append(order, next)
+	}
+	return order
+}
+
+func routeTotals(stops []models.RouteStop) models.RouteTotals {
+	var totals models.RouteTotals
+	for _, stop := range stops {
+		totals.TravelMinutes += stop.TravelMinutes
+		totals.TravelMeters += stop.TravelMeters
+		totals.LateMinutes += stop.LateMinutes
+	}
+	totals.TravelMeters = roundTenth(totals.TravelMeters)
+	return totals
+}
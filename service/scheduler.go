@@ -2,15 +2,41 @@ package service
 
 import (
 	"caregiver-shift-tracker/models"
+	"errors"
 	"fmt"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-// CreateSchedule adds a new schedule to the database
-func CreateSchedule(db *gorm.DB, schedule *models.Schedule) error {
-	return db.Create(schedule).Error
+// ErrVisitNotScheduled is returned when starting a visit that is already underway or finished
+var ErrVisitNotScheduled = errors.New("only a scheduled visit can be started")
+
+// ErrVisitNotInProgress is returned when ending or cancelling the clock-in of a visit that is not underway
+var ErrVisitNotInProgress = errors.New("only a visit in progress can be ended or have its clock-in canceled")
+
+// CreateSchedule adds a new schedule to the database. When applyCarePlan is set, tasks from the
+// client's active care plan for the shift's weekday (in loc) are created alongside it. Doses of
+// the client's active medication orders that fall inside the visit are always planned.
+func CreateSchedule(db *gorm.DB, schedule *models.Schedule, applyCarePlan bool, loc *time.Location) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(schedule).Error; err != nil {
+			return err
+		}
+		if applyCarePlan {
+			tasks, err := ApplyCarePlan(tx, schedule, loc)
+			if err != nil {
+				return err
+			}
+			schedule.Tasks = append(schedule.Tasks, tasks...)
+		}
+		medicationTasks, err := PlanMedicationTasks(tx, schedule)
+		if err != nil {
+			return err
+		}
+		schedule.Tasks = append(schedule.Tasks, medicationTasks...)
+		return nil
+	})
 }
 
 func GetAllSchedules(db *gorm.DB, userID int, loc *time.Location) ([]models.Schedule, error) {
@@ -21,7 +47,7 @@ func GetAllSchedules(db *gorm.DB, userID int, loc *time.Location) ([]models.Sche
 	endOfDayUTC := endOfDayLocal.UTC()
 
 	var schedules []models.Schedule
-	err := db.Preload("Tasks").
+	err := db.Preload("Tasks", orderTasks).
 		Where("user_id = ? AND shift_time BETWEEN ? AND ?", userID, startOfDayUTC, endOfDayUTC).
 		Find(&schedules).Error
 	return schedules, err
@@ -33,47 +59,90 @@ func GetTodaySchedules(db *gorm.DB, userID int, loc *time.Location) ([]models.Sc
 	end := start.Add(24 * time.Hour)
 
 	var schedules []models.Schedule
-	err := db.Preload("Tasks").
+	err := db.Preload("Tasks", orderTasks).
 		Where("user_id = ? AND shift_time BETWEEN ? AND ?", userID, start, end).
 		Find(&schedules).Error
 	return schedules, err
 }
 
+// omitSignatureImages leaves the image blobs out of a signature preload; they are
+// only served by the signature image endpoint
+func omitSignatureImages(db *gorm.DB) *gorm.DB {
+	return db.Omit("image_data")
+}
+
 func GetScheduleByID(db *gorm.DB, scheduleID uint) (*models.Schedule, error) {
 	var schedule models.Schedule
-	err := db.Preload("Tasks").First(&schedule, "id = ?", scheduleID).Error
+	err := db.Preload("Tasks", orderTasks).Preload("Corrections").Preload("Signatures", omitSignatureImages).
+		Preload("Breaks", func(db *gorm.DB) *gorm.DB { return db.Order("started_at ASC") }).
+		First(&schedule, "id = ?", scheduleID).Error
 	return &schedule, err
 }
 
+// StartVisit moves a scheduled visit to in progress. The status is checked in the update itself
+// so a completed, cancelled or already started visit never has its start overwritten.
 func StartVisit(db *gorm.DB, scheduleID uint, lat, lon float64) error {
 	now := time.Now()
-	return db.Model(&models.Schedule{}).
-		Where("id = ?", scheduleID).
+	result := db.Model(&models.Schedule{}).
+		Where("id = ? AND status = ?", scheduleID, models.SCHEDULE_STATUS_SCHEDULED).
 		Updates(map[string]interface{}{
 			"start_time": now,
 			"start_lat":  lat,
 			"start_lon":  lon,
 			"status":     models.SCHEDULE_STATUS_IN_PROGRESS,
-		}).Error
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVisitNotScheduled
+	}
+	return nil
 }
 
-func EndVisit(db *gorm.DB, scheduleID uint, lat, lon float64) error {
+// EndVisit completes the visit and stores any signatures captured at checkout in one transaction.
+// A completion override used for the checkout is consumed in the same transaction. As with
+// StartVisit, the status is checked in the update so a finished visit is never ended again.
+func EndVisit(db *gorm.DB, scheduleID uint, lat, lon float64, signatures []models.VisitSignature, override *models.TaskCompletionOverride) error {
 	now := time.Now()
-	return db.Model(&models.Schedule{}).
-		Where("id = ?", scheduleID).
-		Updates(map[string]interface{}{
-			"end_time": now,
-			"end_lat":  lat,
-			"end_lon":  lon,
-			"status":   models.SCHEDULE_STATUS_COMPLETED,
-		}).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Schedule{}).
+			Where("id = ? AND status = ?", scheduleID, models.SCHEDULE_STATUS_IN_PROGRESS).
+			Updates(map[string]interface{}{
+				"end_time": now,
+				"end_lat":  lat,
+				"end_lon":  lon,
+				"status":   models.SCHEDULE_STATUS_COMPLETED,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVisitNotInProgress
+		}
+		for i := range signatures {
+			if err := tx.Create(&signatures[i]).Error; err != nil {
+				return err
+			}
+		}
+		if override != nil {
+			result := tx.Model(override).Where("used_at IS NULL").Update("used_at", now)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrOverrideInvalid
+			}
+		}
+		return nil
+	})
 }
 
 func GetUpcomingSchedules(db *gorm.DB, userID int) ([]models.Schedule, error) {
 	now := time.Now()
 
 	var schedules []models.Schedule
-	err := db.Preload("Tasks").
+	err := db.Preload("Tasks", orderTasks).
 		Where("user_id = ? AND shift_time >= ?", userID, now).
 		Where("status = ?", models.SCHEDULE_STATUS_SCHEDULED).
 		Find(&schedules).Error
@@ -84,8 +153,7 @@ func GetUpcomingSchedules(db *gorm.DB, userID int) ([]models.Schedule, error) {
 func GetMissedSchedules(db *gorm.DB, userID int, loc *time.Location) ([]models.Schedule, error) {
 	nowInUserTZ := time.Now().In(loc)
 	nowUTC := nowInUserTZ.UTC()
-	gracePeriod := 5 * time.Minute
-	cutoffTime := nowUTC.Add(-gracePeriod)
+	cutoffTime := nowUTC.Add(-MissedVisitGrace)
 
 	startOfDayLocal := time.Date(nowInUserTZ.Year(), nowInUserTZ.Month(), nowInUserTZ.Day(), 0, 0, 0, 0, loc)
 	endOfDayLocal := time.Date(nowInUserTZ.Year(), nowInUserTZ.Month(), nowInUserTZ.Day(), 23, 59, 59, 0, loc)
@@ -93,7 +161,7 @@ func GetMissedSchedules(db *gorm.DB, userID int, loc *time.Location) ([]models.S
 	endOfDayUTC := endOfDayLocal.UTC()
 
 	var schedules []models.Schedule
-	err := db.Debug().Preload("Tasks").
+	err := db.Debug().Preload("Tasks", orderTasks).
 		Where("user_id = ? AND shift_time BETWEEN ? AND ? AND shift_time < ? AND status IN (?)",
 			userID,
 			startOfDayUTC, endOfDayUTC, cutoffTime,
@@ -158,7 +226,7 @@ func GetTodayCompletedSchedules(db *gorm.DB, userID int, loc *time.Location) ([]
 	var schedules []models.Schedule
 
 	// Use end_time to filter completed schedules
-	err := db.Preload("Tasks").
+	err := db.Preload("Tasks", orderTasks).
 		Where("user_id = ? AND shift_time BETWEEN ? AND ? AND status = ?", userID, startUTC, endUTC, models.SCHEDULE_STATUS_COMPLETED).
 		Find(&schedules).Error
 
@@ -186,20 +254,30 @@ func GetTodayCompletedSchedules(db *gorm.DB, userID int, loc *time.Location) ([]
 	return schedules, err
 }
 
+// CancelStartVisit undoes the clock-in of a visit in progress, discarding any breaks taken since
 func CancelStartVisit(db *gorm.DB, scheduleID uint) error {
-	return db.Model(&models.Schedule{}).
-		Where("id = ?", scheduleID).
-		Updates(map[string]interface{}{
-			"start_time": nil,
-			"start_lat":  nil,
-			"start_lon":  nil,
-			"status":     models.SCHEDULE_STATUS_SCHEDULED,
-		}).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Schedule{}).
+			Where("id = ? AND status = ?", scheduleID, models.SCHEDULE_STATUS_IN_PROGRESS).
+			Updates(map[string]interface{}{
+				"start_time": nil,
+				"start_lat":  nil,
+				"start_lon":  nil,
+				"status":     models.SCHEDULE_STATUS_SCHEDULED,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVisitNotInProgress
+		}
+		return tx.Where("schedule_id = ?", scheduleID).Delete(&models.VisitBreak{}).Error
+	})
 }
 
 func FetchSchedulesWithTasks(db *gorm.DB, userID int) ([]models.Schedule, error) {
 	var schedules []models.Schedule
-	err := db.Preload("Tasks").Where("user_id = ?", userID).Find(&schedules).Error
+	err := db.Preload("Tasks", orderTasks).Where("user_id = ?", userID).Find(&schedules).Error
 	return schedules, err
 }
 
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSeriesInvalid    = errors.New("invalid visit series")
+	ErrSeriesInactive   = errors.New("the visit series is not active")
+	ErrSeriesUnassigned = errors.New("assign a caregiver to the visit series before generating visits")
+)
+
+// BuildVisitSeries validates a request and fills in a series. Dates and the start time are
+// read in loc, the agency timezone.
+func BuildVisitSeries(series *models.VisitSeries, req models.VisitSeriesRequest, loc *time.Location) error {
+	if _, err := time.Parse("15:04", req.StartTime); err != nil {
+		return fmt.Errorf("%w: start time %q must be HH:MM", ErrSeriesInvalid, req.StartTime)
+	}
+	start, err := time.ParseInLocation("2006-01-02", req.StartDate, loc)
+	if err != nil {
+		return fmt.Errorf("%w: start date must be YYYY-MM-DD", ErrSeriesInvalid)
+	}
+	var end *time.Time
+	if req.EndDate != "" {
+		e, err := time.ParseInLocation("2006-01-02", req.EndDate, loc)
+		if err != nil || e.Before(start) {
+			return fmt.Errorf("%w: end date must be on or after the start date", ErrSeriesInvalid)
+		}
+		end = &e
+	}
+
+	series.UserID = req.UserID
+	series.DaysOfWeek = normalizeDaysOfWeek(req.DaysOfWeek)
+	series.StartTime = req.StartTime
+	series.DurationMinutes = req.DurationMinutes
+	series.ServiceCode = req.ServiceCode
+	series.StartDate = start
+	series.EndDate = end
+	if req.Active != nil {
+		series.Active = *req.Active
+	}
+	return nil
+}
+
+// SaveVisitSeries creates or updates a series. Visits already generated are left as they are.
+func SaveVisitSeries(db *gorm.DB, series *models.VisitSeries) error {
+	return db.Save(series).Error
+}
+
+// GetVisitSeriesByID retrieves a single series
+func GetVisitSeriesByID(db *gorm.DB, seriesID uint) (*models.VisitSeries, error) {
+	var series models.VisitSeries
+	err := db.First(&series, "id = ?", seriesID).Error
+	return &series, err
+}
+
+// ListVisitSeries returns a client's series, active first
+func ListVisitSeries(db *gorm.DB, clientID uint) ([]models.VisitSeries, error) {
+	var series []models.VisitSeries
+	err := db.Where("client_id = ?", clientID).Order("active DESC, start_time ASC").Find(&series).Error
+	return series, err
+}
+
+// DeleteVisitSeries removes a series; the visits generated from it stay scheduled
+func DeleteVisitSeries(db *gorm.DB, series *models.VisitSeries) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Schedule{}).Where("series_id = ?", series.ID).Update("series_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(series).Error
+	})
+}
+
+// SeriesOccurrences lists the start times of the series' visits in [from, to)
+func SeriesOccurrences(series *models.VisitSeries, from, to time.Time, loc *time.Location) []time.Time {
+	startTime, err := time.Parse("15:04", series.StartTime)
+	if err != nil {
+		return nil
+	}
+	first := dateOnly(series.StartDate, loc)
+	if day := localMidnight(from.In(loc), loc); day.After(first) {
+		first = day
+	}
+
+	var occurrences []time.Time
+	for day := first; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if series.EndDate != nil && day.After(dateOnly(*series.EndDate, loc)) {
+			break
+		}
+		if !appliesOnDay(series.DaysOfWeek, day.Weekday()) {
+			continue
+		}
+		at := time.Date(day.Year(), day.Month(), day.Day(), startTime.Hour(), startTime.Minute(), 0, 0, loc)
+		if !at.Before(from) && at.Before(to) {
+			occurrences = append(occurrences, at)
+		}
+	}
+	return occurrences
+}
+
+// GenerateSeriesVisits schedules the series' visits in [from, to) for its caregiver, with the
+// client's care plan and medications applied as for any new visit. A day that already has a
+// visit from the series is skipped, even if that visit has since been moved to another time.
+func GenerateSeriesVisits(db *gorm.DB, series *models.VisitSeries, from, to time.Time, loc *time.Location) ([]models.Schedule, error) {
+	if !series.Active {
+		return nil, ErrSeriesInactive
+	}
+	if series.UserID == nil {
+		return nil, ErrSeriesUnassigned
+	}
+	client, err := GetClientByID(db, series.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []models.Schedule
+	err = db.Transaction(func(tx *gorm.DB) error {
+		generated, err := seriesVisitDays(tx, []uint{series.ID}, from, to, loc)
+		if err != nil {
+			return err
+		}
+		for _, at := range SeriesOccurrences(series, from, to, loc) {
+			if generated[seriesDay{series.ID, at.Format("2006-01-02")}] {
+				continue
+			}
+			seriesID := series.ID
+			schedule := models.Schedule{
+				UserID:          *series.UserID,
+				ClientID:        &client.ID,
+				ClientName:      client.FullName,
+				Location:        client.Address,
+				ShiftTime:       at.UTC(),
+				Status:          models.SCHEDULE_STATUS_SCHEDULED,
+				ServiceCode:     series.ServiceCode,
+				DurationMinutes: series.DurationMinutes,
+				SeriesID:        &seriesID,
+			}
+			if err := CreateSchedule(tx, &schedule, true, loc); err != nil {
+				return err
+			}
+			created = append(created, schedule)
+		}
+		return nil
+	})
+	return created, err
+}
+
+// seriesDay is one series' calendar day in the agency timezone
+type seriesDay struct {
+	seriesID uint
+	date     string
+}
+
+// seriesVisitDays finds the days in [from, to) that already have a visit from one of the series.
+// The window is widened by a day each way so visits moved across midnight still count.
+func seriesVisitDays(db *gorm.DB, seriesIDs []uint, from, to time.Time, loc *time.Location) (map[seriesDay]bool, error) {
+	days := map[seriesDay]bool{}
+	if len(seriesIDs) == 0 {
+		return days, nil
+	}
+	var schedules []models.Schedule
+	err := db.Select("id", "series_id", "shift_time").
+		Where("series_id IN ? AND shift_time >= ? AND shift_time < ? AND deleted_at IS NULL",
+			seriesIDs, from.AddDate(0, 0, -1).UTC(), to.AddDate(0, 0, 1).UTC()).
+		Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range schedules {
+		days[seriesDay{*s.SeriesID, s.ShiftTime.In(loc).Format("2006-01-02")}] = true
+	}
+	return days, nil
+}
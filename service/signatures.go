@@ -0,0 +1,137 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxSignatureImageBytes caps the decoded size of a signature image
+const MaxSignatureImageBytes = 512 * 1024
+
+var (
+	ErrSignatureNoData        = errors.New("signature must include either an image or strokes")
+	ErrSignatureBothFormats   = errors.New("signature must include an image or strokes, not both")
+	ErrSignatureInvalidImage  = errors.New("signature image must be a base64 encoded PNG or JPEG")
+	ErrSignatureImageTooLarge = fmt.Errorf("signature image must be at most %d KB", MaxSignatureImageBytes/1024)
+	ErrSignatureEmptyStrokes  = errors.New("signature strokes must contain at least one stroke of two or more points")
+)
+
+// BuildVisitSignature validates a captured signature and computes its hash
+func BuildVisitSignature(scheduleID uint, input models.SignatureInput, signedAt time.Time) (*models.VisitSignature, error) {
+	sig := &models.VisitSignature{
+		ScheduleID:   scheduleID,
+		SignerType:   input.SignerType,
+		SignerName:   strings.TrimSpace(input.SignerName),
+		Relationship: strings.TrimSpace(input.Relationship),
+		SignedAt:     signedAt.UTC().Truncate(time.Microsecond),
+	}
+
+	hasImage := input.Image != ""
+	hasStrokes := len(input.Strokes) > 0
+	switch {
+	case hasImage && hasStrokes:
+		return nil, ErrSignatureBothFormats
+	case hasImage:
+		data, contentType, err := decodeSignatureImage(input.Image)
+		if err != nil {
+			return nil, err
+		}
+		sig.Format = models.SIGNATURE_FORMAT_IMAGE
+		sig.ContentType = contentType
+		sig.ImageData = data
+	case hasStrokes:
+		for _, stroke := range input.Strokes {
+			if len(stroke) < 2 {
+				return nil, ErrSignatureEmptyStrokes
+			}
+		}
+		raw, err := json.Marshal(input.Strokes)
+		if err != nil {
+			return nil, err
+		}
+		strokes := string(raw)
+		sig.Format = models.SIGNATURE_FORMAT_STROKES
+		sig.ContentType = "application/json"
+		sig.Strokes = &strokes
+	default:
+		return nil, ErrSignatureNoData
+	}
+
+	sig.Hash = SignatureHash(sig)
+	return sig, nil
+}
+
+// SignatureHash is SHA-256 over the schedule, signer details, signing time and signature data.
+// The fields are JSON encoded so free text such as the signer name cannot be shifted into a
+// neighbouring field to produce the same hash for a different signature.
+func SignatureHash(sig *models.VisitSignature) string {
+	fields := struct {
+		ScheduleID   uint   `json:"schedule_id"`
+		SignerType   string `json:"signer_type"`
+		SignerName   string `json:"signer_name"`
+		Relationship string `json:"relationship"`
+		SignedAt     string `json:"signed_at"`
+		Format       string `json:"format"`
+		Image        []byte `json:"image,omitempty"`
+		Strokes      string `json:"strokes,omitempty"`
+	}{
+		ScheduleID:   sig.ScheduleID,
+		SignerType:   sig.SignerType,
+		SignerName:   sig.SignerName,
+		Relationship: sig.Relationship,
+		SignedAt:     sig.SignedAt.UTC().Format(time.RFC3339Nano),
+		Format:       sig.Format,
+	}
+	if sig.Format == models.SIGNATURE_FORMAT_IMAGE {
+		fields.Image = sig.ImageData
+	} else if sig.Strokes != nil {
+		fields.Strokes = *sig.Strokes
+	}
+	// Marshalling plain strings, numbers and bytes cannot fail
+	data, _ := json.Marshal(fields)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// GetVisitSignature retrieves one signature belonging to a schedule
+func GetVisitSignature(db *gorm.DB, scheduleID, signatureID uint) (*models.VisitSignature, error) {
+	var sig models.VisitSignature
+	err := db.First(&sig, "id = ? AND schedule_id = ?", signatureID, scheduleID).Error
+	return &sig, err
+}
+
+// decodeSignatureImage accepts raw base64 or a data URL and returns the bytes and detected content type
+func decodeSignatureImage(encoded string) ([]byte, string, error) {
+	if strings.HasPrefix(encoded, "data:") {
+		comma := strings.Index(encoded, ",")
+		if comma < 0 {
+			return nil, "", ErrSignatureInvalidImage
+		}
+		encoded = encoded[comma+1:]
+	}
+	if base64.StdEncoding.DecodedLen(len(encoded)) > MaxSignatureImageBytes+3 {
+		return nil, "", ErrSignatureImageTooLarge
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", ErrSignatureInvalidImage
+	}
+	if len(data) > MaxSignatureImageBytes {
+		return nil, "", ErrSignatureImageTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	if contentType != "image/png" && contentType != "image/jpeg" {
+		return nil, "", ErrSignatureInvalidImage
+	}
+	return data, contentType, nil
+}
@@ -35,9 +35,9 @@ func DeleteTask(db *gorm.DB, taskID uint) error {
 	return db.Delete(&models.Task{}, "id = ?", taskID).Error
 }
 
-// UpdateTask updates a specific task in the database
+// UpdateTask saves a task's description and status; its other columns are left as they are
 func UpdateTask(db *gorm.DB, task *models.Task) error {
-	return db.Save(task).Error
+	return db.Model(task).Select("description", "status", "reason", "completed_at", "completion_timing").Updates(task).Error
 }
 
 // GetScheduleByTaskID retrieves the schedule associated with a task
@@ -53,13 +53,26 @@ func GetScheduleByTaskID(db *gorm.DB, taskID uint) (*models.Schedule, error) {
 	return &schedule, nil
 }
 
-// UpdateTaskStatus updates the status and reason of a task
-func UpdateTaskStatus(db *gorm.DB, taskID uint, status string, reason *string, completedAt *time.Time) error {
+// UpdateTaskStatus updates the status and reason of a task, flagging completions of timed
+// tasks as early, on time or late
+func UpdateTaskStatus(db *gorm.DB, task *models.Task, status string, reason *string, completedAt *time.Time) error {
+	timing := ""
+	if completedAt != nil {
+		timing = CompletionTiming(task, *completedAt)
+	}
 	return db.Model(&models.Task{}).
-		Where("id = ?", taskID).
+		Where("id = ?", task.ID).
 		Updates(map[string]interface{}{
-			"status":       status,
-			"reason":       reason,
-			"completed_at": completedAt,
+			"status":            status,
+			"reason":            reason,
+			"completed_at":      completedAt,
+			"completion_timing": timing,
 		}).Error
 }
+
+// GetTaskByID retrieves a single task
+func GetTaskByID(db *gorm.DB, taskID uint) (*models.Task, error) {
+	var task models.Task
+	err := db.First(&task, "id = ?", taskID).Error
+	return &task, err
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTimesheetNotEditable = errors.New("timesheet has already been submitted")
+	ErrTimesheetPeriodOpen  = errors.New("the pay period has not ended yet")
+	ErrTimesheetNotPending  = errors.New("timesheet is not awaiting approval")
+	ErrTimesheetLocked      = errors.New("visit belongs to an approved timesheet and can no longer be changed")
+)
+
+// PayPeriodFor returns the start and end (exclusive) of the pay period containing t
+func PayPeriodFor(settings models.PayPeriodSettings, t time.Time) (time.Time, time.Time) {
+	loc := settings.Location
+	length := 7
+	if settings.Type == models.PAY_PERIOD_BIWEEKLY {
+		length = 14
+	}
+	anchor := time.Date(settings.Anchor.Year(), settings.Anchor.Month(), settings.Anchor.Day(), 0, 0, 0, 0, loc)
+
+	// Count calendar days rather than hours so DST changes do not shift the boundary
+	local := t.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	anchorDay := time.Date(anchor.Year(), anchor.Month(), anchor.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(day.Sub(anchorDay).Hours() / 24)
+	periods := days / length
+	if days < 0 && days%length != 0 {
+		periods--
+	}
+	start := anchor.AddDate(0, 0, periods*length)
+	return start, start.AddDate(0, 0, length)
+}
+
+// RoundClockTime rounds t to the configured increment, measured from midnight in the agency timezone
+func RoundClockTime(t time.Time, settings models.PayPeriodSettings) time.Time {
+	if settings.RoundingMinutes <= 0 {
+		return t
+	}
+	local := t.In(settings.Location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, settings.Location)
+	offset := local.Sub(midnight)
+	increment := time.Duration(settings.RoundingMinutes) * time.Minute
+
+	rounded := offset.Truncate(increment)
+	switch settings.RoundingMode {
+	case models.ROUNDING_UP:
+		if rounded < offset {
+			rounded += increment
+		}
+	case models.ROUNDING_DOWN:
+	default:
+		rounded = offset.Round(increment)
+	}
+	return midnight.Add(rounded)
+}
+
+// BuildTimesheetEntries turns a caregiver's completed visits that started in [start, end) into
+// timesheet entries. Clock times are rounded and unpaid breaks are deducted from worked time.
+func BuildTimesheetEntries(db *gorm.DB, userID uint, start, end time.Time, settings models.PayPeriodSettings, rules models.BreakRules) ([]models.TimesheetEntry, error) {
+	var schedules []models.Schedule
+	err := db.Preload("Breaks", func(db *gorm.DB) *gorm.DB { return db.Order("started_at ASC") }).
+		Where("user_id = ? AND status = ? AND start_time >= ? AND start_time < ? AND end_time IS NOT NULL",
+			userID, models.SCHEDULE_STATUS_COMPLETED, start.UTC(), end.UTC()).
+		Order("start_time ASC").
+		Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.TimesheetEntry, 0, len(schedules))
+	for i := range schedules {
+		schedule := &schedules[i]
+		summary := SummarizeVisitTime(schedule, schedule.Breaks, rules, *schedule.EndTime)
+		roundedStart := RoundClockTime(*schedule.StartTime, settings)
+		roundedEnd := RoundClockTime(*schedule.EndTime, settings)
+		if roundedEnd.Before(roundedStart) {
+			roundedEnd = roundedStart
+		}
+		worked := minutesBetween(roundedStart, roundedEnd) - summary.UnpaidBreakMinutes
+		if worked < 0 {
+			worked = 0
+		}
+		entries = append(entries, models.TimesheetEntry{
+			ScheduleID:         schedule.ID,
+			ClientName:         schedule.ClientName,
+			StartTime:          *schedule.StartTime,
+			EndTime:            *schedule.EndTime,
+			RoundedStart:       roundedStart,
+			RoundedEnd:         roundedEnd,
+			UnpaidBreakMinutes: summary.UnpaidBreakMinutes,
+			WorkedMinutes:      worked,
+		})
+	}
+	return entries, nil
+}
+
+// GetTimesheetForPeriod returns the caregiver's timesheet for the pay period containing t,
+// creating it if needed. Open and rejected timesheets are rebuilt from the current visits.
+func GetTimesheetForPeriod(db *gorm.DB, userID uint, t time.Time, settings models.PayPeriodSettings, rules models.BreakRules) (*models.Timesheet, error) {
+	start, end := PayPeriodFor(settings, t)
+	var sheet models.Timesheet
+	err := db.Where("user_id = ? AND period_start = ?", userID, start.UTC()).First(&sheet).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		sheet = models.Timesheet{UserID: userID, PeriodStart: start.UTC(), PeriodEnd: end.UTC(), Status: models.TIMESHEET_STATUS_OPEN}
+		if err := db.Create(&sheet).Error; err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if isTimesheetEditable(&sheet) {
+		if err := rebuildTimesheet(db, &sheet, settings, rules); err != nil {
+			return nil, err
+		}
+	}
+	return GetTimesheetByID(db, sheet.ID)
+}
+
+// GetTimesheetByID retrieves a timesheet with its entries in visit order
+func GetTimesheetByID(db *gorm.DB, timesheetID uint) (*models.Timesheet, error) {
+	var sheet models.Timesheet
+	err := db.Preload("Entries", func(db *gorm.DB) *gorm.DB { return db.Order("start_time ASC") }).
+		First(&sheet, "id = ?", timesheetID).Error
+	return &sheet, err
+}
+
+// ListTimesheets returns timesheets matching the filter, most recent period first
+func ListTimesheets(db *gorm.DB, filter models.TimesheetFilter) ([]models.Timesheet, error) {
+	query := db.Order("period_start DESC, user_id ASC")
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.PeriodStart != nil {
+		query = query.Where("period_start = ?", filter.PeriodStart.UTC())
+	}
+	var sheets []models.Timesheet
+	err := query.Find(&sheets).Error
+	return sheets, err
+}
+
+// SubmitTimesheet rebuilds the timesheet one last time and sends it for approval
+func SubmitTimesheet(db *gorm.DB, sheet *models.Timesheet, settings models.PayPeriodSettings, rules models.BreakRules, now time.Time) error {
+	if !isTimesheetEditable(sheet) {
+		return ErrTimesheetNotEditable
+	}
+	if now.Before(sheet.PeriodEnd) {
+		return ErrTimesheetPeriodOpen
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := rebuildTimesheet(tx, sheet, settings, rules); err != nil {
+			return err
+		}
+		sheet.Status = models.TIMESHEET_STATUS_SUBMITTED
+		sheet.SubmittedAt = &now
+		return tx.Omit("Entries").Save(sheet).Error
+	})
+}
+
+// ReviewTimesheet approves or rejects a submitted timesheet
+func ReviewTimesheet(db *gorm.DB, sheet *models.Timesheet, approve bool, reviewerID uint, note *string) error {
+	if sheet.Status != models.TIMESHEET_STATUS_SUBMITTED {
+		return ErrTimesheetNotPending
+	}
+	now := time.Now()
+	sheet.Status = models.TIMESHEET_STATUS_REJECTED
+	if approve {
+		sheet.Status = models.TIMESHEET_STATUS_APPROVED
+	}
+	sheet.ReviewedBy = &reviewerID
+	sheet.ReviewedAt = &now
+	sheet.ReviewNote = note
+	return db.Omit("Entries").Save(sheet).Error
+}
+
+// EnsureVisitUnlocked returns ErrTimesheetLocked when the visit is on an approved timesheet, or
+// when its current or proposed times fall inside a period the caregiver already has approved
+func EnsureVisitUnlocked(db *gorm.DB, schedule *models.Schedule, proposed ...*time.Time) error {
+	var count int64
+	err := db.Model(&models.TimesheetEntry{}).
+		Joins("JOIN timesheets ON timesheets.id = timesheet_entries.timesheet_id").
+		Where("timesheet_entries.schedule_id = ? AND timesheets.status = ?", schedule.ID, models.TIMESHEET_STATUS_APPROVED).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTimesheetLocked
+	}
+
+	times := append([]*time.Time{schedule.StartTime}, proposed...)
+	for _, t := range times {
+		if t == nil {
+			continue
+		}
+		err := db.Model(&models.Timesheet{}).
+			Where("user_id = ? AND status = ? AND period_start <= ? AND period_end > ?",
+				schedule.UserID, models.TIMESHEET_STATUS_APPROVED, t.UTC(), t.UTC()).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrTimesheetLocked
+		}
+	}
+	return nil
+}
+
+func isTimesheetEditable(sheet *models.Timesheet) bool {
+	return sheet.Status == models.TIMESHEET_STATUS_OPEN || sheet.Status == models.TIMESHEET_STATUS_REJECTED
+}
+
+// rebuildTimesheet replaces the timesheet's entries and totals with ones built from the visits
+func rebuildTimesheet(db *gorm.DB, sheet *models.Timesheet, settings models.PayPeriodSettings, rules models.BreakRules) error {
+	entries, err := BuildTimesheetEntries(db, sheet.UserID, sheet.PeriodStart, sheet.PeriodEnd, settings, rules)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("timesheet_id = ?", sheet.ID).Delete(&models.TimesheetEntry{}).Error; err != nil {
+			return err
+		}
+		sheet.VisitCount, sheet.WorkedMinutes, sheet.UnpaidBreakMinutes = len(entries), 0, 0
+		for i := range entries {
+			entries[i].TimesheetID = sheet.ID
+			sheet.WorkedMinutes += entries[i].WorkedMinutes
+			sheet.UnpaidBreakMinutes += entries[i].UnpaidBreakMinutes
+		}
+		if len(entries) > 0 {
+			if err := tx.Create(&entries).Error; err != nil {
+				return err
+			}
+		}
+		sheet.Entries = entries
+		return tx.Omit("Entries").Save(sheet).Error
+	})
+}
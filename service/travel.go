@@ -0,0 +1,219 @@
+package service
+
+import (
+	"caregiver-shift-tracker/models"
+	"caregiver-shift-tracker/routing"
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RebuildTravelSegments recomputes the segments between a caregiver's consecutive completed
+// visits that started on an agency-local day (given as a calendar day). Legs whose visits and
+// coordinates are unchanged keep their routed figures, so the provider is only asked about new
+// or moved legs; a provider failure falls back to the straight line and is retried next time.
+func RebuildTravelSegments(ctx context.Context, db *gorm.DB, userID uint, day time.Time, loc *time.Location, provider routing.Provider, speedKmh float64) ([]models.TravelSegment, error) {
+	from := localMidnight(day, loc)
+	var schedules []models.Schedule
+	err := db.Where("user_id = ? AND status = ? AND start_time >= ? AND start_time < ? AND end_time IS NOT NULL",
+		userID, models.SCHEDULE_STATUS_COMPLETED, from.UTC(), from.AddDate(0, 0, 1).UTC()).
+		Order("start_time ASC").Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	clients, err := LoadVisitClients(db, schedules)
+	if err != nil {
+		return nil, err
+	}
+	var existing []models.TravelSegment
+	if err := db.Where("user_id = ? AND date = ?", userID, day.Format("2006-01-02")).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	previous := make(map[uint]models.TravelSegment, len(existing))
+	for _, seg := range existing {
+		previous[seg.ToScheduleID] = seg
+	}
+
+	straightLine := routing.NewStraightLine(speedKmh)
+	segments := make([]models.TravelSegment, 0, len(schedules))
+	for i := 1; i < len(schedules); i++ {
+		prev, next := &schedules[i-1], &schedules[i]
+		fromLat, fromLon, ok1 := visitEndPoint(prev, clients)
+		toLat, toLon, ok2 := visitStartPoint(next, clients)
+		if !ok1 || !ok2 {
+			continue
+		}
+		start, end := routing.Point{Lat: fromLat, Lon: fromLon}, routing.Point{Lat: toLat, Lon: toLon}
+		straight, _ := straightLine.Route(ctx, start, end)
+		seg := models.TravelSegment{
+			UserID:          userID,
+			Date:            day,
+			FromScheduleID:  prev.ID,
+			ToScheduleID:    next.ID,
+			DepartAt:        *prev.EndTime,
+			ArriveAt:        *next.StartTime,
+			FromLat:         fromLat,
+			FromLon:         fromLon,
+			ToLat:           toLat,
+			ToLon:           toLon,
+			StraightMeters:  roundTenth(straight.Meters),
+			StraightMinutes: straight.Minutes,
+			Provider:        provider.Name(),
+		}
+		if provider.Name() != routing.PROVIDER_STRAIGHT_LINE {
+			if old, ok := previous[next.ID]; ok && sameTravelLeg(old, seg) && old.RoutedMeters != nil {
+				seg.RoutedMeters, seg.RoutedMinutes = old.RoutedMeters, old.RoutedMinutes
+			} else if route, err := provider.Route(ctx, start, end); err != nil {
+				seg.RoutingError = truncate(err.Error(), 255)
+			} else {
+				meters := roundTenth(route.Meters)
+				seg.RoutedMeters, seg.RoutedMinutes = &meters, &route.Minutes
+			}
+		}
+		seg.Meters, seg.Minutes = seg.StraightMeters, seg.StraightMinutes
+		if seg.RoutedMeters != nil {
+			seg.Meters, seg.Minutes = *seg.RoutedMeters, *seg.RoutedMinutes
+		}
+		segments = append(segments, seg)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND date = ?", userID, day.Format("2006-01-02")).Delete(&models.TravelSegment{}).Error; err != nil {
+			return err
+		}
+		// A visit moved to another day may still be the arrival of a segment stored there
+		ids := make([]uint, 0, len(segments))
+		for _, seg := range segments {
+			ids = append(ids, seg.ToScheduleID)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Where("to_schedule_id IN ?", ids).Delete(&models.TravelSegment{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&segments).Error
+	})
+	return segments, err
+}
+
+// RebuildTravelForVisit rebuilds the travel of the days a visit started on before and after a
+// change, so moving a visit to another day fixes both
+func RebuildTravelForVisit(ctx context.Context, db *gorm.DB, before *models.Schedule, loc *time.Location, provider routing.Provider, speedKmh float64) error {
+	after, err := GetScheduleByID(db, before.ID)
+	if err != nil {
+		return err
+	}
+	var days []time.Time
+	for _, s := range []*models.Schedule{before, after} {
+		if s.StartTime != nil {
+			day := calendarDay(*s.StartTime, loc)
+			if len(days) == 0 || !days[0].Equal(day) {
+				days = append(days, day)
+			}
+		}
+	}
+	for _, day := range days {
+		if _, err := RebuildTravelSegments(ctx, db, after.UserID, day, loc, provider, speedKmh); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebuildTravelRange rebuilds every day in [from, to] (calendar days) for the given caregivers
+// and returns how many segments were stored
+func RebuildTravelRange(ctx context.Context, db *gorm.DB, userIDs []uint, from, to time.Time, loc *time.Location, provider routing.Provider, speedKmh float64) (int, error) {
+	count := 0
+	for _, userID := range userIDs {
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			segments, err := RebuildTravelSegments(ctx, db, userID, day, loc, provider, speedKmh)
+			if err != nil {
+				return count, err
+			}
+			count += len(segments)
+		}
+	}
+	return count, nil
+}
+
+// ListTravelSegments returns stored segments by caregiver and time
+func ListTravelSegments(db *gorm.DB, filter models.TravelFilter) ([]models.TravelSegment, error) {
+	query := db.Order("user_id ASC, arrive_at ASC")
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.From != nil {
+		query = query.Where("date >= ?", filter.From.Format("2006-01-02"))
+	}
+	if filter.To != nil {
+		query = query.Where("date <= ?", filter.To.Format("2006-01-02"))
+	}
+	var segments []models.TravelSegment
+	err := query.Find(&segments).Error
+	return segments, err
+}
+
+// SummarizeTravel totals segments per caregiver, paying mileageRate per mile
+func SummarizeTravel(segments []models.TravelSegment, mileageRate float64) []models.TravelSummary {
+	byUser := map[uint]*models.TravelSummary{}
+	for _, seg := range segments {
+		summary, ok := byUser[seg.UserID]
+		if !ok {
+			summary = &models.TravelSummary{UserID: seg.UserID}
+			byUser[seg.UserID] = summary
+		}
+		summary.Segments++
+		summary.Meters += seg.Meters
+		summary.Minutes += seg.Minutes
+	}
+	summaries := make([]models.TravelSummary, 0, len(byUser))
+	for _, summary := range byUser {
+		summary.Meters = roundTenth(summary.Meters)
+		summary.Miles = math.Round(summary.Meters/metersPerMile*10) / 10
+		summary.Reimbursement = roundCents(summary.Miles * mileageRate)
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].UserID < summaries[j].UserID })
+	return summaries
+}
+
+// StoredTravel uses the recorded segment between two visits, falling back to estimate for legs
+// without one, such as planned visits or a day not rebuilt yet
+func StoredTravel(segments []models.TravelSegment, fallback TravelEstimator) TravelEstimator {
+	byLeg := make(map[[2]uint]models.TravelSegment, len(segments))
+	for _, seg := range segments {
+		byLeg[[2]uint{seg.FromScheduleID, seg.ToScheduleID}] = seg
+	}
+	return func(prev, next *models.Schedule) TravelEstimate {
+		if seg, ok := byLeg[[2]uint{prev.ID, next.ID}]; ok {
+			return TravelEstimate{Meters: seg.Meters, Minutes: seg.Minutes}
+		}
+		return fallback(prev, next)
+	}
+}
+
+// travelSegmentsArriving loads a caregiver's segments arriving in [start, end)
+func travelSegmentsArriving(db *gorm.DB, userID uint, start, end time.Time) ([]models.TravelSegment, error) {
+	var segments []models.TravelSegment
+	err := db.Where("user_id = ? AND arrive_at >= ? AND arrive_at < ?", userID, start.UTC(), end.UTC()).Find(&segments).Error
+	return segments, err
+}
+
+func sameTravelLeg(a, b models.TravelSegment) bool {
+	return a.FromScheduleID == b.FromScheduleID && a.Provider == b.Provider &&
+		sameCoordinate(a.FromLat, b.FromLat) && sameCoordinate(a.FromLon, b.FromLon) &&
+		sameCoordinate(a.ToLat, b.ToLat) && sameCoordinate(a.ToLon, b.ToLon)
+}
+
+// sameCoordinate compares at the precision the decimal columns keep
+func sameCoordinate(a, b float64) bool {
+	return math.Abs(a-b) < 1e-7
+}
+
+func roundTenth(v float64) float64 {
+	return math.Round(v*10) / 10
+}
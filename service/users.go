@@ -36,3 +36,10 @@ func LoginUser(db *gorm.DB, email, password string) (*models.User, error) {
 
 	return &user, nil
 }
+
+// GetUserByID retrieves a single user
+func GetUserByID(db *gorm.DB, userID uint) (*models.User, error) {
+	var user models.User
+	err := db.First(&user, "id = ?", userID).Error
+	return &user, err
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// s3Stub is a minimal in-memory S3 that only serves requests carrying a valid SigV4 signature
+type s3Stub struct {
+	accessKey, secretKey, region string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func newS3Stub(t *testing.T) (*s3Stub, *httptest.Server) {
+	stub := &s3Stub{accessKey: "AKIDTEST", secretKey: "secret/key+1", region: "eu-west-1",
+		objects: map[string][]byte{}, types: map[string]string{}}
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	return stub, srv
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if reason := s.verify(r); reason != "" {
+		http.Error(w, reason, http.StatusForbidden)
+		return
+	}
+
+	// Objects are keyed by the escaped path so the test sees exactly what went over the wire
+	path := r.URL.EscapedPath()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		s.objects[path] = body
+		s.types[path] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		body, ok := s.objects[path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(s.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// verify recomputes the SigV4 signature from the request as received, returning why it is invalid
+func (s *s3Stub) verify(r *http.Request) string {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ")
+	params := map[string]string{}
+	for _, part := range strings.Split(auth, ", ") {
+		if name, value, ok := strings.Cut(part, "="); ok {
+			params[name] = value
+		}
+	}
+	credential := strings.Split(params["Credential"], "/")
+	if len(credential) != 5 || credential[0] != s.accessKey || credential[2] != s.region ||
+		credential[3] != "s3" || credential[4] != "aws4_request" {
+		return "bad credential scope"
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, credential[1]) {
+		return "credential date does not match X-Amz-Date"
+	}
+
+	signed := strings.Split(params["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signed) {
+		return "signed headers are not sorted"
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range signed {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		params["SignedHeaders"],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	digest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + strings.Join(credential[1:], "/") + "\n" + hex.EncodeToString(digest[:])
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{credential[1], s.region, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	if !hmac.Equal([]byte(hex.EncodeToString(key)), []byte(params["Signature"])) {
+		return "signature mismatch"
+	}
+	return ""
+}
+
+func TestS3StoragePutGetDelete(t *testing.T) {
+	stub, srv := newS3Stub(t)
+	store, err := NewS3Storage(S3Config{Endpoint: srv.URL, Region: stub.region, Bucket: "visits",
+		AccessKey: stub.accessKey, SecretKey: stub.secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	// Spaces, plus signs and non-ASCII all need SigV4 URI encoding
+	key := "schedules/7/wound photo+1 café.png"
+	content := []byte("not really a png")
+
+	if err := store.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "image/png"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	path := "/visits/schedules/7/wound%20photo%2B1%20caf%C3%A9.png"
+	if got := stub.types[path]; got != "image/png" {
+		t.Fatalf("stored content type %q at %s, objects: %v", got, path, stub.objects)
+	}
+
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if !bytes.Equal(got, content) {
+		t.Fatalf("get returned %q, want %q", got, content)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get after delete: %v, want ErrNotFound", err)
+	}
+	// Deleting a missing object is not an error
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("second delete: %v", err)
+	}
+}
+
+func TestS3StorageRejectedSignature(t *testing.T) {
+	stub, srv := newS3Stub(t)
+	store, err := NewS3Storage(S3Config{Endpoint: srv.URL, Region: stub.region, Bucket: "visits",
+		AccessKey: stub.accessKey, SecretKey: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put(context.Background(), "a.txt", strings.NewReader("x"), 1, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("put with the wrong secret: %v, want a 403 error", err)
+	}
+	if len(stub.objects) != 0 {
+		t.Fatalf("stub stored %d objects for an unsigned request", len(stub.objects))
+	}
+}
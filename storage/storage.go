@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"caregiver-shift-tracker/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	DRIVER_LOCAL = "local"
+	DRIVER_S3    = "s3"
+)
+
+// ErrNotFound is returned by Get when no object exists for the key
+var ErrNotFound = errors.New("object not found")
+
+// Storage keeps uploaded files outside the database
+type Storage interface {
+	// Driver names the backend so stored records can say where their bytes live
+	Driver() string
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New builds the storage backend selected by STORAGE_DRIVER (local by default)
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case "", DRIVER_LOCAL:
+		dir := cfg.StorageLocalDir
+		if dir == "" {
+			dir = "uploads"
+		}
+		return NewLocalStorage(dir)
+	case DRIVER_S3:
+		return NewS3Storage(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
@@ -1,12 +1,45 @@
 package utils
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"mime"
 	"net/smtp"
+	"strings"
 )
 
-// SendEmail sends an email using the configured SMTP server
+// EmailAttachment is a file attached to an outgoing email
+type EmailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// EmailMessage is an outgoing email. When both Text and HTML are set the message carries
+// them as alternatives so clients pick the richest one they can show.
+type EmailMessage struct {
+	To          []string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []EmailAttachment
+}
+
+// SendEmail sends a plain text email using the configured SMTP server
 func SendEmail(email, subject, body string) error {
+	return SendEmailMessage(EmailMessage{To: []string{email}, Subject: subject, Text: body})
+}
+
+// SendEmailMessage sends a message with optional HTML body and attachments using the
+// configured SMTP server
+func SendEmailMessage(msg EmailMessage) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("failed to send email: no recipients")
+	}
+
 	smtpServer := cfg.SMTPServer
 	smtpPort := cfg.SMTPPort
 	smtpUsername := cfg.SenderEmail
@@ -16,20 +49,111 @@ func SendEmail(email, subject, body string) error {
 		smtpPort = "587"
 	}
 
-	// Email headers
-	message := fmt.Sprintf(
-		"From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
-		smtpUsername, email, subject, body,
-	)
+	message, err := BuildEmailMessage(smtpUsername, msg)
+	if err != nil {
+		return fmt.Errorf("failed to build email: %w", err)
+	}
 
 	// Authentication for SMTP server
 	auth := smtp.PlainAuth("", smtpUsername, smtpPassword, smtpServer)
 
 	// Send the email
-	err := smtp.SendMail(smtpServer+":"+smtpPort, auth, smtpUsername, []string{email}, []byte(message))
+	err = smtp.SendMail(smtpServer+":"+smtpPort, auth, smtpUsername, msg.To, message)
 	if err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
 	return nil
 }
+
+// BuildEmailMessage renders msg as an RFC 5322 message. A plain text message without
+// attachments stays a single part; anything richer becomes MIME multipart.
+func BuildEmailMessage(from string, msg EmailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" && len(msg.Attachments) == 0 {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		buf.WriteString(msg.Text)
+		return buf.Bytes(), nil
+	}
+
+	if len(msg.Attachments) == 0 {
+		if err := writeBody(&buf, msg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	boundary, err := mimeBoundary()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	if err := writeBody(&buf, msg); err != nil {
+		return nil, err
+	}
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		fmt.Fprintf(&buf, "\r\n--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s\r\n", mime.FormatMediaType(contentType, map[string]string{"name": attachment.Filename}))
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+		fmt.Fprintf(&buf, "Content-Disposition: %s\r\n\r\n", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+		writeBase64(&buf, attachment.Data)
+	}
+	fmt.Fprintf(&buf, "\r\n--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+// writeBody writes the part headers and content for the message text. Text and HTML
+// together become multipart/alternative with the plain text first.
+func writeBody(buf *bytes.Buffer, msg EmailMessage) error {
+	if msg.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		buf.WriteString(msg.Text)
+		return nil
+	}
+	if msg.Text == "" {
+		buf.WriteString("Content-Type: text/html; charset=UTF-8\r\n\r\n")
+		buf.WriteString(msg.HTML)
+		return nil
+	}
+
+	boundary, err := mimeBoundary()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(buf, "--%s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n", boundary, msg.Text)
+	fmt.Fprintf(buf, "--%s\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n", boundary, msg.HTML)
+	fmt.Fprintf(buf, "--%s--\r\n", boundary)
+	return nil
+}
+
+// writeBase64 writes data base64 encoded in 76 character lines
+func writeBase64(buf *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+}
+
+func mimeBoundary() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "boundary-" + hex.EncodeToString(b), nil
+}
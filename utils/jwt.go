@@ -3,6 +3,7 @@ package utils
 import (
 	"caregiver-shift-tracker/config"
 	"caregiver-shift-tracker/logger"
+	"caregiver-shift-tracker/models"
 	"errors"
 	"net/http"
 	"strings"
@@ -121,6 +122,16 @@ func ExtractJWT(tokenString string, isRefresh bool) (int, int, error) {
 
 // AdminOnly ensures the request has a valid JWT and admin access (RoleID = 1)
 func AdminOnly() gin.HandlerFunc {
+	return requireRole("Access denied: Admin role required", models.ROLE_ADMIN)
+}
+
+// StaffOnly ensures the request has a valid JWT and admin or customer care access (RoleID = 1 or 2)
+func StaffOnly() gin.HandlerFunc {
+	return requireRole("Access denied: Staff role required", models.ROLE_ADMIN, models.ROLE_CUSTOMER_CARE)
+}
+
+// requireRole rejects requests whose JWT role is not one of the allowed roles
+func requireRole(deniedMessage string, allowedRoles ...int) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authHeader := ctx.GetHeader("Authorization")
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
@@ -137,8 +148,15 @@ func AdminOnly() gin.HandlerFunc {
 			return
 		}
 
-		if roleID != 1 {
-			logger.RespondRaw(ctx, http.StatusForbidden, gin.H{"error": "Access denied: Admin role required"})
+		allowed := false
+		for _, role := range allowedRoles {
+			if roleID == role {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			logger.RespondRaw(ctx, http.StatusForbidden, gin.H{"error": deniedMessage})
 			ctx.Abort()
 			return
 		}